@@ -0,0 +1,21 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+var adviceFlags = map[string]int{
+	"sequential": unix.FADV_SEQUENTIAL,
+	"random":     unix.FADV_RANDOM,
+	"willneed":   unix.FADV_WILLNEED,
+}
+
+// adviseFile passes the --fadvise hint to the kernel. The hint only tunes
+// readahead, so failing to set it is not an error.
+func adviseFile(f *os.File) {
+	if fileAdvice != "" {
+		unix.Fadvise(int(f.Fd()), 0, 0, adviceFlags[fileAdvice])
+	}
+}
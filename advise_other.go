@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+var adviceFlags = map[string]int{"sequential": 0, "random": 0, "willneed": 0}
+
+// adviseFile does nothing; readahead hints are only given on Linux.
+func adviseFile(f *os.File) {}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Plot size in characters for --age-plot.
+const (
+	agePlotWidth  = 60
+	agePlotHeight = 15
+)
+
+// agePoint is one file's modification time and duration.
+type agePoint struct {
+	path     string
+	modTime  time.Time
+	duration float64
+}
+
+// agePoints pairs each measured file with its modification time, oldest
+// first. Files without either are left out.
+func agePoints(root string, audioFiles []string, durations []float64, results []result) []agePoint {
+	var points []agePoint
+	for i, p := range audioFiles {
+		if durations[i] <= 0 || results[i].modTime.IsZero() {
+			continue
+		}
+		points = append(points, agePoint{path: filepath.ToSlash(relPath(root, p)), modTime: results[i].modTime, duration: durations[i]})
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].modTime.Before(points[j].modTime) })
+	return points
+}
+
+// ageScatterJSON reports whether --age-scatter writes JSON rather than CSV.
+func ageScatterJSON(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+// writeAgeScatter writes the pairs as JSON when path ends in .json, and as
+// CSV otherwise.
+func writeAgeScatter(path string, points []agePoint) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if ageScatterJSON(path) {
+		type jsonPoint struct {
+			Path     string    `json:"path"`
+			ModTime  time.Time `json:"mtime"`
+			Duration float64   `json:"duration_seconds"`
+		}
+		out := make([]jsonPoint, len(points))
+		for i, p := range points {
+			out[i] = jsonPoint{p.path, p.modTime, p.duration}
+		}
+		enc := json.NewEncoder(w)
+		if err := enc.Encode(out); err != nil {
+			file.Close()
+			return err
+		}
+	} else {
+		cw, err := newCSVWriter(w)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		cw.Write([]string{"path", "mtime", "duration_seconds"})
+		for _, p := range points {
+			cw.Write([]string{p.path, p.modTime.UTC().Format(time.RFC3339), strconv.FormatFloat(p.duration, 'f', 3, 64)})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ageTrend is the least-squares slope of duration over modification time,
+// in seconds per year, and the correlation coefficient.
+func ageTrend(points []agePoint) (perYear, r float64, ok bool) {
+	if len(points) < 3 {
+		return 0, 0, false
+	}
+	const year = 365.25 * 24 * 3600
+	t0 := points[0].modTime
+	var sx, sy float64
+	for _, p := range points {
+		sx += p.modTime.Sub(t0).Seconds() / year
+		sy += p.duration
+	}
+	n := float64(len(points))
+	mx, my := sx/n, sy/n
+	var sxx, syy, sxy float64
+	for _, p := range points {
+		dx := p.modTime.Sub(t0).Seconds()/year - mx
+		dy := p.duration - my
+		sxx += dx * dx
+		syy += dy * dy
+		sxy += dx * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0, 0, false
+	}
+	return sxy / sxx, sxy / math.Sqrt(sxx*syy), true
+}
+
+// ageMark is the plot character for a cell holding n files.
+func ageMark(n int) byte {
+	switch {
+	case n == 0:
+		return ' '
+	case n == 1:
+		return '.'
+	case n <= 3:
+		return ':'
+	case n <= 9:
+		return '*'
+	}
+	return '#'
+}
+
+// printAgePlot draws the pairs as a character scatter plot, time across
+// and duration up, with a denser mark where more files fall. Durations
+// above the 99th percentile are clipped to the top row so one long file
+// does not flatten the rest.
+func printAgePlot(points []agePoint) {
+	fmt.Fprintln(console, "\n=== File age vs duration ===")
+	if len(points) == 0 {
+		fmt.Fprintln(console, "No files with both a duration and a modification time.")
+		return
+	}
+	sorted := make([]float64, len(points))
+	for i, p := range points {
+		sorted[i] = p.duration
+	}
+	sort.Float64s(sorted)
+	top := quantile(sorted, 0.99)
+	if top <= 0 {
+		top = sorted[len(sorted)-1]
+	}
+	oldest, newest := points[0].modTime, points[len(points)-1].modTime
+	span := newest.Sub(oldest).Seconds()
+
+	var grid [agePlotHeight][agePlotWidth]int
+	for _, p := range points {
+		x := 0
+		if span > 0 {
+			x = int(p.modTime.Sub(oldest).Seconds() / span * (agePlotWidth - 1))
+		}
+		y := int(math.Min(p.duration/top, 1) * (agePlotHeight - 1))
+		grid[agePlotHeight-1-y][x]++
+	}
+	for row := range grid {
+		label := ""
+		switch row {
+		case 0:
+			label = formatClock(top)
+		case agePlotHeight - 1:
+			label = formatClock(0)
+		}
+		var line strings.Builder
+		for _, n := range grid[row] {
+			line.WriteByte(ageMark(n))
+		}
+		fmt.Fprintf(console, "%9s |%s\n", label, strings.TrimRight(line.String(), " "))
+	}
+	fmt.Fprintf(console, "%9s +%s\n", "", strings.Repeat("-", agePlotWidth))
+	first, last := oldest.Format("2006-01-02"), newest.Format("2006-01-02")
+	fmt.Fprintf(console, "%9s  %s%*s\n", "", first, agePlotWidth-len(first), last)
+	if perYear, r, ok := ageTrend(points); ok {
+		fmt.Fprintf(console, "Trend: %+.1f seconds per year of modification time (r = %.2f, %d files)\n", perYear, r, len(points))
+	}
+}
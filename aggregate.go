@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Aggregation policies for --stats-over: which files the per-file mean and
+// median are taken over. Totals always sum the valid files only.
+const (
+	statsOverValid     = "valid"     // probed, with a positive duration
+	statsOverProcessed = "processed" // probed, zero durations included
+	statsOverAll       = "all"       // every file, failures counting as zero
+)
+
+// aggregate classes every result as failed, zero-duration or valid, so a
+// zero-length success is never confused with an error in the totals.
+type aggregate struct {
+	failed       int
+	zero         int
+	valid        int
+	totalSeconds float64
+	meanSeconds  float64
+	medianSecs   float64
+	policy       string
+}
+
+func checkStatsPolicy(policy string) error {
+	switch policy {
+	case statsOverValid, statsOverProcessed, statsOverAll:
+		return nil
+	}
+	return fmt.Errorf("unsupported --stats-over value: %s (want valid, processed or all)", policy)
+}
+
+func aggregateResults(results []result, policy string) aggregate {
+	agg := aggregate{policy: policy}
+	var over []float64
+	for _, res := range results {
+		switch {
+		case res.err != nil:
+			agg.failed++
+			if policy == statsOverAll {
+				over = append(over, 0)
+			}
+		case res.duration <= 0:
+			agg.zero++
+			if policy != statsOverValid {
+				over = append(over, 0)
+			}
+		default:
+			agg.valid++
+			agg.totalSeconds += res.duration
+			over = append(over, res.duration)
+		}
+	}
+	if len(over) > 0 {
+		agg.meanSeconds = agg.totalSeconds / float64(len(over))
+		sort.Float64s(over)
+		agg.medianSecs = quantile(over, 0.5)
+	}
+	return agg
+}
@@ -0,0 +1,72 @@
+package main
+
+// sampleStats carries the results of the optional full-decode analyses.
+type sampleStats struct {
+	analyzed       bool
+	err            error
+	speechSeconds  float64
+	silentFraction float64
+	digitalZero    bool
+	loudness       float64 // integrated LUFS, -Inf below the gate
+	clippedSamples int64
+	totalSamples   int64
+	decodedSeconds float64
+}
+
+// wantsSamples reports whether any enabled option needs decoded samples.
+func (o options) wantsSamples() bool {
+	return o.deep || o.speechHours || o.detectSilence
+}
+
+// analyzeSamples runs every enabled analyzer over a single decode pass.
+func analyzeSamples(filePath string, opts options) sampleStats {
+	var stats sampleStats
+	if !canDecodePCM(filePath) {
+		return stats
+	}
+
+	var analyzers []sampleAnalyzer
+	var vad *speechDetector
+	if opts.speechHours {
+		vad = &speechDetector{}
+		analyzers = append(analyzers, vad)
+	}
+	var silence *silenceDetector
+	if opts.detectSilence {
+		silence = newSilenceDetector(opts.silenceLevel)
+		analyzers = append(analyzers, silence)
+	}
+	var meter *loudnessMeter
+	var clips *clipCounter
+	var frames *frameCounter
+	if opts.deep {
+		meter = &loudnessMeter{}
+		clips = &clipCounter{}
+		frames = &frameCounter{}
+		analyzers = append(analyzers, meter, clips, frames)
+	}
+
+	if err := decodePCM(filePath, analyzers); err != nil {
+		stats.err = err
+		return stats
+	}
+	stats.analyzed = true
+	if vad != nil {
+		stats.speechSeconds = vad.speechSeconds()
+	}
+	if silence != nil {
+		stats.silentFraction = silence.silentFraction()
+		stats.digitalZero = silence.peak == 0
+	}
+	if meter != nil {
+		stats.loudness = meter.integrated()
+	}
+	if clips != nil {
+		stats.clippedSamples = clips.clipped
+		stats.totalSamples = clips.total
+	}
+	if frames != nil {
+		stats.decodedSeconds = frames.seconds()
+	}
+	return stats
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Reasons recorded in --audit-log for files that were not counted.
+const (
+	auditExtension  = "extension"           // not an audio extension
+	auditSidecar    = "sidecar"             // transcript or subtitle read for a report
+	auditUnreadable = "unreadable"          // the walk could not stat or list it
+	auditSymlinkDir = "symlinked-directory" // directories behind symlinks are not followed
+	auditStub       = "placeholder"         // LFS/annex/DVC pointer or empty file without content
+	auditShard      = "other-shard"         // assigned to another --shard
+	auditNotSampled = "not-sampled"         // left out of the --estimate sample
+	auditProbeError = "probe-error"         // probing failed
+)
+
+// auditEvent is one line of the --audit-log JSONL file: what happened to
+// one path and why.
+type auditEvent struct {
+	Time     time.Time `json:"time"`
+	Path     string    `json:"path"`
+	Decision string    `json:"decision"` // counted, skipped or failed
+	Reason   string    `json:"reason,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+	Duration *float64  `json:"duration,omitempty"`
+}
+
+// auditLog records every counting decision of a scan, so a dataset team
+// can show afterwards exactly which files made up the hours. A nil
+// *auditLog records nothing.
+type auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+	root string
+	err  error
+}
+
+func openAuditLog(path, root string) (*auditLog, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(file)
+	return &auditLog{file: file, w: w, enc: json.NewEncoder(w), root: root}, nil
+}
+
+func (l *auditLog) record(e auditEvent) {
+	if l == nil {
+		return
+	}
+	e.Time = time.Now().UTC()
+	e.Path = filepath.ToSlash(relPath(l.root, e.Path))
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err == nil {
+		l.err = l.enc.Encode(e)
+	}
+}
+
+func (l *auditLog) skipped(path, reason, detail string) {
+	l.record(auditEvent{Path: path, Decision: "skipped", Reason: reason, Detail: detail})
+}
+
+// skippedExcept records every path of before missing from after.
+func (l *auditLog) skippedExcept(before, after []string, reason string) {
+	if l == nil {
+		return
+	}
+	kept := make(map[string]bool, len(after))
+	for _, path := range after {
+		kept[path] = true
+	}
+	for _, path := range before {
+		if !kept[path] {
+			l.skipped(path, reason, "")
+		}
+	}
+}
+
+// results records the outcome of every probed file.
+func (l *auditLog) results(audioFiles []string, results []result) {
+	if l == nil {
+		return
+	}
+	for i, res := range results {
+		if res.err != nil {
+			l.record(auditEvent{Path: audioFiles[i], Decision: "failed", Reason: auditProbeError, Detail: res.err.Error()})
+			continue
+		}
+		seconds := res.duration
+		l.record(auditEvent{Path: audioFiles[i], Decision: "counted", Duration: &seconds})
+	}
+}
+
+// Close flushes the log and returns the first write error.
+func (l *auditLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	if l.err == nil {
+		l.err = l.w.Flush()
+	}
+	if err := l.file.Close(); l.err == nil {
+		l.err = err
+	}
+	return l.err
+}
@@ -0,0 +1,22 @@
+package main
+
+import "fmt"
+
+// printBooksReport treats every top-level folder as one audiobook or series
+// and shows how long each takes to finish at the given playback speed.
+func printBooksReport(root string, audioFiles []string, durations []float64, speed float64) {
+	books := groupDurations(audioFiles, durations, func(path string) (string, bool) {
+		return topLevelDir(root, path)
+	})
+	sortGroupsBySeconds(books)
+
+	fmt.Fprintln(console, "\n=== Books ===")
+	if len(books) == 0 {
+		fmt.Fprintln(console, "No subfolders found.")
+		return
+	}
+	fmt.Fprintf(console, "%-40s %6s %10s %14s\n", "Title", "Files", "Duration", fmt.Sprintf("At %g×", speed))
+	for _, b := range books {
+		fmt.Fprintf(console, "%-40s %6d %10s %14s\n", b.key, b.files, formatClock(b.seconds), formatClock(b.seconds/speed))
+	}
+}
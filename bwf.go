@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// bwfInfo is what a field recorder writes into a Broadcast Wave file's
+// bext and iXML chunks.
+type bwfInfo struct {
+	found      bool
+	date       string // bext OriginationDate, yyyy-mm-dd
+	time       string // bext OriginationTime, hh:mm:ss
+	originator string
+	project    string
+	scene      string
+	take       string
+	tape       string
+}
+
+// bextHeaderLen covers the fixed bext fields up to and including
+// OriginationTime.
+const bextHeaderLen = 256 + 32 + 32 + 10 + 8
+
+// ixmlDoc picks the production fields out of an iXML chunk.
+type ixmlDoc struct {
+	Project string `xml:"PROJECT"`
+	Scene   string `xml:"SCENE"`
+	Take    string `xml:"TAKE"`
+	Tape    string `xml:"TAPE"`
+}
+
+// readBWF extracts bext and iXML metadata from a WAV file. Files without
+// either chunk return found false.
+func readBWF(filePath string) bwfInfo {
+	var info bwfInfo
+	if strings.ToLower(filepath.Ext(filePath)) != ".wav" {
+		return info
+	}
+	file, err := openAudio(filePath)
+	if err != nil {
+		return info
+	}
+	defer file.Close()
+	chunks, err := scanRIFFChunks(file, false)
+	if err != nil {
+		return info
+	}
+	for _, c := range chunks {
+		switch c.id {
+		case "bext":
+			if c.size < bextHeaderLen {
+				continue
+			}
+			p := make([]byte, bextHeaderLen)
+			if _, err := file.ReadAt(p, c.offset); err != nil {
+				continue
+			}
+			info.found = true
+			info.originator = cString(p[256:288])
+			info.date = normalizeBextDate(cString(p[320:330]))
+			info.time = cString(p[330:338])
+		case "iXML":
+			p := make([]byte, c.size)
+			if _, err := file.ReadAt(p, c.offset); err != nil {
+				continue
+			}
+			var doc ixmlDoc
+			if err := xml.Unmarshal(bytes.TrimRight(p, "\x00"), &doc); err != nil {
+				continue
+			}
+			info.found = true
+			info.project = strings.TrimSpace(doc.Project)
+			info.scene = strings.TrimSpace(doc.Scene)
+			info.take = strings.TrimSpace(doc.Take)
+			info.tape = strings.TrimSpace(doc.Tape)
+		}
+	}
+	return info
+}
+
+// cString trims a fixed-width, NUL-padded ASCII field.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// normalizeBextDate accepts the separators recorders use in place of the
+// spec's hyphens (2024:05:01, 2024/05/01).
+func normalizeBextDate(s string) string {
+	if len(s) != 10 {
+		return s
+	}
+	return strings.NewReplacer(":", "-", "/", "-", ".", "-", " ", "-").Replace(s)
+}
+
+// metadata exposes the fields to --json-files alongside plugin metadata.
+func (b bwfInfo) metadata() map[string]any {
+	m := make(map[string]any)
+	for k, v := range map[string]string{
+		"bwf_date": b.date, "bwf_time": b.time, "originator": b.originator,
+		"project": b.project, "scene": b.scene, "take": b.take, "tape": b.tape,
+	} {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// shootDay is the hours recorded on one origination date.
+type shootDay struct {
+	date    string
+	files   int
+	seconds float64
+	scenes  map[string]bool
+	takes   map[string]bool
+}
+
+func printBWFReport(audioFiles []string, durations []float64, infos []bwfInfo) {
+	days := make(map[string]*shootDay)
+	var withMeta, undated int
+	for i, info := range infos {
+		if !info.found {
+			continue
+		}
+		withMeta++
+		if info.date == "" {
+			undated++
+			continue
+		}
+		d, ok := days[info.date]
+		if !ok {
+			d = &shootDay{date: info.date, scenes: make(map[string]bool), takes: make(map[string]bool)}
+			days[info.date] = d
+		}
+		d.files++
+		d.seconds += durations[i]
+		if info.scene != "" {
+			d.scenes[info.scene] = true
+			if info.take != "" {
+				d.takes[info.scene+"/"+info.take] = true
+			}
+		}
+	}
+	list := make([]*shootDay, 0, len(days))
+	for _, d := range days {
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].date < list[j].date })
+
+	fmt.Fprintln(console, "\n=== Shoot days (BWF) ===")
+	fmt.Fprintf(console, "Files with bext/iXML metadata: %d of %d\n", withMeta, len(audioFiles))
+	if undated > 0 {
+		fmt.Fprintf(console, "Without an origination date: %d\n", undated)
+	}
+	if len(list) == 0 {
+		return
+	}
+	fmt.Fprintf(console, "\n%-12s %8s %10s %8s %8s\n", "Date", "Files", "Hours", "Scenes", "Takes")
+	for _, d := range list {
+		fmt.Fprintf(console, "%-12s %8d %10.2f %8d %8d\n", d.date, d.files, d.seconds/3600.0, len(d.scenes), len(d.takes))
+	}
+}
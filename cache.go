@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cacheEntry is one line of the --cache JSONL file: the probe result of a
+// file at one version.
+type cacheEntry struct {
+	Path     string         `json:"path"`
+	Size     int64          `json:"size"`
+	Version  string         `json:"version"`
+	Duration float64        `json:"duration"`
+	Prober   string         `json:"prober,omitempty"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// probeCache holds the probe results of an earlier scan, keyed by absolute
+// NFC path. It is only read while workers run.
+type probeCache struct {
+	entries map[string]cacheEntry
+}
+
+// fileVersion identifies the content of a file without reading it: the
+// object ETag when the mount exposes one (object stores keep it across
+// copies and clock skew), otherwise the modification time.
+func fileVersion(path string, modTime time.Time) string {
+	if etag := objectETag(contentPath(path)); etag != "" {
+		return "etag:" + etag
+	}
+	return "mtime:" + modTime.UTC().Format(time.RFC3339Nano)
+}
+
+// proberVersion names the probers besides the native one that may answer
+// for path, so a cache written under another --backend, --fallback or set
+// of plugins misses rather than returning another prober's duration.
+func proberVersion(path string, opts options) string {
+	var v string
+	if opts.backend != "native" {
+		v += ";backend=" + opts.backend
+	}
+	if plugin, ok := opts.plugins[strings.ToLower(filepath.Ext(path))]; ok {
+		v += ";plugin=" + plugin
+	}
+	if opts.fallback != "" {
+		v += ";fallback=" + opts.fallback
+	}
+	return v
+}
+
+func cacheKey(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	return nfc(abs)
+}
+
+// lookup returns the cached probe of path if its size and version match.
+func (c *probeCache) lookup(path string, size int64, version string) (cacheEntry, bool) {
+	if c == nil {
+		return cacheEntry{}, false
+	}
+	e, ok := c.entries[cacheKey(path)]
+	return e, ok && version != "" && e.Size == size && e.Version == version
+}
+
+// readProbeCache loads a --cache file. A missing file is an empty cache.
+func readProbeCache(path string) (*probeCache, error) {
+	c := &probeCache{entries: make(map[string]cacheEntry)}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e cacheEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		c.entries[e.Path] = e
+	}
+	return c, scanner.Err()
+}
+
+// writeProbeCache replaces the --cache file with this scan's successful
+// probes, plus the entries of files outside this scan so that caches
+// shared between roots keep working.
+func writeProbeCache(path string, old *probeCache, audioFiles []string, results []result) error {
+	entries := make(map[string]cacheEntry, len(old.entries))
+	for k, e := range old.entries {
+		entries[k] = e
+	}
+	for i, res := range results {
+		key := cacheKey(audioFiles[i])
+		delete(entries, key)
+		if res.err != nil || res.version == "" {
+			continue
+		}
+		entries[key] = cacheEntry{Path: key, Size: res.size, Version: res.version, Duration: res.duration, Prober: res.prober, Metadata: res.metadata}
+	}
+
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
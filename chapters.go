@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+// chapter is one chapter of an audiobook or podcast episode, in seconds
+// from the start of the file.
+type chapter struct {
+	title    string
+	start    float64
+	duration float64
+}
+
+// maxChapterTitle bounds the text sample read for one chapter title, and
+// maxChapters the chapters taken from one file.
+const (
+	maxChapterTitle = 1024
+	maxChapters     = 10000
+)
+
+// sampleOffsets lists the file offset and size of the first limit samples,
+// following the chunk layout the same way playableSeconds does.
+func (t *mp4Track) sampleOffsets(limit int) (offsets []uint64, sizes []uint32) {
+	sample := uint32(0)
+	runs := stscCursor{stsc: t.stsc}
+	for chunk := range t.chunkOffsets {
+		perChunk := runs.samplesInChunk(uint32(chunk + 1))
+		offset := t.chunkOffsets[chunk]
+		for i := uint32(0); i < perChunk && sample < t.sampleCount; i++ {
+			if len(offsets) == limit {
+				return offsets, sizes
+			}
+			size := t.sampleSize
+			if size == 0 {
+				size = t.sampleSizes[sample]
+			}
+			offsets = append(offsets, offset)
+			sizes = append(sizes, size)
+			offset += uint64(size)
+			sample++
+		}
+	}
+	return offsets, sizes
+}
+
+// mp4Chapters reads the QuickTime chapter track used by M4B and AAX
+// audiobooks: a text track whose samples are the chapter titles and whose
+// sample durations are the chapter lengths. The first text track is taken
+// as the chapter track, which is how iTunes and Audible files are laid
+// out.
+func mp4Chapters(file *audioFile, mp4 *mp4Info) []chapter {
+	var track *mp4Track
+	for _, t := range mp4.tracks {
+		if t.handler == "text" || t.handler == "sbtl" {
+			track = t
+			break
+		}
+	}
+	if track == nil || track.timescale == 0 || len(track.stts) == 0 {
+		return nil
+	}
+
+	offsets, sizes := track.sampleOffsets(maxChapters)
+	var chapters []chapter
+	var units uint64
+	sample := 0
+	for _, e := range track.stts {
+		for n := uint32(0); n < e.count; n++ {
+			c := chapter{
+				start:    float64(units) / float64(track.timescale),
+				duration: float64(e.delta) / float64(track.timescale),
+			}
+			if sample < len(offsets) {
+				c.title = readTextSample(file, offsets[sample], sizes[sample])
+			}
+			chapters = append(chapters, c)
+			units += uint64(e.delta)
+			sample++
+			if len(chapters) == maxChapters {
+				return chapters
+			}
+		}
+	}
+	return chapters
+}
+
+// readTextSample decodes a QuickTime text sample: a 16-bit length then the
+// text, UTF-8 or UTF-16 with a byte-order mark.
+func readTextSample(file *audioFile, offset uint64, size uint32) string {
+	if size < 2 {
+		return ""
+	}
+	p := make([]byte, min(size, maxChapterTitle))
+	if _, err := file.ReadAt(p, int64(offset)); err != nil {
+		return ""
+	}
+	n := int(binary.BigEndian.Uint16(p[0:2]))
+	text := p[2:]
+	if n < len(text) {
+		text = text[:n]
+	}
+	return decodeText(text)
+}
+
+// decodeText turns UTF-16 with a byte-order mark into a string, passing
+// anything else through as UTF-8.
+func decodeText(b []byte) string {
+	if len(b) < 2 {
+		return nfc(string(b))
+	}
+	var order binary.ByteOrder
+	switch {
+	case b[0] == 0xFE && b[1] == 0xFF:
+		order = binary.BigEndian
+	case b[0] == 0xFF && b[1] == 0xFE:
+		order = binary.LittleEndian
+	default:
+		return nfc(string(b))
+	}
+	units := make([]uint16, 0, len(b)/2)
+	for i := 2; i+1 < len(b); i += 2 {
+		units = append(units, order.Uint16(b[i:]))
+	}
+	return nfc(string(utf16.Decode(units)))
+}
+
+// parseNeroChapters reads a Nero chpl box payload: start times in 100ns
+// units with Pascal-string titles. Durations are filled in by
+// finishChapters.
+func parseNeroChapters(p []byte) []chapter {
+	if len(p) < 5 {
+		return nil
+	}
+	pos := 4
+	if p[0] == 1 {
+		pos += 4 // version 1 has a reserved word before the count
+	}
+	if pos >= len(p) {
+		return nil
+	}
+	count := int(p[pos])
+	pos++
+	var chapters []chapter
+	for i := 0; i < count && pos+9 <= len(p); i++ {
+		start := binary.BigEndian.Uint64(p[pos:])
+		n := int(p[pos+8])
+		pos += 9
+		if pos+n > len(p) {
+			break
+		}
+		chapters = append(chapters, chapter{title: decodeText(p[pos : pos+n]), start: float64(start) / 1e7})
+		pos += n
+	}
+	return chapters
+}
+
+// finishChapters fills in missing durations from the next chapter's start,
+// or the end of the file for the last one.
+func finishChapters(chapters []chapter, total float64) []chapter {
+	for i := range chapters {
+		if chapters[i].duration > 0 {
+			continue
+		}
+		end := total
+		if i+1 < len(chapters) {
+			end = chapters[i+1].start
+		}
+		chapters[i].duration = max(end-chapters[i].start, 0)
+	}
+	return chapters
+}
+
+// readChapters returns a file's chapters, or nil for files without any:
+// the QuickTime chapter track or Nero chpl box of MP4 files, and the ID3
+// CHAP frames of MP3s.
+func readChapters(filePath string, duration float64) []chapter {
+	file, err := openAudio(filePath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".m4a", ".m4b", ".aax":
+		mp4, err := parseMP4(file)
+		if err != nil {
+			return nil
+		}
+		if chapters := mp4Chapters(file, mp4); len(chapters) > 0 {
+			return chapters
+		}
+		return finishChapters(mp4.chpl, duration)
+	case ".mp3":
+		return finishChapters(id3Chapters(file), duration)
+	}
+	return nil
+}
+
+// printChapterListing lists every chapter of every chaptered file.
+func printChapterListing(root string, audioFiles []string, chapters [][]chapter) {
+	fmt.Fprintln(console, "\n=== Chapters ===")
+	for i, p := range audioFiles {
+		if len(chapters[i]) == 0 {
+			continue
+		}
+		fmt.Fprintf(console, "\n%s (%d chapters)\n", relPath(root, p), len(chapters[i]))
+		printList(len(chapters[i]), func(j int) string {
+			c := chapters[i][j]
+			return fmt.Sprintf("%3d. %s  %s  %s", j+1, formatClock(c.start), formatClock(c.duration), c.title)
+		})
+	}
+}
+
+// printAudiobookReport lists every chaptered file as a book with its
+// chapter count, length and mean chapter length.
+func printAudiobookReport(root string, audioFiles []string, durations []float64, chapters [][]chapter) {
+	fmt.Fprintln(console, "\n=== Audiobooks ===")
+	var books int
+	var seconds float64
+	var total int
+	for i := range audioFiles {
+		if len(chapters[i]) > 0 {
+			books++
+			seconds += durations[i]
+			total += len(chapters[i])
+		}
+	}
+	fmt.Fprintf(console, "Chaptered files: %d (%.2f hours, %d chapters)\n", books, seconds/3600.0, total)
+	if books == 0 {
+		return
+	}
+	fmt.Fprintf(console, "\n%-40s %8s %10s %12s\n", "Book", "Chapters", "Duration", "Mean chapter")
+	listed := 0
+	for i, p := range audioFiles {
+		if len(chapters[i]) == 0 {
+			continue
+		}
+		if listed == maxListed {
+			fmt.Fprintf(console, "... and %d more\n", books-maxListed)
+			break
+		}
+		listed++
+		fmt.Fprintf(console, "%-40s %8d %10s %12s\n", relPath(root, p), len(chapters[i]), formatClock(durations[i]), formatClock(durations[i]/float64(len(chapters[i]))))
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// checksumAlgorithms maps the --checksums names to hash constructors.
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+const manifestHeader = "# howManyHours manifest algo="
+
+// hashFile returns the hex digest of a file and the number of bytes hashed.
+func hashFile(filePath, algo string) (string, int64, error) {
+	newHash, ok := checksumAlgorithms[algo]
+	if !ok {
+		return "", 0, fmt.Errorf("unsupported checksum algorithm: %s", algo)
+	}
+	file, err := openAudioWhole(filePath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	h := newHash()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// manifestEntry is one line of a checksum manifest. Paths are relative to
+// the scanned root and use forward slashes so manifests travel between
+// machines.
+type manifestEntry struct {
+	path     string
+	size     int64
+	hash     string
+	duration float64
+}
+
+type manifest struct {
+	algo    string
+	entries []manifestEntry
+}
+
+func writeManifest(path string, m manifest) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	fmt.Fprintf(w, "%s%s\n", manifestHeader, m.algo)
+	fmt.Fprintln(w, "# path\tsize\thash\tduration_seconds")
+	for _, e := range m.entries {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%.3f\n", e.path, e.size, e.hash, e.duration)
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// readManifest reads a --checksums manifest, or the file list of a
+// --scan-manifest.
+func readManifest(path string) (manifest, error) {
+	var m manifest
+	file, err := os.Open(path)
+	if err != nil {
+		return m, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	if first, err := reader.Peek(1); err == nil && first[0] == '{' {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return m, err
+		}
+		if m, err = scanManifestEntries(data); err != nil {
+			return m, err
+		}
+		if _, ok := checksumAlgorithms[m.algo]; !ok {
+			return m, fmt.Errorf("scan manifest has no supported hash_algorithm")
+		}
+		return m, nil
+	}
+
+	scanner := bufio.NewScanner(reader)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.HasPrefix(line, manifestHeader) {
+			m.algo = strings.TrimPrefix(line, manifestHeader)
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			return m, fmt.Errorf("line %d: expected 4 tab-separated fields", n)
+		}
+		size, err1 := strconv.ParseInt(fields[1], 10, 64)
+		duration, err2 := strconv.ParseFloat(fields[3], 64)
+		if err1 != nil || err2 != nil {
+			return m, fmt.Errorf("line %d: invalid size or duration", n)
+		}
+		m.entries = append(m.entries, manifestEntry{path: nfc(fields[0]), size: size, hash: fields[2], duration: duration})
+	}
+	if err := scanner.Err(); err != nil {
+		return m, err
+	}
+	if _, ok := checksumAlgorithms[m.algo]; !ok {
+		return m, fmt.Errorf("manifest does not declare a supported algorithm (header %q)", manifestHeader+"<algo>")
+	}
+	return m, nil
+}
+
+// buildManifest turns scan results into manifest entries sorted by path.
+func buildManifest(root, algo string, audioFiles []string, durations []float64, results []result) manifest {
+	m := manifest{algo: algo}
+	for i, path := range audioFiles {
+		m.entries = append(m.entries, manifestEntry{
+			path:     manifestPath(root, path),
+			size:     results[i].size,
+			hash:     results[i].hash,
+			duration: durations[i],
+		})
+	}
+	sort.Slice(m.entries, func(i, j int) bool { return m.entries[i].path < m.entries[j].path })
+	return m
+}
+
+// manifestPath is the portable form of path used as a manifest key.
+func manifestPath(root, path string) string {
+	return nfc(filepath.ToSlash(relPath(root, path)))
+}
+
+type manifestMismatch struct {
+	path           string
+	expected, got  string
+	durationChange float64
+}
+
+type manifestReport struct {
+	listed          int
+	listedSeconds   float64
+	foundSeconds    float64
+	verified        int
+	hashMismatch    []manifestMismatch
+	sizeMismatch    []manifestMismatch
+	durationChanged []manifestMismatch
+	missing         []string
+	unlisted        []string
+	unreadable      []string
+	tolerance       float64
+}
+
+// verifyManifest compares a delivered manifest with the scan of the tree.
+func verifyManifest(expected, actual manifest, tolerance float64) manifestReport {
+	report := manifestReport{listed: len(expected.entries), tolerance: tolerance}
+
+	found := make(map[string]manifestEntry, len(actual.entries))
+	for _, e := range actual.entries {
+		found[e.path] = e
+	}
+
+	for _, want := range expected.entries {
+		report.listedSeconds += want.duration
+		got, ok := found[want.path]
+		if !ok {
+			report.missing = append(report.missing, want.path)
+			continue
+		}
+		delete(found, want.path)
+		report.foundSeconds += got.duration
+
+		switch {
+		case got.hash == "":
+			report.unreadable = append(report.unreadable, want.path)
+		case got.size != want.size:
+			report.sizeMismatch = append(report.sizeMismatch, manifestMismatch{
+				path: want.path, expected: strconv.FormatInt(want.size, 10), got: strconv.FormatInt(got.size, 10),
+			})
+		case got.hash != want.hash:
+			report.hashMismatch = append(report.hashMismatch, manifestMismatch{path: want.path, expected: want.hash, got: got.hash})
+		default:
+			report.verified++
+		}
+		if d := got.duration - want.duration; math.Abs(d) > tolerance {
+			report.durationChanged = append(report.durationChanged, manifestMismatch{path: want.path, durationChange: d})
+		}
+	}
+
+	for path, e := range found {
+		report.unlisted = append(report.unlisted, path)
+		report.foundSeconds += e.duration
+	}
+	sort.Strings(report.unlisted)
+	return report
+}
+
+func printManifestReport(report manifestReport) {
+	fmt.Fprintln(console, "\n=== Manifest Verification ===")
+	fmt.Fprintf(console, "Files in manifest: %d (%.2f hours)\n", report.listed, report.listedSeconds/3600.0)
+	fmt.Fprintf(console, "Hours found on disk: %.2f\n", report.foundSeconds/3600.0)
+	fmt.Fprintf(console, "Verified: %d\n", report.verified)
+	fmt.Fprintf(console, "Checksum mismatches: %d\n", len(report.hashMismatch))
+	fmt.Fprintf(console, "Size mismatches: %d\n", len(report.sizeMismatch))
+	fmt.Fprintf(console, "Duration changes (> %.1fs): %d\n", report.tolerance, len(report.durationChanged))
+	fmt.Fprintf(console, "Missing files: %d\n", len(report.missing))
+	fmt.Fprintf(console, "Files not in manifest: %d\n", len(report.unlisted))
+	if len(report.unreadable) > 0 {
+		fmt.Fprintf(console, "Unreadable files: %d\n", len(report.unreadable))
+	}
+
+	section := func(title string, n int, line func(i int) string) {
+		if n == 0 {
+			return
+		}
+		fmt.Fprintf(console, "\n%s:\n", title)
+		printList(n, line)
+	}
+	section("Checksum mismatches", len(report.hashMismatch), func(i int) string {
+		m := report.hashMismatch[i]
+		return fmt.Sprintf("%s (expected %s, got %s)", m.path, m.expected, m.got)
+	})
+	section("Size mismatches", len(report.sizeMismatch), func(i int) string {
+		m := report.sizeMismatch[i]
+		return fmt.Sprintf("%s (expected %s bytes, got %s)", m.path, m.expected, m.got)
+	})
+	section("Duration changes", len(report.durationChanged), func(i int) string {
+		m := report.durationChanged[i]
+		return fmt.Sprintf("%s (%+.1fs)", m.path, m.durationChange)
+	})
+	section("Missing files", len(report.missing), func(i int) string { return report.missing[i] })
+	section("Files not in manifest", len(report.unlisted), func(i int) string { return report.unlisted[i] })
+	section("Unreadable files", len(report.unreadable), func(i int) string { return report.unreadable[i] })
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// clipLevel is the normalised magnitude treated as full scale. It sits a
+// hair below 1.0 so the asymmetric int16 maximum (32767/32768) counts.
+const clipLevel = 0.999
+
+// clipCounter counts samples pinned at full scale.
+type clipCounter struct {
+	clipped int64
+	total   int64
+}
+
+func (c *clipCounter) start(format pcmFormat) {}
+
+func (c *clipCounter) process(samples []float64) {
+	for _, v := range samples {
+		if math.Abs(v) >= clipLevel {
+			c.clipped++
+		}
+	}
+	c.total += int64(len(samples))
+}
+
+type clippedFile struct {
+	path     string
+	duration float64
+	clipped  int64
+	percent  float64
+}
+
+type clippingReport struct {
+	threshold      float64
+	files          []clippedFile
+	clippedSeconds float64
+	cleanSeconds   float64
+}
+
+func buildClippingReport(audioFiles []string, durations []float64, stats []sampleStats, thresholdPercent float64) clippingReport {
+	report := clippingReport{threshold: thresholdPercent}
+	for i, s := range stats {
+		if durations[i] <= 0 {
+			continue
+		}
+		var p float64
+		if s.totalSamples > 0 {
+			p = float64(s.clippedSamples) / float64(s.totalSamples) * 100
+		}
+		if !s.analyzed || p <= thresholdPercent {
+			report.cleanSeconds += durations[i]
+			continue
+		}
+		report.files = append(report.files, clippedFile{path: audioFiles[i], duration: durations[i], clipped: s.clippedSamples, percent: p})
+		report.clippedSeconds += durations[i]
+	}
+	sort.Slice(report.files, func(i, j int) bool { return report.files[i].percent > report.files[j].percent })
+	return report
+}
+
+func printClippingReport(root string, report clippingReport) {
+	fmt.Fprintln(console, "\n=== Clipping ===")
+	fmt.Fprintf(console, "Files over %.2f%% clipped samples: %d (%.2f hours)\n", report.threshold, len(report.files), report.clippedSeconds/3600.0)
+	fmt.Fprintf(console, "Hours excluding clipped files: %.2f\n", report.cleanSeconds/3600.0)
+	printList(len(report.files), func(i int) string {
+		f := report.files[i]
+		return fmt.Sprintf("%s (%d samples, %.2f%%)", relPath(root, f.path), f.clipped, f.percent)
+	})
+}
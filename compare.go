@@ -0,0 +1,216 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// compareSide is the scan of one of the two trees, keyed by relative path
+// without extension so a transcoded copy (song.flac vs song.mp3) lines up
+// with its original.
+type compareSide struct {
+	root      string
+	files     int
+	errors    int
+	seconds   float64
+	durations map[string]float64
+	failed    map[string]bool
+}
+
+func scanCompareSide(dir string, opts options) (compareSide, error) {
+	root, err := resolveRoot(dir)
+	if err != nil {
+		return compareSide{}, err
+	}
+	side := compareSide{root: root, durations: make(map[string]float64), failed: make(map[string]bool)}
+	tree, err := walkTree(root, audioExtensions(opts), opts)
+	if err != nil {
+		return side, err
+	}
+	fmt.Printf("Scanning %s: %d audio files\n", displayPath(root), len(tree.audioFiles))
+	results := processFiles(tree.audioFiles, tree.sizes, opts)
+	side.files = len(tree.audioFiles)
+	for i, p := range tree.audioFiles {
+		rel := nfc(filepath.ToSlash(relPath(root, p)))
+		key := strings.TrimSuffix(rel, path.Ext(rel))
+		if results[i].err != nil {
+			side.errors++
+			side.failed[key] = true
+			continue
+		}
+		side.durations[key] += results[i].duration
+		side.seconds += results[i].duration
+	}
+	return side, nil
+}
+
+type compareDiff struct {
+	key    string
+	change float64 // b minus a, in seconds
+}
+
+// compareReport lists the files that differ between two scans.
+type compareReport struct {
+	a, b      compareSide
+	onlyA     []string
+	onlyB     []string
+	changed   []compareDiff
+	tolerance float64
+}
+
+func buildCompareReport(a, b compareSide, tolerance float64) compareReport {
+	report := compareReport{a: a, b: b, tolerance: tolerance}
+	for key, da := range a.durations {
+		db, ok := b.durations[key]
+		if !ok && !b.failed[key] {
+			report.onlyA = append(report.onlyA, key)
+			continue
+		}
+		if d := db - da; ok && math.Abs(d) > tolerance {
+			report.changed = append(report.changed, compareDiff{key: key, change: d})
+		}
+	}
+	for key := range b.durations {
+		if _, ok := a.durations[key]; !ok && !a.failed[key] {
+			report.onlyB = append(report.onlyB, key)
+		}
+	}
+	sort.Strings(report.onlyA)
+	sort.Strings(report.onlyB)
+	sort.Slice(report.changed, func(i, j int) bool {
+		return math.Abs(report.changed[i].change) > math.Abs(report.changed[j].change)
+	})
+	return report
+}
+
+// printTranscodeReport reads the comparison as source (A) against its
+// transcode (B): matched files that came out shorter or longer, and sources
+// with no transcode. Files only in the destination are not a failure.
+func printTranscodeReport(report compareReport) (failed bool) {
+	var shorter, longer []compareDiff
+	for _, d := range report.changed {
+		if d.change < 0 {
+			shorter = append(shorter, d)
+		} else {
+			longer = append(longer, d)
+		}
+	}
+	verified := len(report.a.durations) - len(report.onlyA) - len(report.changed)
+	for key := range report.a.durations {
+		if report.b.failed[key] {
+			verified--
+		}
+	}
+
+	fmt.Println("\n=== Transcode verification ===")
+	fmt.Printf("Source: %s (%d files, %.2f hours)\n", displayPath(report.a.root), report.a.files, report.a.seconds/3600.0)
+	fmt.Printf("Destination: %s (%d files, %.2f hours)\n", displayPath(report.b.root), report.b.files, report.b.seconds/3600.0)
+	fmt.Printf("Verified within %.1fs: %d\n", report.tolerance, verified)
+	fmt.Printf("Shorter than source: %d\n", len(shorter))
+	fmt.Printf("Longer than source: %d\n", len(longer))
+	fmt.Printf("Missing from destination: %d\n", len(report.onlyA))
+	if report.b.errors > 0 {
+		fmt.Printf("Unreadable in destination: %d\n", report.b.errors)
+	}
+
+	section := func(title string, diffs []compareDiff) {
+		if len(diffs) == 0 {
+			return
+		}
+		fmt.Printf("\n%s:\n", title)
+		printList(len(diffs), func(i int) string { return fmt.Sprintf("%s (%+.1fs)", diffs[i].key, diffs[i].change) })
+	}
+	section("Shorter than source", shorter)
+	section("Longer than source", longer)
+	if len(report.onlyA) > 0 {
+		fmt.Println("\nMissing from destination:")
+		printList(len(report.onlyA), func(i int) string { return report.onlyA[i] })
+	}
+	return len(report.changed) > 0 || len(report.onlyA) > 0 || report.b.errors > 0
+}
+
+func printCompareReport(report compareReport) {
+	a, b := report.a, report.b
+	fmt.Println("\n=== Comparison ===")
+	fmt.Printf("A: %s\nB: %s\n\n", displayPath(a.root), displayPath(b.root))
+	fmt.Printf("%-8s %12s %12s %12s\n", "", "A", "B", "B - A")
+	fmt.Printf("%-8s %12.2f %12.2f %+12.2f\n", "Hours", a.seconds/3600.0, b.seconds/3600.0, (b.seconds-a.seconds)/3600.0)
+	fmt.Printf("%-8s %12d %12d %+12d\n", "Files", a.files, b.files, b.files-a.files)
+	fmt.Printf("%-8s %12d %12d %+12d\n", "Errors", a.errors, b.errors, b.errors-a.errors)
+
+	if len(report.onlyA) == 0 && len(report.onlyB) == 0 && len(report.changed) == 0 {
+		fmt.Printf("\nEvery file matches within %.1fs.\n", report.tolerance)
+		return
+	}
+	if len(report.changed) > 0 {
+		fmt.Printf("\nDuration changes (> %.1fs): %d\n", report.tolerance, len(report.changed))
+		printList(len(report.changed), func(i int) string {
+			return fmt.Sprintf("%s (%+.1fs)", report.changed[i].key, report.changed[i].change)
+		})
+	}
+	if len(report.onlyA) > 0 {
+		fmt.Printf("\nOnly in A: %d\n", len(report.onlyA))
+		printList(len(report.onlyA), func(i int) string { return report.onlyA[i] })
+	}
+	if len(report.onlyB) > 0 {
+		fmt.Printf("\nOnly in B: %d\n", len(report.onlyB))
+		printList(len(report.onlyB), func(i int) string { return report.onlyB[i] })
+	}
+}
+
+// compareFlags are the flags of the compare subcommand.
+type compareFlags struct {
+	tolerance float64
+	transcode bool
+}
+
+func (f *compareFlags) define(fs *flag.FlagSet) {
+	fs.Float64Var(&f.tolerance, "tolerance", 0.5, "seconds two matched files may differ before they are reported")
+	fs.BoolVar(&f.transcode, "transcode", false, "verify <dirB> is a transcode of <dirA>: report shorter, longer and missing transcodes")
+}
+
+// runCompare implements "howManyHours compare <dirA> <dirB>". Files are
+// matched by relative path without extension, so a transcoded copy of a
+// library compares against its original.
+func runCompare(args []string) {
+	var flags compareFlags
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	flags.define(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s compare [flags] <dirA> <dirB>\n\nFlags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	opts := options{order: "interleave"}
+	var sides [2]compareSide
+	for i := range sides {
+		side, err := scanCompareSide(fs.Arg(i), opts)
+		if err != nil {
+			fmt.Printf("Error reading directory: %v\n", err)
+			os.Exit(1)
+		}
+		sides[i] = side
+	}
+	report := buildCompareReport(sides[0], sides[1], flags.tolerance)
+	if flags.transcode {
+		if printTranscodeReport(report) {
+			os.Exit(1)
+		}
+		return
+	}
+	printCompareReport(report)
+	if len(report.onlyA) > 0 || len(report.onlyB) > 0 || len(report.changed) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// completionFlag describes one flag for the generated completion scripts.
+// Exactly one of choices, files and dirs applies to a flag taking a value;
+// a value flag with none of them (numbers, addresses) completes nothing.
+type completionFlag struct {
+	name        string
+	description string
+	takesValue  bool
+	choices     []string
+	files, dirs bool
+}
+
+// completionCommand is the top-level scan or one subcommand. args is what
+// its positional arguments complete: "dirs" or "files".
+type completionCommand struct {
+	name        string
+	description string
+	flags       []completionFlag
+	args        string
+}
+
+// flagChoices lists the values of the flags that only accept a fixed set.
+var flagChoices = map[string][]string{
+	"order":          {"walk", "small-first", "interleave"},
+	"lang":           {"en", "fr", "es"},
+	"units":          {"minutes", "hours", "days"},
+	"format":         {"text", "json"},
+	"fadvise":        {"sequential", "random", "willneed"},
+	"stats-over":     {"valid", "processed", "all"},
+	"backend":        {"native", "libav"},
+	"by":             {"duration", "count"},
+	"fallback":       {"ffprobe"},
+	"growing":        {"provisional", "skip", "retry"},
+	"track-duration": {"container", "sum"},
+	"progress":       {progressBar, progressPlain, progressNone},
+}
+
+// subcommands are completed from the flags their define methods register,
+// the same ones they parse when they run.
+var subcommands = []struct {
+	name, description, args string
+	define                  func(*flag.FlagSet)
+}{
+	{"split", "write duration-balanced train/dev/test file lists", "dirs", new(splitFlags).define},
+	{"merge", "combine --format json scan outputs into one report", "files", new(mergeFlags).define},
+	{"compare", "compare hours, files and errors of two directories", "dirs", new(compareFlags).define},
+	{"self-update", "replace this binary with the latest release", "", new(selfUpdateFlags).define},
+	{"completion", "print a shell completion script", "", nil},
+}
+
+var (
+	mentionsFile   = regexp.MustCompile(`\b(file|manifest|database)\b`)
+	mentionsDir    = regexp.MustCompile(`\b(directory|folder)\b`)
+	mentionsNoPath = regexp.MustCompile(`://|host:port|address|regexp|algorithm`)
+)
+
+// completionFlags describes the flags registered on fs. Whether a string
+// flag takes a file or a directory is read off its usage text.
+func completionFlags(fs *flag.FlagSet) []completionFlag {
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		c := completionFlag{name: f.Name, description: completionDescription(f.Usage), takesValue: true}
+		var value any
+		if getter, ok := f.Value.(flag.Getter); ok {
+			value = getter.Get()
+		}
+		switch value.(type) {
+		case bool:
+			c.takesValue = false
+		case int, int64, uint, uint64, float64, time.Duration:
+		default:
+			if c.choices = flagChoices[f.Name]; c.choices != nil {
+				break
+			}
+			usage := strings.ToLower(f.Usage)
+			if mentionsNoPath.MatchString(usage) {
+				break
+			}
+			c.files = mentionsFile.MatchString(usage)
+			c.dirs = !c.files && mentionsDir.MatchString(usage)
+		}
+		flags = append(flags, c)
+	})
+	return flags
+}
+
+func completionCommands() []completionCommand {
+	defineFlags(&options{})
+	commands := []completionCommand{{flags: completionFlags(flag.CommandLine), args: "dirs"}}
+	for _, sub := range subcommands {
+		cmd := completionCommand{name: sub.name, description: sub.description, args: sub.args}
+		if sub.define != nil {
+			fs := flag.NewFlagSet(sub.name, flag.ContinueOnError)
+			sub.define(fs)
+			cmd.flags = completionFlags(fs)
+		}
+		commands = append(commands, cmd)
+	}
+	return commands
+}
+
+// completionDescription shortens a usage string to its first clause.
+func completionDescription(usage string) string {
+	if i := strings.IndexAny(usage, ";("); i > 0 {
+		usage = usage[:i]
+	}
+	usage = strings.TrimSpace(usage)
+	if r := []rune(usage); len(r) > 70 {
+		usage = strings.TrimSpace(string(r[:69])) + "…"
+	}
+	return usage
+}
+
+// runCompletion implements "howManyHours completion <shell>".
+func runCompletion(args []string) {
+	fs := flag.NewFlagSet("completion", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s completion bash|zsh|fish|powershell\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "Prints a completion script for the shell, for example:")
+		fmt.Fprintf(fs.Output(), "  source <(%s completion bash)\n", os.Args[0])
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	program := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	commands := completionCommands()
+	var script string
+	switch fs.Arg(0) {
+	case "bash":
+		script = bashCompletion(program, commands)
+	case "zsh":
+		script = zshCompletion(program, commands)
+	case "fish":
+		script = fishCompletion(program, commands)
+	case "powershell", "pwsh":
+		script = powershellCompletion(program, commands)
+	default:
+		fmt.Fprintf(os.Stderr, "Unsupported shell: %s (want bash, zsh, fish or powershell)\n", fs.Arg(0))
+		os.Exit(2)
+	}
+	fmt.Print(script)
+}
+
+// shellIdentifier turns the program name into a shell function name.
+func shellIdentifier(program string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, program)
+}
+
+func subcommandNames(commands []completionCommand) []string {
+	var names []string
+	for _, cmd := range commands[1:] {
+		names = append(names, cmd.name)
+	}
+	return names
+}
+
+func bashCompletion(program string, commands []completionCommand) string {
+	var b strings.Builder
+	fn := "_" + shellIdentifier(program)
+	fmt.Fprintf(&b, "# bash completion for %s\n", program)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    local cur prev cmd\n")
+	b.WriteString("    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	b.WriteString("    cmd=\"\"\n")
+	fmt.Fprintf(&b, "    case \"${COMP_WORDS[1]}\" in\n        %s) cmd=\"${COMP_WORDS[1]}\" ;;\n    esac\n", strings.Join(subcommandNames(commands), "|"))
+	b.WriteString("    case \"$cmd\" in\n")
+	for _, cmd := range commands {
+		label := cmd.name
+		if label == "" {
+			label = `""`
+		}
+		fmt.Fprintf(&b, "    %s)\n", label)
+		b.WriteString("        case \"$prev\" in\n")
+		var names []string
+		for _, f := range cmd.flags {
+			names = append(names, "--"+f.name)
+			if !f.takesValue {
+				continue
+			}
+			pattern := "-" + f.name + "|--" + f.name
+			switch {
+			case f.choices != nil:
+				fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return ;;\n", pattern, strings.Join(f.choices, " "))
+			case f.dirs:
+				fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -d -- \"$cur\")); return ;;\n", pattern)
+			case f.files:
+				fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", pattern)
+			default:
+				fmt.Fprintf(&b, "        %s) return ;;\n", pattern)
+			}
+		}
+		b.WriteString("        esac\n")
+		b.WriteString("        if [[ \"$cur\" == -* ]]; then\n")
+		fmt.Fprintf(&b, "            COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(names, " "))
+		switch {
+		case cmd.name == "":
+			b.WriteString("        elif [[ $COMP_CWORD -eq 1 ]]; then\n")
+			fmt.Fprintf(&b, "            COMPREPLY=($(compgen -W \"%s\" -- \"$cur\") $(compgen -d -- \"$cur\"))\n", strings.Join(subcommandNames(commands), " "))
+			b.WriteString("        else\n            COMPREPLY=($(compgen -d -- \"$cur\"))\n")
+		case cmd.name == "completion":
+			b.WriteString("        else\n            COMPREPLY=($(compgen -W \"bash zsh fish powershell\" -- \"$cur\"))\n")
+		case cmd.args == "dirs":
+			b.WriteString("        else\n            COMPREPLY=($(compgen -d -- \"$cur\"))\n")
+		case cmd.args == "files":
+			b.WriteString("        else\n            COMPREPLY=($(compgen -f -- \"$cur\"))\n")
+		}
+		b.WriteString("        fi\n        ;;\n")
+	}
+	b.WriteString("    esac\n}\n")
+	fmt.Fprintf(&b, "complete -o filenames -F %s %s\n", fn, program)
+	return b.String()
+}
+
+// zshQuote escapes a description for an _arguments spec in single quotes.
+func zshQuote(s string) string {
+	return strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+func zshCompletion(program string, commands []completionCommand) string {
+	var b strings.Builder
+	fn := "_" + shellIdentifier(program)
+	fmt.Fprintf(&b, "#compdef %s\n\n", program)
+	for _, cmd := range commands {
+		name := fn
+		if cmd.name != "" {
+			name += "_" + shellIdentifier(cmd.name)
+		}
+		var specs []string
+		for _, f := range cmd.flags {
+			spec := "--" + f.name + "[" + zshQuote(f.description) + "]"
+			if f.takesValue {
+				switch {
+				case f.choices != nil:
+					spec += ":" + f.name + ":(" + strings.Join(f.choices, " ") + ")"
+				case f.dirs:
+					spec += ":directory:_files -/"
+				case f.files:
+					spec += ":file:_files"
+				default:
+					spec += ":" + f.name + ": "
+				}
+			}
+			specs = append(specs, spec)
+		}
+		switch {
+		case cmd.name == "completion":
+			specs = append(specs, "1:shell:(bash zsh fish powershell)")
+		case cmd.args == "dirs":
+			specs = append(specs, "*:folder:_files -/")
+		case cmd.args == "files":
+			specs = append(specs, "*:file:_files")
+		}
+		fmt.Fprintf(&b, "%s() {\n    _arguments -s", name)
+		for _, spec := range specs {
+			fmt.Fprintf(&b, " \\\n        '%s'", spec)
+		}
+		b.WriteString("\n")
+		b.WriteString("}\n\n")
+	}
+
+	fmt.Fprintf(&b, "%s_dispatch() {\n", fn)
+	b.WriteString("    if (( CURRENT == 2 )) && [[ $words[2] != -* ]]; then\n")
+	b.WriteString("        local -a commands\n        commands=(\n")
+	for _, cmd := range commands[1:] {
+		fmt.Fprintf(&b, "            '%s:%s'\n", cmd.name, zshQuote(cmd.description))
+	}
+	b.WriteString("        )\n")
+	fmt.Fprintf(&b, "        _describe command commands\n        _files -/\n        return\n    fi\n")
+	b.WriteString("    case $words[2] in\n")
+	for _, cmd := range commands[1:] {
+		fmt.Fprintf(&b, "    %s) shift words; (( CURRENT-- )); %s_%s ;;\n", cmd.name, fn, shellIdentifier(cmd.name))
+	}
+	fmt.Fprintf(&b, "    *) %s ;;\n    esac\n}\n\n", fn)
+	fmt.Fprintf(&b, "compdef %s_dispatch %s\n", fn, program)
+	return b.String()
+}
+
+// fishQuote quotes s as a fish single-quoted string.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+func fishCompletion(program string, commands []completionCommand) string {
+	var b strings.Builder
+	subcommands := strings.Join(subcommandNames(commands), " ")
+	fmt.Fprintf(&b, "# fish completion for %s\n", program)
+	fmt.Fprintf(&b, "complete -c %s -f\n", program)
+	for _, cmd := range commands {
+		condition := "not __fish_seen_subcommand_from " + subcommands
+		if cmd.name != "" {
+			condition = "__fish_seen_subcommand_from " + cmd.name
+			fmt.Fprintf(&b, "complete -c %s -n '__fish_use_subcommand' -a %s -d %s\n", program, cmd.name, fishQuote(cmd.description))
+		}
+		for _, f := range cmd.flags {
+			line := fmt.Sprintf("complete -c %s -n '%s' -l %s -d %s", program, condition, f.name, fishQuote(f.description))
+			if f.takesValue {
+				switch {
+				case f.choices != nil:
+					line += " -x -a " + fishQuote(strings.Join(f.choices, " "))
+				case f.dirs:
+					line += " -x -a '(__fish_complete_directories (commandline -ct))'"
+				case f.files:
+					line += " -r -F"
+				default:
+					line += " -x"
+				}
+			}
+			b.WriteString(line + "\n")
+		}
+		switch {
+		case cmd.name == "completion":
+			fmt.Fprintf(&b, "complete -c %s -n '%s' -a 'bash zsh fish powershell'\n", program, condition)
+		case cmd.args == "dirs":
+			fmt.Fprintf(&b, "complete -c %s -n '%s' -a '(__fish_complete_directories (commandline -ct))'\n", program, condition)
+		case cmd.args == "files":
+			fmt.Fprintf(&b, "complete -c %s -n '%s' -F\n", program, condition)
+		}
+	}
+	return b.String()
+}
+
+// powershellQuote quotes s as a PowerShell single-quoted string.
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func powershellCompletion(program string, commands []completionCommand) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# PowerShell completion for %s\n", program)
+	b.WriteString("$commands = @{\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "    %s = @{ args = %s; flags = @{\n", powershellQuote(cmd.name), powershellQuote(cmd.args))
+		flags := append([]completionFlag(nil), cmd.flags...)
+		sort.Slice(flags, func(i, j int) bool { return flags[i].name < flags[j].name })
+		for _, f := range flags {
+			kind := "none"
+			switch {
+			case !f.takesValue:
+				kind = "switch"
+			case f.choices != nil:
+				kind = strings.Join(f.choices, ",")
+			case f.dirs:
+				kind = "dirs"
+			case f.files:
+				kind = "files"
+			}
+			fmt.Fprintf(&b, "        %s = @(%s, %s)\n", powershellQuote("--"+f.name), powershellQuote(kind), powershellQuote(f.description))
+		}
+		b.WriteString("    } }\n")
+	}
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "Register-ArgumentCompleter -Native -CommandName %s, %s.exe -ScriptBlock {\n", powershellQuote(program), program)
+	b.WriteString(`    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -ne '') { $words = $words[0..($words.Count - 2)] }
+    $name = ''
+    if ($words.Count -gt 1 -and $commands.ContainsKey($words[1]) -and $words[1] -ne '') { $name = $words[1] }
+    $cmd = $commands[$name]
+    $prev = if ($words.Count -gt 1) { $words[-1] } else { '' }
+    $paths = {
+        param($dirsOnly)
+        Get-ChildItem -Path "$wordToComplete*" -Directory:$dirsOnly -ErrorAction SilentlyContinue | ForEach-Object {
+            [System.Management.Automation.CompletionResult]::new($_.FullName, $_.Name, 'ProviderItem', $_.FullName)
+        }
+    }
+    if ($cmd.flags.ContainsKey($prev) -and $cmd.flags[$prev][0] -ne 'switch') {
+        switch ($cmd.flags[$prev][0]) {
+            'none' { return }
+            'dirs' { return & $paths $true }
+            'files' { return & $paths $false }
+            default {
+                return $cmd.flags[$prev][0] -split ',' | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+                }
+            }
+        }
+    }
+    if ($wordToComplete -like '-*') {
+        return $cmd.flags.Keys | Where-Object { $_ -like "$wordToComplete*" } | Sort-Object | ForEach-Object {
+            [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $cmd.flags[$_][1])
+        }
+    }
+    if ($name -eq 'completion') {
+        return 'bash', 'zsh', 'fish', 'powershell' | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+            [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+        }
+    }
+    if ($name -eq '' -and $words.Count -eq 1) {
+        $commands.Keys | Where-Object { $_ -ne '' -and $_ -like "$wordToComplete*" } | Sort-Object | ForEach-Object {
+            [System.Management.Automation.CompletionResult]::new($_, $_, 'Command', $_)
+        }
+    }
+    switch ($cmd.args) {
+        'dirs' { & $paths $true }
+        'files' { & $paths $false }
+    }
+}
+`)
+	return b.String()
+}
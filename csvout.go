@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// csvBOM and csvCRLF are --bom and --crlf. Excel on Windows reads a CSV
+// without a byte order mark in the ANSI code page, mangling non-ASCII
+// file names, and expects CRLF line ends.
+var (
+	csvBOM  bool
+	csvCRLF bool
+)
+
+// newCSVWriter starts a CSV export on w, in the encoding --bom and --crlf
+// ask for.
+func newCSVWriter(w io.Writer) (*csv.Writer, error) {
+	if csvBOM {
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return nil, err
+		}
+	}
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = csvCRLF
+	return cw, nil
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Daemon mode: with --daemon SOCKET the tree is scanned, then rescanned
+// every --daemon-rescan, and per-directory totals are served over a unix
+// socket so file managers and editor plugins can show "hours in this
+// folder" without waiting for a scan. Rescans reuse the probes of
+// unchanged files, so they cost little more than the walk.
+//
+// The protocol is JSON-RPC 2.0, one request or response per line:
+//
+//	{"jsonrpc":"2.0","id":1,"method":"hours","params":{"path":"/music/jazz"}}
+//	{"jsonrpc":"2.0","id":1,"result":{"path":"jazz","files":812,"errors":0,"seconds":201600,"hours":56,"scanned":"..."}}
+//
+// Methods: hours and children take a path, absolute or relative to the
+// root; status and rescan take none.
+
+// JSON-RPC error codes.
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcNotScanned     = -32000 // the first scan has not finished
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// dirTotal is the audio under one directory, subdirectories included.
+type dirTotal struct {
+	Path    string    `json:"path"`
+	Files   int       `json:"files"`
+	Errors  int       `json:"errors"`
+	Seconds float64   `json:"seconds"`
+	Hours   float64   `json:"hours"`
+	Scanned time.Time `json:"scanned"`
+}
+
+// daemonState is the totals of the last finished scan.
+type daemonState struct {
+	scanned  time.Time
+	elapsed  time.Duration
+	dirs     map[string]*dirTotal // relative slash path -> total; "." is the root
+	children map[string][]string  // relative slash path -> immediate subdirectories holding audio
+}
+
+type daemon struct {
+	root   string
+	opts   options
+	rescan chan struct{}
+
+	mu       sync.RWMutex
+	state    *daemonState
+	scanning bool
+	next     time.Time
+}
+
+// runDaemon scans root, serves its totals on socketPath and rescans until
+// interrupted.
+func runDaemon(socketPath, root string, opts options) error {
+	if info, err := os.Lstat(socketPath); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("%s exists and is not a socket", socketPath)
+		}
+		if conn, err := net.Dial("unix", socketPath); err == nil {
+			conn.Close()
+			return fmt.Errorf("another daemon is listening on %s", socketPath)
+		}
+		os.Remove(socketPath)
+	}
+	// Only this user may ask about the tree.
+	ln, err := listenPrivate(socketPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(socketPath, 0o600); err != nil {
+		ln.Close()
+		return err
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		ln.Close()
+	}()
+	defer os.Remove(socketPath)
+
+	if opts.cache != "" {
+		if opts.probeCache, err = readProbeCache(opts.cache); err != nil {
+			ln.Close()
+			return err
+		}
+	} else {
+		opts.probeCache = &probeCache{entries: make(map[string]cacheEntry)}
+	}
+	if progressStyle == progressBar {
+		progressStyle = progressNone
+	}
+	d := &daemon{root: root, opts: opts, rescan: make(chan struct{}, 1)}
+	go d.scanLoop()
+
+	fmt.Printf("Serving totals for %s on %s\n", root, socketPath)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				fmt.Println("Daemon stopped.")
+				return nil
+			}
+			return err
+		}
+		go d.serve(conn)
+	}
+}
+
+// scanLoop scans now, then after every interval or rescan request.
+func (d *daemon) scanLoop() {
+	for {
+		d.scan()
+		timer := time.NewTimer(d.opts.daemonRescan)
+		d.mu.Lock()
+		d.next = time.Now().Add(d.opts.daemonRescan)
+		d.mu.Unlock()
+		select {
+		case <-timer.C:
+		case <-d.rescan:
+			timer.Stop()
+		}
+	}
+}
+
+func (d *daemon) scan() {
+	d.mu.Lock()
+	d.scanning = true
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		d.scanning = false
+		d.mu.Unlock()
+	}()
+
+	start := time.Now()
+	before := readResourceUsage()
+	tree, err := walkTree(d.root, audioExtensions(d.opts), d.opts)
+	if err != nil {
+		fmt.Printf("Warning: rescan of %s failed: %v\n", d.root, err)
+		return
+	}
+	results := processFiles(tree.audioFiles, tree.sizes, d.opts)
+	if d.opts.cache != "" {
+		if err := writeProbeCache(d.opts.cache, d.opts.probeCache, tree.audioFiles, results); err != nil {
+			fmt.Printf("Warning: could not update cache: %v\n", err)
+		}
+	}
+	d.opts.probeCache = resultCache(d.opts.probeCache, tree.audioFiles, results)
+
+	state := buildDaemonState(d.root, tree.audioFiles, results, start)
+	state.elapsed = time.Since(start)
+	d.mu.Lock()
+	d.state = state
+	d.mu.Unlock()
+	total := state.dirs["."]
+	fmt.Printf("%s scanned %d files, %.2f hours, in %s (%s)\n", time.Now().UTC().Format(time.RFC3339), total.Files, total.Hours, state.elapsed.Round(100*time.Millisecond), readResourceUsage().since(before))
+
+	if d.opts.emitDirFiles != "" {
+		if _, failed := emitDirFiles(d.opts.emitDirFiles, state.dirs, d.root, tree.audioFiles); len(failed) > 0 {
+			fmt.Printf("Warning: could not write %s in %d directories, e.g. %s\n", d.opts.emitDirFiles, len(failed), failed[0])
+		}
+	}
+	if d.opts.mqtt != "" {
+		summary := newScanSummary(d.root, start, tree.audioFiles, results, false, d.opts.statsOver)
+		if err := publishMQTT(d.opts.mqtt, summary); err != nil {
+			fmt.Printf("Warning: could not publish to MQTT: %v\n", err)
+		}
+	}
+}
+
+// resultCache is the cache the next rescan looks files up in: old's
+// entries with this scan's successful probes replacing those of the same
+// files.
+func resultCache(old *probeCache, audioFiles []string, results []result) *probeCache {
+	c := &probeCache{entries: make(map[string]cacheEntry, len(old.entries))}
+	for k, e := range old.entries {
+		c.entries[k] = e
+	}
+	for i, res := range results {
+		key := cacheKey(audioFiles[i])
+		delete(c.entries, key)
+		if res.err == nil && res.version != "" {
+			c.entries[key] = cacheEntry{Path: key, Size: res.size, Version: res.version, Duration: res.duration, Prober: res.prober, Metadata: res.metadata}
+		}
+	}
+	return c
+}
+
+// buildDaemonState adds each file to its directory and every directory
+// above it.
+func buildDaemonState(root string, audioFiles []string, results []result, scanned time.Time) *daemonState {
+	state := &daemonState{scanned: scanned, dirs: make(map[string]*dirTotal), children: make(map[string][]string)}
+	dir := func(rel string) *dirTotal {
+		t, ok := state.dirs[rel]
+		if !ok {
+			t = &dirTotal{Path: rel, Scanned: scanned}
+			state.dirs[rel] = t
+			if rel != "." {
+				parent := path.Dir(rel)
+				state.children[parent] = append(state.children[parent], rel)
+			}
+		}
+		return t
+	}
+	dir(".")
+	for i, p := range audioFiles {
+		parts := pathComponents(root, p)
+		dirs := []string{"."}
+		for n := 1; n <= len(parts); n++ {
+			dirs = append(dirs, strings.Join(parts[:n], "/"))
+		}
+		for _, rel := range dirs {
+			t := dir(rel)
+			t.Files++
+			if results[i].err != nil {
+				t.Errors++
+			} else if results[i].duration > 0 {
+				t.Seconds += results[i].duration
+			}
+		}
+	}
+	for _, t := range state.dirs {
+		t.Hours = t.Seconds / 3600
+	}
+	for _, c := range state.children {
+		sort.Strings(c)
+	}
+	return state
+}
+
+// serve answers one connection's requests in order until it closes.
+func (d *daemon) serve(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, 1<<20)
+	enc := json.NewEncoder(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var req rpcRequest
+		resp := rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			resp.Error = &rpcError{rpcParseError, "parse error"}
+		} else {
+			if req.ID != nil {
+				resp.ID = req.ID
+			}
+			resp.Result, resp.Error = d.call(req.Method, req.Params)
+			if req.ID == nil {
+				continue // a notification gets no response
+			}
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (d *daemon) call(method string, params json.RawMessage) (any, *rpcError) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	switch method {
+	case "status":
+		status := map[string]any{"root": d.root, "scanning": d.scanning}
+		if d.state != nil {
+			total := d.state.dirs["."]
+			status["files"], status["hours"] = total.Files, total.Hours
+			status["scanned"], status["scan_seconds"] = d.state.scanned, d.state.elapsed.Seconds()
+			status["next_scan"] = d.next
+		}
+		return status, nil
+	case "rescan":
+		select {
+		case d.rescan <- struct{}{}:
+		default: // one is already pending
+		}
+		return map[string]any{"queued": true}, nil
+	case "hours", "children":
+		if d.state == nil {
+			return nil, &rpcError{rpcNotScanned, "first scan still running"}
+		}
+		var p struct {
+			Path string `json:"path"`
+		}
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &p); err != nil {
+				return nil, &rpcError{rpcInvalidParams, "params must be {\"path\": ...}"}
+			}
+		}
+		rel, err := d.relDir(p.Path)
+		if err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+		if method == "hours" {
+			if t, ok := d.state.dirs[rel]; ok {
+				return t, nil
+			}
+			return &dirTotal{Path: rel, Scanned: d.state.scanned}, nil
+		}
+		children := make([]*dirTotal, 0, len(d.state.children[rel]))
+		for _, c := range d.state.children[rel] {
+			children = append(children, d.state.dirs[c])
+		}
+		return children, nil
+	}
+	return nil, &rpcError{rpcMethodNotFound, "method not found: " + method}
+}
+
+// relDir turns a request path into the relative slash path of the
+// directory totals, refusing paths outside the root.
+func (d *daemon) relDir(p string) (string, error) {
+	if p == "" {
+		return ".", nil
+	}
+	if filepath.IsAbs(p) {
+		// The root was resolved through symlinks; resolve the path too.
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			p = real
+		}
+		rel, err := filepath.Rel(d.root, p)
+		if err != nil {
+			return "", err
+		}
+		p = rel
+	}
+	p = nfc(filepath.ToSlash(filepath.Clean(p)))
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return "", fmt.Errorf("%s is outside %s", p, d.root)
+	}
+	return p, nil
+}
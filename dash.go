@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// mpd holds the parts of a DASH MPD that durations are read from.
+type mpd struct {
+	Type     string      `xml:"type,attr"`
+	Duration string      `xml:"mediaPresentationDuration,attr"`
+	Periods  []mpdPeriod `xml:"Period"`
+}
+
+type mpdPeriod struct {
+	Duration string          `xml:"duration,attr"`
+	Sets     []mpdAdaptation `xml:"AdaptationSet"`
+}
+
+type mpdAdaptation struct {
+	ContentType     string              `xml:"contentType,attr"`
+	MimeType        string              `xml:"mimeType,attr"`
+	Template        *mpdSegmentTemplate `xml:"SegmentTemplate"`
+	Representations []struct {
+		MimeType string              `xml:"mimeType,attr"`
+		Template *mpdSegmentTemplate `xml:"SegmentTemplate"`
+	} `xml:"Representation"`
+}
+
+type mpdSegmentTemplate struct {
+	Timescale uint64 `xml:"timescale,attr"`
+	Timeline  []struct {
+		D uint64 `xml:"d,attr"`
+		R int64  `xml:"r,attr"`
+	} `xml:"SegmentTimeline>S"`
+}
+
+// seconds sums a SegmentTimeline. A negative repeat count ("until the
+// next S or the period end") counts the segment once.
+func (t *mpdSegmentTemplate) seconds() float64 {
+	if t == nil || len(t.Timeline) == 0 {
+		return 0
+	}
+	timescale := t.Timescale
+	if timescale == 0 {
+		timescale = 1
+	}
+	var units uint64
+	for _, s := range t.Timeline {
+		units += s.D * uint64(max(s.R, 0)+1)
+	}
+	return float64(units) / float64(timescale)
+}
+
+// audio reports whether the adaptation set carries audio.
+func (a mpdAdaptation) audio() bool {
+	if a.ContentType == "audio" || strings.HasPrefix(a.MimeType, "audio/") {
+		return true
+	}
+	for _, r := range a.Representations {
+		if strings.HasPrefix(r.MimeType, "audio/") {
+			return true
+		}
+	}
+	return false
+}
+
+// timelineSeconds is the segment timeline of the period's first audio
+// adaptation set, or of its first set when none is marked as audio.
+func (p mpdPeriod) timelineSeconds() float64 {
+	sets := p.Sets
+	for _, a := range p.Sets {
+		if a.audio() {
+			sets = []mpdAdaptation{a}
+			break
+		}
+	}
+	for _, a := range sets {
+		if s := a.Template.seconds(); s > 0 {
+			return s
+		}
+		for _, r := range a.Representations {
+			if s := r.Template.seconds(); s > 0 {
+				return s
+			}
+		}
+	}
+	return 0
+}
+
+// dashDuration is the duration of a DASH MPD: its mediaPresentationDuration,
+// or else the sum over periods of their duration or segment timeline.
+func dashDuration(location string) (float64, error) {
+	data, err := readStreamManifest(location)
+	if err != nil {
+		return 0, err
+	}
+	var m mpd
+	if err := xml.Unmarshal(data, &m); err != nil {
+		return 0, fmt.Errorf("invalid MPD: %w", err)
+	}
+	if m.Duration != "" {
+		return parseISODuration(m.Duration)
+	}
+	var total float64
+	for _, p := range m.Periods {
+		if p.Duration != "" {
+			d, err := parseISODuration(p.Duration)
+			if err != nil {
+				return 0, err
+			}
+			total += d
+			continue
+		}
+		total += p.timelineSeconds()
+	}
+	if total == 0 {
+		if m.Type == "dynamic" {
+			return 0, errors.New("live MPD without a duration or segment timeline")
+		}
+		return 0, errors.New("MPD has no duration")
+	}
+	return total, nil
+}
+
+// parseISODuration parses the xs:duration values MPDs use, such as
+// PT1H2M3.5S or P1DT2H. Years and months have no fixed length and are
+// rejected.
+func parseISODuration(s string) (float64, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(s), "P")
+	if !ok || rest == "" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	var seconds float64
+	inTime := false
+	for rest != "" {
+		if rest[0] == 'T' {
+			inTime = true
+			rest = rest[1:]
+			continue
+		}
+		i := strings.IndexFunc(rest, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if i <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		n, err := strconv.ParseFloat(rest[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		switch unit := rest[i]; {
+		case unit == 'D' && !inTime:
+			seconds += n * 86400
+		case unit == 'H' && inTime:
+			seconds += n * 3600
+		case unit == 'M' && inTime:
+			seconds += n * 60
+		case unit == 'S' && inTime:
+			seconds += n
+		default:
+			return 0, fmt.Errorf("unsupported duration %q", s)
+		}
+		rest = rest[i+1:]
+	}
+	return seconds, nil
+}
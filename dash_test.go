@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestParseISODuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{in: "PT1H2M3.5S", want: 3723.5},
+		{in: "P1DT2H", want: 93600},
+		{in: "PT0S", want: 0},
+		{in: " PT90S ", want: 90},
+		{in: "PT1.5M", want: 90},
+		{in: "P2D", want: 172800},
+		{in: "", wantErr: true},
+		{in: "P", wantErr: true},
+		{in: "1H", wantErr: true},
+		{in: "P1Y", wantErr: true},  // years have no fixed length
+		{in: "P1M", wantErr: true},  // months neither
+		{in: "P1H", wantErr: true},  // hours belong after T
+		{in: "PT1D", wantErr: true}, // days belong before T
+		{in: "PTH", wantErr: true},  // unit without a number
+		{in: "PT1.2.3S", wantErr: true},
+		{in: "PT5", wantErr: true}, // number without a unit
+	}
+	for _, tt := range tests {
+		got, err := parseISODuration(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseISODuration(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseISODuration(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func FuzzParseISODuration(f *testing.F) {
+	f.Add("PT1H2M3.5S")
+	f.Add("P1DT2H")
+	f.Fuzz(func(t *testing.T, s string) {
+		if d, err := parseISODuration(s); err == nil && d < 0 {
+			t.Fatalf("parseISODuration(%q) = %v", s, d)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-audio/audio"
+	gomp3 "github.com/hajimehoshi/go-mp3"
+)
+
+// pcmFormat describes the decoded stream handed to sample analyzers.
+type pcmFormat struct {
+	sampleRate int
+	channels   int
+}
+
+// sampleAnalyzer consumes decoded audio. Samples are interleaved and
+// normalised to [-1, 1]; blocks always hold whole frames.
+type sampleAnalyzer interface {
+	start(format pcmFormat)
+	process(samples []float64)
+}
+
+// decodeBlockFrames is how many frames are decoded per analyzer call.
+const decodeBlockFrames = 4096
+
+// canDecodePCM reports whether a full sample decode is available for a file.
+// Header-only formats still get a duration but skip sample analysis.
+func canDecodePCM(filePath string) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".wav", ".mp3":
+		return true
+	}
+	return false
+}
+
+// decodePCM decodes the whole file once and feeds every analyzer.
+func decodePCM(filePath string, analyzers []sampleAnalyzer) error {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".wav":
+		return decodeWAV(filePath, analyzers)
+	case ".mp3":
+		return decodeMP3(filePath, analyzers)
+	default:
+		return fmt.Errorf("sample decoding not supported for %s", filepath.Ext(filePath))
+	}
+}
+
+func decodeWAV(filePath string, analyzers []sampleAnalyzer) error {
+	file, err := openAudioWhole(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder, err := newWAVDecoder(file)
+	if err != nil {
+		return err
+	}
+	if !decoder.IsValidFile() {
+		return fmt.Errorf("invalid WAV file")
+	}
+	if decoder.WavAudioFormat != 1 {
+		return fmt.Errorf("unsupported WAV encoding %d (only integer PCM is decoded)", decoder.WavAudioFormat)
+	}
+
+	channels := int(decoder.NumChans)
+	format := pcmFormat{sampleRate: int(decoder.SampleRate), channels: channels}
+	for _, a := range analyzers {
+		a.start(format)
+	}
+
+	scale := float64(int64(1) << (decoder.BitDepth - 1))
+	buf := &audio.IntBuffer{Data: make([]int, decodeBlockFrames*channels)}
+	samples := make([]float64, len(buf.Data))
+	for {
+		n, err := decoder.PCMBuffer(buf)
+		if err != nil {
+			return err
+		}
+		n -= n % channels
+		if n == 0 {
+			return nil
+		}
+		for i, v := range buf.Data[:n] {
+			// 8-bit WAV is unsigned; wider depths are signed.
+			if decoder.BitDepth == 8 {
+				v -= 128
+			}
+			samples[i] = float64(v) / scale
+		}
+		for _, a := range analyzers {
+			a.process(samples[:n])
+		}
+	}
+}
+
+func decodeMP3(filePath string, analyzers []sampleAnalyzer) error {
+	file, err := openAudioWhole(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder, err := gomp3.NewDecoder(file)
+	if err != nil {
+		return err
+	}
+
+	// go-mp3 always produces 16-bit little-endian stereo.
+	format := pcmFormat{sampleRate: decoder.SampleRate(), channels: 2}
+	for _, a := range analyzers {
+		a.start(format)
+	}
+
+	raw := make([]byte, decodeBlockFrames*4)
+	samples := make([]float64, decodeBlockFrames*2)
+	for {
+		n, err := io.ReadFull(decoder, raw)
+		n -= n % 4
+		for i := 0; i < n/2; i++ {
+			samples[i] = float64(int16(binary.LittleEndian.Uint16(raw[i*2:]))) / 32768
+		}
+		if n > 0 {
+			for _, a := range analyzers {
+				a.process(samples[:n/2])
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
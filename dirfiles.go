@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// checkDirFileName accepts a plain file name for --emit-dir-files, which
+// must not be mistaken for audio by the next scan.
+func checkDirFileName(name string, opts options) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid --emit-dir-files %q: want a file name such as .hours", name)
+	}
+	if audioExtensions(opts)[strings.ToLower(filepath.Ext(name))] {
+		return fmt.Errorf("invalid --emit-dir-files %q: the name has an audio extension", name)
+	}
+	return nil
+}
+
+// dirFileContent is the summary written into one directory. It holds no
+// timestamp, so a file only changes when the directory's audio does and
+// other tools can diff or watch it.
+func dirFileContent(t *dirTotal) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "hours: %s\n", strconv.FormatFloat(t.Seconds/3600, 'f', 4, 64))
+	fmt.Fprintf(&b, "seconds: %s\n", strconv.FormatFloat(t.Seconds, 'f', 3, 64))
+	fmt.Fprintf(&b, "files: %d\n", t.Files)
+	fmt.Fprintf(&b, "errors: %d\n", t.Errors)
+	return b.Bytes()
+}
+
+// emitDirFiles writes name into every directory holding audio, with the
+// totals of its subtree. Files whose content would not change are left
+// alone so their modification times keep meaning something. Each file is
+// written beside its target and renamed over it, so a symlink planted
+// under name is replaced rather than followed. It returns the number
+// written and the directories that could not be written.
+func emitDirFiles(name string, totals map[string]*dirTotal, root string, audioFiles []string) (written int, failed []string) {
+	walked := walkedDirs(root, audioFiles)
+	dirs := make([]string, 0, len(totals))
+	for rel := range totals {
+		dirs = append(dirs, rel)
+	}
+	sort.Strings(dirs)
+	for _, rel := range dirs {
+		dir, ok := walked[rel]
+		if !ok {
+			failed = append(failed, rel)
+			continue
+		}
+		path := filepath.Join(dir, name)
+		content := dirFileContent(totals[rel])
+		if fi, err := os.Lstat(path); err == nil && fi.Mode().IsRegular() {
+			if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, content) {
+				continue
+			}
+		}
+		if err := replaceFile(path, content); err != nil {
+			failed = append(failed, rel)
+			continue
+		}
+		written++
+	}
+	return written, failed
+}
+
+// walkedDirs maps the directory keys of buildDaemonState, which are NFC
+// normalised, back to the directories as walked: on a filesystem that
+// keeps names decomposed, joining the key to root names no directory.
+func walkedDirs(root string, audioFiles []string) map[string]string {
+	dirs := map[string]string{".": root}
+	for _, p := range audioFiles {
+		for d := filepath.Dir(p); d != root && d != filepath.Dir(d); d = filepath.Dir(d) {
+			rel := nfc(filepath.ToSlash(relPath(root, d)))
+			if _, ok := dirs[rel]; ok || rel == "." || strings.HasPrefix(rel, "../") {
+				break
+			}
+			dirs[rel] = d
+		}
+	}
+	return dirs
+}
+
+// replaceFile writes content to a temporary file in path's directory and
+// renames it over path.
+func replaceFile(path string, content []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEmitDirFiles(t *testing.T) {
+	root := t.TempDir()
+	// The directory name is decomposed, as macOS hands it out; the
+	// totals are keyed by its composed form.
+	album := filepath.Join(root, "Cafe\u0301")
+	if err := os.Mkdir(album, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	track := filepath.Join(album, "a.mp3")
+	if err := os.WriteFile(track, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	victim := filepath.Join(t.TempDir(), "victim")
+	if err := os.WriteFile(victim, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(victim, filepath.Join(album, ".hours")); err != nil {
+		t.Skip("symlinks unavailable:", err)
+	}
+
+	files := []string{track}
+	totals := buildDaemonState(root, files, []result{{duration: 7200}}, time.Now()).dirs
+	written, failed := emitDirFiles(".hours", totals, root, files)
+	if written != 2 || len(failed) != 0 {
+		t.Fatalf("emitDirFiles = %d, %q; want 2, none", written, failed)
+	}
+	if got, _ := os.ReadFile(victim); string(got) != "keep" {
+		t.Errorf("symlink target overwritten: %q", got)
+	}
+	fi, err := os.Lstat(filepath.Join(album, ".hours"))
+	if err != nil || !fi.Mode().IsRegular() {
+		t.Fatalf("Lstat = %v, %v; want a regular file", fi, err)
+	}
+	got, _ := os.ReadFile(filepath.Join(album, ".hours"))
+	if want := dirFileContent(totals["Caf\u00e9"]); string(got) != string(want) {
+		t.Errorf("content = %q; want %q", got, want)
+	}
+
+	if written, _ := emitDirFiles(".hours", totals, root, files); written != 0 {
+		t.Errorf("second run wrote %d files; want 0", written)
+	}
+	entries, _ := os.ReadDir(album)
+	if len(entries) != 2 {
+		t.Errorf("album holds %d entries; want the track and .hours", len(entries))
+	}
+}
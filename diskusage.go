@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+type dirUsage struct {
+	dir     string
+	files   int
+	seconds float64
+	bytes   int64
+}
+
+// gbPerHour is the space each listening hour costs; +Inf for a folder of
+// files without a duration.
+func (d dirUsage) gbPerHour() float64 {
+	if d.seconds <= 0 {
+		return math.Inf(1)
+	}
+	return float64(d.bytes) / 1e9 / (d.seconds / 3600)
+}
+
+// buildDiskUsageReport sums hours and bytes per directory at depth below
+// the root, files higher up counting toward the folder holding them, and
+// orders the directories by GB per hour, the costliest first, since those
+// save the most space per hour when transcoded or moved to cold storage.
+func buildDiskUsageReport(root string, audioFiles []string, durations []float64, results []result, depth int) []dirUsage {
+	index := make(map[string]int)
+	var dirs []dirUsage
+	for i, p := range audioFiles {
+		parts := pathComponents(root, p)
+		if len(parts) > depth {
+			parts = parts[:depth]
+		}
+		dir := "."
+		if len(parts) > 0 {
+			dir = strings.Join(parts, "/")
+		}
+		n, ok := index[dir]
+		if !ok {
+			n = len(dirs)
+			index[dir] = n
+			dirs = append(dirs, dirUsage{dir: dir})
+		}
+		dirs[n].files++
+		dirs[n].seconds += max(durations[i], 0)
+		dirs[n].bytes += results[i].size
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		a, b := dirs[i].gbPerHour(), dirs[j].gbPerHour()
+		if a != b {
+			return a > b
+		}
+		if dirs[i].bytes != dirs[j].bytes {
+			return dirs[i].bytes > dirs[j].bytes
+		}
+		return dirs[i].dir < dirs[j].dir
+	})
+	return dirs
+}
+
+func printDiskUsageReport(dirs []dirUsage) {
+	fmt.Fprintln(console, "\n=== Disk usage ===")
+	if len(dirs) == 0 {
+		fmt.Fprintln(console, "No audio files.")
+		return
+	}
+	var total dirUsage
+	for _, d := range dirs {
+		total.files += d.files
+		total.seconds += d.seconds
+		total.bytes += d.bytes
+	}
+	line := func(d dirUsage) {
+		ratio := "-"
+		if r := d.gbPerHour(); !math.IsInf(r, 1) {
+			ratio = fmt.Sprintf("%.3f", r)
+		}
+		fmt.Fprintf(console, "%-40s %8d %10.2f %10.2f %8s %7.1f%%\n", d.dir, d.files, d.seconds/3600.0, float64(d.bytes)/1e9, ratio, percent(float64(d.bytes), float64(total.bytes)))
+	}
+	fmt.Fprintf(console, "%-40s %8s %10s %10s %8s %8s\n", "Directory", "Files", "Hours", "GB", "GB/hour", "Space")
+	for i, d := range dirs {
+		if i == maxListed {
+			fmt.Fprintf(console, "... and %d more\n", len(dirs)-maxListed)
+			break
+		}
+		line(d)
+	}
+	total.dir = "Total"
+	line(total)
+}
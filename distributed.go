@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Distributed scans: a coordinator started with --serve-work walks the tree
+// and hands out batches of relative paths over net/rpc; machines started
+// with --join probe them against their own mount of the same tree and send
+// back the results. Only the duration probe runs remotely, so the sample
+// passes (--deep, --speech-hours...) are not available in this mode.
+
+const (
+	// workBatchSize is how many files a joined worker asks for at once.
+	workBatchSize = 64
+	// workLease is how long a batch may stay unreported before it is handed
+	// to another worker, so a crashed machine does not stall the scan.
+	workLease = 5 * time.Minute
+)
+
+// WorkItem is one file to probe; Path is relative to the scan root.
+type WorkItem struct {
+	Index int
+	Path  string
+}
+
+// WorkRequest asks the coordinator for up to Max files.
+type WorkRequest struct {
+	Worker string
+	Max    int
+}
+
+// WorkBatch is the coordinator's answer. Done tells the worker to exit;
+// an empty batch that is not Done means "ask again shortly".
+type WorkBatch struct {
+	Items []WorkItem
+	Done  bool
+}
+
+// WorkResult is the probe outcome for one WorkItem.
+type WorkResult struct {
+	Index    int
+	Duration float64
+	Err      string
+	Prober   string
+	Size     int64
+	ModTime  time.Time
+}
+
+// ResultBatch returns finished items to the coordinator.
+type ResultBatch struct {
+	Worker  string
+	Results []WorkResult
+}
+
+// WorkQueue is the coordinator's RPC service.
+type WorkQueue struct {
+	mu      sync.Mutex
+	paths   []string
+	pending []int
+	leased  map[int]time.Time
+	results []result
+	have    []bool
+	left    int
+	done    chan struct{}
+	onDone  func(n int)
+}
+
+func newWorkQueue(root string, audioFiles []string, onDone func(n int)) *WorkQueue {
+	q := &WorkQueue{
+		paths:   make([]string, len(audioFiles)),
+		pending: make([]int, len(audioFiles)),
+		leased:  make(map[int]time.Time),
+		results: make([]result, len(audioFiles)),
+		have:    make([]bool, len(audioFiles)),
+		left:    len(audioFiles),
+		done:    make(chan struct{}),
+		onDone:  onDone,
+	}
+	for i, path := range audioFiles {
+		q.paths[i] = filepath.ToSlash(relPath(root, path))
+		q.pending[i] = i
+	}
+	if q.left == 0 {
+		close(q.done)
+	}
+	return q
+}
+
+// Next leases up to req.Max files to a worker.
+func (q *WorkQueue) Next(req WorkRequest, batch *WorkBatch) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.left == 0 {
+		batch.Done = true
+		return nil
+	}
+	// Expired leases go back to the queue.
+	for i, at := range q.leased {
+		if time.Since(at) > workLease {
+			delete(q.leased, i)
+			q.pending = append(q.pending, i)
+		}
+	}
+	n := min(max(req.Max, 1), len(q.pending))
+	for _, i := range q.pending[:n] {
+		batch.Items = append(batch.Items, WorkItem{Index: i, Path: q.paths[i]})
+		q.leased[i] = time.Now()
+	}
+	q.pending = q.pending[n:]
+	return nil
+}
+
+// Report stores finished results; duplicates from re-leased work are
+// ignored. finished tells the worker the whole scan is complete.
+func (q *WorkQueue) Report(batch ResultBatch, finished *bool) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stored := 0
+	for _, r := range batch.Results {
+		if r.Index < 0 || r.Index >= len(q.results) || q.have[r.Index] {
+			continue
+		}
+		res := result{index: r.Index, duration: r.Duration, prober: r.Prober, size: r.Size, modTime: r.ModTime}
+		if r.Err != "" {
+			res.err = errors.New(r.Err)
+		}
+		q.results[r.Index] = res
+		q.have[r.Index] = true
+		delete(q.leased, r.Index)
+		q.left--
+		stored++
+	}
+	if stored > 0 && q.onDone != nil {
+		q.onDone(stored)
+	}
+	if q.left == 0 && stored > 0 {
+		close(q.done)
+	}
+	*finished = q.left == 0
+	return nil
+}
+
+// serveWork runs the coordinator until every file has been reported.
+func serveWork(addr, root string, audioFiles []string, security workSecurity) ([]result, error) {
+	bar := newProgress(len(audioFiles))
+	queue := newWorkQueue(root, audioFiles, func(n int) { bar.Add(n) })
+
+	server := rpc.NewServer()
+	if err := server.Register(queue); err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(rpc.DefaultRPCPath, security.requireToken(server))
+	// The listener stays open while the report prints so that polling
+	// workers still learn the scan is done.
+	listener, err := security.listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(console, "Serving %d files on %s; start workers with --join %s <folder_path>\n\n", len(audioFiles), listener.Addr(), listener.Addr())
+	if tcp, ok := listener.Addr().(*net.TCPAddr); ok && !tcp.IP.IsLoopback() && !security.authenticated() {
+		fmt.Fprintln(os.Stderr, "Warning: any machine that can reach this address can take work and report results; set --auth-token-file or --tls-ca")
+	}
+	go http.Serve(listener, mux)
+
+	<-queue.done
+	bar.Finish()
+	fmt.Fprintln(console)
+	return queue.results, nil
+}
+
+// runJoin pulls work from a coordinator until it reports the scan done,
+// probing each path under the local root with the local worker count.
+func runJoin(addr, root string, opts options) error {
+	client, err := opts.security.dial(addr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	host, _ := os.Hostname()
+	name := fmt.Sprintf("%s/%d", host, os.Getpid())
+	fmt.Fprintf(console, "Joined %s as %s\n", addr, name)
+
+	probed := 0
+	for {
+		var batch WorkBatch
+		if err := client.Call("WorkQueue.Next", WorkRequest{Worker: name, Max: workBatchSize}, &batch); err != nil {
+			return err
+		}
+		if batch.Done {
+			fmt.Fprintf(console, "Coordinator finished; %d files probed here.\n", probed)
+			return nil
+		}
+		if len(batch.Items) == 0 {
+			time.Sleep(time.Second)
+			continue
+		}
+
+		report := ResultBatch{Worker: name, Results: make([]WorkResult, len(batch.Items))}
+		var wg sync.WaitGroup
+		work := make(chan int)
+		for w := 0; w < numWorkers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range work {
+					item := batch.Items[i]
+					res := processFile(fileJob{path: filepath.Join(root, filepath.FromSlash(item.Path)), index: item.Index}, opts)
+					out := WorkResult{Index: item.Index, Duration: res.duration, Prober: res.prober, Size: res.size, ModTime: res.modTime}
+					if res.err != nil {
+						out.Err = res.err.Error()
+					}
+					report.Results[i] = out
+				}
+			}()
+		}
+		for i := range batch.Items {
+			work <- i
+		}
+		close(work)
+		wg.Wait()
+
+		var finished bool
+		if err := client.Call("WorkQueue.Report", report, &finished); err != nil {
+			return err
+		}
+		probed += len(batch.Items)
+		if finished {
+			fmt.Fprintf(console, "Coordinator finished; %d files probed here.\n", probed)
+			return nil
+		}
+	}
+}
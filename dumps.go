@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// dumpScanBlock is how much of an MP3 stream dump is searched at a time,
+// and dumpScanOverlap how much of each block is searched again with the
+// next, so a marker across the seam is still seen whole.
+const (
+	dumpScanBlock   = 1 << 20
+	dumpScanOverlap = 4 << 10
+)
+
+// maxDumpTag bounds an ID3v2 tag read in the middle of a stream dump.
+const maxDumpTag = 1 << 20
+
+// dumpMarker is a track change found in an MP3 stream dump, at a byte
+// offset.
+type dumpMarker struct {
+	offset int64
+	length int64 // bytes the marker spans, not searched again
+	title  string
+}
+
+// readStreamTracks returns the tracks of a stream dump, or nil for files
+// with no internal boundary: one per chain of a chained Ogg file, and for
+// MP3s one per ID3v2 tag or ICY StreamTitle change met in the middle of
+// the stream.
+func readStreamTracks(filePath string, duration float64) []chapter {
+	var tracks []chapter
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".ogg":
+		tracks = oggStreamTracks(filePath)
+	case ".mp3":
+		tracks = mp3StreamTracks(filePath, duration)
+	}
+	if len(tracks) < 2 {
+		return nil
+	}
+	return finishChapters(tracks, duration)
+}
+
+// oggStreamTracks makes a track of each audio chain, titled from its
+// comment header.
+func oggStreamTracks(filePath string) []chapter {
+	file, err := openAudio(filePath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	o, err := parseOgg(file)
+	if err != nil {
+		return nil
+	}
+	var tracks []chapter
+	var start float64
+	for _, c := range o.chains {
+		seconds := c.seconds()
+		if seconds == 0 {
+			continue
+		}
+		tracks = append(tracks, chapter{title: c.title(), start: start, duration: seconds})
+		start += seconds
+	}
+	return tracks
+}
+
+// mp3StreamTracks places the markers of an MP3 dump in time by their share
+// of the file's bytes, which holds for the constant-bitrate streams radio
+// stations send. A repeated StreamTitle is not a new track.
+func mp3StreamTracks(filePath string, duration float64) []chapter {
+	file, err := openAudioWhole(filePath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return nil
+	}
+	markers, err := scanDumpMarkers(file)
+	if err != nil {
+		return nil
+	}
+	var tracks []chapter
+	if len(markers) == 0 || markers[0].offset > 0 {
+		tracks = append(tracks, chapter{})
+	}
+	for _, m := range markers {
+		if n := len(tracks); n > 0 && m.title != "" && tracks[n-1].title == m.title {
+			continue
+		}
+		tracks = append(tracks, chapter{title: m.title, start: duration * float64(m.offset) / float64(info.Size())})
+	}
+	return tracks
+}
+
+// scanDumpMarkers reads a whole MP3 for ID3v2 tag headers and ICY
+// metadata blocks.
+func scanDumpMarkers(file *audioFile) ([]dumpMarker, error) {
+	var markers []dumpMarker
+	buf := make([]byte, dumpScanBlock+dumpScanOverlap)
+	var base int64 // file offset of buf[0]
+	var skip int64 // file offset before which the last marker spans
+	carry := 0
+	for {
+		n, err := io.ReadFull(file, buf[carry:])
+		end := carry + n
+		last := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !last {
+			return nil, err
+		}
+		limit := end - dumpScanOverlap
+		if last {
+			limit = end
+		}
+		for i := 0; i < limit; {
+			at := indexMarker(buf[i:end])
+			if at < 0 || i+at >= limit {
+				break
+			}
+			i += at
+			if base+int64(i) >= skip {
+				if m, ok := readDumpMarker(file, buf[i:end], base+int64(i)); ok {
+					markers = append(markers, m)
+					skip = m.offset + m.length
+				}
+			}
+			i++
+		}
+		if last {
+			return markers, nil
+		}
+		carry = copy(buf, buf[limit:end])
+		base += int64(limit)
+	}
+}
+
+// indexMarker is the index of the next possible marker in b.
+func indexMarker(b []byte) int {
+	id3 := bytes.Index(b, []byte("ID3"))
+	icy := bytes.Index(b, []byte("StreamTitle='"))
+	switch {
+	case id3 < 0:
+		return icy
+	case icy < 0:
+		return id3
+	}
+	return min(id3, icy)
+}
+
+// readDumpMarker checks the marker at the start of b, which is at offset
+// in the file.
+func readDumpMarker(file *audioFile, b []byte, offset int64) (dumpMarker, bool) {
+	if rest, ok := bytes.CutPrefix(b, []byte("StreamTitle='")); ok {
+		end := bytes.Index(rest, []byte("';"))
+		if end < 0 {
+			return dumpMarker{}, false
+		}
+		return dumpMarker{offset: offset, length: int64(len("StreamTitle='") + end), title: nfc(string(rest[:end]))}, true
+	}
+	if len(b) < 10 || !bytes.HasPrefix(b, []byte("ID3")) || (b[3] != 3 && b[3] != 4) || b[4] == 0xFF {
+		return dumpMarker{}, false
+	}
+	for _, c := range b[6:10] {
+		if c >= 0x80 {
+			return dumpMarker{}, false
+		}
+	}
+	size := syncsafe(b[6:10])
+	if size == 0 || size > maxDumpTag {
+		return dumpMarker{}, false
+	}
+	tag := make([]byte, size)
+	if _, err := file.ReadAt(tag, offset+10); err != nil && !errors.Is(err, io.EOF) {
+		return dumpMarker{}, false
+	}
+	var title, artist string
+	for _, frame := range id3Frames(tag, b[3]) {
+		switch frame.id {
+		case "TIT2":
+			title = id3Text(frame.body)
+		case "TPE1":
+			artist = id3Text(frame.body)
+		}
+	}
+	if title != "" && artist != "" {
+		title = artist + " - " + title
+	}
+	return dumpMarker{offset: offset, length: 10 + size, title: title}, true
+}
+
+// printStreamTrackReport lists the stream dumps with internal track
+// boundaries, with each track's start, length and title.
+func printStreamTrackReport(root string, audioFiles []string, durations []float64, tracks [][]chapter) {
+	fmt.Fprintln(console, "\n=== Stream dump tracks ===")
+	var dumps, total int
+	var seconds float64
+	for i := range audioFiles {
+		if len(tracks[i]) > 0 {
+			dumps++
+			total += len(tracks[i])
+			seconds += max(durations[i], 0)
+		}
+	}
+	if dumps == 0 {
+		fmt.Fprintln(console, "No stream dumps with track boundaries.")
+		return
+	}
+	fmt.Fprintf(console, "Stream dumps: %d (%.2f hours, %d tracks)\n", dumps, seconds/3600.0, total)
+	for i, p := range audioFiles {
+		if len(tracks[i]) == 0 {
+			continue
+		}
+		fmt.Fprintf(console, "\n%s (%s, %d tracks)\n", relPath(root, p), formatClock(durations[i]), len(tracks[i]))
+		printList(len(tracks[i]), func(j int) string {
+			t := tracks[i][j]
+			return fmt.Sprintf("%3d. %s  %s  %s", j+1, formatClock(t.start), formatClock(t.duration), t.title)
+		})
+	}
+}
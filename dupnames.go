@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// duplicateName is one basename found in several directories with the
+// same duration.
+type duplicateName struct {
+	name     string
+	duration float64
+	paths    []string
+}
+
+// duplicateNameReport is a cheap duplication heuristic ahead of content
+// dedup: same file name and same duration in different places.
+type duplicateNameReport struct {
+	groups           []duplicateName
+	redundantFiles   int
+	redundantSeconds float64
+	tolerance        float64
+}
+
+// buildDuplicateNameReport groups files by case-folded basename, then by
+// duration in tolerance-wide buckets. Adjacent buckets are not merged, so a
+// pair straddling a boundary can be missed; the report is a heuristic.
+func buildDuplicateNameReport(audioFiles []string, durations []float64, tolerance float64) duplicateNameReport {
+	report := duplicateNameReport{tolerance: tolerance}
+	type key struct {
+		name   string
+		bucket int64
+	}
+	groups := make(map[key][]int)
+	for i, p := range audioFiles {
+		if durations[i] <= 0 {
+			continue
+		}
+		k := key{name: strings.ToLower(nfc(filepath.Base(p)))}
+		if tolerance > 0 {
+			k.bucket = int64(math.Round(durations[i] / tolerance))
+		} else {
+			k.bucket = int64(math.Float64bits(durations[i]))
+		}
+		groups[k] = append(groups[k], i)
+	}
+	for k, members := range groups {
+		if len(members) < 2 {
+			continue
+		}
+		g := duplicateName{name: k.name, duration: durations[members[0]]}
+		for _, i := range members {
+			g.paths = append(g.paths, audioFiles[i])
+		}
+		sort.Strings(g.paths)
+		report.groups = append(report.groups, g)
+		report.redundantFiles += len(members) - 1
+		report.redundantSeconds += float64(len(members)-1) * g.duration
+	}
+	sort.Slice(report.groups, func(i, j int) bool {
+		a, b := report.groups[i], report.groups[j]
+		if wa, wb := float64(len(a.paths)-1)*a.duration, float64(len(b.paths)-1)*b.duration; wa != wb {
+			return wa > wb
+		}
+		return a.name < b.name
+	})
+	return report
+}
+
+func printDuplicateNameReport(root string, report duplicateNameReport) {
+	fmt.Fprintln(console, "\n=== Duplicate file names ===")
+	fmt.Fprintf(console, "Names in several directories with the same duration (±%.1fs): %d\n", report.tolerance, len(report.groups))
+	if len(report.groups) == 0 {
+		return
+	}
+	fmt.Fprintf(console, "Likely redundant copies: %d files, %.2f hours\n", report.redundantFiles, report.redundantSeconds/3600.0)
+	for i, g := range report.groups {
+		if i == maxListed {
+			fmt.Fprintf(console, "... and %d more\n", len(report.groups)-maxListed)
+			break
+		}
+		fmt.Fprintf(console, "\n%s (%s, %d copies):\n", g.name, formatClock(g.duration), len(g.paths))
+		for _, p := range g.paths {
+			fmt.Fprintf(console, "  %s\n", relPath(root, p))
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Email delivery: with --email-to the summary of a finished scan is mailed
+// through the SMTP server of an --smtp-config file, for scheduled scans on
+// headless machines whose output nobody reads. --email-html attaches an
+// HTML report with the hours per top-level folder and the failed files.
+
+// smtpConfig is an --smtp-config file:
+//
+//	host: smtp.example.com
+//	port: 587                  # default 587, or 465 with tls: tls
+//	username: nas@example.com  # omit for servers without authentication
+//	password_file: /etc/howmanyhours/smtp-password
+//	from: NAS <nas@example.com>
+//	tls: starttls              # starttls (default), tls or none
+type smtpConfig struct {
+	Host         string `yaml:"host"`
+	Port         int    `yaml:"port"`
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+	From         string `yaml:"from"`
+	TLS          string `yaml:"tls"`
+}
+
+func loadSMTPConfig(path string) (*smtpConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c smtpConfig
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	switch c.TLS {
+	case "":
+		c.TLS = "starttls"
+	case "starttls", "tls", "none":
+	default:
+		return nil, fmt.Errorf("%s: unsupported tls: %s (want starttls, tls or none)", path, c.TLS)
+	}
+	if c.Host == "" || c.From == "" {
+		return nil, fmt.Errorf("%s: host and from are required", path)
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return nil, fmt.Errorf("%s: invalid from address: %w", path, err)
+	}
+	if c.Port == 0 {
+		c.Port = 587
+		if c.TLS == "tls" {
+			c.Port = 465
+		}
+	}
+	if c.PasswordFile != "" {
+		secret, err := os.ReadFile(c.PasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		c.Password = strings.TrimSpace(string(secret))
+	}
+	return &c, nil
+}
+
+// parseRecipients reads the comma-separated --email-to list.
+func parseRecipients(s string) ([]string, error) {
+	list, err := mail.ParseAddressList(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --email-to: %w", err)
+	}
+	addrs := make([]string, len(list))
+	for i, a := range list {
+		addrs[i] = a.Address
+	}
+	return addrs, nil
+}
+
+// emailSubject leads with the total so the inbox list alone answers the
+// question.
+func emailSubject(s scanSummary) string {
+	subject := fmt.Sprintf("howManyHours: %.2f hours in %s", s.TotalHours, filepath.Base(s.Root))
+	if s.Errors > 0 {
+		subject += fmt.Sprintf(" (%d failed)", s.Errors)
+	}
+	return subject
+}
+
+func emailBody(s scanSummary, elapsed time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Scan of %s finished %s after %s.\n\n", s.Root, s.Time.Add(elapsed).Format(time.RFC3339), elapsed.Round(time.Second))
+	fmt.Fprintf(&b, "Files: %d\n", s.Files)
+	fmt.Fprintf(&b, "Processed: %d\n", s.Processed)
+	fmt.Fprintf(&b, "Zero duration: %d\n", s.Zero)
+	fmt.Fprintf(&b, "Errors: %d\n", s.Errors)
+	if s.Placeholders > 0 {
+		fmt.Fprintf(&b, "Cloud placeholders: %d\n", s.Placeholders)
+	}
+	if s.Provisional > 0 {
+		fmt.Fprintf(&b, "Still being written: %d\n", s.Provisional)
+	}
+	fmt.Fprintf(&b, "\nTotal: %.2f hours (%s)\n", s.TotalHours, formatClock(s.TotalSeconds))
+	fmt.Fprintf(&b, "Mean file: %s, median %s\n", formatClock(s.MeanSeconds), formatClock(s.MedianSecs))
+	return b.String()
+}
+
+var emailReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"clock": formatClock,
+	"hours": func(seconds float64) string { return strconv.FormatFloat(seconds/3600, 'f', 2, 64) },
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Summary.Root}}</title>
+<style>body{font-family:sans-serif}table{border-collapse:collapse}td,th{padding:2px 10px;text-align:right}td:first-child,th:first-child{text-align:left}</style>
+</head><body>
+<h1>{{printf "%.2f" .Summary.TotalHours}} hours in {{.Summary.Root}}</h1>
+<p>Scanned {{.Summary.Time.Format "2006-01-02 15:04 MST"}}: {{.Summary.Files}} files, {{.Summary.Processed}} processed, {{.Summary.Zero}} zero duration, {{.Summary.Errors}} failed.</p>
+<h2>Folders</h2>
+<table><tr><th>Folder</th><th>Files</th><th>Hours</th><th>Duration</th></tr>
+{{range .Folders}}<tr><td>{{.Key}}</td><td>{{.Files}}</td><td>{{hours .Seconds}}</td><td>{{clock .Seconds}}</td></tr>
+{{end}}</table>
+{{if .Failed}}<h2>Failed files</h2>
+<ul>{{range .Failed}}<li>{{.Path}}: {{.Error}}</li>
+{{end}}</ul>{{end}}
+</body></html>
+`))
+
+type emailFolder struct {
+	Key     string
+	Files   int
+	Seconds float64
+}
+
+// emailReport renders the --email-html attachment from the summary, with
+// files directly in the root under ".".
+func emailReport(s scanSummary) ([]byte, error) {
+	index := make(map[string]int)
+	var folders []emailFolder
+	var failed []fileSummary
+	for _, f := range s.Results {
+		if f.Error != "" {
+			failed = append(failed, f)
+		}
+		key := "."
+		if dir, _, ok := strings.Cut(filepath.ToSlash(f.Path), "/"); ok {
+			key = dir
+		}
+		i, seen := index[key]
+		if !seen {
+			i = len(folders)
+			index[key] = i
+			folders = append(folders, emailFolder{Key: key})
+		}
+		folders[i].Files++
+		folders[i].Seconds += max(f.Duration, 0)
+	}
+	sort.Slice(folders, func(i, j int) bool {
+		if folders[i].Seconds != folders[j].Seconds {
+			return folders[i].Seconds > folders[j].Seconds
+		}
+		return folders[i].Key < folders[j].Key
+	})
+	var out bytes.Buffer
+	err := emailReportTemplate.Execute(&out, struct {
+		Summary scanSummary
+		Folders []emailFolder
+		Failed  []fileSummary
+	}{s, folders, failed})
+	return out.Bytes(), err
+}
+
+// buildEmail assembles the message: plain text alone, or multipart/mixed
+// with the HTML report attached.
+func buildEmail(from string, to []string, subject, body string, html []byte) []byte {
+	var msg bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&msg, "%s: %s\r\n", k, v) }
+	header("From", from)
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	text := strings.ReplaceAll(body, "\n", "\r\n")
+	if html == nil {
+		header("Content-Type", "text/plain; charset=utf-8")
+		msg.WriteString("\r\n" + text)
+		return msg.Bytes()
+	}
+	var b [12]byte
+	rand.Read(b[:])
+	boundary := "hmh-" + hex.EncodeToString(b[:])
+	header("Content-Type", `multipart/mixed; boundary="`+boundary+`"`)
+	fmt.Fprintf(&msg, "\r\n--%s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n", boundary, text)
+	fmt.Fprintf(&msg, "--%s\r\nContent-Type: text/html; charset=utf-8\r\nContent-Transfer-Encoding: base64\r\nContent-Disposition: attachment; filename=\"howmanyhours-report.html\"\r\n\r\n", boundary)
+	encoded := base64.StdEncoding.EncodeToString(html)
+	for len(encoded) > 76 {
+		msg.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	msg.WriteString(encoded + "\r\n")
+	fmt.Fprintf(&msg, "--%s--\r\n", boundary)
+	return msg.Bytes()
+}
+
+// sendEmail mails the summary of a finished scan to every --email-to
+// recipient.
+func sendEmail(c *smtpConfig, to []string, s scanSummary, elapsed time.Duration, attachHTML bool) error {
+	var html []byte
+	if attachHTML {
+		var err error
+		if html, err = emailReport(s); err != nil {
+			return err
+		}
+	}
+	from, _ := mail.ParseAddress(c.From)
+	msg := buildEmail(c.From, to, emailSubject(s), emailBody(s, elapsed), html)
+
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	conn, err := net.DialTimeout("tcp", addr, 30*time.Second)
+	if err != nil {
+		return err
+	}
+	if c.TLS == "tls" {
+		conn = tls.Client(conn, &tls.Config{ServerName: c.Host})
+	}
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if c.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not offer STARTTLS (set tls: none to send in the clear)", c.Host)
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: c.Host}); err != nil {
+			return err
+		}
+	}
+	if c.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.Username, c.Password, c.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// stratum groups files that are expected to have similar durations: the
+// same top-level directory and extension.
+type stratum struct {
+	key     string
+	size    int   // files in the population
+	sampled []int // indices into the sampled file list
+}
+
+// parseSampleFraction accepts "5%" or "0.05".
+func parseSampleFraction(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	scale := 1.0
+	if strings.HasSuffix(s, "%") {
+		s = strings.TrimSuffix(s, "%")
+		scale = 100
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sample size %q", s)
+	}
+	v /= scale
+	if v <= 0 || v > 1 {
+		return 0, fmt.Errorf("sample size must be between 0 and 100%%")
+	}
+	return v, nil
+}
+
+func stratumKey(root, path string) string {
+	rel := filepath.ToSlash(relPath(root, path))
+	top := "."
+	if i := strings.IndexByte(rel, '/'); i >= 0 {
+		top = rel[:i]
+	}
+	return top + "|" + strings.ToLower(filepath.Ext(path))
+}
+
+// sampleFiles draws a stratified random sample. Every stratum contributes
+// at least two files (when it has them) so its variance can be estimated.
+func sampleFiles(root string, files []string, fraction float64, seed int64) ([]string, []stratum) {
+	byKey := make(map[string][]string)
+	for _, f := range files {
+		key := stratumKey(root, f)
+		byKey[key] = append(byKey[key], f)
+	}
+	keys := make([]string, 0, len(byKey))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	rng := rand.New(rand.NewSource(seed))
+	var sample []string
+	strata := make([]stratum, 0, len(keys))
+	for _, k := range keys {
+		members := byKey[k]
+		n := int(math.Ceil(fraction * float64(len(members))))
+		n = min(max(n, 2), len(members))
+
+		s := stratum{key: k, size: len(members)}
+		for _, i := range rng.Perm(len(members))[:n] {
+			s.sampled = append(s.sampled, len(sample))
+			sample = append(sample, members[i])
+		}
+		strata = append(strata, s)
+	}
+	return sample, strata
+}
+
+// dropFromStrata removes the sampled files marked in dropped, which are
+// indices into the sample, renumbering the rest to match the shortened
+// list. Dropped files leave their stratum's population too: they are not
+// counted, sampled or not.
+func dropFromStrata(strata []stratum, dropped []bool) []stratum {
+	index := make([]int, len(dropped))
+	n := 0
+	for i, d := range dropped {
+		index[i] = -1
+		if !d {
+			index[i] = n
+			n++
+		}
+	}
+	out := make([]stratum, len(strata))
+	for k, s := range strata {
+		out[k] = stratum{key: s.key, size: s.size}
+		for _, i := range s.sampled {
+			if index[i] < 0 {
+				out[k].size--
+				continue
+			}
+			out[k].sampled = append(out[k].sampled, index[i])
+		}
+	}
+	return out
+}
+
+type estimateReport struct {
+	population int
+	sampled    int
+	strata     int
+	total      float64 // seconds
+	margin     float64 // 95% half-width in seconds
+}
+
+// buildEstimate extrapolates the population total with the standard
+// stratified estimator. Files that failed to probe count as zero, as they
+// would in an exact scan.
+func buildEstimate(strata []stratum, durations []float64) estimateReport {
+	report := estimateReport{strata: len(strata)}
+	var variance float64
+	for _, s := range strata {
+		report.population += s.size
+		report.sampled += len(s.sampled)
+		if len(s.sampled) == 0 {
+			continue // every sampled file was dropped
+		}
+
+		n := float64(len(s.sampled))
+		var mean float64
+		for _, i := range s.sampled {
+			mean += durations[i]
+		}
+		mean /= n
+		report.total += float64(s.size) * mean
+
+		if len(s.sampled) < 2 {
+			continue
+		}
+		var ss float64
+		for _, i := range s.sampled {
+			ss += (durations[i] - mean) * (durations[i] - mean)
+		}
+		sVar := ss / (n - 1)
+		N := float64(s.size)
+		variance += N * N * (1 - n/N) * sVar / n
+	}
+	report.margin = 1.96 * math.Sqrt(variance)
+	return report
+}
+
+func printEstimate(report estimateReport) {
+	fmt.Fprintln(console, "\n=== Estimate ===")
+	fmt.Fprintf(console, "Sampled files: %d of %d (%.1f%%) across %d strata\n",
+		report.sampled, report.population, percent(float64(report.sampled), float64(report.population)), report.strata)
+	fmt.Fprintf(console, "Estimated total audio duration: %.2f hours (95%% CI %.2f - %.2f)\n",
+		report.total/3600.0, max(report.total-report.margin, 0)/3600.0, (report.total+report.margin)/3600.0)
+	if report.population > 0 {
+		fmt.Fprintf(console, "Estimated mean duration per file: %.2f minutes\n", report.total/float64(report.population)/60)
+	}
+}
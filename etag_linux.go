@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// etagAttrs are the extended attribute names object-store FUSE mounts
+// publish the ETag under.
+var etagAttrs = []string{"user.s3.etag", "user.etag", "user.ETag"}
+
+// objectETag returns the object ETag a FUSE mount exposes for path, or ""
+// for local files and mounts without one.
+func objectETag(path string) string {
+	buf := make([]byte, 256)
+	for _, attr := range etagAttrs {
+		if n, err := unix.Getxattr(path, attr, buf); err == nil && n > 0 {
+			return strings.Trim(string(buf[:n]), "\"\x00")
+		}
+	}
+	return ""
+}
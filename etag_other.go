@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+// objectETag returns ""; ETags are only read from Linux extended
+// attributes, so other systems fall back to modification times.
+func objectETag(path string) string {
+	return ""
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// expectedCount is one directory's entry in an --expect-counts contract.
+// Either figure may be left out.
+//
+//	train/spk001: {files: 120, hours: 2.5}
+//	test: {hours: 10}
+type expectedCount struct {
+	Files *int     `yaml:"files"`
+	Hours *float64 `yaml:"hours"`
+}
+
+// loadExpectedCounts reads an --expect-counts YAML file of directory paths,
+// relative to the scanned root, and what each should hold. "." is the
+// whole tree.
+func loadExpectedCounts(path string) (map[string]expectedCount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]expectedCount
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	expected := make(map[string]expectedCount, len(raw))
+	for dir, want := range raw {
+		if want.Files == nil && want.Hours == nil {
+			return nil, fmt.Errorf("%s: %q expects neither files nor hours", path, dir)
+		}
+		if (want.Files != nil && *want.Files < 0) || (want.Hours != nil && *want.Hours < 0) {
+			return nil, fmt.Errorf("%s: %q has a negative expectation", path, dir)
+		}
+		expected[expectedDir(dir)] = want
+	}
+	return expected, nil
+}
+
+// expectedDir normalizes a contract directory to forward slashes with no
+// leading "./" or trailing slash.
+func expectedDir(dir string) string {
+	dir = strings.Trim(strings.ReplaceAll(nfc(dir), "\\", "/"), "/")
+	for strings.HasPrefix(dir, "./") {
+		dir = strings.TrimPrefix(dir, "./")
+	}
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
+
+type completenessRow struct {
+	dir          string
+	want         expectedCount
+	files        int
+	seconds      float64
+	filesOK      bool
+	hoursOK      bool
+	missingEntry bool // no audio file at all under the directory
+}
+
+type completenessReport struct {
+	rows      []completenessRow
+	tolerance float64 // percent of the expected hours
+	failed    int
+}
+
+// buildCompletenessReport counts the audio files and hours under each
+// contract directory, subdirectories included. Files that could not be
+// measured count as files but add no hours.
+func buildCompletenessReport(root string, audioFiles []string, durations []float64, expected map[string]expectedCount, tolerance float64) completenessReport {
+	report := completenessReport{tolerance: tolerance}
+	got := make(map[string]*completenessRow, len(expected))
+	for dir, want := range expected {
+		got[dir] = &completenessRow{dir: dir, want: want}
+	}
+	for i, path := range audioFiles {
+		parts := pathComponents(root, path)
+		if row := got["."]; row != nil {
+			row.files++
+			row.seconds += max(durations[i], 0)
+		}
+		for n := 1; n <= len(parts); n++ {
+			if row := got[strings.Join(parts[:n], "/")]; row != nil {
+				row.files++
+				row.seconds += max(durations[i], 0)
+			}
+		}
+	}
+	for _, row := range got {
+		row.filesOK = row.want.Files == nil || row.files == *row.want.Files
+		row.hoursOK = true
+		if row.want.Hours != nil {
+			want := *row.want.Hours
+			row.hoursOK = math.Abs(row.seconds/3600.0-want) <= want*tolerance/100
+		}
+		row.missingEntry = row.files == 0
+		if !row.filesOK || !row.hoursOK {
+			report.failed++
+		}
+		report.rows = append(report.rows, *row)
+	}
+	sort.Slice(report.rows, func(i, j int) bool { return report.rows[i].dir < report.rows[j].dir })
+	return report
+}
+
+func printCompletenessReport(report completenessReport) {
+	fmt.Fprintln(console, "\n=== Expected counts ===")
+	fmt.Fprintf(console, "%-30s %8s %8s %8s %10s %10s %9s  %s\n", "Directory", "Files", "Expected", "Delta", "Hours", "Expected", "Delta", "Status")
+	for _, r := range report.rows {
+		wantFiles, deltaFiles := "-", "-"
+		if r.want.Files != nil {
+			wantFiles = fmt.Sprintf("%d", *r.want.Files)
+			deltaFiles = fmt.Sprintf("%+d", r.files-*r.want.Files)
+		}
+		wantHours, deltaHours := "-", "-"
+		if r.want.Hours != nil {
+			wantHours = fmt.Sprintf("%.2f", *r.want.Hours)
+			deltaHours = fmt.Sprintf("%+.2f", r.seconds/3600.0-*r.want.Hours)
+		}
+		status := "ok"
+		switch {
+		case r.missingEntry && (!r.filesOK || !r.hoursOK):
+			status = "MISSING"
+		case !r.filesOK && !r.hoursOK:
+			status = "FILES, HOURS"
+		case !r.filesOK:
+			status = "FILES"
+		case !r.hoursOK:
+			status = "HOURS"
+		}
+		fmt.Fprintf(console, "%-30s %8d %8s %8s %10.2f %10s %9s  %s\n", r.dir, r.files, wantFiles, deltaFiles, r.seconds/3600.0, wantHours, deltaHours, status)
+	}
+	if report.failed == 0 {
+		fmt.Fprintf(console, "All %d directories match (hours within %.1f%%).\n", len(report.rows), report.tolerance)
+		return
+	}
+	fmt.Fprintf(console, "Warning: %d of %d directories do not match the expected counts (hours within %.1f%%)\n", report.failed, len(report.rows), report.tolerance)
+}
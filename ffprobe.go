@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ffprobeTimeout bounds a single ffprobe invocation so a hung decoder
+// cannot stall a worker forever.
+const ffprobeTimeout = 2 * time.Minute
+
+// ffprobeDuration asks ffprobe for the container duration.
+func ffprobeDuration(filePath string) (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ffprobeTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "json",
+		contentPath(filePath),
+	).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return 0, fmt.Errorf("ffprobe: %s", exitErr.Stderr)
+		}
+		return 0, fmt.Errorf("ffprobe: %w", err)
+	}
+
+	var probe struct {
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return 0, fmt.Errorf("ffprobe: %w", err)
+	}
+	duration, err := strconv.ParseFloat(probe.Format.Duration, 64)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("ffprobe: no duration reported")
+	}
+	return duration, nil
+}
+
+// plausibleDuration rejects the values corrupt headers produce: NaN,
+// infinities and negative durations.
+func plausibleDuration(d float64) bool {
+	return !math.IsNaN(d) && !math.IsInf(d, 0) && d >= 0
+}
+
+// probeDuration tries the libav backend when selected, the native prober,
+// then a plugin for the extension, then the configured fallback backend. It
+// reports which one produced the duration along with any metadata a plugin
+// returned.
+func probeDuration(filePath string, opts options) (float64, string, map[string]any, error) {
+	if opts.backend == "libav" {
+		if d, err := libavDuration(filePath); err == nil {
+			return d, "libav", nil, nil
+		}
+	}
+	duration, err := getAudioDuration(filePath)
+	if err == nil && !plausibleDuration(duration) {
+		err = fmt.Errorf("implausible duration %v", duration)
+	}
+	if err == nil {
+		return duration, "native", nil, nil
+	}
+	if plugin, ok := opts.plugins[strings.ToLower(filepath.Ext(filePath))]; ok {
+		out, perr := pluginProbe(plugin, filePath)
+		if perr == nil {
+			return out.Duration, "plugin", out.Metadata, nil
+		}
+		err = perr
+	}
+	if opts.fallback == "ffprobe" {
+		if d, ferr := ffprobeDuration(filePath); ferr == nil {
+			return d, "ffprobe", nil, nil
+		}
+	}
+	return 0, "", nil, err
+}
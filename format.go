@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/tcolgate/mp3"
+)
+
+// streamFormat is the codec and sample rate of a file's audio, as far as
+// its headers tell.
+type streamFormat struct {
+	codec      string
+	sampleRate int
+}
+
+// probeFormat reads the stream format from the file headers, preferring
+// whatever a plugin already reported in its metadata.
+func probeFormat(filePath string, metadata map[string]any) streamFormat {
+	var f streamFormat
+	if codec, ok := metadata["codec"].(string); ok {
+		f.codec = codec
+	}
+	if rate, ok := metadata["sample_rate"].(float64); ok {
+		f.sampleRate = int(rate)
+	}
+	if f.codec != "" && f.sampleRate > 0 {
+		return f
+	}
+
+	file, err := openAudio(filePath)
+	if err != nil {
+		return f
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".wav":
+		if decoder, err := newWAVDecoder(file); err == nil && decoder.IsValidFile() {
+			f.codec = "pcm"
+			if decoder.WavAudioFormat != 1 {
+				f.codec = "wav"
+			}
+			f.sampleRate = int(decoder.SampleRate)
+		}
+	case ".mp3":
+		var frame mp3.Frame
+		var skipped int
+		if mp3.NewDecoder(file).Decode(&frame, &skipped) == nil {
+			f.codec = "mp3"
+			f.sampleRate = int(frame.Header().SampleRate())
+		}
+	case ".m4a", ".m4b", ".aax":
+		if info, err := parseMP4(file); err == nil {
+			// AAX audio is Audible-encrypted AAC; only its headers are read.
+			f.codec = "aac"
+			if strings.EqualFold(filepath.Ext(filePath), ".aax") {
+				f.codec = "aax"
+			}
+			// An audio track's media timescale is its sample rate.
+			for _, t := range info.tracks {
+				if t.handler == "soun" {
+					f.sampleRate = int(t.timescale)
+					break
+				}
+			}
+		}
+	}
+	return f
+}
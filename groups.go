@@ -0,0 +1,66 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// groupStat aggregates the files sharing a grouping key (a folder, label,
+// speaker, split...).
+type groupStat struct {
+	key     string
+	files   int
+	seconds float64
+}
+
+// groupDurations sums durations per key. keyOf returns false for files that
+// belong to no group.
+func groupDurations(audioFiles []string, durations []float64, keyOf func(path string) (string, bool)) []groupStat {
+	index := make(map[string]int)
+	var groups []groupStat
+	for i, path := range audioFiles {
+		key, ok := keyOf(path)
+		if !ok {
+			continue
+		}
+		g, seen := index[key]
+		if !seen {
+			g = len(groups)
+			index[key] = g
+			groups = append(groups, groupStat{key: key})
+		}
+		groups[g].files++
+		groups[g].seconds += durations[i]
+	}
+	return groups
+}
+
+// sortGroupsBySeconds orders groups longest first, then by key.
+func sortGroupsBySeconds(groups []groupStat) {
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].seconds != groups[j].seconds {
+			return groups[i].seconds > groups[j].seconds
+		}
+		return groups[i].key < groups[j].key
+	})
+}
+
+// pathComponents splits the directory part of path, relative to root, into
+// its folder names.
+func pathComponents(root, path string) []string {
+	dir := nfc(filepath.ToSlash(filepath.Dir(relPath(root, path))))
+	if dir == "." || dir == "" {
+		return nil
+	}
+	return strings.Split(dir, "/")
+}
+
+// topLevelDir returns the first folder below root containing path.
+func topLevelDir(root, path string) (string, bool) {
+	parts := pathComponents(root, path)
+	if len(parts) == 0 {
+		return "", false
+	}
+	return parts[0], true
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// --growing modes for files still being written when the scan sees them.
+const (
+	growingProvisional = "provisional" // count them, but flag the totals as provisional
+	growingSkip        = "skip"        // leave them out of the totals
+	growingRetry       = "retry"       // re-probe them at the end once their size settles
+)
+
+// growingRetries is how many times retry mode waits --growing-settle for a
+// file to stop changing before reporting it provisional.
+const growingRetries = 3
+
+const auditGrowing = "growing" // still being written (--growing skip)
+
+func checkGrowingMode(mode string) error {
+	switch mode {
+	case "", growingProvisional, growingSkip, growingRetry:
+		return nil
+	}
+	return fmt.Errorf("unsupported --growing mode: %s (want provisional, skip or retry)", mode)
+}
+
+// matchesGrowingGlob reports whether path matches one of the --growing-glob
+// patterns, tried against both the file name and the slash-separated path
+// relative to the root.
+func matchesGrowingGlob(root, p string, globs []string) bool {
+	rel := filepath.ToSlash(relPath(root, p))
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, filepath.Base(p)); ok {
+			return true
+		}
+		if ok, _ := path.Match(glob, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fileState is what a file looked like at one stat.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+func (s fileState) same(o fileState) bool {
+	return s.size == o.size && s.modTime.Equal(o.modTime)
+}
+
+func statFile(p string) (fileState, bool) {
+	info, err := os.Stat(contentPath(p))
+	if err != nil {
+		return fileState{}, false
+	}
+	return fileState{info.Size(), info.ModTime()}, true
+}
+
+// findGrowing marks the files that were still being written during the
+// scan: those matching --growing-glob, those whose size at probe time
+// differs from the walk's, and those that changed since they were probed.
+// Files modified within settle of the check are looked at again once
+// settle has passed, so a recording flushed every few seconds is caught.
+func findGrowing(root string, audioFiles []string, sizes map[string]int64, results []result, globs []string, settle time.Duration) []bool {
+	growing := make([]bool, len(audioFiles))
+	probed := func(i int) fileState { return fileState{results[i].size, results[i].modTime} }
+
+	var recent []int
+	checked := time.Now()
+	for i, p := range audioFiles {
+		if matchesGrowingGlob(root, p, globs) {
+			growing[i] = true
+			continue
+		}
+		if size, ok := sizes[p]; ok && results[i].size != size && !results[i].modTime.IsZero() {
+			growing[i] = true
+			continue
+		}
+		now, ok := statFile(p)
+		switch {
+		case !ok:
+		case !now.same(probed(i)):
+			growing[i] = true
+		case checked.Sub(now.modTime) < settle:
+			recent = append(recent, i)
+		}
+	}
+	if len(recent) > 0 {
+		time.Sleep(settle)
+		for _, i := range recent {
+			if now, ok := statFile(audioFiles[i]); ok && !now.same(probed(i)) {
+				growing[i] = true
+			}
+		}
+	}
+	return growing
+}
+
+// retryGrowing waits for files that changed during the scan to settle and
+// probes them again. A file counts as settled when its size and mtime are
+// unchanged across one settle interval. Files matching --growing-glob are
+// named as in progress, so they are never retried. It returns what is still
+// growing after growingRetries rounds.
+func retryGrowing(root string, audioFiles []string, results []result, growing []bool, opts options) []bool {
+	last := make(map[int]fileState)
+	for i, g := range growing {
+		if g && !matchesGrowingGlob(root, audioFiles[i], opts.growingGlobs) {
+			last[i], _ = statFile(audioFiles[i])
+		}
+	}
+	for round := 0; round < growingRetries && len(last) > 0; round++ {
+		printf("Waiting %s for %d files still being written...\n", opts.growingSettle, len(last))
+		time.Sleep(opts.growingSettle)
+		for i, before := range last {
+			now, ok := statFile(audioFiles[i])
+			if !ok {
+				delete(last, i)
+				continue
+			}
+			if !now.same(before) {
+				last[i] = now
+				continue
+			}
+			res := processFile(fileJob{path: audioFiles[i], index: i}, opts)
+			if now.same(fileState{res.size, res.modTime}) {
+				results[i] = res
+				growing[i] = false
+				delete(last, i)
+			} else {
+				last[i] = fileState{res.size, res.modTime}
+			}
+		}
+	}
+	return growing
+}
+
+// dropGrowing removes the growing files from a scan (--growing skip).
+func dropGrowing(audioFiles []string, results []result, growing []bool, audit *auditLog) ([]string, []result) {
+	var keptFiles []string
+	var keptResults []result
+	for i, p := range audioFiles {
+		if growing[i] {
+			audit.skipped(p, auditGrowing, "")
+			continue
+		}
+		keptFiles = append(keptFiles, p)
+		keptResults = append(keptResults, results[i])
+	}
+	return keptFiles, keptResults
+}
+
+func printGrowingReport(root string, audioFiles []string, durations []float64, growing []bool) {
+	var paths []string
+	var seconds float64
+	for i, g := range growing {
+		if g {
+			paths = append(paths, audioFiles[i])
+			seconds += durations[i]
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	fmt.Fprintln(console, "\n=== Provisional: still being written ===")
+	fmt.Fprintf(console, "%d files (%.2f hours so far) were growing during the scan; their durations will still increase.\n", len(paths), seconds/3600.0)
+	printPathList(root, paths)
+}
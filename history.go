@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// historyEntry is one line of the --history JSONL file.
+type historyEntry struct {
+	Time         time.Time `json:"time"`
+	Root         string    `json:"root"`
+	Files        int       `json:"files"`
+	TotalSeconds float64   `json:"total_seconds"`
+}
+
+// readHistory returns the recorded scans of root, oldest first. A missing
+// file is an empty history.
+func readHistory(path, root string) ([]historyEntry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		if e.Root == root {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func appendHistory(path string, e historyEntry) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// collectionRate fits a least-squares line through the history and returns
+// its slope in seconds of audio per second of wall time.
+func collectionRate(entries []historyEntry) (float64, bool) {
+	if len(entries) < 2 {
+		return 0, false
+	}
+	t0 := entries[0].Time
+	var sx, sy, sxx, sxy float64
+	for _, e := range entries {
+		x := e.Time.Sub(t0).Seconds()
+		sx += x
+		sy += e.TotalSeconds
+		sxx += x * x
+		sxy += x * e.TotalSeconds
+	}
+	n := float64(len(entries))
+	denom := n*sxx - sx*sx
+	if denom == 0 {
+		return 0, false
+	}
+	return (n*sxy - sx*sy) / denom, true
+}
+
+type goalReport struct {
+	goalSeconds   float64
+	totalSeconds  float64
+	history       []historyEntry
+	hasHistory    bool
+	rate          float64 // audio seconds per wall second
+	projectedDate time.Time
+}
+
+func buildGoalReport(goalHours, totalSeconds float64, history []historyEntry, now time.Time) goalReport {
+	report := goalReport{goalSeconds: goalHours * 3600, totalSeconds: totalSeconds, history: history}
+	rate, ok := collectionRate(history)
+	report.hasHistory = ok
+	report.rate = rate
+	if remaining := report.goalSeconds - totalSeconds; ok && rate > 0 && remaining > 0 {
+		report.projectedDate = now.Add(time.Duration(remaining / rate * float64(time.Second)))
+	}
+	return report
+}
+
+func printGoalReport(report goalReport) {
+	fmt.Fprintln(console, "\n=== Goal ===")
+	fmt.Fprintf(console, "Target: %.2f hours\n", report.goalSeconds/3600.0)
+	fmt.Fprintf(console, "Collected: %.2f hours (%.1f%%)\n", report.totalSeconds/3600.0, percent(report.totalSeconds, report.goalSeconds))
+
+	remaining := report.goalSeconds - report.totalSeconds
+	if remaining <= 0 {
+		fmt.Fprintln(console, "Goal reached.")
+		return
+	}
+	fmt.Fprintf(console, "Remaining: %.2f hours\n", remaining/3600.0)
+
+	switch {
+	case !report.hasHistory:
+		fmt.Fprintln(console, "Projection: needs at least two scans recorded with --history")
+	case report.rate <= 0:
+		fmt.Fprintf(console, "Collection rate: %.2f hours/day over %d scans; no projection\n", report.rate*86400/3600, len(report.history))
+	default:
+		fmt.Fprintf(console, "Collection rate: %.2f hours/day over %d scans since %s\n",
+			report.rate*86400/3600, len(report.history), report.history[0].Time.Format("2006-01-02"))
+		fmt.Fprintf(console, "Projected completion: %s\n", report.projectedDate.Format("2006-01-02"))
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxManifestSize caps how much of an HLS playlist or DASH MPD is read.
+const maxManifestSize = 16 << 20
+
+// maxPlaylistDepth bounds master playlists that point at other masters.
+const maxPlaylistDepth = 4
+
+const auditVariant = "playlist-variant" // counted through the master playlist that lists it
+
+// isStreamURL reports whether p is an http(s) URL of an HLS or DASH
+// manifest, which may stand in for <folder_path>.
+func isStreamURL(p string) bool {
+	u, err := url.Parse(p)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".m3u8", ".mpd":
+		return true
+	}
+	return false
+}
+
+// streamManifestExt is the lower-case extension of a manifest path or URL,
+// ignoring any query string.
+func streamManifestExt(p string) string {
+	if u, err := url.Parse(p); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return strings.ToLower(path.Ext(u.Path))
+	}
+	return strings.ToLower(filepath.Ext(p))
+}
+
+// readStreamManifest reads a manifest from a local path, through the
+// accounted audio reads, or over HTTP, counted against --max-bytes too.
+func readStreamManifest(location string) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	remote := isHTTP(location)
+	if remote {
+		if !scanBudgetLeft() {
+			return nil, errScanLimit
+		}
+		r, err = download(location)
+	} else {
+		r, err = openAudio(location)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, maxManifestSize+1))
+	if remote {
+		ioStats.reads.Add(1)
+		ioStats.bytes.Add(int64(len(data)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxManifestSize {
+		return nil, fmt.Errorf("manifest larger than %d bytes", maxManifestSize)
+	}
+	return data, nil
+}
+
+func isHTTP(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// manifestScope bounds what a local manifest may refer to: files under
+// root, the resolved scan root, that --allowed-roots also permits. An
+// empty root leaves local references unbounded.
+var manifestScope struct {
+	root    string
+	allowed []string
+}
+
+// setManifestScope confines local manifest references to root, or to the
+// directory of root when a single file is scanned.
+func setManifestScope(root string, allowed []string) {
+	manifestScope.allowed = allowed
+	if isStreamURL(root) {
+		return
+	}
+	root = realPath(root)
+	if info, err := os.Stat(root); err == nil && !info.IsDir() {
+		root = filepath.Dir(root)
+	}
+	manifestScope.root = root
+}
+
+// resolveReference resolves a URI found in a manifest against the
+// manifest's own location. Only a manifest fetched over HTTP may lead to
+// a URL; a local one may only lead to files within manifestScope, so a
+// playlist in the scanned tree cannot send the scan elsewhere.
+func resolveReference(base, ref string) (string, error) {
+	if isHTTP(base) {
+		b, err := url.Parse(base)
+		if err != nil {
+			return "", err
+		}
+		r, err := url.Parse(ref)
+		if err != nil {
+			return "", err
+		}
+		target := b.ResolveReference(r).String()
+		if !isHTTP(target) {
+			return "", fmt.Errorf("%s: not an http(s) URL", ref)
+		}
+		return target, nil
+	}
+	if isHTTP(ref) {
+		return "", fmt.Errorf("%s: a local playlist may not refer to a URL", ref)
+	}
+	if u, err := url.PathUnescape(ref); err == nil {
+		ref = u
+	}
+	if i := strings.IndexAny(ref, "?#"); i >= 0 {
+		ref = ref[:i]
+	}
+	target := filepath.Join(filepath.Dir(base), filepath.FromSlash(ref))
+	if filepath.IsAbs(ref) {
+		target = filepath.Clean(ref)
+	}
+	if manifestScope.root != "" && !within(realPath(target), manifestScope.root) {
+		return "", fmt.Errorf("%s: outside the scanned tree", ref)
+	}
+	if !pathAllowed(target, manifestScope.allowed) {
+		return "", fmt.Errorf("%s: %w", ref, errNotAllowed)
+	}
+	return target, nil
+}
+
+// hlsPlaylist is what the duration of an HLS playlist is taken from.
+type hlsPlaylist struct {
+	seconds  float64  // sum of #EXTINF segment durations (media playlists)
+	segments int      // number of #EXTINF segments
+	variants []string // #EXT-X-STREAM-INF URIs (master playlists)
+	audio    []string // #EXT-X-MEDIA TYPE=AUDIO URIs (master playlists)
+}
+
+func (p hlsPlaylist) master() bool {
+	return len(p.variants) > 0 || len(p.audio) > 0
+}
+
+// parseHLS reads the tags durations need from an M3U8 playlist.
+func parseHLS(data []byte) (hlsPlaylist, error) {
+	var p hlsPlaylist
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64<<10), maxManifestSize)
+	first := true
+	streamInf := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			if line != "#EXTM3U" {
+				return p, errors.New("not an M3U8 playlist (no #EXTM3U header)")
+			}
+			first = false
+			continue
+		}
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			d, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || d < 0 {
+				return p, fmt.Errorf("invalid #EXTINF duration %q", value)
+			}
+			p.seconds += d
+			p.segments++
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			streamInf = true
+		case strings.HasPrefix(line, "#EXT-X-MEDIA:"):
+			attrs := hlsAttributes(strings.TrimPrefix(line, "#EXT-X-MEDIA:"))
+			if attrs["TYPE"] == "AUDIO" && attrs["URI"] != "" {
+				p.audio = append(p.audio, attrs["URI"])
+			}
+		case strings.HasPrefix(line, "#"):
+		default:
+			if streamInf {
+				p.variants = append(p.variants, line)
+				streamInf = false
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return p, err
+	}
+	if first {
+		return p, errors.New("empty playlist")
+	}
+	return p, nil
+}
+
+// hlsAttributes splits an HLS attribute list (KEY=value,KEY="quoted,value").
+func hlsAttributes(list string) map[string]string {
+	attrs := make(map[string]string)
+	for list != "" {
+		key, rest, ok := strings.Cut(list, "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+			rest = strings.TrimPrefix(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[strings.TrimSpace(key)] = value
+		list = rest
+	}
+	return attrs
+}
+
+// hlsDuration is the duration of an HLS playlist. A master playlist counts
+// once, as its first audio rendition or, failing that, its first variant:
+// the variants are the same recording at different bitrates.
+func hlsDuration(location string) (float64, error) {
+	for depth := 0; depth < maxPlaylistDepth; depth++ {
+		data, err := readStreamManifest(location)
+		if err != nil {
+			return 0, err
+		}
+		p, err := parseHLS(data)
+		if err != nil {
+			return 0, err
+		}
+		if !p.master() {
+			if p.segments == 0 {
+				return 0, errors.New("playlist has no segments")
+			}
+			return p.seconds, nil
+		}
+		next := p.variants
+		if len(p.audio) > 0 {
+			next = p.audio
+		}
+		if location, err = resolveReference(location, next[0]); err != nil {
+			return 0, err
+		}
+	}
+	return 0, errors.New("master playlists nested too deeply")
+}
+
+// dropVariantPlaylists removes from audioFiles the local playlists that a
+// master playlist also in audioFiles refers to, since the master already
+// counts their recording.
+func dropVariantPlaylists(audioFiles []string, audit *auditLog) []string {
+	listed := make(map[string]bool)
+	for _, p := range audioFiles {
+		if streamManifestExt(p) != ".m3u8" {
+			continue
+		}
+		data, err := readStreamManifest(p)
+		if err != nil {
+			continue
+		}
+		playlist, err := parseHLS(data)
+		if err != nil {
+			continue
+		}
+		for _, ref := range append(playlist.variants, playlist.audio...) {
+			if target, err := resolveReference(p, ref); err == nil && !isHTTP(target) && target != p {
+				listed[target] = true
+			}
+		}
+	}
+	if len(listed) == 0 {
+		return audioFiles
+	}
+	kept := audioFiles[:0:0]
+	for _, p := range audioFiles {
+		if listed[p] {
+			audit.skipped(p, auditVariant, "")
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept
+}
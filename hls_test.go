@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseHLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    hlsPlaylist
+		wantErr bool
+	}{
+		{
+			name: "media",
+			in:   "#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:9.5,\na.ts\n#EXTINF:10,title\nb.ts\n#EXT-X-ENDLIST\n",
+			want: hlsPlaylist{seconds: 19.5, segments: 2},
+		},
+		{
+			name: "master",
+			in: "#EXTM3U\n" +
+				"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"aac\",NAME=\"English, main\",URI=\"audio/en.m3u8\"\n" +
+				"#EXT-X-MEDIA:TYPE=SUBTITLES,URI=\"subs.m3u8\"\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=128000\nlow.m3u8\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=256000\n\nhigh.m3u8\n",
+			want: hlsPlaylist{variants: []string{"low.m3u8", "high.m3u8"}, audio: []string{"audio/en.m3u8"}},
+		},
+		{
+			name: "byte order mark and CRLF",
+			in:   "\ufeff#EXTM3U\r\n#EXTINF:4,\r\nx.ts\r\n",
+			want: hlsPlaylist{seconds: 4, segments: 1},
+		},
+		{name: "bare URI is not a variant", in: "#EXTM3U\nstray.m3u8\n", want: hlsPlaylist{}},
+		{name: "empty", in: "", wantErr: true},
+		{name: "no header", in: "#EXTINF:4,\nx.ts\n", wantErr: true},
+		{name: "negative duration", in: "#EXTM3U\n#EXTINF:-1,\nx.ts\n", wantErr: true},
+		{name: "bad duration", in: "#EXTM3U\n#EXTINF:abc,\nx.ts\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseHLS([]byte(tt.in))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseHLS = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHLSAttributes(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string]string
+	}{
+		{`TYPE=AUDIO,URI="a.m3u8"`, map[string]string{"TYPE": "AUDIO", "URI": "a.m3u8"}},
+		{`NAME="English, main",DEFAULT=YES`, map[string]string{"NAME": "English, main", "DEFAULT": "YES"}},
+		{`URI="unterminated`, map[string]string{"URI": "unterminated"}},
+		{`URI=""`, map[string]string{"URI": ""}},
+		{`NOVALUE`, map[string]string{}},
+		{``, map[string]string{}},
+	}
+	for _, tt := range tests {
+		if got := hlsAttributes(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("hlsAttributes(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestResolveReference(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"show", "other"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	local := filepath.Join(root, "show", "master.m3u8")
+	setManifestScope(root, nil)
+	defer func() { manifestScope.root, manifestScope.allowed = "", nil }()
+
+	tests := []struct {
+		base, ref string
+		want      string
+		wantErr   bool
+	}{
+		{base: "https://cdn.example/live/master.m3u8", ref: "audio/en.m3u8", want: "https://cdn.example/live/audio/en.m3u8"},
+		{base: "https://cdn.example/live/master.m3u8", ref: "http://other.example/x.m3u8", want: "http://other.example/x.m3u8"},
+		{base: "https://cdn.example/live/master.m3u8", ref: "file:///etc/passwd", wantErr: true},
+		{base: local, ref: "low%20rate.m3u8?token=1", want: filepath.Join(root, "show", "low rate.m3u8")},
+		{base: local, ref: "../other/x.m3u8", want: filepath.Join(root, "other", "x.m3u8")},
+		{base: local, ref: "https://evil.example/x.m3u8", wantErr: true},
+		{base: local, ref: "../../outside.m3u8", wantErr: true},
+		{base: local, ref: "/etc/passwd", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := resolveReference(tt.base, tt.ref)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveReference(%q, %q) error = %v, wantErr %v", tt.base, tt.ref, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("resolveReference(%q, %q) = %q, want %q", tt.base, tt.ref, got, tt.want)
+		}
+	}
+
+	manifestScope.allowed = []string{filepath.Join(realPath(root), "show")}
+	if _, err := resolveReference(local, "../other/x.m3u8"); !errors.Is(err, errNotAllowed) {
+		t.Errorf("reference outside --allowed-roots: error = %v, want errNotAllowed", err)
+	}
+}
+
+func FuzzParseHLS(f *testing.F) {
+	f.Add([]byte("#EXTM3U\n#EXTINF:9.5,\na.ts\n"))
+	f.Add([]byte("#EXTM3U\n#EXT-X-MEDIA:TYPE=AUDIO,URI=\"a.m3u8\"\n#EXT-X-STREAM-INF:BANDWIDTH=1\nv.m3u8\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := parseHLS(data)
+		if err == nil && (p.seconds < 0 || p.segments < 0) {
+			t.Fatalf("parseHLS = %+v", p)
+		}
+	})
+}
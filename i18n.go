@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// translations maps each user-facing format string, in English, to its
+// translation. Strings without an entry print in English.
+var translations = map[language.Tag]map[string]string{
+	language.French: {
+		"Warning: skipping %s: %v\n":                                                      "Attention : %s ignoré : %v\n",
+		"Shard %s: %d of %d audio files.\n":                                               "Partition %s : %d fichiers audio sur %d.\n",
+		"Error resolving path: %v\n":                                                      "Erreur de résolution du chemin : %v\n",
+		"Error reading manifest: %v\n":                                                    "Erreur de lecture du manifeste : %v\n",
+		"Error reading expected counts: %v\n":                                             "Erreur de lecture des effectifs attendus : %v\n",
+		"Error reading segments: %v\n":                                                    "Erreur de lecture des segments : %v\n",
+		"Scanning directory: %s\n":                                                        "Analyse du dossier : %s\n",
+		"Error reading directory: %v\n":                                                   "Erreur de lecture du dossier : %v\n",
+		"No audio files found in the folder.\n":                                           "Aucun fichier audio trouvé dans le dossier.\n",
+		"\n%d files would be scanned.\n":                                                  "\n%d fichiers seraient analysés.\n",
+		"Queued %d files on %s\n":                                                         "%d fichiers mis en file d'attente sur %s\n",
+		"Found %d audio files. Sampling %d with %d workers...\n\n":                        "%d fichiers audio trouvés. Échantillonnage de %d avec %d workers...\n\n",
+		"Found %d audio files. Processing with %d workers...\n\n":                         "%d fichiers audio trouvés. Traitement avec %d workers...\n\n",
+		"Priority %s: no audio files\n":                                                   "Priorité %s : aucun fichier audio\n",
+		"Priority %s: %d files, %s (%d errors)\n":                                         "Priorité %s : %d fichiers, %s (%d erreurs)\n",
+		"Processing files...":                                                             "Traitement des fichiers...",
+		"Total audio duration in whole seconds: %d\n":                                     "Durée audio totale en secondes entières : %d\n",
+		"%s processed %d/%d files (%.1f%%), %s elapsed, %.1f files/s\n":                   "%s %d/%d fichiers traités (%.1f %%), %s écoulé, %.1f fichiers/s\n",
+		"\n=== Results ===\n":                                                             "\n=== Résultats ===\n",
+		"Total files found: %d\n":                                                         "Fichiers trouvés : %d\n",
+		"Files sampled: %d\n":                                                             "Fichiers échantillonnés : %d\n",
+		"Successfully processed: %d\n":                                                    "Traités avec succès : %d\n",
+		"Errors: %d\n":                                                                    "Erreurs : %d\n",
+		"Shorter than %s: %d\n":                                                           "Plus courts que %s : %d\n",
+		"Still being written (provisional): %d\n":                                         "En cours d'écriture (provisoire) : %d\n",
+		"Peak memory: %.1f MB, read: %.1f MB, CPU time: %.2fs\n":                          "Mémoire maximale : %.1f Mo, lu : %.1f Mo, temps CPU : %.2fs\n",
+		"Skipped at your request: %d in %s\n":                                             "Ignorés à votre demande : %d dans %s\n",
+		"Skipped while still being written: %d\n":                                         "Ignorés car en cours d'écriture : %d\n",
+		"Waiting %s for %d files still being written...\n":                                "Attente de %s pour %d fichiers en cours d'écriture...\n",
+		"Zero duration: %d\n":                                                             "Durée nulle : %d\n",
+		"Median audio duration per file: %s\n":                                            "Durée audio médiane par fichier : %s\n",
+		"Mean and median taken over %s files (--stats-over)\n":                            "Moyenne et médiane calculées sur les fichiers %s (--stats-over)\n",
+		"\nFiles shorter than %s:\n":                                                      "\nFichiers plus courts que %s :\n",
+		"Resolved via plugins: %d\n":                                                      "Résolus par plugins : %d\n",
+		"Served from cache: %d\n":                                                         "Servis depuis le cache : %d\n",
+		"Error reading cache: %v\n":                                                       "Erreur de lecture du cache : %v\n",
+		"Warning: could not update cache: %v\n":                                           "Attention : impossible de mettre à jour le cache : %v\n",
+		"Warning: could not publish results: %v\n":                                        "Attention : impossible de publier les résultats : %v\n",
+		"Placeholders (content not synced): %d (%s)\n":                                    "Fichiers de substitution (contenu non synchronisé) : %d (%s)\n",
+		"Placeholders resolved from local caches: %d\n":                                   "Fichiers de substitution résolus depuis les caches locaux : %d\n",
+		"Their pointers record %.1f MB, roughly %s at this scan's bytes per second\n":     "Leurs pointeurs indiquent %.1f Mo, soit environ %s au débit de cette analyse\n",
+		"Warning: these hours exclude %d placeholder files whose content is not synced\n": "Attention : ces heures excluent %d fichiers de substitution dont le contenu n'est pas synchronisé\n",
+		"Warning: %d placeholder files (%s) have no synced content\n":                     "Attention : %d fichiers de substitution (%s) n'ont pas de contenu synchronisé\n",
+		"Resolved via %s fallback: %d\n":                                                  "Résolus par repli %s : %d\n",
+		"Total audio duration: %s\n":                                                      "Durée audio totale : %s\n",
+		"Mean audio duration per file: %s (%s minutes)\n":                                 "Durée audio moyenne par fichier : %s (%s minutes)\n",
+		"Mean audio duration per file: %s\n":                                              "Durée audio moyenne par fichier : %s\n",
+		"Oldest file modified: %s\n":                                                      "Fichier modifié le plus ancien : %s\n",
+		"Newest file modified: %s\n":                                                      "Fichier modifié le plus récent : %s\n",
+		"Date range covered: %s\n":                                                        "Période couverte : %s\n",
+		"Processing speed: %.0f× realtime (%.2f audio-hours per wall-second)\n":           "Vitesse de traitement : %.0f× le temps réel (%.2f heures audio par seconde)\n",
+		"At %g×, this is %s ≈ %s working days\n":                                          "À %g×, cela fait %s ≈ %s jours ouvrés\n",
+		"Error writing parquet: %v\n":                                                     "Erreur d'écriture Parquet : %v\n",
+		"Per-file records written to %s\n":                                                "Enregistrements par fichier écrits dans %s\n",
+		"Warning: could not update history: %v\n":                                         "Attention : impossible de mettre à jour l'historique : %v\n",
+		"Error writing SQLite database: %v\n":                                             "Erreur d'écriture de la base SQLite : %v\n",
+		"\nScan %d recorded in %s\n":                                                      "\nAnalyse %d enregistrée dans %s\n",
+		"Warning: could not read history: %v\n":                                           "Attention : impossible de lire l'historique : %v\n",
+		"Error writing manifest: %v\n":                                                    "Erreur d'écriture du manifeste : %v\n",
+		"Error writing audit log: %v\n":                                                   "Erreur d'écriture du journal d'audit : %v\n",
+		"\n%s files written in %d of %d directories\n":                                    "\nFichiers %s écrits dans %d répertoires sur %d\n",
+		"\nManifest written to %s\n":                                                      "\nManifeste écrit dans %s\n",
+		"Error writing scan manifest: %v\n":                                               "Erreur d'écriture du manifeste d'analyse : %v\n",
+		"\nScan manifest written to %s\n":                                                 "\nManifeste d'analyse écrit dans %s\n",
+		"%.0f days (%.1f years)":                                                          "%.0f jours (%.1f ans)",
+		"%.1f days":                                                                       "%.1f jours",
+		"minutes":                                                                         "minutes",
+		"hours":                                                                           "heures",
+		"days":                                                                            "jours",
+	},
+	language.Spanish: {
+		"Warning: skipping %s: %v\n":                                                      "Aviso: se omite %s: %v\n",
+		"Shard %s: %d of %d audio files.\n":                                               "Fragmento %s: %d de %d archivos de audio.\n",
+		"Error resolving path: %v\n":                                                      "Error al resolver la ruta: %v\n",
+		"Error reading expected counts: %v\n":                                             "Error al leer los recuentos esperados: %v\n",
+		"Error reading manifest: %v\n":                                                    "Error al leer el manifiesto: %v\n",
+		"Error reading segments: %v\n":                                                    "Error al leer los segmentos: %v\n",
+		"Scanning directory: %s\n":                                                        "Analizando el directorio: %s\n",
+		"Error reading directory: %v\n":                                                   "Error al leer el directorio: %v\n",
+		"No audio files found in the folder.\n":                                           "No se encontraron archivos de audio en la carpeta.\n",
+		"\n%d files would be scanned.\n":                                                  "\nSe analizarían %d archivos.\n",
+		"Queued %d files on %s\n":                                                         "%d archivos puestos en cola en %s\n",
+		"Found %d audio files. Sampling %d with %d workers...\n\n":                        "Se encontraron %d archivos de audio. Muestreando %d con %d workers...\n\n",
+		"Found %d audio files. Processing with %d workers...\n\n":                         "Se encontraron %d archivos de audio. Procesando con %d workers...\n\n",
+		"Priority %s: no audio files\n":                                                   "Prioridad %s: ningún archivo de audio\n",
+		"Priority %s: %d files, %s (%d errors)\n":                                         "Prioridad %s: %d archivos, %s (%d errores)\n",
+		"Processing files...":                                                             "Procesando archivos...",
+		"Total audio duration in whole seconds: %d\n":                                     "Duración total de audio en segundos enteros: %d\n",
+		"%s processed %d/%d files (%.1f%%), %s elapsed, %.1f files/s\n":                   "%s %d/%d archivos procesados (%.1f %%), %s transcurrido, %.1f archivos/s\n",
+		"\n=== Results ===\n":                                                             "\n=== Resultados ===\n",
+		"Total files found: %d\n":                                                         "Archivos encontrados: %d\n",
+		"Files sampled: %d\n":                                                             "Archivos muestreados: %d\n",
+		"Successfully processed: %d\n":                                                    "Procesados correctamente: %d\n",
+		"Errors: %d\n":                                                                    "Errores: %d\n",
+		"Shorter than %s: %d\n":                                                           "Más cortos que %s: %d\n",
+		"Still being written (provisional): %d\n":                                         "Aún en escritura (provisional): %d\n",
+		"Peak memory: %.1f MB, read: %.1f MB, CPU time: %.2fs\n":                          "Memoria máxima: %.1f MB, leído: %.1f MB, tiempo de CPU: %.2fs\n",
+		"Skipped at your request: %d in %s\n":                                             "Omitidos a petición suya: %d en %s\n",
+		"Skipped while still being written: %d\n":                                         "Omitidos por estar aún en escritura: %d\n",
+		"Waiting %s for %d files still being written...\n":                                "Esperando %s a %d archivos aún en escritura...\n",
+		"Zero duration: %d\n":                                                             "Duración cero: %d\n",
+		"Median audio duration per file: %s\n":                                            "Duración mediana por archivo: %s\n",
+		"Mean and median taken over %s files (--stats-over)\n":                            "Media y mediana calculadas sobre los archivos %s (--stats-over)\n",
+		"\nFiles shorter than %s:\n":                                                      "\nArchivos más cortos que %s:\n",
+		"Resolved via plugins: %d\n":                                                      "Resueltos mediante plugins: %d\n",
+		"Served from cache: %d\n":                                                         "Servidos desde la caché: %d\n",
+		"Error reading cache: %v\n":                                                       "Error al leer la caché: %v\n",
+		"Warning: could not update cache: %v\n":                                           "Aviso: no se pudo actualizar la caché: %v\n",
+		"Warning: could not publish results: %v\n":                                        "Aviso: no se pudieron publicar los resultados: %v\n",
+		"Placeholders (content not synced): %d (%s)\n":                                    "Marcadores de posición (contenido no sincronizado): %d (%s)\n",
+		"Placeholders resolved from local caches: %d\n":                                   "Marcadores de posición resueltos desde cachés locales: %d\n",
+		"Their pointers record %.1f MB, roughly %s at this scan's bytes per second\n":     "Sus punteros registran %.1f MB, unas %s al ritmo de bytes por segundo de este análisis\n",
+		"Warning: these hours exclude %d placeholder files whose content is not synced\n": "Aviso: estas horas excluyen %d marcadores de posición cuyo contenido no está sincronizado\n",
+		"Warning: %d placeholder files (%s) have no synced content\n":                     "Aviso: %d marcadores de posición (%s) no tienen contenido sincronizado\n",
+		"Resolved via %s fallback: %d\n":                                                  "Resueltos mediante %s: %d\n",
+		"Total audio duration: %s\n":                                                      "Duración total de audio: %s\n",
+		"Mean audio duration per file: %s (%s minutes)\n":                                 "Duración media por archivo: %s (%s minutos)\n",
+		"Mean audio duration per file: %s\n":                                              "Duración media por archivo: %s\n",
+		"Oldest file modified: %s\n":                                                      "Archivo modificado más antiguo: %s\n",
+		"Newest file modified: %s\n":                                                      "Archivo modificado más reciente: %s\n",
+		"Date range covered: %s\n":                                                        "Periodo cubierto: %s\n",
+		"Processing speed: %.0f× realtime (%.2f audio-hours per wall-second)\n":           "Velocidad de procesamiento: %.0f× tiempo real (%.2f horas de audio por segundo)\n",
+		"At %g×, this is %s ≈ %s working days\n":                                          "A %g×, son %s ≈ %s días laborables\n",
+		"Error writing parquet: %v\n":                                                     "Error al escribir Parquet: %v\n",
+		"Per-file records written to %s\n":                                                "Registros por archivo escritos en %s\n",
+		"Warning: could not update history: %v\n":                                         "Aviso: no se pudo actualizar el historial: %v\n",
+		"Error writing SQLite database: %v\n":                                             "Error al escribir la base SQLite: %v\n",
+		"\nScan %d recorded in %s\n":                                                      "\nAnálisis %d registrado en %s\n",
+		"Warning: could not read history: %v\n":                                           "Aviso: no se pudo leer el historial: %v\n",
+		"Error writing manifest: %v\n":                                                    "Error al escribir el manifiesto: %v\n",
+		"Error writing audit log: %v\n":                                                   "Error al escribir el registro de auditoría: %v\n",
+		"\n%s files written in %d of %d directories\n":                                    "\nArchivos %s escritos en %d de %d directorios\n",
+		"\nManifest written to %s\n":                                                      "\nManifiesto escrito en %s\n",
+		"Error writing scan manifest: %v\n":                                               "Error al escribir el manifiesto de análisis: %v\n",
+		"\nScan manifest written to %s\n":                                                 "\nManifiesto de análisis escrito en %s\n",
+		"%.0f days (%.1f years)":                                                          "%.0f días (%.1f años)",
+		"%.1f days":                                                                       "%.1f días",
+		"minutes":                                                                         "minutos",
+		"hours":                                                                           "horas",
+		"days":                                                                            "días",
+	},
+}
+
+// supportedLanguages lists English first so it wins unmatched requests.
+var supportedLanguages = language.NewMatcher([]language.Tag{language.English, language.French, language.Spanish})
+
+// messages translates output; nil prints the English strings unchanged.
+var messages *message.Printer
+
+func init() {
+	for tag, table := range translations {
+		for key, msg := range table {
+			message.SetString(tag, key, msg)
+		}
+	}
+	setLanguage(envLanguage())
+}
+
+// envLanguage reads the message locale from the environment the way
+// gettext does, e.g. "fr_FR.UTF-8" from LANG.
+func envLanguage() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			v, _, _ = strings.Cut(v, ".")
+			return strings.ReplaceAll(v, "_", "-")
+		}
+	}
+	return ""
+}
+
+// setLanguage selects the closest supported language to lang.
+func setLanguage(lang string) {
+	messages = nil
+	if lang == "" || lang == "C" || lang == "POSIX" {
+		return
+	}
+	tag, _, _ := supportedLanguages.Match(language.Make(lang))
+	if base, _ := tag.Base(); base.String() != "en" {
+		messages = message.NewPrinter(tag)
+	}
+}
+
+// tr formats a user-facing message in the selected language.
+func tr(format string, a ...any) string {
+	if messages == nil {
+		return fmt.Sprintf(format, a...)
+	}
+	return messages.Sprintf(format, a...)
+}
+
+// printf prints a user-facing message in the selected language.
+func printf(format string, a ...any) {
+	fmt.Fprint(console, tr(format, a...))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// maxID3Tag bounds the ID3v2 tag read when looking for chapters.
+const maxID3Tag = 16 << 20
+
+// id3Chapters reads the CHAP frames of an ID3v2.3/2.4 tag at the start of
+// an MP3, as written by podcast tools, titled by their TIT2 sub-frames.
+func id3Chapters(file *audioFile) []chapter {
+	var hdr [10]byte
+	if _, err := file.ReadAt(hdr[:], 0); err != nil || string(hdr[0:3]) != "ID3" {
+		return nil
+	}
+	version := hdr[3]
+	if version != 3 && version != 4 {
+		return nil
+	}
+	size := syncsafe(hdr[6:10])
+	if size > maxID3Tag {
+		return nil
+	}
+	tag := make([]byte, size)
+	if _, err := file.ReadAt(tag, 10); err != nil && err != io.EOF {
+		return nil
+	}
+
+	var chapters []chapter
+	for _, frame := range id3Frames(tag, version) {
+		if frame.id != "CHAP" || len(chapters) == maxChapters {
+			continue
+		}
+		body := frame.body
+		end := 0
+		for end < len(body) && body[end] != 0 {
+			end++
+		}
+		if end+17 > len(body) {
+			continue
+		}
+		times := body[end+1:]
+		startMs := binary.BigEndian.Uint32(times[0:4])
+		endMs := binary.BigEndian.Uint32(times[4:8])
+		c := chapter{start: float64(startMs) / 1000}
+		if endMs > startMs {
+			c.duration = float64(endMs-startMs) / 1000
+		}
+		for _, sub := range id3Frames(times[16:], version) {
+			if sub.id == "TIT2" {
+				c.title = id3Text(sub.body)
+			}
+		}
+		chapters = append(chapters, c)
+	}
+	return chapters
+}
+
+type id3Frame struct {
+	id   string
+	body []byte
+}
+
+// id3Frames splits a run of ID3v2 frames, stopping at padding or at a
+// frame that overruns the tag.
+func id3Frames(b []byte, version byte) []id3Frame {
+	var frames []id3Frame
+	for len(b) >= 10 && b[0] != 0 {
+		size := int(binary.BigEndian.Uint32(b[4:8]))
+		if version == 4 {
+			size = int(syncsafe(b[4:8]))
+		}
+		if size < 0 || 10+size > len(b) {
+			break
+		}
+		frames = append(frames, id3Frame{id: string(b[0:4]), body: b[10 : 10+size]})
+		b = b[10+size:]
+	}
+	return frames
+}
+
+// syncsafe decodes the 7-bits-per-byte integers of ID3v2 sizes.
+func syncsafe(b []byte) int64 {
+	return int64(b[0]&0x7f)<<21 | int64(b[1]&0x7f)<<14 | int64(b[2]&0x7f)<<7 | int64(b[3]&0x7f)
+}
+
+// id3Text decodes a text frame body: an encoding byte, then Latin-1,
+// UTF-16 with BOM, UTF-16BE or UTF-8.
+func id3Text(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	text := b[1:]
+	switch b[0] {
+	case 0:
+		runes := make([]rune, len(text))
+		for i, c := range text {
+			runes[i] = rune(c)
+		}
+		return trimNUL(nfc(string(runes)))
+	case 1:
+		return trimNUL(decodeText(text))
+	case 2:
+		return trimNUL(decodeText(append([]byte{0xFE, 0xFF}, text...)))
+	}
+	return trimNUL(nfc(string(text)))
+}
+
+func trimNUL(s string) string {
+	for len(s) > 0 && s[len(s)-1] == 0 {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// idleCheckInterval is how often --idle re-reads the load and read latency.
+const idleCheckInterval = time.Second
+
+// idleGate pauses workers while the machine is busy: when the one-minute
+// load average per CPU exceeds maxLoad, or reads have recently been slower
+// than maxLatency on average.
+type idleGate struct {
+	maxLoad    float64
+	maxLatency time.Duration
+
+	mu        sync.Mutex
+	checked   time.Time
+	busy      bool
+	lastReads int64
+	lastNanos int64
+}
+
+func newIdleGate(maxLoad float64, maxLatency time.Duration) *idleGate {
+	return &idleGate{maxLoad: maxLoad, maxLatency: maxLatency}
+}
+
+// wait blocks until the machine is quiet enough to probe the next file.
+func (g *idleGate) wait() {
+	for g.isBusy() {
+		time.Sleep(idleCheckInterval)
+	}
+}
+
+func (g *idleGate) isBusy() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if time.Since(g.checked) < idleCheckInterval {
+		return g.busy
+	}
+	g.checked = time.Now()
+
+	g.busy = false
+	if load, ok := loadAverage(); ok && load/float64(runtime.NumCPU()) > g.maxLoad {
+		g.busy = true
+	}
+	reads, nanos := ioStats.reads.Load(), ioStats.readNanos.Load()
+	if n := reads - g.lastReads; n > 0 && time.Duration((nanos-g.lastNanos)/n) > g.maxLatency {
+		g.busy = true
+	}
+	g.lastReads, g.lastNanos = reads, nanos
+	return g.busy
+}
+
+// loadAverage returns the one-minute load average where the system exposes
+// it through /proc.
+func loadAverage() (float64, bool) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, false
+	}
+	field, _, _ := strings.Cut(string(data), " ")
+	load, err := strconv.ParseFloat(field, 64)
+	return load, err == nil
+}
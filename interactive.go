@@ -0,0 +1,225 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Interactive mode: with --interactive the walk stops on cases a rule
+// cannot settle and asks whether to count them. A/N answers apply to the
+// rest of the session's cases of the same kind.
+const (
+	askSymlink  = "symlinked directories"
+	askArchive  = "archives"
+	askMismatch = "extension mismatches"
+)
+
+// --audit-log reasons specific to interactive mode.
+const (
+	auditDeclined    = "declined"     // the user said no
+	auditSymlinkLoop = "symlink-loop" // a symlinked directory leading back into the walk
+)
+
+type prompter struct {
+	in     *bufio.Reader
+	always map[string]bool // kind -> standing answer
+}
+
+func newPrompter() *prompter {
+	return &prompter{in: bufio.NewReader(os.Stdin), always: make(map[string]bool)}
+}
+
+// ask returns the standing answer for kind, or prompts on stderr. End of
+// input answers no to everything left.
+func (p *prompter) ask(kind, question string) bool {
+	if answer, ok := p.always[kind]; ok {
+		return answer
+	}
+	for {
+		fmt.Fprintf(os.Stderr, "%s [y]es/[n]o/[A]ll %s/[N]one: ", question, kind)
+		line, err := p.in.ReadString('\n')
+		switch strings.TrimSpace(line) {
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		case "A":
+			p.always[kind] = true
+			return true
+		case "N":
+			p.always[kind] = false
+			return false
+		}
+		if err != nil {
+			p.always[kind] = false
+			fmt.Fprintln(os.Stderr)
+			return false
+		}
+	}
+}
+
+// symlinkTarget classifies a symlinked directory against the real paths
+// of the directories the walk already covers. loop is set for a link back
+// to one of them or to an ancestor, which is never followed; a link deeper
+// into a covered directory is only a question, since its files would be
+// counted twice.
+func symlinkTarget(real string, covered []string) (desc string, loop bool) {
+	for _, dir := range covered {
+		switch {
+		case real == dir || strings.HasPrefix(dir, real+string(filepath.Separator)):
+			return "loops back to " + real, true
+		case strings.HasPrefix(real, dir+string(filepath.Separator)):
+			return "points inside the scanned tree, so its files would be counted twice", false
+		}
+	}
+	return "points to " + real, false
+}
+
+// archiveKind returns the archive format of a path by extension, or "".
+func archiveKind(path string) string {
+	name := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(name, ".tar"):
+		return "tar"
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz"
+	}
+	return ""
+}
+
+// Bounds on what one archive may extract, so a zip bomb or an archive of
+// countless tiny members cannot fill the temporary directory.
+const (
+	maxArchiveBytes   = 4 << 30
+	maxArchiveMembers = 10000
+)
+
+// archiveDir holds audio extracted from archives the user chose to count;
+// main removes it when the scan ends.
+var archiveDir string
+
+// extractArchive copies the audio members of an archive to archiveDir and
+// returns them as paths inside the archive ("book.zip/ch01.mp3"), with
+// contentPaths pointing at the extracted copies. Archives beyond
+// maxArchiveBytes or maxArchiveMembers fail with the members extracted
+// so far.
+func extractArchive(path, kind string, extensions map[string]bool) ([]string, error) {
+	if archiveDir == "" {
+		dir, err := os.MkdirTemp("", "howmanyhours-archives-")
+		if err != nil {
+			return nil, err
+		}
+		archiveDir = dir
+	}
+	dest, err := os.MkdirTemp(archiveDir, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var members []string
+	seen, left := 0, int64(maxArchiveBytes)
+	// wanted counts a member and reports whether it is audio to extract.
+	wanted := func(name string) (bool, error) {
+		if seen++; seen > maxArchiveMembers {
+			return false, fmt.Errorf("more than %d members", maxArchiveMembers)
+		}
+		name = filepath.FromSlash(name)
+		return extensions[strings.ToLower(nfc(filepath.Ext(name)))] && filepath.IsLocal(name), nil
+	}
+	extract := func(name string, r io.Reader) error {
+		name = filepath.FromSlash(name)
+		out := filepath.Join(dest, fmt.Sprintf("%d%s", len(members), filepath.Ext(name)))
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(f, io.LimitReader(r, left+1))
+		if left -= n; err == nil && left < 0 {
+			err = fmt.Errorf("more than %d bytes of audio", maxArchiveBytes)
+		}
+		if err != nil {
+			f.Close()
+			os.Remove(out)
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		member := filepath.Join(path, name)
+		contentPaths[member] = out
+		members = append(members, member)
+		return nil
+	}
+
+	if kind == "zip" {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			if ok, err := wanted(f.Name); err != nil {
+				return members, err
+			} else if !ok {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return members, err
+			}
+			err = extract(f.Name, rc)
+			rc.Close()
+			if err != nil {
+				return members, err
+			}
+		}
+		return members, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if kind == "tar.gz" {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return members, nil
+		}
+		if err != nil {
+			return members, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if ok, err := wanted(hdr.Name); err != nil {
+			return members, err
+		} else if ok {
+			if err := extract(hdr.Name, tr); err != nil {
+				return members, err
+			}
+		}
+	}
+}
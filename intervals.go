@@ -0,0 +1,44 @@
+package main
+
+import "sort"
+
+// interval is a time span in seconds, used by annotation and subtitle checks.
+type interval struct {
+	start, end float64
+}
+
+// mergeIntervals sorts spans and collapses overlapping ones, so overlapping
+// speakers or duplicated labels are not counted twice.
+func mergeIntervals(spans []interval) []interval {
+	if len(spans) == 0 {
+		return nil
+	}
+	sorted := make([]interval, 0, len(spans))
+	for _, s := range spans {
+		if s.end > s.start {
+			sorted = append(sorted, s)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start < sorted[j].start })
+
+	var merged []interval
+	for _, s := range sorted {
+		if n := len(merged); n > 0 && s.start <= merged[n-1].end {
+			if s.end > merged[n-1].end {
+				merged[n-1].end = s.end
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// coveredSeconds returns the total length of the union of spans.
+func coveredSeconds(spans []interval) float64 {
+	var total float64
+	for _, s := range mergeIntervals(spans) {
+		total += s.end - s.start
+	}
+	return total
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// ioStats accumulates the reads made through audioFile across all workers.
+// Time spent waiting on the throttle is not counted as I/O. aheadNanos is
+// the part of the reads made by the --io-workers pool rather than by the
+// probing workers.
+var ioStats struct {
+	reads      atomic.Int64
+	bytes      atomic.Int64
+	readNanos  atomic.Int64
+	aheadNanos atomic.Int64
+}
+
+// audioFile is an open audio file whose reads are timed into ioStats. It
+// deliberately wraps rather than embeds *os.File so that no promoted method
+// (WriteTo, ReadFrom...) can read around the accounting.
+//
+// When the I/O pool has prefetched the start of the file, reads inside head
+// are served from memory and the file itself is only opened for reads past
+// it. Reads smaller than readBufferSize fill window with one read of that
+// size, so decoders issuing many tiny reads cost one request per window.
+type audioFile struct {
+	path      string
+	f         *os.File
+	head      []byte
+	window    []byte
+	windowOff int64
+	info      os.FileInfo
+	mapped    bool  // head is a memory mapping of the whole file (--mmap)
+	pos       int64 // read position for Read and Seek
+	budget    int64 // bytes left before errProbeLimit; negative is unlimited
+}
+
+// readBufferSize is --read-buffer: the smallest read issued to storage.
+// Zero passes every read through unchanged.
+var readBufferSize = 64 << 10
+
+// fileAdvice is --fadvise: the readahead hint given for every audio file
+// opened, or empty for none.
+var fileAdvice string
+
+// maxProbeBytes is --max-probe-bytes: how much one probe may read from a
+// file before giving up on it. Zero means no limit.
+var maxProbeBytes int64
+
+var errProbeLimit = errors.New("probe read limit reached (see --max-probe-bytes)")
+
+// maxScanBytes is --max-bytes: how much the whole scan may read before
+// every further read fails. Zero means no limit.
+var maxScanBytes int64
+
+var errScanLimit = errors.New("scan read limit reached (see --max-bytes)")
+
+// scanBudgetLeft reports whether the scan may still read from storage.
+func scanBudgetLeft() bool {
+	return maxScanBytes <= 0 || ioStats.bytes.Load() < maxScanBytes
+}
+
+// openAudio opens an audio file for probing, bounded by maxProbeBytes.
+func openAudio(path string) (*audioFile, error) {
+	a, err := openAudioWhole(path)
+	if err == nil && maxProbeBytes > 0 {
+		a.budget = maxProbeBytes
+	}
+	return a, err
+}
+
+// openAudioWhole opens an audio file for passes that read every byte by
+// design, such as hashing and --deep decoding.
+func openAudioWhole(path string) (*audioFile, error) {
+	if p, ok := prefetched.Load(path); ok {
+		p := p.(prefetch)
+		return &audioFile{path: path, head: p.head, info: p.info, budget: -1}, nil
+	}
+	f, err := openStorage(contentPath(path))
+	if err != nil {
+		return nil, err
+	}
+	a := &audioFile{path: path, f: f, budget: -1}
+	if useMmap {
+		a.mapFile()
+	}
+	return a, nil
+}
+
+func (a *audioFile) Read(p []byte) (int, error) {
+	p, err := a.reserve(p)
+	if err != nil {
+		return 0, err
+	}
+	n, err := a.ReadAt(p, a.pos)
+	a.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (a *audioFile) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) > 0 && int64(len(p)) <= a.buffered(off) {
+		// Charged when the window was read.
+		return copy(p, a.window[off-a.windowOff:]), nil
+	}
+	if a.budget >= 0 && int64(len(p)) > a.budget {
+		return 0, errProbeLimit
+	}
+	if off >= 0 && off+int64(len(p)) <= int64(len(a.head)) {
+		if a.mapped {
+			n, err := a.readMapped(p, off)
+			a.charge(n)
+			return n, err
+		}
+		n := copy(p, a.head[off:])
+		a.charge(n)
+		return n, nil
+	}
+	if a.head != nil && off >= a.info.Size() {
+		return 0, io.EOF
+	}
+	if !scanBudgetLeft() {
+		return 0, errScanLimit
+	}
+	if err := a.openFile(); err != nil {
+		return 0, err
+	}
+	if len(p) >= readBufferSize {
+		n, err := a.readStorage(p, off)
+		a.charge(n)
+		return n, err
+	}
+
+	if a.window == nil {
+		a.window = make([]byte, readBufferSize)
+	}
+	size := int64(readBufferSize)
+	if a.budget >= 0 {
+		size = min(size, a.budget)
+	}
+	n, err := a.readStorage(a.window[:size], off)
+	a.window, a.windowOff = a.window[:n], off
+	a.charge(n)
+	n = copy(p, a.window)
+	if n == len(p) {
+		err = nil
+	}
+	return n, err
+}
+
+// readStorage issues one timed, throttled read to the file itself.
+func (a *audioFile) readStorage(p []byte, off int64) (int, error) {
+	throttle.wait(len(p))
+	start := time.Now()
+	n, err := a.f.ReadAt(p, off)
+	a.account(n, start)
+	return n, err
+}
+
+// openFile opens the file behind a prefetched head on first use.
+func (a *audioFile) openFile() error {
+	if a.f != nil {
+		return nil
+	}
+	f, err := openStorage(contentPath(a.path))
+	if err != nil {
+		return err
+	}
+	a.f = f
+	return nil
+}
+
+// reserve shortens p to what the probe budget and the window still allow.
+func (a *audioFile) reserve(p []byte) ([]byte, error) {
+	if a.budget < 0 || len(p) == 0 {
+		return p, nil
+	}
+	left := max(a.budget, a.buffered(a.pos))
+	if left == 0 {
+		return nil, errProbeLimit
+	}
+	return p[:min(int64(len(p)), left)], nil
+}
+
+// buffered is how many bytes from off the window already holds.
+func (a *audioFile) buffered(off int64) int64 {
+	if off < a.windowOff || off >= a.windowOff+int64(len(a.window)) {
+		return 0
+	}
+	return a.windowOff + int64(len(a.window)) - off
+}
+
+func (a *audioFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += a.pos
+	case io.SeekEnd:
+		info, err := a.Stat()
+		if err != nil {
+			return 0, err
+		}
+		offset += info.Size()
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative seek position")
+	}
+	a.pos = offset
+	return offset, nil
+}
+
+func (a *audioFile) Stat() (os.FileInfo, error) {
+	if a.info != nil {
+		return a.info, nil
+	}
+	info, err := a.f.Stat()
+	if err == nil {
+		a.info = info
+	}
+	return info, err
+}
+
+func (a *audioFile) Close() error {
+	if a.f == nil {
+		return nil
+	}
+	if a.mapped {
+		munmapFile(a.head)
+		a.head, a.mapped = nil, false
+	}
+	return closeStorage(a.f)
+}
+
+// charge takes n bytes off the probe budget: those read from storage,
+// including whole windows, and those copied out of head.
+func (a *audioFile) charge(n int) {
+	if a.budget > 0 {
+		a.budget = max(a.budget-int64(n), 0)
+	}
+}
+
+func (a *audioFile) account(n int, start time.Time) {
+	ioStats.readNanos.Add(int64(time.Since(start)))
+	ioStats.reads.Add(1)
+	ioStats.bytes.Add(int64(n))
+}
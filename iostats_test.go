@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProbeBudgetChargesWindow(t *testing.T) {
+	path := writeTemp(t, "a.mp3", make([]byte, 1<<20))
+	tests := []struct {
+		name   string
+		budget int64
+		reads  []int64 // offsets of 10-byte reads
+		want   int64   // budget left
+		err    error   // of the last read
+	}{
+		{name: "window read is charged whole", budget: 100000, reads: []int64{0}, want: 100000 - int64(readBufferSize)},
+		{name: "window hits are free", budget: 100000, reads: []int64{0, 10, 500}, want: 100000 - int64(readBufferSize)},
+		{name: "window shrinks to the budget", budget: 1000, reads: []int64{0}, want: 0},
+		{name: "window serves past the budget", budget: 1000, reads: []int64{0, 900}, want: 0},
+		{name: "beyond the window fails", budget: 1000, reads: []int64{0, 5000}, want: 0, err: errProbeLimit},
+	}
+	for _, tt := range tests {
+		file, err := openAudioWhole(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.budget = tt.budget
+		for _, off := range tt.reads {
+			_, err = file.ReadAt(make([]byte, 10), off)
+		}
+		file.Close()
+		if file.budget != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("%s: budget %d, err %v; want %d, %v", tt.name, file.budget, err, tt.want, tt.err)
+		}
+	}
+}
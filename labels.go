@@ -0,0 +1,57 @@
+package main
+
+import "fmt"
+
+// labelOf returns the folder directly containing a file, the class label in
+// "label/clip.wav" layouts.
+func labelOf(root, path string) (string, bool) {
+	parts := pathComponents(root, path)
+	if len(parts) == 0 {
+		return "", false
+	}
+	return parts[len(parts)-1], true
+}
+
+func printLabelReport(root string, audioFiles []string, durations []float64, maxRatio float64) {
+	labels := groupDurations(audioFiles, durations, func(path string) (string, bool) {
+		return labelOf(root, path)
+	})
+	sortGroupsBySeconds(labels)
+
+	fmt.Fprintln(console, "\n=== Labels ===")
+	if len(labels) == 0 {
+		fmt.Fprintln(console, "No labeled folders found.")
+		return
+	}
+
+	var total float64
+	for _, l := range labels {
+		total += l.seconds
+	}
+	fmt.Fprintf(console, "%-30s %8s %10s %8s\n", "Label", "Files", "Minutes", "Share")
+	for _, l := range labels {
+		fmt.Fprintf(console, "%-30s %8d %10.2f %7.1f%%\n", l.key, l.files, l.seconds/60, percent(l.seconds, total))
+	}
+
+	most, least := labels[0], labels[len(labels)-1]
+	minFiles, maxFiles := labels[0].files, labels[0].files
+	for _, l := range labels {
+		minFiles = min(minFiles, l.files)
+		maxFiles = max(maxFiles, l.files)
+	}
+	fmt.Fprintf(console, "\nLabels: %d\n", len(labels))
+	if least.seconds > 0 {
+		ratio := most.seconds / least.seconds
+		fmt.Fprintf(console, "Duration imbalance: %.1f:1 (%s vs %s)\n", ratio, most.key, least.key)
+		if ratio > maxRatio {
+			fmt.Fprintf(console, "Warning: duration imbalance exceeds %.1f:1\n", maxRatio)
+		}
+	}
+	if minFiles > 0 {
+		ratio := float64(maxFiles) / float64(minFiles)
+		fmt.Fprintf(console, "File count imbalance: %.1f:1\n", ratio)
+		if ratio > maxRatio {
+			fmt.Fprintf(console, "Warning: file count imbalance exceeds %.1f:1\n", maxRatio)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// datasetLayout describes what each folder level below the root means, e.g.
+//
+//	components: [language, split, speaker]
+//	rows: language
+//	columns: split
+//
+// Levels named "" or "_" are ignored. Rows and columns default to the first
+// two named components.
+type datasetLayout struct {
+	Components []string `yaml:"components"`
+	Rows       string   `yaml:"rows"`
+	Columns    string   `yaml:"columns"`
+}
+
+func loadLayout(path string) (datasetLayout, error) {
+	var layout datasetLayout
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return layout, err
+	}
+	if err := yaml.Unmarshal(data, &layout); err != nil {
+		return layout, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var named []string
+	for _, c := range layout.Components {
+		if c != "" && c != "_" {
+			named = append(named, c)
+		}
+	}
+	if layout.Rows == "" && len(named) > 0 {
+		layout.Rows = named[0]
+	}
+	if layout.Columns == "" && len(named) > 1 {
+		layout.Columns = named[1]
+	}
+	if layout.Rows == "" {
+		return layout, fmt.Errorf("%s: layout names no components", path)
+	}
+	for _, dim := range []string{layout.Rows, layout.Columns} {
+		if dim != "" && layout.level(dim) < 0 {
+			return layout, fmt.Errorf("%s: %q is not one of the layout components", path, dim)
+		}
+	}
+	return layout, nil
+}
+
+// level returns the folder depth (0-based) holding the named dimension, or
+// -1 when the layout does not define it.
+func (l datasetLayout) level(dim string) int {
+	for i, c := range l.Components {
+		if c == dim {
+			return i
+		}
+	}
+	return -1
+}
+
+// valueOf returns the folder name at the dimension's level for path.
+func (l datasetLayout) valueOf(root, path, dim string) (string, bool) {
+	level := l.level(dim)
+	parts := pathComponents(root, path)
+	if level < 0 || level >= len(parts) {
+		return "", false
+	}
+	return parts[level], true
+}
+
+type pivotReport struct {
+	rowName, colName string
+	rows, cols       []string
+	cells            map[[2]string]float64 // seconds
+	rowTotals        map[string]float64
+	colTotals        map[string]float64
+	totalSeconds     float64
+	unmatched        int
+}
+
+func buildPivotReport(root string, audioFiles []string, durations []float64, layout datasetLayout) pivotReport {
+	report := pivotReport{
+		rowName:   layout.Rows,
+		colName:   layout.Columns,
+		cells:     make(map[[2]string]float64),
+		rowTotals: make(map[string]float64),
+		colTotals: make(map[string]float64),
+	}
+	for i, path := range audioFiles {
+		row, ok := layout.valueOf(root, path, layout.Rows)
+		if !ok {
+			report.unmatched++
+			continue
+		}
+		col := ""
+		if layout.Columns != "" {
+			if col, ok = layout.valueOf(root, path, layout.Columns); !ok {
+				report.unmatched++
+				continue
+			}
+		}
+		report.cells[[2]string{row, col}] += durations[i]
+		report.rowTotals[row] += durations[i]
+		report.colTotals[col] += durations[i]
+		report.totalSeconds += durations[i]
+	}
+	for row := range report.rowTotals {
+		report.rows = append(report.rows, row)
+	}
+	for col := range report.colTotals {
+		report.cols = append(report.cols, col)
+	}
+	sort.Strings(report.rows)
+	sort.Strings(report.cols)
+	return report
+}
+
+func printPivotReport(report pivotReport) {
+	title := report.rowName
+	if report.colName != "" {
+		title += " × " + report.colName
+	}
+	fmt.Fprintf(console, "\n=== Hours by %s ===\n", title)
+
+	fmt.Fprintf(console, "%-20s", report.rowName)
+	if report.colName != "" {
+		for _, col := range report.cols {
+			fmt.Fprintf(console, " %10s", col)
+		}
+	}
+	fmt.Fprintf(console, " %10s\n", "Total")
+	for _, row := range report.rows {
+		fmt.Fprintf(console, "%-20s", row)
+		if report.colName != "" {
+			for _, col := range report.cols {
+				fmt.Fprintf(console, " %10.2f", report.cells[[2]string{row, col}]/3600.0)
+			}
+		}
+		fmt.Fprintf(console, " %10.2f\n", report.rowTotals[row]/3600.0)
+	}
+	if report.colName != "" {
+		fmt.Fprintf(console, "%-20s", "Total")
+		for _, col := range report.cols {
+			fmt.Fprintf(console, " %10.2f", report.colTotals[col]/3600.0)
+		}
+		fmt.Fprintf(console, " %10.2f\n", report.totalSeconds/3600.0)
+	}
+	if report.unmatched > 0 {
+		fmt.Fprintf(console, "Files not matching the layout: %d\n", report.unmatched)
+	}
+}
@@ -0,0 +1,77 @@
+//go:build libav && cgo
+
+package main
+
+/*
+#cgo pkg-config: libavformat libavutil
+#include <stdlib.h>
+#include <libavformat/avformat.h>
+#include <libavutil/error.h>
+
+// hmh_duration opens a file with libavformat and returns its duration in
+// seconds, or a negative AVERROR code.
+static double hmh_duration(const char *path, int *err) {
+	AVFormatContext *ctx = NULL;
+	double seconds = 0;
+
+	*err = avformat_open_input(&ctx, path, NULL, NULL);
+	if (*err < 0) {
+		return 0;
+	}
+	*err = avformat_find_stream_info(ctx, NULL);
+	if (*err >= 0) {
+		if (ctx->duration != AV_NOPTS_VALUE) {
+			seconds = (double)ctx->duration / AV_TIME_BASE;
+		} else {
+			// Some containers only carry per-stream durations.
+			for (unsigned int i = 0; i < ctx->nb_streams; i++) {
+				AVStream *st = ctx->streams[i];
+				if (st->duration != AV_NOPTS_VALUE) {
+					double s = st->duration * av_q2d(st->time_base);
+					if (s > seconds) {
+						seconds = s;
+					}
+				}
+			}
+		}
+	}
+	avformat_close_input(&ctx);
+	return seconds;
+}
+
+static void hmh_strerror(int err, char *buf, size_t size) {
+	av_strerror(err, buf, size);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// libavAvailable reports whether this binary was built with -tags libav.
+const libavAvailable = true
+
+func init() {
+	// Silence libav's own logging; failures surface as probe errors.
+	C.av_log_set_level(C.AV_LOG_QUIET)
+}
+
+// libavDuration probes any container libavformat understands.
+func libavDuration(filePath string) (float64, error) {
+	cpath := C.CString(contentPath(filePath))
+	defer C.free(unsafe.Pointer(cpath))
+
+	var cerr C.int
+	seconds := float64(C.hmh_duration(cpath, &cerr))
+	if cerr < 0 {
+		buf := make([]byte, 256)
+		C.hmh_strerror(cerr, (*C.char)(unsafe.Pointer(&buf[0])), C.size_t(len(buf)))
+		return 0, fmt.Errorf("libav: %s", C.GoString((*C.char)(unsafe.Pointer(&buf[0]))))
+	}
+	if seconds <= 0 {
+		return 0, fmt.Errorf("libav: no duration reported")
+	}
+	return seconds, nil
+}
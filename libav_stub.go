@@ -0,0 +1,12 @@
+//go:build !libav || !cgo
+
+package main
+
+import "errors"
+
+// libavAvailable reports whether this binary was built with -tags libav.
+const libavAvailable = false
+
+func libavDuration(string) (float64, error) {
+	return 0, errors.New("libav backend not compiled in (build with -tags libav)")
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// biquad is a direct form I IIR section.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) filter(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeighting builds the two-stage ITU-R BS.1770 pre-filter (high shelf then
+// high pass) for an arbitrary sample rate.
+func kWeighting(sampleRate float64) [2]biquad {
+	var stages [2]biquad
+
+	// Stage 1: +4 dB high shelf at 1.5 kHz.
+	a := math.Pow(10, 4.0/40)
+	w0 := 2 * math.Pi * 1500 / sampleRate
+	alpha := math.Sin(w0) / (2 / math.Sqrt2)
+	cosw := math.Cos(w0)
+	a0 := (a + 1) - (a-1)*cosw + 2*math.Sqrt(a)*alpha
+	stages[0] = biquad{
+		b0: a * ((a + 1) + (a-1)*cosw + 2*math.Sqrt(a)*alpha) / a0,
+		b1: -2 * a * ((a - 1) + (a+1)*cosw) / a0,
+		b2: a * ((a + 1) + (a-1)*cosw - 2*math.Sqrt(a)*alpha) / a0,
+		a1: 2 * ((a - 1) - (a+1)*cosw) / a0,
+		a2: ((a + 1) - (a-1)*cosw - 2*math.Sqrt(a)*alpha) / a0,
+	}
+
+	// Stage 2: high pass at 38 Hz (the RLB curve).
+	w0 = 2 * math.Pi * 38 / sampleRate
+	alpha = math.Sin(w0) / (2 * 0.5)
+	cosw = math.Cos(w0)
+	a0 = 1 + alpha
+	stages[1] = biquad{
+		b0: (1 + cosw) / 2 / a0,
+		b1: -(1 + cosw) / a0,
+		b2: (1 + cosw) / 2 / a0,
+		a1: -2 * cosw / a0,
+		a2: (1 - alpha) / a0,
+	}
+	return stages
+}
+
+// loudnessMeter computes EBU R128 integrated loudness: 400ms blocks with 75%
+// overlap, an absolute gate at -70 LUFS and a relative gate 10 LU below.
+type loudnessMeter struct {
+	channels  [][2]biquad
+	weights   []float64
+	stepLen   int
+	stepPos   int
+	stepSum   float64
+	steps     [4]float64 // the last four 100ms sub-blocks
+	stepCount int
+	blocks    []float64 // weighted mean square per 400ms block
+}
+
+func (m *loudnessMeter) start(format pcmFormat) {
+	m.channels = make([][2]biquad, format.channels)
+	m.weights = make([]float64, format.channels)
+	for c := range m.channels {
+		m.channels[c] = kWeighting(float64(format.sampleRate))
+		m.weights[c] = 1
+	}
+	// 5.1 layouts: the LFE carries no weight, surrounds are boosted.
+	if format.channels == 6 {
+		m.weights[3], m.weights[4], m.weights[5] = 0, 1.41, 1.41
+	}
+	m.stepLen = format.sampleRate / 10
+	if m.stepLen < 1 {
+		m.stepLen = 1
+	}
+}
+
+func (m *loudnessMeter) process(samples []float64) {
+	n := len(m.channels)
+	for i := 0; i+n <= len(samples); i += n {
+		for c := 0; c < n; c++ {
+			y := m.channels[c][0].filter(samples[i+c])
+			y = m.channels[c][1].filter(y)
+			m.stepSum += m.weights[c] * y * y
+		}
+		m.stepPos++
+		if m.stepPos == m.stepLen {
+			m.steps[m.stepCount%4] = m.stepSum / float64(m.stepLen)
+			m.stepCount++
+			m.stepSum, m.stepPos = 0, 0
+			if m.stepCount >= 4 {
+				m.blocks = append(m.blocks, (m.steps[0]+m.steps[1]+m.steps[2]+m.steps[3])/4)
+			}
+		}
+	}
+}
+
+func blockLoudness(meanSquare float64) float64 {
+	return -0.691 + 10*math.Log10(meanSquare)
+}
+
+// integrated returns the gated loudness in LUFS, or -Inf when every block
+// falls below the absolute gate.
+func (m *loudnessMeter) integrated() float64 {
+	var sum float64
+	var n int
+	for _, b := range m.blocks {
+		if blockLoudness(b) > -70 {
+			sum += b
+			n++
+		}
+	}
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	relative := blockLoudness(sum/float64(n)) - 10
+
+	sum, n = 0, 0
+	for _, b := range m.blocks {
+		if l := blockLoudness(b); l > -70 && l > relative {
+			sum += b
+			n++
+		}
+	}
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	return blockLoudness(sum / float64(n))
+}
+
+type loudnessOutlier struct {
+	path string
+	lufs float64
+}
+
+type loudnessReport struct {
+	measured  []float64 // sorted integrated loudness of measurable files
+	belowGate int       // files with no block above the absolute gate
+	skipped   int
+	median    float64
+	tolerance float64
+	outliers  []loudnessOutlier
+}
+
+func buildLoudnessReport(audioFiles []string, durations []float64, stats []sampleStats, tolerance float64) loudnessReport {
+	report := loudnessReport{tolerance: tolerance}
+	for i, s := range stats {
+		if durations[i] <= 0 {
+			continue
+		}
+		switch {
+		case !s.analyzed:
+			report.skipped++
+		case math.IsInf(s.loudness, -1):
+			report.belowGate++
+		default:
+			report.measured = append(report.measured, s.loudness)
+		}
+	}
+	sort.Float64s(report.measured)
+	if len(report.measured) == 0 {
+		return report
+	}
+
+	report.median = quantile(report.measured, 0.5)
+	for i, s := range stats {
+		if s.analyzed && !math.IsInf(s.loudness, -1) && math.Abs(s.loudness-report.median) > tolerance {
+			report.outliers = append(report.outliers, loudnessOutlier{path: audioFiles[i], lufs: s.loudness})
+		}
+	}
+	sort.Slice(report.outliers, func(i, j int) bool {
+		return math.Abs(report.outliers[i].lufs-report.median) > math.Abs(report.outliers[j].lufs-report.median)
+	})
+	return report
+}
+
+func printLoudnessReport(root string, report loudnessReport) {
+	fmt.Fprintln(console, "\n=== Loudness (EBU R128) ===")
+	fmt.Fprintf(console, "Measured files: %d\n", len(report.measured))
+	if report.belowGate > 0 {
+		fmt.Fprintf(console, "Below -70 LUFS gate: %d\n", report.belowGate)
+	}
+	if report.skipped > 0 {
+		fmt.Fprintf(console, "Not analyzed (no sample decoder or decode error): %d\n", report.skipped)
+	}
+	if len(report.measured) == 0 {
+		return
+	}
+
+	m := report.measured
+	fmt.Fprintf(console, "Integrated loudness: min %.1f / p10 %.1f / median %.1f / p90 %.1f / max %.1f LUFS\n",
+		m[0], quantile(m, 0.1), report.median, quantile(m, 0.9), m[len(m)-1])
+	fmt.Fprintf(console, "Outliers (more than %.1f LU from median): %d\n", report.tolerance, len(report.outliers))
+	printList(len(report.outliers), func(i int) string {
+		o := report.outliers[i]
+		return fmt.Sprintf("%s (%.1f LUFS, %+.1f LU)", relPath(root, o.path), o.lufs, o.lufs-report.median)
+	})
+}
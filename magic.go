@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sniffContainer names the container a file's leading bytes identify,
+// using the extension it would normally carry, or "" when unrecognised.
+func sniffContainer(filePath string) string {
+	file, err := openAudio(filePath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	head := make([]byte, 12)
+	n, _ := io.ReadFull(file, head)
+	return containerOf(head[:n])
+}
+
+func containerOf(head []byte) string {
+	switch {
+	case len(head) >= 12 && bytes.HasPrefix(head, []byte("RIFF")) && string(head[8:12]) == "WAVE":
+		return ".wav"
+	case len(head) >= 12 && (bytes.HasPrefix(head, []byte("RF64")) || bytes.HasPrefix(head, []byte("BW64"))) && string(head[8:12]) == "WAVE":
+		return ".wav"
+	case len(head) >= 12 && bytes.HasPrefix(head, []byte("FORM")) && (string(head[8:12]) == "AIFF" || string(head[8:12]) == "AIFC"):
+		return ".aiff"
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return ".ogg"
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return ".flac"
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		return ".m4a"
+	case bytes.HasPrefix(head, []byte("ID3")):
+		return ".mp3"
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0 && head[1]&0x06 != 0:
+		// MPEG audio frame sync with a layer set; ADTS AAC has layer 0.
+		return ".mp3"
+	}
+	return ""
+}
+
+// extensionAliases are extensions that name the same container.
+var extensionAliases = map[string]string{
+	".aif":  ".aiff",
+	".mp4":  ".m4a",
+	".m4b":  ".m4a",
+	".aax":  ".m4a",
+	".oga":  ".ogg",
+	".opus": ".ogg",
+}
+
+// containerMismatch reports whether a recognised container is not the one
+// the file's extension names, returning the extension's canonical form.
+func containerMismatch(path, detected string) (string, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if alias, ok := extensionAliases[ext]; ok {
+		ext = alias
+	}
+	return ext, detected != "" && ext != detected
+}
+
+type extensionMismatch struct {
+	path     string
+	ext      string
+	detected string
+}
+
+// extensionReport lists files whose content is not what their extension
+// says, which breaks loaders that dispatch on the extension.
+type extensionReport struct {
+	checked      int
+	unrecognised int
+	mismatches   []extensionMismatch
+}
+
+func buildExtensionReport(audioFiles []string, containers []string) extensionReport {
+	var report extensionReport
+	for i, detected := range containers {
+		report.checked++
+		if detected == "" {
+			report.unrecognised++
+			continue
+		}
+		if ext, mismatch := containerMismatch(audioFiles[i], detected); mismatch {
+			report.mismatches = append(report.mismatches, extensionMismatch{path: audioFiles[i], ext: ext, detected: detected})
+		}
+	}
+	sort.Slice(report.mismatches, func(i, j int) bool { return report.mismatches[i].path < report.mismatches[j].path })
+	return report
+}
+
+func printExtensionReport(root string, report extensionReport) {
+	fmt.Fprintln(console, "\n=== Extension check ===")
+	fmt.Fprintf(console, "Checked files: %d\n", report.checked)
+	if report.unrecognised > 0 {
+		fmt.Fprintf(console, "Unrecognised content: %d\n", report.unrecognised)
+	}
+	fmt.Fprintf(console, "Extension mismatches: %d\n", len(report.mismatches))
+	if len(report.mismatches) == 0 {
+		return
+	}
+
+	pairs := make(map[string]int)
+	for _, m := range report.mismatches {
+		pairs[fmt.Sprintf("%s files containing %s", m.ext, strings.TrimPrefix(m.detected, "."))]++
+	}
+	keys := make([]string, 0, len(pairs))
+	for k := range pairs {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if pairs[keys[i]] != pairs[keys[j]] {
+			return pairs[keys[i]] > pairs[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	for _, k := range keys {
+		fmt.Fprintf(console, "  %s: %d\n", k, pairs[k])
+	}
+	fmt.Fprintln(console)
+	printList(len(report.mismatches), func(i int) string {
+		m := report.mismatches[i]
+		return fmt.Sprintf("%s (content is %s)", relPath(root, m.path), strings.TrimPrefix(m.detected, "."))
+	})
+}
@@ -1,14 +1,16 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/go-audio/wav"
 	"github.com/schollz/progressbar/v3"
 	"github.com/tcolgate/mp3"
 )
@@ -16,34 +18,78 @@ import (
 // Worker pool size - adjust based on your CPU cores
 var numWorkers = runtime.NumCPU()
 
+// workingDayHours converts listening time into working days.
+const workingDayHours = 8.0
+
 type fileJob struct {
 	path  string
 	index int
+	// slot is the probing worker's, for skip requests; nil outside a scan.
+	slot *skipSlot
 }
 
 type result struct {
 	index    int
 	duration float64
 	err      error
+	prober   string // backend that produced the duration
+	metadata map[string]any
+	samples  sampleStats
+	// format is only filled in with --parquet or --json-files.
+	format streamFormat
+	// truncation is only filled in with --check-truncation.
+	truncation truncationCheck
+	// chapters is only filled in with --audiobooks or --chapters.
+	chapters []chapter
+	// tracks is only filled in with --tracks.
+	tracks []audioTrack
+	// chains is only filled in with --chains.
+	chains int
+	// streamTracks is only filled in with --stream-tracks.
+	streamTracks []chapter
+	// profile is only filled in with --codec-profiles.
+	profile string
+	// tagDuration and tagSource are only filled in with --tag-audit.
+	tagDuration float64
+	tagSource   string
+	// bwf is only filled in with --bwf.
+	bwf bwfInfo
+	// container is only filled in with --check-extensions.
+	container string
+	// hash is only filled in with --checksums.
+	hash    string
+	size    int64
+	modTime time.Time
+	// version and cached are only filled in with --cache.
+	version string
+	cached  bool
 }
 
 func getAudioDuration(filePath string) (float64, error) {
-	ext := strings.ToLower(filepath.Ext(filePath))
+	ext := streamManifestExt(filePath)
 
 	switch ext {
 	case ".mp3":
 		return getMP3Duration(filePath)
 	case ".wav":
 		return getWAVDuration(filePath)
-	case ".m4a":
+	case ".m4a", ".m4b", ".aax":
 		return getM4ADuration(filePath)
+	case ".ogg":
+		return getOggDuration(filePath)
+	case ".mod", ".xm", ".s3m", ".it":
+		return getTrackerDuration(filePath)
+	case ".m3u8":
+		return hlsDuration(filePath)
+	case ".mpd":
+		return dashDuration(filePath)
 	default:
 		return 0, fmt.Errorf("unsupported format: %s", ext)
 	}
 }
 
 func getMP3Duration(filePath string) (float64, error) {
-	file, err := os.Open(filePath)
+	file, err := openAudio(filePath)
 	if err != nil {
 		return 0, err
 	}
@@ -56,6 +102,9 @@ func getMP3Duration(filePath string) (float64, error) {
 
 	for {
 		err := decoder.Decode(&frame, &skipped)
+		if errors.Is(err, errProbeLimit) || errors.Is(err, errScanLimit) {
+			return 0, err
+		}
 		if err != nil {
 			break
 		}
@@ -66,101 +115,36 @@ func getMP3Duration(filePath string) (float64, error) {
 }
 
 func getWAVDuration(filePath string) (float64, error) {
-	file, err := os.Open(filePath)
+	file, err := openAudio(filePath)
 	if err != nil {
 		return 0, err
 	}
 	defer file.Close()
 
-	decoder := wav.NewDecoder(file)
-	if !decoder.IsValidFile() {
-		return 0, fmt.Errorf("invalid WAV file")
-	}
-
-	duration, err := decoder.Duration()
-	if err != nil {
-		return 0, err
-	}
-	return duration.Seconds(), nil
+	// The duration comes from the chunks rather than go-audio/wav, which
+	// divides the RIFF size (headers included) by the byte rate and so
+	// cannot cope with streamed or RF64 sizes.
+	return wavDuration(file)
 }
 
 func getM4ADuration(filePath string) (float64, error) {
-	file, err := os.Open(filePath)
+	file, err := openAudio(filePath)
 	if err != nil {
 		return 0, err
 	}
 	defer file.Close()
 
-	// Read file info to get size
-	info, err := file.Stat()
+	// M4A files use the MP4 container format; the duration lives in the
+	// movie header ('mvhd') nested inside 'moov'.
+	info, err := parseMP4(file)
 	if err != nil {
 		return 0, err
 	}
 
-	// Parse M4A/MP4 atoms to find duration
-	// M4A files use MP4 container format with atoms/boxes
-	buf := make([]byte, 8)
-	var duration float64
-	var timeScale uint32
-
-	for {
-		_, err := file.Read(buf)
-		if err != nil {
-			break
-		}
-
-		// Read atom size and type
-		size := uint32(buf[0])<<24 | uint32(buf[1])<<16 | uint32(buf[2])<<8 | uint32(buf[3])
-		atomType := string(buf[4:8])
-
-		if size == 0 {
-			break
-		}
-
-		// Look for 'mvhd' (movie header) atom which contains duration
-		if atomType == "mvhd" {
-			headerBuf := make([]byte, size-8)
-			_, err := file.Read(headerBuf)
-			if err != nil {
-				break
-			}
-
-			// Parse mvhd atom
-			version := headerBuf[0]
-			if version == 0 {
-				// Version 0: 32-bit values
-				timeScale = uint32(headerBuf[12])<<24 | uint32(headerBuf[13])<<16 | uint32(headerBuf[14])<<8 | uint32(headerBuf[15])
-				durationUnits := uint32(headerBuf[16])<<24 | uint32(headerBuf[17])<<16 | uint32(headerBuf[18])<<8 | uint32(headerBuf[19])
-				if timeScale > 0 {
-					duration = float64(durationUnits) / float64(timeScale)
-				}
-			} else if version == 1 {
-				// Version 1: 64-bit values
-				timeScale = uint32(headerBuf[20])<<24 | uint32(headerBuf[21])<<16 | uint32(headerBuf[22])<<8 | uint32(headerBuf[23])
-				durationUnits := uint64(headerBuf[24])<<56 | uint64(headerBuf[25])<<48 | uint64(headerBuf[26])<<40 | uint64(headerBuf[27])<<32 |
-					uint64(headerBuf[28])<<24 | uint64(headerBuf[29])<<16 | uint64(headerBuf[30])<<8 | uint64(headerBuf[31])
-				if timeScale > 0 {
-					duration = float64(durationUnits) / float64(timeScale)
-				}
-			}
-			break
-		}
-
-		// Skip to next atom
-		if size > 8 {
-			_, err = file.Seek(int64(size-8), 1)
-			if err != nil {
-				break
-			}
-		}
-
-		// Safety check to prevent infinite loops
-		currentPos, _ := file.Seek(0, 1)
-		if currentPos >= info.Size() {
-			break
-		}
+	duration := info.seconds()
+	if trackDuration == trackDurationSum {
+		duration = info.trackSeconds()
 	}
-
 	if duration == 0 {
 		return 0, fmt.Errorf("could not parse M4A duration")
 	}
@@ -168,77 +152,272 @@ func getM4ADuration(filePath string) (float64, error) {
 	return duration, nil
 }
 
-func worker(jobs <-chan fileJob, results chan<- result, wg *sync.WaitGroup, progress *progressbar.ProgressBar) {
+func worker(jobs <-chan fileJob, results []result, wg *sync.WaitGroup, progress progressMeter, opts options) {
 	defer wg.Done()
+	t := newTally(progress)
+	defer t.flush()
+	slot := skipper.slot()
+	defer skipper.release(slot)
 	for job := range jobs {
-		duration, err := getAudioDuration(job.path)
-		if err != nil {
-			results <- result{index: job.index, duration: 0, err: err}
-		} else {
-			results <- result{index: job.index, duration: duration, err: nil}
+		if opts.idleGate != nil {
+			opts.idleGate.wait()
 		}
-		progress.Add(1)
+		job.slot = slot
+		start := time.Now()
+		results[job.index] = processFile(job, opts)
+		t.record(start)
 	}
 }
 
-func main() {
-	if len(os.Args) != 2 {
-		fmt.Println("Usage: calculate <folder_path>")
-		return
+// processFile probes one file and runs the per-file passes the options ask
+// for. A panic in a decoder fails that file instead of the whole scan.
+func processFile(job fileJob, opts options) (res result) {
+	if skipper.covers(job.path) {
+		return result{index: job.index, err: errUserSkipped}
+	}
+	if !pathAllowed(job.path, opts.allowedRoots) {
+		return result{index: job.index, err: errNotAllowed}
+	}
+	job.slot.probing(filepath.Dir(job.path))
+	defer job.slot.probing("")
+	var size int64
+	var modTime time.Time
+	var version string
+	if info, err := os.Stat(contentPath(job.path)); err == nil {
+		size, modTime = info.Size(), info.ModTime()
+		if opts.probeCache != nil {
+			version = fileVersion(job.path, modTime) + proberVersion(job.path, opts)
+			if trackDuration != trackDurationContainer {
+				// Cached MP4 durations depend on how tracks are counted.
+				version += ";tracks=" + trackDuration
+			}
+		}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			res = result{index: job.index, err: fmt.Errorf("malformed file: %v", r), size: size, modTime: modTime}
+		}
+	}()
+
+	// Sniffed before probing so files that fail to probe are checked too.
+	var container string
+	if opts.checkExtensions {
+		container = sniffContainer(job.path)
+	}
+
+	var duration float64
+	var prober string
+	var metadata map[string]any
+	var err error
+	entry, cached := opts.probeCache.lookup(job.path, size, version)
+	if cached {
+		duration, prober, metadata = entry.Duration, entry.Prober, entry.Metadata
+	} else {
+		duration, prober, metadata, err = probeDuration(job.path, opts)
+	}
+	if err != nil {
+		res = result{index: job.index, duration: 0, err: err, size: size, modTime: modTime, container: container}
+		if algo := opts.hashAlgorithm(); algo != "" {
+			res.hash, res.size, _ = hashFile(job.path, algo)
+		}
+		return res
+	}
+
+	res = result{index: job.index, duration: duration, err: nil, prober: prober, metadata: metadata, size: size, modTime: modTime, container: container, version: version, cached: cached}
+	if opts.wantsSamples() {
+		res.samples = analyzeSamples(job.path, opts)
+	}
+	if opts.wantsFormat() {
+		res.format = probeFormat(job.path, metadata)
+	}
+	if opts.checkTruncation {
+		res.truncation = checkTruncation(job.path)
+	}
+	if opts.tagAudit {
+		res.tagDuration, res.tagSource = tagDuration(job.path)
+	}
+	if opts.audiobooks || opts.chapters {
+		res.chapters = readChapters(job.path, duration)
+	}
+	if opts.tracks {
+		res.tracks = readTracks(job.path)
+	}
+	if opts.chains {
+		res.chains = readOggChains(job.path)
+	}
+	if opts.streamTracks {
+		res.streamTracks = readStreamTracks(job.path, duration)
 	}
+	if opts.codecProfiles {
+		res.profile = readCodecProfile(job.path)
+	}
+	if opts.bwf {
+		res.bwf = readBWF(job.path)
+		if res.bwf.found {
+			if res.metadata == nil {
+				res.metadata = make(map[string]any)
+			}
+			for k, v := range res.bwf.metadata() {
+				res.metadata[k] = v
+			}
+		}
+	}
+	if algo := opts.hashAlgorithm(); algo != "" {
+		res.hash, res.size, _ = hashFile(job.path, algo)
+	}
+	return res
+}
 
-	folderPath := os.Args[1]
+// audioExtensions returns the extensions scanned as audio, including any
+// contributed by prober plugins.
+func audioExtensions(opts options) map[string]bool {
 	extensions := map[string]bool{
 		".mp3":  true,
 		".wav":  true,
 		".ogg":  true,
 		".flac": true,
 		".m4a":  true,
+		".m4b":  true,
+		".aax":  true,
+		".m3u8": true,
+		".mpd":  true,
+		".mod":  true,
+		".xm":   true,
+		".s3m":  true,
+		".it":   true,
 	}
-
-	// Resolve symlink if needed
-	resolvedPath, err := filepath.EvalSymlinks(folderPath)
-	if err != nil {
-		fmt.Printf("Error resolving path: %v\n", err)
-		return
+	for ext := range opts.plugins {
+		extensions[ext] = true
 	}
+	return extensions
+}
 
-	fmt.Printf("Scanning directory: %s\n", resolvedPath)
+// scanTree lists the files found under a root, split by role.
+type scanTree struct {
+	audioFiles  []string
+	sizes       map[string]int64 // audio file sizes from the walk
+	transcripts []string
+	subtitles   []string
+	// placeholders stand in for audio not present in the working tree
+	// (LFS pointers, DVC stubs, ...); they are not probed.
+	placeholders     []string
+	placeholderKinds map[string]string
+}
 
-	// Collect audio files
-	var audioFiles []string
-	err = filepath.Walk(resolvedPath, func(path string, info os.FileInfo, err error) error {
+// walkTree collects audio files and, when the options ask for them, the
+// sidecar files reports pair with audio.
+func walkTree(root string, extensions map[string]bool, opts options) (scanTree, error) {
+	tree := scanTree{sizes: make(map[string]int64), placeholderKinds: make(map[string]string)}
+	if isStreamURL(root) {
+		tree.audioFiles = []string{root}
+		return tree, nil
+	}
+	// covered holds the real paths of the directories walked so far, for
+	// --interactive to tell symlink loops and duplicates apart.
+	covered := []string{root}
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		covered[0] = real
+	}
+	var visit filepath.WalkFunc
+	visit = func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			fmt.Printf("Warning: skipping %s: %v\n", path, err)
+			printf("Warning: skipping %s: %v\n", path, err)
+			opts.audit.skipped(path, auditUnreadable, err.Error())
 			return nil // Skip files we can't read
 		}
+		dir := path
+		if !info.IsDir() {
+			dir = filepath.Dir(path)
+		}
+		if skipper.covers(path) {
+			opts.audit.skipped(dir, auditUserSkip, "")
+			return filepath.SkipDir
+		}
+		skipper.walk(dir)
+		if info.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Stat(path); err == nil && target.IsDir() {
+				real, err := filepath.EvalSymlinks(path)
+				if err != nil || opts.prompt == nil {
+					opts.audit.skipped(path, auditSymlinkDir, "")
+					return nil
+				}
+				desc, loop := symlinkTarget(real, covered)
+				if loop {
+					fmt.Fprintf(os.Stderr, "Not following %s: it %s\n", path, desc)
+					opts.audit.skipped(path, auditSymlinkLoop, real)
+					return nil
+				}
+				if !opts.prompt.ask(askSymlink, fmt.Sprintf("%s is a symlinked directory that %s. Scan it?", path, desc)) {
+					opts.audit.skipped(path, auditDeclined, askSymlink)
+					return nil
+				}
+				covered = append(covered, real)
+				return filepath.Walk(path+string(filepath.Separator), visit)
+			}
+		}
 		if !info.IsDir() {
-			ext := strings.ToLower(filepath.Ext(path))
+			ext := strings.ToLower(nfc(filepath.Ext(path)))
 			if extensions[ext] {
-				audioFiles = append(audioFiles, path)
+				if kind := placeholderKind(path, info); kind != "" {
+					tree.placeholders = append(tree.placeholders, path)
+					tree.placeholderKinds[path] = kind
+					return nil
+				}
+				if opts.prompt != nil {
+					detected := sniffContainer(path)
+					if ext, mismatch := containerMismatch(path, detected); mismatch {
+						if !opts.prompt.ask(askMismatch, fmt.Sprintf("%s holds %s data, not %s. Count it?", path, strings.TrimPrefix(detected, "."), ext)) {
+							opts.audit.skipped(path, auditDeclined, askMismatch)
+							return nil
+						}
+					}
+				}
+				tree.audioFiles = append(tree.audioFiles, path)
+				tree.sizes[path] = info.Size()
+			} else if kind := archiveKind(path); kind != "" && opts.prompt != nil {
+				if !opts.prompt.ask(askArchive, fmt.Sprintf("%s is a %s archive. Extract and count the audio inside?", path, kind)) {
+					opts.audit.skipped(path, auditDeclined, askArchive)
+					return nil
+				}
+				members, err := extractArchive(path, kind, extensions)
+				if err != nil {
+					printf("Warning: skipping %s: %v\n", path, err)
+					opts.audit.skipped(path, auditUnreadable, err.Error())
+				}
+				for _, member := range members {
+					tree.audioFiles = append(tree.audioFiles, member)
+					if info, err := os.Stat(contentPath(member)); err == nil {
+						tree.sizes[member] = info.Size()
+					}
+				}
+			} else if audio, ok := dvcPlaceholder(path, extensions); ext == ".dvc" && ok {
+				tree.placeholders = append(tree.placeholders, audio)
+				tree.placeholderKinds[audio] = placeholderDVC
+			} else if opts.requireTranscript != "" && ext == opts.requireTranscript {
+				tree.transcripts = append(tree.transcripts, path)
+				opts.audit.skipped(path, auditSidecar, "transcript")
+			} else if opts.subtitles && subtitleExtensions[ext] {
+				tree.subtitles = append(tree.subtitles, path)
+				opts.audit.skipped(path, auditSidecar, "subtitle")
+			} else {
+				opts.audit.skipped(path, auditExtension, ext)
 			}
 		}
 		return nil
-	})
-
-	if err != nil {
-		fmt.Printf("Error reading directory: %v\n", err)
-		return
-	}
-
-	if len(audioFiles) == 0 {
-		fmt.Println("No audio files found in the folder.")
-		return
 	}
+	err := filepath.Walk(root, visit)
+	skipper.walk("")
+	tree.audioFiles = dropVariantPlaylists(tree.audioFiles, opts.audit)
+	return tree, err
+}
 
-	fmt.Printf("Found %d audio files. Processing with %d workers...\n\n", len(audioFiles), numWorkers)
-
-	// Create progress bar
-	bar := progressbar.NewOptions(len(audioFiles),
+// newProgressBar draws the per-file progress shown while probing.
+func newProgressBar(files int) *progressbar.ProgressBar {
+	return progressbar.NewOptions(files,
 		progressbar.OptionEnableColorCodes(true),
 		progressbar.OptionShowBytes(false),
 		progressbar.OptionSetWidth(50),
-		progressbar.OptionSetDescription("[cyan]Processing files...[reset]"),
+		progressbar.OptionSetDescription("[cyan]"+tr("Processing files...")+"[reset]"),
 		progressbar.OptionSetTheme(progressbar.Theme{
 			Saucer:        "[green]=[reset]",
 			SaucerHead:    "[green]>[reset]",
@@ -249,66 +428,767 @@ func main() {
 		progressbar.OptionShowCount(),
 		progressbar.OptionShowIts(),
 		progressbar.OptionSetItsString("files"),
+		progressbar.OptionSetWriter(console),
 	)
+}
 
-	// Create worker pool
+// processFiles probes every file on the worker pool behind a progress bar,
+// in the --order schedule, and returns the results in input order.
+func processFiles(audioFiles []string, sizes map[string]int64, opts options) []result {
+	bar := newProgress(len(audioFiles))
+
+	// Create worker pool. Each job owns one slot of fileResults, so workers
+	// fill it without coordinating.
 	jobs := make(chan fileJob, len(audioFiles))
-	results := make(chan result, len(audioFiles))
+	fileResults := make([]result, len(audioFiles))
 	var wg sync.WaitGroup
 
 	// Start workers
-	for i := 0; i < numWorkers; i++ {
+	if opts.ioWorkers > 0 {
 		wg.Add(1)
-		go worker(jobs, results, &wg, bar)
+		go func() {
+			defer wg.Done()
+			runPipeline(jobs, fileResults, bar, opts)
+		}()
+	} else {
+		for i := 0; i < numWorkers; i++ {
+			wg.Add(1)
+			go worker(jobs, fileResults, &wg, bar, opts)
+		}
 	}
 
 	// Send jobs
-	for i, file := range audioFiles {
-		jobs <- fileJob{path: file, index: i}
+	for _, i := range scheduleFiles(audioFiles, sizes, opts.order) {
+		jobs <- fileJob{path: audioFiles[i], index: i}
 	}
 	close(jobs)
+	wg.Wait()
+
+	bar.Finish()
+	fmt.Fprintln(console)
+	return fileResults
+}
 
-	// Close results channel when all workers are done
-	go func() {
-		wg.Wait()
-		close(results)
+func main() {
+	os.Exit(run())
+}
+
+// run is the whole program but for the exit, which it returns as a status
+// so that its deferred cleanup (the audit log, the trace, extracted
+// archives) runs before main exits.
+func run() int {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "split":
+			runSplit(os.Args[2:])
+			return 0
+		case "merge":
+			runMerge(os.Args[2:])
+			return 0
+		case "compare":
+			runCompare(os.Args[2:])
+			return 0
+		case "self-update":
+			runSelfUpdate(os.Args[2:])
+			return 0
+		case "completion":
+			runCompletion(os.Args[2:])
+			return 0
+		}
+	}
+
+	opts := parseOptions()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		return 0
+	}
+
+	// Every scan ends with the exit line, whichever way it ends; the
+	// deferred call runs after all other cleanup.
+	start := time.Now()
+	exit := scanSummary{Root: flag.Arg(0)}
+	var exitErr error
+	defer func() {
+		writeExitLine(os.Stderr, exit, time.Since(start), exitErr)
 	}()
 
-	// Collect results
-	durations := make([]float64, len(audioFiles))
-	errorCount := 0
+	// A machine-readable summary bound for stdout gets stdout to itself; the
+	// usual report and progress bar move to stderr.
+	if opts.machineSummary() && opts.output == "" {
+		console = os.Stderr
+	}
+
+	folderPath := flag.Arg(0)
+	extensions := audioExtensions(opts)
+
+	// Resolve symlink if needed
+	resolvedPath := folderPath
+	var err error
+	if !isStreamURL(folderPath) {
+		if resolvedPath, err = resolveRoot(folderPath); err != nil {
+			printf("Error resolving path: %v\n", err)
+			exitErr = err
+			return 0
+		}
+	}
+	exit.Root = resolvedPath
+	// Checked before anything, the trace included, is written.
+	if err := checkScanSafety(resolvedPath, opts); err != nil {
+		printf("Error: %v\n", err)
+		exitErr = err
+		return 2
+	}
+	setManifestScope(resolvedPath, opts.allowedRoots)
+
+	stopProfiling, err := startProfiling(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting trace: %v\n", err)
+		exitErr = err
+		return 1
+	}
+	defer stopProfiling()
+	tel := newTelemetry(opts.otlp)
+
+	if opts.join != "" {
+		if err := runJoin(opts.join, resolvedPath, opts); err != nil {
+			printf("Error: %v\n", err)
+			exitErr = err
+			return 1
+		}
+		return 0
+	}
+	if opts.queueJobs != "" {
+		if err := runQueueWorker(resolvedPath, opts); err != nil {
+			printf("Error: %v\n", err)
+			exitErr = err
+			return 1
+		}
+		return 0
+	}
+	if opts.daemon != "" {
+		if err := runDaemon(opts.daemon, resolvedPath, opts); err != nil {
+			printf("Error: %v\n", err)
+			exitErr = err
+			return 1
+		}
+		return 0
+	}
+
+	var expectedManifest manifest
+	if opts.verifyManifest != "" {
+		expectedManifest, err = readManifest(opts.verifyManifest)
+		if err != nil {
+			printf("Error reading manifest: %v\n", err)
+			exitErr = err
+			return 0
+		}
+		opts.checksums = expectedManifest.algo
+	}
+
+	var expectedCounts map[string]expectedCount
+	if opts.expectCounts != "" {
+		expectedCounts, err = loadExpectedCounts(opts.expectCounts)
+		if err != nil {
+			printf("Error reading expected counts: %v\n", err)
+			exitErr = err
+			return 0
+		}
+	}
+
+	var segmentSets []segmentSet
+	if len(opts.segments) > 0 {
+		segmentSets, err = loadSegments(opts.segments)
+		if err != nil {
+			printf("Error reading segments: %v\n", err)
+			exitErr = err
+			return 0
+		}
+	}
+
+	if opts.auditLog != "" {
+		if opts.audit, err = openAuditLog(opts.auditLog, resolvedPath); err != nil {
+			printf("Error writing audit log: %v\n", err)
+			exitErr = err
+			return 1
+		}
+		defer func() {
+			if err := opts.audit.Close(); err != nil {
+				printf("Error writing audit log: %v\n", err)
+			}
+		}()
+	}
+
+	defer func() {
+		if archiveDir != "" {
+			os.RemoveAll(archiveDir)
+		}
+	}()
+
+	printf("Scanning directory: %s\n", displayPath(resolvedPath))
+
+	if !isStreamURL(resolvedPath) {
+		startSkipper(resolvedPath)
+	}
+	walkStart := time.Now()
+	tree, err := walkTree(resolvedPath, extensions, opts)
+	walkTime := time.Since(walkStart)
+	tel.stage("walk", walkStart, walkStart.Add(walkTime), map[string]any{"howmanyhours.files": len(tree.audioFiles)})
+	if err != nil {
+		printf("Error reading directory: %v\n", err)
+		exitErr = err
+		return 0
+	}
+	audioFiles, transcripts, subtitles := tree.audioFiles, tree.transcripts, tree.subtitles
+	placeholders := tree.placeholders
+
+	// Placeholders whose content sits in a local cache are probed from
+	// there; the rest keep the size their pointer records.
+	recordedSizes := make(map[string]int64)
+	resolvedCount := 0
+	if opts.resolvePointers {
+		var unresolved []string
+		for _, path := range placeholders {
+			ptr := resolvePlaceholder(path, tree.placeholderKinds[path])
+			if ptr.content == "" {
+				unresolved = append(unresolved, path)
+				recordedSizes[path] = ptr.size
+				continue
+			}
+			contentPaths[path] = ptr.content
+			audioFiles = append(audioFiles, path)
+			if info, err := os.Stat(ptr.content); err == nil {
+				tree.sizes[path] = info.Size()
+			}
+			resolvedCount++
+		}
+		placeholders = unresolved
+	}
+	for _, path := range placeholders {
+		opts.audit.skipped(path, auditStub, tree.placeholderKinds[path])
+	}
+
+	if len(audioFiles) == 0 {
+		printf("No audio files found in the folder.\n")
+		if len(placeholders) > 0 {
+			printf("Warning: %d placeholder files (%s) have no synced content\n", len(placeholders), placeholderCounts(placeholders, tree.placeholderKinds))
+		}
+		exit.Placeholders = len(placeholders)
+		return 0
+	}
 
-	for res := range results {
-		if res.err != nil {
-			errorCount++
+	if opts.shardSpec.count > 0 {
+		total := len(audioFiles)
+		all := audioFiles
+		audioFiles = shardFiles(resolvedPath, audioFiles, opts.shardSpec)
+		opts.audit.skippedExcept(all, audioFiles, auditShard)
+		placeholders = shardFiles(resolvedPath, placeholders, opts.shardSpec)
+		printf("Shard %s: %d of %d audio files.\n", opts.shardSpec, len(audioFiles), total)
+		exit.Shard = opts.shardSpec.String()
+		if len(audioFiles) == 0 {
+			exit.Placeholders = len(placeholders)
+			return 0
+		}
+	}
+
+	var strata []stratum
+	population := len(audioFiles)
+	if opts.estimate {
+		all := audioFiles
+		audioFiles, strata = sampleFiles(resolvedPath, audioFiles, opts.sampleFraction, opts.seed)
+		opts.audit.skippedExcept(all, audioFiles, auditNotSampled)
+	}
+
+	exit.Files, exit.Placeholders = len(audioFiles), len(placeholders)
+	if opts.dryRun {
+		for _, path := range audioFiles {
+			fmt.Fprintln(console, path)
+		}
+		printf("\n%d files would be scanned.\n", len(audioFiles))
+		return 0
+	}
+	if opts.enqueue != "" {
+		if err := enqueueFiles(opts.enqueue, resolvedPath, audioFiles); err != nil {
+			printf("Error: %v\n", err)
+			exitErr = err
+			return 1
+		}
+		printf("Queued %d files on %s\n", len(audioFiles), redactURL(opts.enqueue))
+		return 0
+	}
+
+	if opts.estimate {
+		printf("Found %d audio files. Sampling %d with %d workers...\n\n", population, len(audioFiles), numWorkers)
+	} else {
+		printf("Found %d audio files. Processing with %d workers...\n\n", len(audioFiles), numWorkers)
+	}
+
+	scanStart := time.Now()
+	var fileResults []result
+	userSkipped := 0
+	if opts.serveWork != "" {
+		fileResults, err = serveWork(opts.serveWork, resolvedPath, audioFiles, opts.security)
+		if err != nil {
+			printf("Error serving work: %v\n", err)
+			exitErr = err
+			return 0
+		}
+	} else {
+		if opts.cache != "" {
+			opts.probeCache, err = readProbeCache(opts.cache)
+			if err != nil {
+				printf("Error reading cache: %v\n", err)
+				exitErr = err
+				return 0
+			}
+		}
+		if opts.priorityList != nil {
+			fileResults = processPrioritized(resolvedPath, audioFiles, tree.sizes, opts.priorityList, opts)
 		} else {
-			durations[res.index] = res.duration
+			fileResults = processFiles(audioFiles, tree.sizes, opts)
+		}
+		if len(skipper.dirs()) > 0 {
+			before := len(audioFiles)
+			var dropped []bool
+			audioFiles, fileResults, dropped = dropUserSkipped(audioFiles, fileResults, opts.audit)
+			userSkipped = before - len(audioFiles)
+			if strata != nil {
+				strata = dropFromStrata(strata, dropped)
+			}
+		}
+		if opts.queueResults != "" {
+			if err := publishResults(opts.queueResults, resolvedPath, audioFiles, fileResults, opts.jsonFiles); err != nil {
+				printf("Warning: could not publish results: %v\n", err)
+			}
+		}
+		if opts.cache != "" {
+			if err := writeProbeCache(opts.cache, opts.probeCache, audioFiles, fileResults); err != nil {
+				printf("Warning: could not update cache: %v\n", err)
+			}
+		}
+	}
+	var growing []bool
+	growingSkipped := 0
+	if opts.growing != "" {
+		growing = findGrowing(resolvedPath, audioFiles, tree.sizes, fileResults, opts.growingGlobs, opts.growingSettle)
+		switch opts.growing {
+		case growingRetry:
+			growing = retryGrowing(resolvedPath, audioFiles, fileResults, growing, opts)
+		case growingSkip:
+			before := len(audioFiles)
+			audioFiles, fileResults = dropGrowing(audioFiles, fileResults, growing, opts.audit)
+			if strata != nil {
+				strata = dropFromStrata(strata, growing)
+			}
+			growingSkipped = before - len(audioFiles)
+			growing = nil
+		}
+	}
+	provisional := 0
+	for _, g := range growing {
+		if g {
+			provisional++
 		}
 	}
+	probeTime := time.Since(scanStart)
+	opts.audit.results(audioFiles, fileResults)
+	aggregateStart := time.Now()
+	tel.stage("probe", scanStart, aggregateStart, map[string]any{"howmanyhours.files": len(audioFiles), "howmanyhours.workers": numWorkers})
 
-	bar.Finish()
-	fmt.Println()
+	durations := make([]float64, len(audioFiles))
+	samples := make([]sampleStats, len(audioFiles))
+	truncations := make([]truncationCheck, len(audioFiles))
+	var shortFiles []string
+	for i, res := range fileResults {
+		if res.err == nil {
+			durations[i] = res.duration
+			samples[i] = res.samples
+			truncations[i] = res.truncation
+			if res.duration < opts.shortDuration.Seconds() {
+				shortFiles = append(shortFiles, audioFiles[i])
+			}
+		}
+	}
 
 	// Calculate totals
-	var totalSeconds float64
-	validFiles := 0
-	for _, d := range durations {
-		if d > 0 {
-			totalSeconds += d
-			validFiles++
+	agg := aggregateResults(fileResults, opts.statsOver)
+	totalSeconds := agg.totalSeconds
+	totalHours := totalSeconds / 3600.0
+	meanHours := agg.meanSeconds / 3600.0
+
+	printf("\n=== Results ===\n")
+	if opts.estimate {
+		printf("Total files found: %d\n", population)
+		printf("Files sampled: %d\n", len(audioFiles))
+	} else {
+		printf("Total files found: %d\n", len(audioFiles))
+	}
+	printf("Successfully processed: %d\n", agg.valid)
+	if agg.zero > 0 {
+		printf("Zero duration: %d\n", agg.zero)
+	}
+	printf("Errors: %d\n", agg.failed)
+	if len(shortFiles) > 0 {
+		printf("Shorter than %s: %d\n", opts.shortDuration, len(shortFiles))
+	}
+	if provisional > 0 {
+		printf("Still being written (provisional): %d\n", provisional)
+	}
+	if growingSkipped > 0 {
+		printf("Skipped while still being written: %d\n", growingSkipped)
+	}
+	if userSkipped > 0 {
+		printf("Skipped at your request: %d in %s\n", userSkipped, skipper.list())
+	}
+	if opts.cache != "" {
+		cachedCount := 0
+		for _, res := range fileResults {
+			if res.cached {
+				cachedCount++
+			}
+		}
+		printf("Served from cache: %d\n", cachedCount)
+	}
+	if resolvedCount > 0 {
+		printf("Placeholders resolved from local caches: %d\n", resolvedCount)
+	}
+	if len(placeholders) > 0 {
+		printf("Placeholders (content not synced): %d (%s)\n", len(placeholders), placeholderCounts(placeholders, tree.placeholderKinds))
+	}
+	if len(opts.plugins) > 0 {
+		pluginCount := 0
+		for _, res := range fileResults {
+			if res.prober == "plugin" {
+				pluginCount++
+			}
+		}
+		printf("Resolved via plugins: %d\n", pluginCount)
+	}
+	if opts.fallback != "" {
+		fallbackCount := 0
+		for _, res := range fileResults {
+			if res.prober == opts.fallback {
+				fallbackCount++
+			}
 		}
+		printf("Resolved via %s fallback: %d\n", opts.fallback, fallbackCount)
+	}
+	printf("Total audio duration: %s\n", opts.numbers.duration(totalSeconds, 2))
+	if opts.integerSeconds {
+		printf("Total audio duration in whole seconds: %d\n", opts.numbers.wholeSeconds(totalSeconds))
+	}
+	if opts.numbers.unit == "hours" {
+		printf("Mean audio duration per file: %s (%s minutes)\n", opts.numbers.duration(meanHours*3600, 4), opts.numbers.number(meanHours*60, 2))
+	} else {
+		printf("Mean audio duration per file: %s\n", opts.numbers.duration(meanHours*3600, 4))
+	}
+	printf("Median audio duration per file: %s\n", opts.numbers.duration(agg.medianSecs, 4))
+	if agg.policy != statsOverValid {
+		printf("Mean and median taken over %s files (--stats-over)\n", agg.policy)
+	}
+	if oldest, newest, ok := modTimeRange(fileResults); ok {
+		printf("Oldest file modified: %s\n", oldest.Format("2006-01-02 15:04"))
+		printf("Newest file modified: %s\n", newest.Format("2006-01-02 15:04"))
+		printf("Date range covered: %s\n", formatSpan(newest.Sub(oldest)))
+	}
+	if len(placeholders) > 0 {
+		printf("Warning: these hours exclude %d placeholder files whose content is not synced\n", len(placeholders))
+		var recorded int64
+		for _, path := range placeholders {
+			recorded += recordedSizes[path]
+		}
+		if rate := bytesPerSecond(fileResults); recorded > 0 && rate > 0 {
+			printf("Their pointers record %.1f MB, roughly %s at this scan's bytes per second\n", float64(recorded)/1e6, opts.numbers.duration(float64(recorded)/rate, 2))
+		}
+	}
+	perf := buildPerfReport(walkTime, probeTime, numWorkers, opts.ioWorkers, len(audioFiles), totalSeconds)
+	printf("Processing speed: %.0f× realtime (%.2f audio-hours per wall-second)\n", perf.realtimeFactor(), perf.realtimeFactor()/3600)
+	usage := readResourceUsage()
+	printResourceUsage(usage)
+	if opts.playbackSpeed > 0 {
+		listening := totalHours / opts.playbackSpeed
+		printf("At %g×, this is %s ≈ %s working days\n", opts.playbackSpeed, opts.numbers.duration(listening*3600, 1), opts.numbers.number(listening/workingDayHours, 1))
 	}
 
-	totalHours := totalSeconds / 3600.0
-	meanHours := 0.0
-	if validFiles > 0 {
-		meanHours = (totalSeconds / float64(validFiles)) / 3600.0
+	if len(shortFiles) > 0 {
+		printf("\nFiles shorter than %s:\n", opts.shortDuration)
+		printPathList(resolvedPath, shortFiles)
+	}
+	if provisional > 0 {
+		printGrowingReport(resolvedPath, audioFiles, durations, growing)
 	}
 
-	fmt.Println("\n=== Results ===")
-	fmt.Printf("Total files found: %d\n", len(audioFiles))
-	fmt.Printf("Successfully processed: %d\n", validFiles)
-	fmt.Printf("Errors: %d\n", errorCount)
-	fmt.Printf("Total audio duration: %.2f hours\n", totalHours)
-	fmt.Printf("Mean audio duration per file: %.4f hours (%.2f minutes)\n", meanHours, meanHours*60)
+	if opts.timings {
+		printPerfReport(perf)
+	}
+	if opts.readCost {
+		for _, path := range audioFiles {
+			perf.treeBytes += tree.sizes[path]
+		}
+		printReadCost(perf, opts.egressCost, opts.requestCost)
+	}
+
+	if opts.parquet != "" {
+		if err := writeParquet(opts.parquet, resolvedPath, audioFiles, fileResults); err != nil {
+			printf("Error writing parquet: %v\n", err)
+		} else {
+			printf("Per-file records written to %s\n", opts.parquet)
+		}
+	}
+
+	if opts.estimate {
+		printEstimate(buildEstimate(strata, durations))
+	}
+
+	if opts.books {
+		speed := opts.playbackSpeed
+		if speed <= 0 {
+			speed = 1
+		}
+		printBooksReport(resolvedPath, audioFiles, durations, speed)
+	}
+
+	if opts.packs {
+		printPackReport(resolvedPath, audioFiles, durations, opts.loopThreshold)
+	}
+
+	if opts.splitReport {
+		printSplitReport(buildSplitReport(resolvedPath, audioFiles, durations, opts.splitDirs, opts.expectRatios, opts.ratioTolerance))
+	}
+
+	if opts.labels {
+		printLabelReport(resolvedPath, audioFiles, durations, opts.imbalanceRatio)
+	}
+
+	if opts.pareto > 0 {
+		printParetoReport(buildParetoReport(resolvedPath, audioFiles, durations, opts.pareto))
+	}
+
+	if opts.diskUsage > 0 {
+		printDiskUsageReport(buildDiskUsageReport(resolvedPath, audioFiles, durations, fileResults, opts.diskUsage))
+	}
+
+	if opts.estimateProc != "" {
+		printProcessingReport(buildProcessingReport(durations, opts.processingPlan))
+	}
+
+	if opts.simulateBitrate != "" {
+		printReencodeReport(buildReencodeReport(resolvedPath, audioFiles, durations, fileResults, opts.bitrateTarget))
+	}
+
+	if opts.ageScatter != "" || opts.agePlot {
+		points := agePoints(resolvedPath, audioFiles, durations, fileResults)
+		if opts.ageScatter != "" {
+			if err := writeAgeScatter(opts.ageScatter, points); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", opts.ageScatter, err)
+			}
+		}
+		if opts.agePlot {
+			printAgePlot(points)
+		}
+	}
+
+	if opts.folded != "" {
+		if err := writeFolded(opts.folded, resolvedPath, audioFiles, durations); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", opts.folded, err)
+		}
+	}
+	if opts.treemap != "" {
+		if err := writeTreemap(opts.treemap, resolvedPath, audioFiles, durations); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", opts.treemap, err)
+		}
+	}
+	if opts.emitDirFiles != "" && !isStreamURL(resolvedPath) {
+		totals := buildDaemonState(resolvedPath, audioFiles, fileResults, scanStart).dirs
+		written, failed := emitDirFiles(opts.emitDirFiles, totals, resolvedPath, audioFiles)
+		printf("\n%s files written in %d of %d directories\n", opts.emitDirFiles, written, len(totals))
+		if len(failed) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: could not write %s in %d directories, e.g. %s\n", opts.emitDirFiles, len(failed), failed[0])
+		}
+	}
+
+	if opts.pivot != nil {
+		printPivotReport(buildPivotReport(resolvedPath, audioFiles, durations, *opts.pivot))
+	}
+
+	if opts.expectCounts != "" {
+		printCompletenessReport(buildCompletenessReport(resolvedPath, audioFiles, durations, expectedCounts, opts.expectTolerance))
+	}
+
+	if opts.speakers.enabled() {
+		printSpeakerReport(buildSpeakerReport(resolvedPath, audioFiles, durations, opts.speakers, opts.speakerMaxShare, opts.splitDirs))
+	}
+
+	if opts.history != "" && !opts.estimate {
+		entry := historyEntry{Time: time.Now(), Root: resolvedPath, Files: len(audioFiles), TotalSeconds: totalSeconds}
+		if err := appendHistory(opts.history, entry); err != nil {
+			printf("Warning: could not update history: %v\n", err)
+		}
+	}
+	if opts.sqlite != "" && !opts.estimate {
+		if id, err := writeSQLite(opts.sqlite, resolvedPath, time.Now(), audioFiles, fileResults); err != nil {
+			printf("Error writing SQLite database: %v\n", err)
+		} else {
+			printf("\nScan %d recorded in %s\n", id, opts.sqlite)
+		}
+	}
+	if opts.goalHours > 0 {
+		var history []historyEntry
+		if opts.history != "" {
+			history, err = readHistory(opts.history, resolvedPath)
+			if err != nil {
+				printf("Warning: could not read history: %v\n", err)
+			}
+		}
+		printGoalReport(buildGoalReport(opts.goalHours, totalSeconds, history, time.Now()))
+	}
+
+	if opts.requireTranscript != "" {
+		printTranscriptReport(resolvedPath, pairTranscripts(audioFiles, durations, transcripts))
+	}
+	if len(opts.segments) > 0 {
+		printSegmentReport(resolvedPath, buildSegmentReport(audioFiles, durations, segmentSets))
+	}
+	if opts.subtitles {
+		printSubtitleReport(resolvedPath, buildSubtitleReport(audioFiles, durations, subtitles, opts.subtitleGap))
+	}
+	if opts.outliers != "" {
+		printOutlierReport(resolvedPath, buildOutlierReport(audioFiles, durations, opts.outlierSpec))
+	}
+	if opts.deep {
+		printVerifyReport(resolvedPath, buildVerifyReport(audioFiles, durations, samples, opts.verifyTolerance))
+		printLoudnessReport(resolvedPath, buildLoudnessReport(audioFiles, durations, samples, opts.loudnessTolerance))
+		printClippingReport(resolvedPath, buildClippingReport(audioFiles, durations, samples, opts.clipPercent))
+	}
+	if opts.audiobooks || opts.chapters {
+		chapters := make([][]chapter, len(fileResults))
+		for i, res := range fileResults {
+			chapters[i] = res.chapters
+		}
+		if opts.audiobooks {
+			printAudiobookReport(resolvedPath, audioFiles, durations, chapters)
+		}
+		if opts.chapters {
+			printChapterListing(resolvedPath, audioFiles, chapters)
+		}
+	}
+	if opts.tracks {
+		tracks := make([][]audioTrack, len(fileResults))
+		for i, res := range fileResults {
+			tracks[i] = res.tracks
+		}
+		printTrackReport(resolvedPath, audioFiles, durations, tracks)
+	}
+	if opts.chains {
+		chains := make([]int, len(fileResults))
+		for i, res := range fileResults {
+			chains[i] = res.chains
+		}
+		printChainReport(resolvedPath, audioFiles, durations, chains)
+	}
+	if opts.streamTracks {
+		tracks := make([][]chapter, len(fileResults))
+		for i, res := range fileResults {
+			tracks[i] = res.streamTracks
+		}
+		printStreamTrackReport(resolvedPath, audioFiles, durations, tracks)
+	}
+	if opts.codecProfiles {
+		profiles := make([]string, len(fileResults))
+		for i, res := range fileResults {
+			profiles[i] = res.profile
+		}
+		printProfileReport(audioFiles, durations, profiles)
+	}
+	if opts.takes {
+		printTakeReport(resolvedPath, buildTakeReport(audioFiles, durations, opts.takeRe))
+	}
+	if opts.bwf {
+		infos := make([]bwfInfo, len(fileResults))
+		for i, res := range fileResults {
+			infos[i] = res.bwf
+		}
+		printBWFReport(audioFiles, durations, infos)
+	}
+	if opts.tagAudit {
+		printTagAuditReport(resolvedPath, buildTagAuditReport(audioFiles, fileResults, opts.tagTolerance))
+	}
+	if opts.duplicateNames {
+		printDuplicateNameReport(resolvedPath, buildDuplicateNameReport(audioFiles, durations, opts.duplicateTol))
+	}
+	if opts.checkExtensions {
+		containers := make([]string, len(fileResults))
+		for i, res := range fileResults {
+			containers[i] = res.container
+		}
+		printExtensionReport(resolvedPath, buildExtensionReport(audioFiles, containers))
+	}
+	if opts.checkTruncation {
+		printTruncationReport(resolvedPath, buildTruncationReport(audioFiles, truncations, opts.verifyTolerance))
+	}
+	if opts.checksums != "" {
+		scanned := buildManifest(resolvedPath, opts.checksums, audioFiles, durations, fileResults)
+		if opts.verifyManifest != "" {
+			printManifestReport(verifyManifest(expectedManifest, scanned, opts.verifyTolerance))
+		} else if err := writeManifest(opts.manifestPath(), scanned); err != nil {
+			printf("Error writing manifest: %v\n", err)
+		} else {
+			printf("\nManifest written to %s\n", opts.manifestPath())
+		}
+	}
+	if opts.speechHours {
+		printSpeechReport(buildSpeechReport(durations, samples))
+	}
+	if opts.detectSilence {
+		printSilenceReport(resolvedPath, buildSilenceReport(audioFiles, durations, samples, opts.silencePercent))
+	}
+
+	summary := newScanSummary(resolvedPath, scanStart, audioFiles, fileResults, opts.jsonFiles, opts.statsOver)
+	if opts.shardSpec.count > 0 {
+		summary.Shard = opts.shardSpec.String()
+	}
+	summary.Placeholders = len(placeholders)
+	summary.Short = len(shortFiles)
+	summary.Provisional = provisional
+	summary.Resources = &usage
+	exit = summary
+	if opts.integerSeconds {
+		seconds := opts.numbers.wholeSeconds(summary.TotalSeconds)
+		summary.TotalSecsInt = &seconds
+	}
+	if opts.scanManifest != "" {
+		m := buildScanManifest(summary, opts, opts.hashAlgorithm(), audioFiles, fileResults)
+		if err := writeScanManifest(opts.scanManifest, m); err != nil {
+			printf("Error writing scan manifest: %v\n", err)
+		} else {
+			printf("\nScan manifest written to %s\n", opts.scanManifest)
+		}
+	}
+	if opts.output != "" {
+		if err := writeSummaryFile(opts.output, opts.appendOutput, summary, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", opts.output, err)
+			exitErr = err
+			return 1
+		}
+	} else if opts.machineSummary() {
+		if err := writeSummary(os.Stdout, summary, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing summary: %v\n", err)
+			exitErr = err
+			return 1
+		}
+	}
+	tel.stage("aggregate", aggregateStart, time.Now(), nil)
+	if err := tel.finish(summary, perf); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not export telemetry: %v\n", err)
+	}
+	if opts.smtp != nil {
+		if err := sendEmail(opts.smtp, opts.emailRecipients, summary, time.Since(walkStart), opts.emailHTML); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not send email: %v\n", err)
+		}
+	}
+	if opts.mqtt != "" {
+		if err := publishMQTT(opts.mqtt, summary); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not publish to MQTT: %v\n", err)
+		}
+	}
+	return 0
 }
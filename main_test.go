@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTemp writes data to a file called name in a fresh directory.
+func writeTemp(t testing.TB, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// pcmWAV is a 16-bit mono WAV header at rate whose data chunk declares
+// declared bytes, followed by actual bytes of silence.
+func pcmWAV(rate uint32, declared, actual int) []byte {
+	b := make([]byte, 44, 44+actual)
+	copy(b[0:], "RIFF")
+	binary.LittleEndian.PutUint32(b[4:], uint32(36+declared))
+	copy(b[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(b[16:], 16)
+	binary.LittleEndian.PutUint16(b[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(b[22:], 1)
+	binary.LittleEndian.PutUint32(b[24:], rate)
+	binary.LittleEndian.PutUint32(b[28:], rate*2)
+	binary.LittleEndian.PutUint16(b[32:], 2)
+	binary.LittleEndian.PutUint16(b[34:], 16)
+	copy(b[36:], "data")
+	binary.LittleEndian.PutUint32(b[40:], uint32(declared))
+	return append(b, make([]byte, actual)...)
+}
+
+func FuzzMP3Duration(f *testing.F) {
+	f.Add([]byte{0xFF, 0xFB, 0x90, 0x64, 0x00})
+	f.Add([]byte("ID3\x04\x00\x00\x00\x00\x00\x00"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		getMP3Duration(writeTemp(t, "f.mp3", data))
+	})
+}
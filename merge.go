@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+)
+
+// readSummaries loads every scan summary in a --format json output file,
+// one JSON object per line as written with --output/--append. Merging
+// needs each file's result, so scans written without --json-files are
+// refused.
+func readSummaries(file string) ([]scanSummary, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var summaries []scanSummary
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1<<20), 1<<30)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var s scanSummary
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, n, err)
+		}
+		if s.Files > 0 && len(s.Results) == 0 {
+			return nil, fmt.Errorf("%s:%d: no per-file results to merge; write the scans with --json-files", file, n)
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, scanner.Err()
+}
+
+// mergeSummaries combines scans into one summary. A file seen in several
+// scans (overlapping shards, or the same root scanned on different days)
+// counts once, taking the result of the most recent scan.
+func mergeSummaries(summaries []scanSummary) (merged scanSummary, duplicates int) {
+	sort.SliceStable(summaries, func(i, j int) bool { return summaries[i].Time.Before(summaries[j].Time) })
+
+	index := make(map[string]int)
+	roots := make(map[string]bool)
+	for _, s := range summaries {
+		roots[s.Root] = true
+		if s.Time.After(merged.Time) {
+			merged.Time = s.Time
+		}
+		for _, f := range s.Results {
+			key := path.Join(s.Root, f.Path)
+			if i, seen := index[key]; seen {
+				merged.Results[i] = f
+				duplicates++
+				continue
+			}
+			index[key] = len(merged.Results)
+			merged.Results = append(merged.Results, f)
+		}
+	}
+	if len(roots) == 1 {
+		merged.Root = summaries[0].Root
+	} else {
+		// Paths from several roots only stay unambiguous with the
+		// root in front.
+		for key, i := range index {
+			merged.Results[i].Path = key
+		}
+	}
+
+	merged.Files = len(merged.Results)
+	var valid []float64
+	for _, f := range merged.Results {
+		if f.Error != "" {
+			merged.Errors++
+			continue
+		}
+		if f.Duration > 0 {
+			merged.Processed++
+			merged.TotalSeconds += f.Duration
+			valid = append(valid, f.Duration)
+		} else {
+			merged.Zero++
+		}
+		if !f.ModTime.IsZero() {
+			if merged.Oldest.IsZero() || f.ModTime.Before(merged.Oldest) {
+				merged.Oldest = f.ModTime
+			}
+			if f.ModTime.After(merged.Newest) {
+				merged.Newest = f.ModTime
+			}
+		}
+	}
+	merged.TotalHours = merged.TotalSeconds / 3600.0
+	if merged.Processed > 0 {
+		merged.MeanSeconds = merged.TotalSeconds / float64(merged.Processed)
+	}
+	sort.Float64s(valid)
+	merged.MedianSecs = quantile(valid, 0.5)
+	merged.StatsOver = statsOverValid
+	sort.Slice(merged.Results, func(i, j int) bool { return merged.Results[i].Path < merged.Results[j].Path })
+	return merged, duplicates
+}
+
+// mergeFlags are the flags of the merge subcommand.
+type mergeFlags struct {
+	format string
+}
+
+func (f *mergeFlags) define(fs *flag.FlagSet) {
+	fs.StringVar(&f.format, "format", "text", "output format: text, or json for a merged scan summary")
+}
+
+// runMerge implements "howManyHours merge a.json b.json ...".
+func runMerge(args []string) {
+	var flags mergeFlags
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	flags.define(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s merge [flags] <scan.json>...\n\nFlags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if flags.format != "text" && flags.format != "json" {
+		fmt.Fprintf(os.Stderr, "Unsupported format: %s\n", flags.format)
+		os.Exit(2)
+	}
+
+	var all []scanSummary
+	for _, file := range fs.Args() {
+		summaries, err := readSummaries(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %v\n", err)
+			os.Exit(1)
+		}
+		all = append(all, summaries...)
+	}
+	if len(all) == 0 {
+		fmt.Fprintln(os.Stderr, "No scan summaries found.")
+		os.Exit(1)
+	}
+	merged, duplicates := mergeSummaries(all)
+
+	if flags.format == "json" {
+		if err := writeSummary(os.Stdout, merged, options{format: "json"}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Println("=== Merged results ===")
+	fmt.Printf("Scans merged: %d\n", len(all))
+	if duplicates > 0 {
+		fmt.Printf("Duplicate paths (latest scan kept): %d\n", duplicates)
+	}
+	fmt.Printf("Total files: %d\n", merged.Files)
+	fmt.Printf("Successfully processed: %d\n", merged.Processed)
+	if merged.Zero > 0 {
+		fmt.Printf("Zero duration: %d\n", merged.Zero)
+	}
+	fmt.Printf("Errors: %d\n", merged.Errors)
+	fmt.Printf("Total audio duration: %.2f hours\n", merged.TotalHours)
+	fmt.Printf("Mean audio duration per file: %.4f hours (%.2f minutes)\n", merged.MeanSeconds/3600, merged.MeanSeconds/60)
+	fmt.Printf("Median audio duration per file: %.4f hours\n", merged.MedianSecs/3600)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMergeSummaries(t *testing.T) {
+	day1 := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	tests := []struct {
+		name       string
+		in         []scanSummary
+		root       string
+		latest     time.Time
+		paths      []string
+		seconds    float64
+		errors     int
+		duplicates int
+	}{
+		{
+			name: "shards of one root",
+			in: []scanSummary{
+				{Time: day1, Root: "/data", Results: []fileSummary{{Path: "a.wav", Duration: 10}}},
+				{Time: day1, Root: "/data", Results: []fileSummary{{Path: "b/c.wav", Duration: 20}}},
+			},
+			root:    "/data",
+			latest:  day1,
+			paths:   []string{"a.wav", "b/c.wav"},
+			seconds: 30,
+		},
+		{
+			name: "latest scan wins, whatever the order given",
+			in: []scanSummary{
+				{Time: day2, Root: "/data", Results: []fileSummary{{Path: "a.wav", Duration: 12}}},
+				{Time: day1, Root: "/data", Results: []fileSummary{{Path: "a.wav", Error: "truncated"}, {Path: "b.wav", Duration: 5}}},
+			},
+			root:       "/data",
+			latest:     day2,
+			paths:      []string{"a.wav", "b.wav"},
+			seconds:    17,
+			duplicates: 1,
+		},
+		{
+			name: "several roots keep the root in each path",
+			in: []scanSummary{
+				{Time: day1, Root: "/music", Results: []fileSummary{{Path: "a.mp3", Duration: 60}}},
+				{Time: day2, Root: "/podcasts", Results: []fileSummary{{Path: "a.mp3", Duration: 30}, {Path: "x.mp3", Error: "bad"}}},
+			},
+			latest:  day2,
+			paths:   []string{"/music/a.mp3", "/podcasts/a.mp3", "/podcasts/x.mp3"},
+			seconds: 90,
+			errors:  1,
+		},
+	}
+	for _, tt := range tests {
+		merged, duplicates := mergeSummaries(tt.in)
+		var paths []string
+		for _, f := range merged.Results {
+			paths = append(paths, f.Path)
+		}
+		if merged.Root != tt.root || !reflect.DeepEqual(paths, tt.paths) {
+			t.Errorf("%s: root %q paths %q, want %q %q", tt.name, merged.Root, paths, tt.root, tt.paths)
+		}
+		if merged.TotalSeconds != tt.seconds || merged.Errors != tt.errors || duplicates != tt.duplicates {
+			t.Errorf("%s: %v s, %d errors, %d duplicates; want %v s, %d errors, %d duplicates",
+				tt.name, merged.TotalSeconds, merged.Errors, duplicates, tt.seconds, tt.errors, tt.duplicates)
+		}
+		if merged.Files != len(tt.paths) || !merged.Time.Equal(tt.latest) {
+			t.Errorf("%s: %d files at %v, want %d at %v", tt.name, merged.Files, merged.Time, len(tt.paths), tt.latest)
+		}
+	}
+}
+
+func TestReadSummariesNeedsResults(t *testing.T) {
+	path := writeTemp(t, "scan.json", []byte(`{"root":"/data","files":3,"total_seconds":9}`+"\n"))
+	if _, err := readSummaries(path); err == nil || !strings.Contains(err.Error(), "--json-files") {
+		t.Fatalf("readSummaries without results: error = %v, want one naming --json-files", err)
+	}
+}
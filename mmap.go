@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"runtime/debug"
+)
+
+// useMmap is --mmap: map audio files into memory instead of reading them,
+// so probers that hop between headers make no read syscalls.
+var useMmap bool
+
+// maxMapped keeps 32-bit address spaces from being exhausted by one file.
+const maxMapped = 1 << 30
+
+// errMapFault fails a mapped file whose pages went away, as when it is
+// truncated while mapped.
+var errMapFault = errors.New("mapped file changed while being read")
+
+// mapFile replaces reads of an open file with its memory mapping. Files
+// that cannot be mapped keep the read path.
+func (a *audioFile) mapFile() {
+	info, err := a.f.Stat()
+	if err != nil || info.Size() == 0 || info.Size() > maxMapped || !info.Mode().IsRegular() {
+		return
+	}
+	data, err := mmapFile(a.f, int(info.Size()))
+	if err != nil {
+		return
+	}
+	a.head, a.info, a.mapped = data, info, true
+}
+
+// readMapped copies from the mapping at off, counting the bytes copied as
+// read: the probers only touch the pages they need, not the whole file. A
+// fault on a page past a truncated end becomes errMapFault rather than a
+// SIGBUS that would kill the scan.
+func (a *audioFile) readMapped(p []byte, off int64) (n int, err error) {
+	if !scanBudgetLeft() {
+		return 0, errScanLimit
+	}
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if recover() != nil {
+			n, err = 0, errMapFault
+		}
+	}()
+	n = copy(p, a.head[off:])
+	ioStats.bytes.Add(int64(n))
+	return n, nil
+}
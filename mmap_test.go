@@ -0,0 +1,40 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestReadMappedTruncated(t *testing.T) {
+	useMmap = true
+	defer func() { useMmap = false }()
+	path := writeTemp(t, "f.wav", make([]byte, 1<<20))
+	file, err := openAudio(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if !file.mapped {
+		t.Skip("mmap unavailable")
+	}
+
+	before := ioStats.bytes.Load()
+	p := make([]byte, 16)
+	if _, err := file.ReadAt(p, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := ioStats.bytes.Load() - before; got != 16 {
+		t.Errorf("mapped read counted %d bytes, want 16", got)
+	}
+
+	// Pages past the new end fault; that used to be a SIGBUS.
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.ReadAt(p, 1<<19); !errors.Is(err, errMapFault) {
+		t.Fatalf("ReadAt after truncation = %v, want errMapFault", err)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapFile is not implemented on Windows; files there are always read.
+func mmapFile(f *os.File, size int) ([]byte, error) {
+	return nil, errors.New("mmap not supported")
+}
+
+func munmapFile(data []byte) error {
+	return nil
+}
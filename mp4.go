@@ -0,0 +1,445 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// mp4Box is a parsed box header. offset points at the header, size covers
+// header and payload.
+type mp4Box struct {
+	typ       string
+	offset    int64
+	size      int64
+	headerLen int64
+}
+
+func (b mp4Box) payloadOffset() int64 { return b.offset + b.headerLen }
+func (b mp4Box) payloadSize() int64   { return b.size - b.headerLen }
+func (b mp4Box) end() int64           { return b.offset + b.size }
+
+// Bounds on what one file may make the parser allocate. Sample tables grow
+// with duration (about 4 bytes per AAC frame, so a 24-hour audiobook needs a
+// few megabytes); anything far beyond that is corrupt.
+const (
+	maxBoxPayload    = 64 << 20
+	maxMP4Allocation = 256 << 20
+	// maxBoxDepth bounds container nesting: real files stay under ten
+	// levels, and a file of nested moov boxes would otherwise recurse until
+	// the stack overflows, which no recover() catches.
+	maxBoxDepth = 32
+)
+
+// mediaHeaderLen covers the version 1 mvhd/mdhd fields read by
+// parseMediaHeader and the mdhd language; the rest of those boxes is never
+// needed.
+const mediaHeaderLen = 34
+
+type sttsEntry struct {
+	count, delta uint32
+}
+
+type stscEntry struct {
+	firstChunk, samplesPerChunk uint32
+}
+
+// mp4Track is the subset of a trak box needed for durations and sample
+// layout checks.
+type mp4Track struct {
+	handler      string
+	language     string // ISO 639-2 code from mdhd, "und" when unset
+	timescale    uint32
+	duration     uint64
+	stts         []sttsEntry
+	stsc         []stscEntry
+	sampleSize   uint32   // non-zero when every sample has the same size
+	sampleSizes  []uint32 // per-sample sizes otherwise
+	sampleCount  uint32
+	chunkOffsets []uint64
+	sampleEntry  string // stsd sample entry type, e.g. "mp4a" or "alac"
+	objectType   int    // MPEG-4 audio object type from esds, 0 when absent
+}
+
+type mp4Info struct {
+	timescale uint32
+	duration  uint64
+	tracks    []*mp4Track
+	fileSize  int64
+	allocated int64 // payload bytes read so far, bounded by maxMP4Allocation
+	// chpl holds Nero chapter start times in seconds, with titles.
+	chpl []chapter
+	// itunSMPB is the iTunes gapless-playback tag, which records the
+	// encoder's sample count.
+	itunSMPB string
+}
+
+// containerBoxes are descended into while walking the box tree.
+var containerBoxes = map[string]bool{
+	"moov": true, "trak": true, "mdia": true, "minf": true, "stbl": true,
+}
+
+// parseMP4 walks the ISO base media box tree and collects the movie header
+// and per-track tables.
+func parseMP4(file *audioFile) (*mp4Info, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	mp4 := &mp4Info{fileSize: info.Size()}
+	if err := walkMP4(file, 0, info.Size(), mp4, nil, 0); err != nil {
+		return nil, err
+	}
+	return mp4, nil
+}
+
+func walkMP4(file *audioFile, start, end int64, mp4 *mp4Info, track *mp4Track, depth int) error {
+	if depth > maxBoxDepth {
+		return fmt.Errorf("boxes nested more than %d deep", maxBoxDepth)
+	}
+	for pos := start; pos+8 <= end; {
+		box, err := readBoxHeader(file, pos, end)
+		if err != nil {
+			return err
+		}
+		if box.end() > end && box.typ != "mdat" {
+			return fmt.Errorf("box %q overruns its parent", box.typ)
+		}
+
+		switch {
+		case box.typ == "trak":
+			t := &mp4Track{}
+			mp4.tracks = append(mp4.tracks, t)
+			if err := walkMP4(file, box.payloadOffset(), box.end(), mp4, t, depth+1); err != nil {
+				return err
+			}
+		case box.typ == "udta":
+			// User data only holds metadata (chapters, gapless tags): a
+			// damaged udta leaves those unset but must not cost the duration.
+			_ = walkMP4(file, box.payloadOffset(), box.end(), mp4, track, depth+1)
+		case containerBoxes[box.typ]:
+			if err := walkMP4(file, box.payloadOffset(), box.end(), mp4, track, depth+1); err != nil {
+				return err
+			}
+		case box.typ == "mvhd":
+			payload, err := readBoxPayload(file, box, mediaHeaderLen, mp4)
+			if err != nil {
+				return err
+			}
+			mp4.timescale, mp4.duration, err = parseMediaHeader(payload)
+			if err != nil {
+				return fmt.Errorf("mvhd: %w", err)
+			}
+		case box.typ == "meta" && track == nil:
+			// ISO meta is a full box; QuickTime's variant is not.
+			var flags [4]byte
+			if _, err := file.ReadAt(flags[:], box.payloadOffset()); err != nil {
+				return err
+			}
+			start := box.payloadOffset()
+			if flags == [4]byte{} {
+				start += 4
+			}
+			if err := walkMP4(file, start, box.end(), mp4, nil, depth+1); err != nil {
+				return err
+			}
+		case box.typ == "ilst" && track == nil:
+			if err := walkMP4(file, box.payloadOffset(), box.end(), mp4, nil, depth+1); err != nil {
+				return err
+			}
+		case box.typ == "----" && track == nil:
+			payload, err := readBoxPayload(file, box, min(box.payloadSize(), 4096), mp4)
+			if err != nil {
+				return err
+			}
+			if name, value := parseFreeformTag(payload); name == "iTunSMPB" {
+				mp4.itunSMPB = value
+			}
+		case box.typ == "chpl" && track == nil:
+			payload, err := readBoxPayload(file, box, box.payloadSize(), mp4)
+			if err != nil {
+				return err
+			}
+			mp4.chpl = parseNeroChapters(payload)
+		case track != nil:
+			if err := parseTrackBox(file, box, track, mp4); err != nil {
+				return fmt.Errorf("%s: %w", box.typ, err)
+			}
+		}
+
+		pos = box.end()
+	}
+	return nil
+}
+
+func readBoxHeader(file *audioFile, pos, end int64) (mp4Box, error) {
+	var hdr [16]byte
+	if _, err := file.ReadAt(hdr[:8], pos); err != nil {
+		return mp4Box{}, err
+	}
+	box := mp4Box{
+		typ:       string(hdr[4:8]),
+		offset:    pos,
+		size:      int64(binary.BigEndian.Uint32(hdr[0:4])),
+		headerLen: 8,
+	}
+	switch box.size {
+	case 0:
+		// Box extends to the end of its parent (usually a trailing mdat).
+		box.size = end - pos
+	case 1:
+		if _, err := file.ReadAt(hdr[8:16], pos+8); err != nil {
+			return mp4Box{}, err
+		}
+		box.size = int64(binary.BigEndian.Uint64(hdr[8:16]))
+		box.headerLen = 16
+	}
+	if box.size < box.headerLen {
+		return mp4Box{}, fmt.Errorf("invalid size %d for box %q", box.size, box.typ)
+	}
+	return box, nil
+}
+
+// readBoxPayload reads up to limit bytes of a box payload, refusing boxes
+// whose size would blow the per-box or per-file allocation bounds.
+func readBoxPayload(file *audioFile, box mp4Box, limit int64, mp4 *mp4Info) ([]byte, error) {
+	size := min(box.payloadSize(), limit)
+	if size > maxBoxPayload || mp4.allocated+size > maxMP4Allocation {
+		return nil, fmt.Errorf("box %q too large (%d bytes)", box.typ, box.payloadSize())
+	}
+	if box.payloadOffset()+size > mp4.fileSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+	mp4.allocated += size
+	payload := make([]byte, size)
+	if _, err := file.ReadAt(payload, box.payloadOffset()); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// parseFreeformTag reads an iTunes "----" item: mean, name and data boxes.
+func parseFreeformTag(p []byte) (name, value string) {
+	for len(p) >= 8 {
+		size := int(binary.BigEndian.Uint32(p[0:4]))
+		if size < 8 || size > len(p) {
+			break
+		}
+		typ, body := string(p[4:8]), p[8:size]
+		switch {
+		case typ == "name" && len(body) >= 4:
+			name = string(body[4:])
+		case typ == "data" && len(body) >= 8:
+			value = string(body[8:])
+		}
+		p = p[size:]
+	}
+	return name, value
+}
+
+// parseMediaHeader reads timescale and duration from an mvhd or mdhd
+// payload, which share their leading layout.
+func parseMediaHeader(p []byte) (uint32, uint64, error) {
+	if len(p) < 20 {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	if p[0] == 1 {
+		// Version 1: 64-bit creation/modification times and duration.
+		if len(p) < 32 {
+			return 0, 0, io.ErrUnexpectedEOF
+		}
+		return binary.BigEndian.Uint32(p[20:24]), binary.BigEndian.Uint64(p[24:32]), nil
+	}
+	return binary.BigEndian.Uint32(p[12:16]), uint64(binary.BigEndian.Uint32(p[16:20])), nil
+}
+
+func parseTrackBox(file *audioFile, box mp4Box, t *mp4Track, mp4 *mp4Info) error {
+	limit := box.payloadSize()
+	switch box.typ {
+	case "mdhd", "hdlr":
+		limit = mediaHeaderLen
+	case "stsd":
+		limit = min(limit, maxSampleDescription)
+	case "stts", "stsc", "stsz", "stco", "co64":
+	default:
+		return nil
+	}
+	p, err := readBoxPayload(file, box, limit, mp4)
+	if err != nil {
+		return err
+	}
+	if len(p) < 8 {
+		return io.ErrUnexpectedEOF
+	}
+
+	// Every table box starts with version/flags and an entry count.
+	count := binary.BigEndian.Uint32(p[4:8])
+	entries := func(width int) ([]byte, error) {
+		body := p[8:]
+		if uint64(len(body)) < uint64(count)*uint64(width) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return body, nil
+	}
+
+	switch box.typ {
+	case "mdhd":
+		t.timescale, t.duration, err = parseMediaHeader(p)
+		t.language = mediaLanguage(p)
+		return err
+	case "hdlr":
+		if len(p) < 12 {
+			return io.ErrUnexpectedEOF
+		}
+		t.handler = string(p[8:12])
+	case "stsd":
+		t.sampleEntry, t.objectType = parseSampleDescription(p)
+	case "stts":
+		body, err := entries(8)
+		if err != nil {
+			return err
+		}
+		t.stts = make([]sttsEntry, count)
+		for i := range t.stts {
+			t.stts[i] = sttsEntry{binary.BigEndian.Uint32(body[i*8:]), binary.BigEndian.Uint32(body[i*8+4:])}
+		}
+	case "stsc":
+		body, err := entries(12)
+		if err != nil {
+			return err
+		}
+		t.stsc = make([]stscEntry, count)
+		for i := range t.stsc {
+			t.stsc[i] = stscEntry{binary.BigEndian.Uint32(body[i*12:]), binary.BigEndian.Uint32(body[i*12+4:])}
+		}
+	case "stsz":
+		// stsz puts the constant sample size before the count.
+		if len(p) < 12 {
+			return io.ErrUnexpectedEOF
+		}
+		t.sampleSize = binary.BigEndian.Uint32(p[4:8])
+		t.sampleCount = binary.BigEndian.Uint32(p[8:12])
+		if t.sampleSize == 0 {
+			body := p[12:]
+			if uint64(len(body)) < uint64(t.sampleCount)*4 {
+				return io.ErrUnexpectedEOF
+			}
+			t.sampleSizes = make([]uint32, t.sampleCount)
+			for i := range t.sampleSizes {
+				t.sampleSizes[i] = binary.BigEndian.Uint32(body[i*4:])
+			}
+		}
+	case "stco":
+		body, err := entries(4)
+		if err != nil {
+			return err
+		}
+		t.chunkOffsets = make([]uint64, count)
+		for i := range t.chunkOffsets {
+			t.chunkOffsets[i] = uint64(binary.BigEndian.Uint32(body[i*4:]))
+		}
+	case "co64":
+		body, err := entries(8)
+		if err != nil {
+			return err
+		}
+		t.chunkOffsets = make([]uint64, count)
+		for i := range t.chunkOffsets {
+			t.chunkOffsets[i] = binary.BigEndian.Uint64(body[i*8:])
+		}
+	}
+	return nil
+}
+
+// seconds returns the declared track duration.
+func (t *mp4Track) seconds() float64 {
+	if t.timescale == 0 {
+		return 0
+	}
+	return float64(t.duration) / float64(t.timescale)
+}
+
+// playableSeconds sums the durations of samples whose bytes lie entirely
+// within the first limit bytes of the file, following the chunk layout.
+func (t *mp4Track) playableSeconds(limit int64) float64 {
+	if t.timescale == 0 {
+		return 0
+	}
+
+	var units uint64
+	sample := uint32(0)
+	sttsIdx, sttsLeft := 0, uint32(0)
+	if len(t.stts) > 0 {
+		sttsLeft = t.stts[0].count
+	}
+
+	runs := stscCursor{stsc: t.stsc}
+	for chunk := range t.chunkOffsets {
+		perChunk := runs.samplesInChunk(uint32(chunk + 1))
+		offset := t.chunkOffsets[chunk]
+		for i := uint32(0); i < perChunk && sample < t.sampleCount; i++ {
+			size := t.sampleSize
+			if size == 0 {
+				size = t.sampleSizes[sample]
+			}
+			offset += uint64(size)
+			if offset > uint64(limit) {
+				return float64(units) / float64(t.timescale)
+			}
+
+			for sttsLeft == 0 && sttsIdx+1 < len(t.stts) {
+				sttsIdx++
+				sttsLeft = t.stts[sttsIdx].count
+			}
+			if sttsLeft > 0 {
+				units += uint64(t.stts[sttsIdx].delta)
+				sttsLeft--
+			}
+			sample++
+		}
+	}
+	return float64(units) / float64(t.timescale)
+}
+
+// stscCursor resolves the sample-to-chunk runs for chunks visited in
+// increasing order, moving through stsc once instead of rescanning it for
+// every chunk.
+type stscCursor struct {
+	stsc     []stscEntry
+	next     int
+	perChunk uint32
+}
+
+// samplesInChunk returns the samples in a 1-based chunk, which must not be
+// lower than the chunk asked for last.
+func (c *stscCursor) samplesInChunk(chunk uint32) uint32 {
+	for c.next < len(c.stsc) && c.stsc[c.next].firstChunk <= chunk {
+		c.perChunk = c.stsc[c.next].samplesPerChunk
+		c.next++
+	}
+	return c.perChunk
+}
+
+// audioTracks returns the sound tracks, the ones that carry listening time.
+func (m *mp4Info) audioTracks() []*mp4Track {
+	var tracks []*mp4Track
+	for _, t := range m.tracks {
+		if t.handler == "soun" {
+			tracks = append(tracks, t)
+		}
+	}
+	return tracks
+}
+
+// seconds returns the movie duration, falling back to the longest audio
+// track when the movie header is missing or empty.
+func (m *mp4Info) seconds() float64 {
+	if m.timescale > 0 && m.duration > 0 {
+		return float64(m.duration) / float64(m.timescale)
+	}
+	var longest float64
+	for _, t := range m.audioTracks() {
+		longest = max(longest, t.seconds())
+	}
+	return longest
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// mp4Boxes nests each empty box inside the one before it.
+func mp4Boxes(types ...string) []byte {
+	b := make([]byte, 8*len(types))
+	for i, typ := range types {
+		binary.BigEndian.PutUint32(b[8*i:], uint32(len(b)-8*i))
+		copy(b[8*i+4:], typ)
+	}
+	return b
+}
+
+func TestParseMP4NestedBoxes(t *testing.T) {
+	// Nesting this deep overflowed the stack before maxBoxDepth.
+	types := make([]string, 200000)
+	for i := range types {
+		types[i] = "moov"
+	}
+	file, err := openAudio(writeTemp(t, "nested.m4a", mp4Boxes(types...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := parseMP4(file); err == nil {
+		t.Fatal("parseMP4 accepted boxes nested 200000 deep")
+	}
+}
+
+func TestPlayableSeconds(t *testing.T) {
+	// Four chunks of 10-byte samples at offsets 0, 100, 200 and 300: one
+	// sample in chunk 1, two in chunks 2 and 3, three in chunk 4.
+	track := &mp4Track{
+		timescale:    10,
+		sampleCount:  8,
+		sampleSize:   10,
+		stts:         []sttsEntry{{count: 8, delta: 5}},
+		stsc:         []stscEntry{{1, 1}, {2, 2}, {4, 3}},
+		chunkOffsets: []uint64{0, 100, 200, 300},
+	}
+	tests := []struct {
+		limit int64
+		want  float64
+	}{
+		{0, 0},
+		{10, 0.5},
+		{120, 1.5},
+		{220, 2.5},
+		{310, 3},
+		{1000, 4},
+	}
+	for _, tt := range tests {
+		if got := track.playableSeconds(tt.limit); got != tt.want {
+			t.Errorf("playableSeconds(%d) = %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestPlayableSecondsRepeatedRuns(t *testing.T) {
+	// Every stsc entry starting at chunk 1 made each chunk rescan the whole
+	// table; with a million of each this never finished.
+	const n = 1000000
+	track := &mp4Track{
+		timescale:    1,
+		sampleCount:  n,
+		sampleSize:   1,
+		stts:         []sttsEntry{{count: n, delta: 1}},
+		stsc:         make([]stscEntry, n),
+		chunkOffsets: make([]uint64, n),
+	}
+	for i := range track.stsc {
+		track.stsc[i] = stscEntry{firstChunk: 1, samplesPerChunk: 1}
+		track.chunkOffsets[i] = uint64(i)
+	}
+	if got := track.playableSeconds(n); got != n {
+		t.Fatalf("playableSeconds = %v, want %v", got, n)
+	}
+}
+
+func FuzzParseMP4(f *testing.F) {
+	f.Add(append(mp4Boxes("ftyp"), mp4Boxes("moov", "trak", "mdia", "minf", "stbl", "stts")...))
+	f.Add(mp4Boxes("moov", "udta", "meta", "ilst", "----"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		file, err := openAudio(writeTemp(t, "f.m4a", data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		parseMP4(file)
+	})
+}
+
+func TestSampleOffsetsLimit(t *testing.T) {
+	// One chunk claiming 50M constant-size samples took gigabytes.
+	track := &mp4Track{
+		sampleCount:  50000000,
+		sampleSize:   1,
+		stsc:         []stscEntry{{firstChunk: 1, samplesPerChunk: 50000000}},
+		chunkOffsets: []uint64{100},
+	}
+	offsets, sizes := track.sampleOffsets(3)
+	if len(offsets) != 3 || len(sizes) != 3 || offsets[2] != 102 {
+		t.Fatalf("sampleOffsets(3) = %v, %v", offsets, sizes)
+	}
+}
+
+func TestParseMP4DamagedUserData(t *testing.T) {
+	box := func(typ string, payload []byte) []byte {
+		b := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+		return append(append(b, typ...), payload...)
+	}
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], 5000)
+	// The udta holds a box claiming 3 bytes, less than its own header.
+	udta := box("udta", []byte{0, 0, 0, 3, 'm', 'e', 't', 'a'})
+	file, err := openAudio(writeTemp(t, "udta.m4a", box("moov", append(box("mvhd", mvhd), udta...))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	mp4, err := parseMP4(file)
+	if err != nil {
+		t.Fatalf("parseMP4: %v", err)
+	}
+	if mp4.timescale != 1000 || mp4.duration != 5000 {
+		t.Errorf("mvhd = %d/%d; want 5000/1000", mp4.duration, mp4.timescale)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MQTT publishing: with --mqtt every finished scan publishes its totals as
+// a retained JSON message, so home-automation dashboards such as Home
+// Assistant show the latest count as soon as they subscribe and can alert
+// when it grows. The client speaks just enough MQTT 3.1.1 to connect,
+// publish once at QoS 1 and disconnect.
+
+// mqttState is the message published to the --mqtt topic.
+type mqttState struct {
+	Time         time.Time `json:"time"`
+	Root         string    `json:"root"`
+	Files        int       `json:"files"`
+	Processed    int       `json:"processed"`
+	Errors       int       `json:"errors"`
+	TotalSeconds float64   `json:"total_seconds"`
+	TotalHours   float64   `json:"total_hours"`
+}
+
+func checkMQTTURL(rawURL string) error {
+	if rawURL == "" {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "mqtt" && u.Scheme != "mqtts") || strings.Trim(u.Path, "/") == "" {
+		return fmt.Errorf("unsupported --mqtt URL %q: use mqtt://host/<topic> or mqtts://host/<topic>", rawURL)
+	}
+	return nil
+}
+
+// publishMQTT publishes the scan's totals to mqtt[s]://[user:password@]host[:port]/<topic>.
+func publishMQTT(rawURL string, s scanSummary) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(mqttState{
+		Time: s.Time, Root: s.Root, Files: s.Files, Processed: s.Processed, Errors: s.Errors,
+		TotalSeconds: s.TotalSeconds, TotalHours: s.TotalHours,
+	})
+	if err != nil {
+		return err
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		port := "1883"
+		if u.Scheme == "mqtts" {
+			port = "8883"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	conn, err := net.DialTimeout("tcp", host, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "mqtts" {
+		conn = tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	r := bufio.NewReader(conn)
+
+	if _, err := conn.Write(mqttConnect(u.User)); err != nil {
+		return err
+	}
+	typ, body, err := readMQTTPacket(r)
+	if err != nil {
+		return err
+	}
+	if typ != 0x20 || len(body) != 2 {
+		return fmt.Errorf("mqtt: expected CONNACK, got packet type %d", typ>>4)
+	}
+	if body[1] != 0 {
+		return fmt.Errorf("mqtt: connection refused (return code %d)", body[1])
+	}
+
+	const packetID = 1
+	var publish []byte
+	publish = appendMQTTString(publish, strings.Trim(u.Path, "/"))
+	publish = binary.BigEndian.AppendUint16(publish, packetID)
+	publish = append(publish, payload...)
+	if _, err := conn.Write(mqttPacket(0x30|0x02|0x01, publish)); err != nil { // QoS 1, retained
+		return err
+	}
+	typ, body, err = readMQTTPacket(r)
+	if err != nil {
+		return err
+	}
+	if typ != 0x40 || len(body) != 2 || binary.BigEndian.Uint16(body) != packetID {
+		return fmt.Errorf("mqtt: expected PUBACK, got packet type %d", typ>>4)
+	}
+	_, err = conn.Write(mqttPacket(0xE0, nil))
+	return err
+}
+
+// mqttConnect is a clean-session CONNECT with the URL's credentials.
+func mqttConnect(user *url.Userinfo) []byte {
+	var flags byte = 0x02
+	var b []byte
+	b = appendMQTTString(b, "MQTT")
+	b = append(b, 4) // protocol level 3.1.1
+	flagsAt := len(b)
+	b = append(b, 0)
+	b = binary.BigEndian.AppendUint16(b, 60) // keep-alive seconds
+	b = appendMQTTString(b, fmt.Sprintf("howmanyhours-%d", time.Now().UnixNano()%1e9))
+	if user != nil {
+		flags |= 0x80
+		b = appendMQTTString(b, user.Username())
+		if pass, ok := user.Password(); ok {
+			flags |= 0x40
+			b = appendMQTTString(b, pass)
+		}
+	}
+	b[flagsAt] = flags
+	return mqttPacket(0x10, b)
+}
+
+// mqttPacket prefixes body with the fixed header: the type and flags
+// byte, then the remaining length in 7-bit groups.
+func mqttPacket(header byte, body []byte) []byte {
+	p := []byte{header}
+	n := len(body)
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		p = append(p, digit)
+		if n == 0 {
+			break
+		}
+	}
+	return append(p, body...)
+}
+
+func appendMQTTString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// readMQTTPacket reads one packet, returning its type byte and body.
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var n, shift int
+	for i := 0; ; i++ {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(digit&0x7f) << shift
+		shift += 7
+		if digit&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, nil, fmt.Errorf("mqtt: malformed remaining length")
+		}
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header & 0xF0, body, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// natsQueueGroup makes every howManyHours worker on a subject share its
+// messages instead of each receiving all of them.
+const natsQueueGroup = "howmanyhours"
+
+// natsQueue publishes to and consumes from one NATS subject with the core
+// text protocol. Core NATS does not store messages, so jobs published while
+// no worker is subscribed are lost; put JetStream or Redis in front when
+// that matters.
+type natsQueue struct {
+	conn       net.Conn
+	r          *bufio.Reader
+	subject    string
+	subscribed bool
+}
+
+// dialNATS connects to nats://[user:password@|token@]host[:port]/<subject>.
+func dialNATS(u *url.URL) (*natsQueue, error) {
+	subject := strings.ReplaceAll(strings.Trim(u.Path, "/"), "/", ".")
+	if subject == "" {
+		return nil, fmt.Errorf("%s: missing subject (nats://host:port/<subject>)", u.Redacted())
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	conn, err := net.DialTimeout("tcp", host, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	q := &natsQueue{conn: conn, r: bufio.NewReader(conn), subject: subject}
+	if err := q.handshake(u.User); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *natsQueue) handshake(user *url.Userinfo) error {
+	line, err := q.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("nats: unexpected greeting %q", strings.TrimSpace(line))
+	}
+	connect := map[string]any{"verbose": false, "pedantic": false, "name": "howManyHours", "lang": "go"}
+	if user != nil {
+		if pass, ok := user.Password(); ok {
+			connect["user"], connect["pass"] = user.Username(), pass
+		} else {
+			connect["auth_token"] = user.Username()
+		}
+	}
+	payload, _ := json.Marshal(connect)
+	if _, err := fmt.Fprintf(q.conn, "CONNECT %s\r\n", payload); err != nil {
+		return err
+	}
+	return q.flush()
+}
+
+func (q *natsQueue) push(msg []byte) error {
+	_, err := fmt.Fprintf(q.conn, "PUB %s %d\r\n%s\r\n", q.subject, len(msg), msg)
+	return err
+}
+
+// pull subscribes on first use and waits up to timeout for a message.
+func (q *natsQueue) pull(timeout time.Duration) ([]byte, bool, error) {
+	if !q.subscribed {
+		if _, err := fmt.Fprintf(q.conn, "SUB %s %s 1\r\n", q.subject, natsQueueGroup); err != nil {
+			return nil, false, err
+		}
+		q.subscribed = true
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		q.conn.SetReadDeadline(deadline)
+		line, err := q.r.ReadString('\n')
+		if errors.Is(err, os.ErrDeadlineExceeded) && line == "" {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		q.conn.SetReadDeadline(time.Time{})
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PING":
+			if _, err := io.WriteString(q.conn, "PONG\r\n"); err != nil {
+				return nil, false, err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return nil, false, errors.New("nats: " + strings.Trim(strings.TrimPrefix(line, "-ERR "), "'"))
+		case strings.HasPrefix(line, "MSG "):
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			fields := strings.Fields(line)
+			n, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || n < 0 {
+				return nil, false, fmt.Errorf("nats: bad MSG line %q", line)
+			}
+			if n > maxQueueMessage {
+				return nil, false, fmt.Errorf("nats: %d-byte message exceeds %d bytes", n, maxQueueMessage)
+			}
+			buf := make([]byte, n+2)
+			if _, err := io.ReadFull(q.r, buf); err != nil {
+				return nil, false, err
+			}
+			return buf[:n], true, nil
+		}
+	}
+}
+
+// flush round-trips a PING; the server answers it only after processing
+// every PUB sent before it.
+func (q *natsQueue) flush() error {
+	if _, err := io.WriteString(q.conn, "PING\r\n"); err != nil {
+		return err
+	}
+	for {
+		line, err := q.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New("nats: " + strings.Trim(strings.TrimPrefix(line, "-ERR "), "'"))
+		}
+	}
+}
+
+func (q *natsQueue) Close() error {
+	return q.conn.Close()
+}
@@ -0,0 +1,11 @@
+package main
+
+import "golang.org/x/text/unicode/norm"
+
+// nfc puts a path or name into Unicode normalization form C. macOS hands
+// out decomposed (NFD) names, so "é" may arrive as "e" plus a combining
+// accent; every key derived from a path goes through nfc so both spellings
+// match the same file.
+func nfc(s string) string {
+	return norm.NFC.String(s)
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Ogg page header_type flags.
+const (
+	oggContinued = 0x01
+	oggBOS       = 0x02 // first page of a logical stream
+	oggEOS       = 0x04 // last page of a logical stream
+)
+
+// oggNoGranule marks a page on which no packet ends.
+const oggNoGranule = -1
+
+// oggStream is one logical stream of an Ogg file.
+type oggStream struct {
+	codec   string
+	rate    uint32 // granule positions per second; 0 for streams that are not audio
+	preSkip int64  // Opus samples to discard at the start
+	granule int64  // last granule position seen
+	title   string // from the comment header
+	tagged  bool   // comment header read
+}
+
+// oggChain is a run of logical streams that play together. Chained files,
+// such as Icecast dumps, hold several chains one after the other.
+type oggChain struct {
+	streams map[uint32]*oggStream
+	order   []uint32
+}
+
+// title is the first title among the chain's streams.
+func (c *oggChain) title() string {
+	for _, serial := range c.order {
+		if t := c.streams[serial].title; t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+// seconds is the chain's length: its longest audio stream.
+func (c *oggChain) seconds() float64 {
+	var longest float64
+	for _, serial := range c.order {
+		s := c.streams[serial]
+		if s.rate == 0 || s.granule <= s.preSkip {
+			continue
+		}
+		longest = max(longest, float64(s.granule-s.preSkip)/float64(s.rate))
+	}
+	return longest
+}
+
+// oggFile is what durations are read from in an Ogg file.
+type oggFile struct {
+	chains []*oggChain
+}
+
+func (o *oggFile) seconds() float64 {
+	var total float64
+	for _, c := range o.chains {
+		total += c.seconds()
+	}
+	return total
+}
+
+// audioChains is the number of chains carrying audio.
+func (o *oggFile) audioChains() int {
+	var n int
+	for _, c := range o.chains {
+		if c.seconds() > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// parseOgg walks the page headers of an Ogg file, reading only the
+// identification and comment packets of each logical stream. A
+// beginning-of-stream page that follows data pages starts a new chain.
+// Garbage between pages is skipped, and a page cut short by the end of the
+// file is ignored.
+func parseOgg(file *audioFile) (*oggFile, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	o := &oggFile{}
+	var chain *oggChain
+	var hdr [27 + 255]byte
+	for pos := int64(0); pos+27 <= size; {
+		if _, err := file.ReadAt(hdr[:27], pos); err != nil {
+			return nil, err
+		}
+		if string(hdr[0:4]) != "OggS" || hdr[4] != 0 {
+			next, ok := resyncOgg(file, pos+1, size)
+			if !ok {
+				break
+			}
+			pos = next
+			continue
+		}
+		segments := int(hdr[26])
+		if pos+27+int64(segments) > size {
+			break
+		}
+		if _, err := file.ReadAt(hdr[27:27+segments], pos+27); err != nil {
+			return nil, err
+		}
+		var body int64
+		for _, l := range hdr[27 : 27+segments] {
+			body += int64(l)
+		}
+		bodyAt := pos + 27 + int64(segments)
+		if bodyAt+body > size {
+			break
+		}
+		flags := hdr[5]
+		granule := int64(binary.LittleEndian.Uint64(hdr[6:14]))
+		serial := binary.LittleEndian.Uint32(hdr[14:18])
+
+		if flags&oggBOS != 0 {
+			if chain == nil || chain.started() {
+				chain = &oggChain{streams: make(map[uint32]*oggStream)}
+				o.chains = append(o.chains, chain)
+			}
+			packet := make([]byte, firstPacketLen(hdr[27:27+segments]))
+			if _, err := file.ReadAt(packet, bodyAt); err != nil {
+				return nil, err
+			}
+			chain.streams[serial] = identifyOggStream(packet)
+			chain.order = append(chain.order, serial)
+		} else if chain != nil {
+			s, ok := chain.streams[serial]
+			if ok && !s.tagged && flags&oggContinued == 0 {
+				// The comment header is the first packet after the
+				// identification page; only the part on this page is read.
+				packet := make([]byte, firstPacketLen(hdr[27:27+segments]))
+				if _, err := file.ReadAt(packet, bodyAt); err != nil {
+					return nil, err
+				}
+				s.title = oggTitle(s.codec, packet)
+				s.tagged = true
+			}
+			if ok && granule != oggNoGranule {
+				s.granule = max(s.granule, granule)
+			}
+		}
+		pos = bodyAt + body
+	}
+	if len(o.chains) == 0 {
+		return nil, errors.New("invalid Ogg file")
+	}
+	return o, nil
+}
+
+// started reports whether any stream of the chain has passed its headers.
+func (c *oggChain) started() bool {
+	for _, s := range c.streams {
+		if s.granule > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// firstPacketLen is the length of the first packet of a page, from its
+// lacing values: the packet ends at the first value below 255.
+func firstPacketLen(lacing []byte) int {
+	var n int
+	for _, l := range lacing {
+		n += int(l)
+		if l < 255 {
+			break
+		}
+	}
+	return n
+}
+
+// identifyOggStream reads the codec and granule rate from a logical
+// stream's identification packet. Streams it does not know, such as
+// Theora video or Skeleton metadata, get a zero rate and are not timed.
+func identifyOggStream(p []byte) *oggStream {
+	switch {
+	case len(p) >= 16 && bytes.HasPrefix(p, []byte("\x01vorbis")):
+		return &oggStream{codec: "vorbis", rate: binary.LittleEndian.Uint32(p[12:16])}
+	case len(p) >= 12 && bytes.HasPrefix(p, []byte("OpusHead")):
+		// Opus granule positions always count 48 kHz samples, whatever
+		// the input rate recorded in the header.
+		return &oggStream{codec: "opus", rate: 48000, preSkip: int64(binary.LittleEndian.Uint16(p[10:12]))}
+	case len(p) >= 30 && bytes.HasPrefix(p, []byte("\x7fFLAC")):
+		// The STREAMINFO block starts at 17; the sample rate is its
+		// 20 bits from byte 10.
+		return &oggStream{codec: "flac", rate: uint32(p[27])<<12 | uint32(p[28])<<4 | uint32(p[29])>>4}
+	case len(p) >= 40 && bytes.HasPrefix(p, []byte("Speex   ")):
+		return &oggStream{codec: "speex", rate: binary.LittleEndian.Uint32(p[36:40])}
+	}
+	codec := "unknown"
+	if len(p) > 1 {
+		codec = strings.ToLower(strings.Trim(string(p[1:min(len(p), 8)]), "\x00 "))
+	}
+	return &oggStream{codec: codec}
+}
+
+// oggTitle reads "ARTIST - TITLE", or the title alone, from a stream's
+// comment header packet.
+func oggTitle(codec string, p []byte) string {
+	switch {
+	case codec == "vorbis" && bytes.HasPrefix(p, []byte("\x03vorbis")):
+		p = p[7:]
+	case codec == "opus" && bytes.HasPrefix(p, []byte("OpusTags")):
+		p = p[8:]
+	case codec == "flac" && len(p) >= 4 && p[0]&0x7f == 4:
+		p = p[4:]
+	case codec == "speex":
+	default:
+		return ""
+	}
+	comments := vorbisComments(p)
+	title, artist := comments["TITLE"], comments["ARTIST"]
+	if title != "" && artist != "" {
+		return artist + " - " + title
+	}
+	return title
+}
+
+// vorbisComments parses a Vorbis comment block (vendor string, then
+// KEY=value pairs), keeping the first value of each upper-cased key and
+// stopping at the first comment that overruns the packet.
+func vorbisComments(p []byte) map[string]string {
+	comments := make(map[string]string)
+	if len(p) < 8 {
+		return comments
+	}
+	vendor := int64(binary.LittleEndian.Uint32(p[0:4]))
+	if 4+vendor+4 > int64(len(p)) {
+		return comments
+	}
+	p = p[4+vendor:]
+	count := binary.LittleEndian.Uint32(p[0:4])
+	p = p[4:]
+	for ; count > 0 && len(p) >= 4; count-- {
+		n := int64(binary.LittleEndian.Uint32(p[0:4]))
+		if 4+n > int64(len(p)) {
+			break
+		}
+		key, value, ok := strings.Cut(string(p[4:4+n]), "=")
+		if key = strings.ToUpper(key); ok && comments[key] == "" {
+			comments[key] = nfc(value)
+		}
+		p = p[4+n:]
+	}
+	return comments
+}
+
+// resyncOgg looks for the next page capture pattern within resyncWindow
+// bytes of from.
+func resyncOgg(file *audioFile, from, size int64) (int64, bool) {
+	n := min(int64(resyncWindow), size-from)
+	if n < 27 {
+		return 0, false
+	}
+	buf := make([]byte, n)
+	if _, err := file.ReadAt(buf, from); err != nil && !errors.Is(err, io.EOF) {
+		return 0, false
+	}
+	at := bytes.Index(buf, []byte("OggS"))
+	if at < 0 {
+		return 0, false
+	}
+	return from + int64(at), true
+}
+
+func getOggDuration(filePath string) (float64, error) {
+	file, err := openAudio(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	o, err := parseOgg(file)
+	if err != nil {
+		return 0, err
+	}
+	duration := o.seconds()
+	if duration == 0 {
+		return 0, fmt.Errorf("could not parse Ogg duration")
+	}
+	return duration, nil
+}
+
+// readOggChains counts the audio chains of an Ogg file, or 0 for other
+// files.
+func readOggChains(filePath string) int {
+	if !strings.EqualFold(streamManifestExt(filePath), ".ogg") {
+		return 0
+	}
+	file, err := openAudio(filePath)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	o, err := parseOgg(file)
+	if err != nil {
+		return 0
+	}
+	return o.audioChains()
+}
+
+// printChainReport lists the chained Ogg files, such as Icecast stream
+// dumps, with the number of chains each holds.
+func printChainReport(root string, audioFiles []string, durations []float64, chains []int) {
+	fmt.Fprintln(console, "\n=== Ogg chains ===")
+	var files, total int
+	var seconds float64
+	var lines []string
+	for i, p := range audioFiles {
+		if chains[i] < 2 {
+			continue
+		}
+		files++
+		total += chains[i]
+		seconds += max(durations[i], 0)
+		lines = append(lines, fmt.Sprintf("%s: %d chains (%s)", relPath(root, p), chains[i], formatClock(durations[i])))
+	}
+	if files == 0 {
+		fmt.Fprintln(console, "No chained Ogg files.")
+		return
+	}
+	fmt.Fprintf(console, "Chained Ogg files: %d (%d chains, %.2f hours)\n", files, total, seconds/3600.0)
+	printList(len(lines), func(i int) string { return lines[i] })
+}
@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func FuzzParseOgg(f *testing.F) {
+	f.Add([]byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x13OpusHead\x01\x02\x38\x01\x80\xbb\x00\x00\x00\x00\x00"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		file, err := openAudio(writeTemp(t, "f.ogg", data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if o, err := parseOgg(file); err == nil {
+			o.seconds()
+		}
+	})
+}
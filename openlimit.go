@@ -0,0 +1,58 @@
+package main
+
+import "os"
+
+// openSlots bounds the audio files held open at once across all workers,
+// so a --workers count above the descriptor limit queues instead of
+// failing files with "too many open files". Nil means unbounded.
+var openSlots chan struct{}
+
+// openHeadroom is kept free below RLIMIT_NOFILE for the directory walk,
+// sidecar files, output files and the runtime's own descriptors.
+const openHeadroom = 64
+
+// Subcommands without the flag get the default limit.
+func init() {
+	setOpenLimit(0)
+}
+
+// setOpenLimit sizes openSlots from --max-open-files, or from the
+// process's descriptor limit when it is 0.
+func setOpenLimit(limit int) {
+	if limit <= 0 {
+		if soft := openFileLimit(); soft > 0 {
+			limit = max(soft-openHeadroom, 1)
+		}
+	}
+	openSlots = nil
+	if limit > 0 {
+		openSlots = make(chan struct{}, limit)
+	}
+}
+
+// openStorage opens an audio file once a slot is free and applies the
+// --fadvise hint. Files opened here must be closed with closeStorage.
+func openStorage(path string) (*os.File, error) {
+	if openSlots != nil {
+		openSlots <- struct{}{}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		releaseSlot()
+		return nil, err
+	}
+	adviseFile(f)
+	return f, nil
+}
+
+func closeStorage(f *os.File) error {
+	err := f.Close()
+	releaseSlot()
+	return err
+}
+
+func releaseSlot() {
+	if openSlots != nil {
+		<-openSlots
+	}
+}
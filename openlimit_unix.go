@@ -0,0 +1,16 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// openFileLimit returns the soft RLIMIT_NOFILE, which the Go runtime has
+// already raised to the hard limit at startup, or 0 if it is unknown.
+// Unlimited reports as a huge value, capped here.
+func openFileLimit() int {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 0
+	}
+	return int(min(rl.Cur, 1<<20))
+}
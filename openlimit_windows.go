@@ -0,0 +1,9 @@
+//go:build windows
+
+package main
+
+// openFileLimit returns 0: Windows has no per-process descriptor limit
+// low enough to matter here.
+func openFileLimit() int {
+	return 0
+}
@@ -0,0 +1,664 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// options holds the command-line settings shared by the scan and its reports.
+type options struct {
+	requireTranscript string
+	playbackSpeed     float64
+	estimateProc      string
+	processingPlan    processingPlan
+	books             bool
+	splitReport       bool
+	splits            string
+	splitDirs         map[string]string
+	expectSplit       string
+	expectRatios      []float64
+	ratioTolerance    float64
+	labels            bool
+	imbalanceRatio    float64
+	layout            string
+	pivot             *datasetLayout
+	expectCounts      string
+	expectTolerance   float64
+	pareto            float64
+	diskUsage         int
+	ageScatter        string
+	agePlot           bool
+	simulateBitrate   string
+	bitrateTarget     bitrateTarget
+	folded            string
+	treemap           string
+	emitDirFiles      string
+	speakers          speakerRule
+	speakerRegex      string
+	speakerMaxShare   float64
+	goalHours         float64
+	history           string
+	segments          stringList
+	subtitles         bool
+	subtitleGap       float64
+	speechHours       bool
+	detectSilence     bool
+	silencePercent    float64
+	silenceLevel      float64
+	deep              bool
+	loudnessTolerance float64
+	clipPercent       float64
+	verifyTolerance   float64
+	checkTruncation   bool
+	checkExtensions   bool
+	checksums         string
+	manifest          string
+	verifyManifest    string
+	estimate          bool
+	sample            string
+	sampleFraction    float64
+	seed              int64
+	dryRun            bool
+	parquet           string
+	sqlite            string
+	format            string
+	timings           bool
+	order             string
+	priorityDirs      string
+	priorityList      []string
+	allowedRootsFile  string
+	allowedRoots      []string
+	readOnly          bool
+	ioWorkers         int
+	maxOpenFiles      int
+	readAhead         int
+	readCost          bool
+	shortDuration     time.Duration
+	statsOver         string
+	duplicateNames    bool
+	packs             bool
+	bwf               bool
+	audiobooks        bool
+	chapters          bool
+	tagAudit          bool
+	cache             string
+	probeCache        *probeCache
+	tagTolerance      float64
+	takes             bool
+	takePattern       string
+	takeRe            *regexp.Regexp
+	loopThreshold     float64
+	duplicateTol      float64
+	egressCost        float64
+	requestCost       float64
+	serveWork         string
+	authTokenFile     string
+	tlsCert           string
+	tlsKey            string
+	tlsCA             string
+	security          workSecurity
+	shard             string
+	shardSpec         shardSpec
+	outliers          string
+	outlierSpec       outlierSpec
+	join              string
+	enqueue           string
+	queueJobs         string
+	queueResults      string
+	queueIdle         time.Duration
+	daemon            string
+	daemonRescan      time.Duration
+	maxReadMbps       float64
+	maxIOPS           float64
+	idle              bool
+	idleLoad          float64
+	idleLatency       time.Duration
+	idleGate          *idleGate
+	pprof             string
+	otlp              string
+	emailTo           string
+	emailRecipients   []string
+	smtpConfig        string
+	smtp              *smtpConfig
+	emailHTML         bool
+	mqtt              string
+	auditLog          string
+	interactive       bool
+	scanManifest      string
+	version           bool
+	growing           string
+	tracks            bool
+	chains            bool
+	streamTracks      bool
+	codecProfiles     bool
+	growingGlobs      stringList
+	growingSettle     time.Duration
+	prompt            *prompter
+	audit             *auditLog
+	trace             string
+	lang              string
+	locale            string
+	units             string
+	numbers           numberFormat
+	precision         int
+	rounding          string
+	integerSeconds    bool
+	output            string
+	appendOutput      bool
+	jsonFiles         bool
+	resolvePointers   bool
+	template          string
+	tmpl              *template.Template
+	fallback          string
+	backend           string
+	noPlugins         bool
+	plugins           map[string]string // extension -> plugin executable
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// defineFlags registers the scan flags on the global flag set, bound to
+// the fields of opts.
+func defineFlags(opts *options) {
+	flag.Float64Var(&opts.playbackSpeed, "playback-speed", 0, "also report listening time at this playback speed (e.g. 1.5)")
+	flag.StringVar(&opts.estimateProc, "estimate-processing", "", "estimate transcription wall time for the scanned audio, e.g. rtf=0.15,gpus=4 (also per_file=SECONDS, cost=PER_DEVICE_HOUR)")
+	flag.BoolVar(&opts.books, "books", false, "treat each top-level folder as a book or series and report its time to finish")
+	flag.BoolVar(&opts.splitReport, "split-report", false, "report hours per train/dev/test split (auto-detected from directory names)")
+	flag.StringVar(&opts.splits, "splits", "", "custom split directories, e.g. train=tr,dev=cv|valid,test=tt (implies --split-report)")
+	flag.StringVar(&opts.expectSplit, "expect-ratios", "80/10/10", "expected train/dev/test hour ratios for split warnings")
+	flag.Float64Var(&opts.ratioTolerance, "ratio-tolerance", 5, "percentage points a split ratio may deviate before warning")
+	flag.BoolVar(&opts.labels, "labels", false, "report hours and counts per class label (the folder containing each file)")
+	flag.Float64Var(&opts.imbalanceRatio, "imbalance-ratio", 3, "largest-to-smallest label ratio above which --labels warns")
+	flag.Float64Var(&opts.pareto, "pareto", 0, "list the fewest directories that hold this percentage of all hours (e.g. 80); 0 disables")
+	flag.IntVar(&opts.diskUsage, "disk-usage", 0, "report hours, GB and GB per hour per directory at this depth below the root (e.g. 1 for top-level folders); 0 disables")
+	flag.StringVar(&opts.simulateBitrate, "simulate-bitrate", "", "estimate the library's size if re-encoded at this bitrate, e.g. 96k or opus:96k, from the measured durations")
+	flag.StringVar(&opts.ageScatter, "age-scatter", "", "write each file's modification time and duration to this CSV file (JSON if it ends in .json)")
+	flag.BoolVar(&csvBOM, "bom", false, "start CSV exports with a UTF-8 byte order mark, so Excel reads non-ASCII file names correctly")
+	flag.BoolVar(&csvCRLF, "crlf", false, "end CSV export lines with CRLF, as Windows tools expect")
+	flag.BoolVar(&opts.agePlot, "age-plot", false, "plot duration against modification time in the terminal, with the trend in seconds per year")
+	flag.StringVar(&opts.folded, "folded", "", "write hours by directory as folded stacks (seconds) for flamegraph.pl or speedscope to this file")
+	flag.StringVar(&opts.treemap, "treemap", "", "write hours by directory as a webtreemap JSON tree to this file")
+	flag.StringVar(&opts.emitDirFiles, "emit-dir-files", "", "write a small summary file with this name (e.g. .hours) into every directory holding audio, with its subtree's hours and file counts")
+	flag.StringVar(&opts.layout, "layout", "", "YAML file naming what each folder level means; prints an hours pivot table (e.g. language × split)")
+	flag.StringVar(&opts.expectCounts, "expect-counts", "", "YAML file of the files and/or hours each directory should hold; reports the deltas")
+	flag.Float64Var(&opts.expectTolerance, "expect-tolerance", 1, "percent of the expected hours a directory may differ by before --expect-counts flags it")
+	flag.IntVar(&opts.speakers.level, "speaker-level", 0, "report hours per speaker, taking the speaker ID from the folder at this depth below the root")
+	flag.StringVar(&opts.speakerRegex, "speaker-regex", "", "report hours per speaker, taking the ID from the first capture group matched against the relative path")
+	flag.Float64Var(&opts.speakerMaxShare, "speaker-max-share", 20, "warn when one speaker holds more than this percentage of all hours")
+	flag.Float64Var(&opts.goalHours, "goal-hours", 0, "report progress towards this many hours")
+	flag.StringVar(&opts.history, "history", "", "JSONL file that records each scan's totals; used to project --goal-hours")
+	flag.StringVar(&opts.requireTranscript, "require-transcript", "", "report audio files lacking a sibling transcript with this extension (e.g. .txt)")
+	flag.Var(&opts.segments, "segments", "segmentation file or directory (Kaldi segments, RTTM, CTM, TextGrid, Audacity labels); repeatable")
+	flag.BoolVar(&opts.subtitles, "subtitles", false, "cross-check SRT/VTT files next to audio against the audio duration")
+	flag.BoolVar(&opts.deep, "deep", false, "fully decode every file (WAV and MP3) to verify it plays end-to-end, with loudness and clipping statistics")
+	flag.StringVar(&opts.outliers, "outliers", "", "flag files whose duration is an outlier within their directory: zscore=K (standard deviations from the mean) or mad=K (scaled MADs from the median)")
+	flag.Float64Var(&opts.loudnessTolerance, "loudness-tolerance", 6, "LU from the median loudness beyond which a file is an outlier")
+	flag.Float64Var(&opts.clipPercent, "clip-percent", 0.1, "percentage of full-scale samples above which a file is reported as clipped (with --deep)")
+	flag.Float64Var(&opts.verifyTolerance, "verify-tolerance", 0.5, "seconds a file may fall short of its declared duration before --deep or --check-truncation reports it")
+	flag.StringVar(&opts.statsOver, "stats-over", statsOverValid, "files the mean and median are taken over: valid (positive duration), processed (zero durations too), or all (failures count as zero)")
+	flag.DurationVar(&opts.shortDuration, "short-duration", time.Second, "list files that probe successfully but are shorter than this, including zero-length ones")
+	flag.BoolVar(&opts.audiobooks, "audiobooks", false, "list chaptered M4B/M4A/AAX files as books with chapter counts, length and mean chapter length")
+	flag.BoolVar(&opts.chapters, "chapters", false, "list the chapters (start, length, title) of M4B/M4A/AAX files and of MP3s with ID3 CHAP frames")
+	flag.StringVar(&opts.cache, "cache", "", "JSONL file of earlier probe results; files whose size and ETag (or modification time) are unchanged are not probed again")
+	flag.BoolVar(&opts.tagAudit, "tag-audit", false, "compare tag-declared durations (ID3 TLEN, iTunes iTunSMPB) with measured ones and list files that disagree")
+	flag.Float64Var(&opts.tagTolerance, "tag-tolerance", 2, "seconds a tag-declared duration may differ from the measured one before --tag-audit reports it")
+	flag.BoolVar(&opts.bwf, "bwf", false, "read Broadcast Wave bext/iXML metadata and report hours per shoot day, with scene and take counts")
+	flag.BoolVar(&opts.takes, "takes", false, "group the per-track files of polyphonic recorder takes (TAKE01_T1.wav ... _T8.wav) and report unique take hours next to file hours")
+	flag.StringVar(&opts.takePattern, "take-regex", defaultTakePattern, "regexp on the file name without extension whose first group names the take, for --takes")
+	flag.BoolVar(&opts.packs, "packs", false, "treat each top-level folder as a sample pack: files, minutes, one-shots vs loops and mean one-shot length")
+	flag.Float64Var(&opts.loopThreshold, "loop-threshold", 2, "seconds at or above which --packs counts a sample as a loop rather than a one-shot")
+	flag.BoolVar(&opts.duplicateNames, "duplicate-names", false, "report file names found in several directories with the same duration, a cheap hint of copied files")
+	flag.Float64Var(&opts.duplicateTol, "duplicate-tolerance", 0.1, "seconds within which --duplicate-names treats two durations as equal")
+	flag.BoolVar(&opts.checkExtensions, "check-extensions", false, "report files whose content (by magic bytes) does not match their extension, e.g. MP3 data in a .wav")
+	flag.BoolVar(&opts.checkTruncation, "check-truncation", false, "flag MP3/M4A files whose declared duration exceeds the audio data actually present")
+	flag.StringVar(&opts.checksums, "checksums", "", "write a manifest of path, size, checksum and duration using this algorithm (md5, sha1, sha256, sha512)")
+	flag.BoolVar(&opts.tracks, "tracks", false, "list M4A/M4B/AAX files with several audio tracks (e.g. one per language) with each track's language and duration")
+	flag.StringVar(&trackDuration, "track-duration", trackDurationContainer, "duration counted for MP4 files with several audio tracks: container (the movie duration) or sum (all audio tracks added up)")
+	flag.BoolVar(&opts.chains, "chains", false, "list chained Ogg files (e.g. Icecast stream dumps) with the number of concatenated streams each holds")
+	flag.BoolVar(&opts.streamTracks, "stream-tracks", false, "list the tracks inside stream dumps, split at Ogg chain boundaries and at ID3 tags or ICY StreamTitle changes within MP3s")
+	flag.BoolVar(&opts.codecProfiles, "codec-profiles", false, "break the hours down by codec profile (MP3 CBR bitrate or VBR/ABR, AAC-LC or HE-AAC, PCM bit depth) to plan re-encoding")
+	flag.StringVar(&opts.growing, "growing", "", "check for files still being written and count them as provisional, skip them, or retry them at the end once they settle")
+	flag.Var(&opts.growingGlobs, "growing-glob", "treat files whose name or relative path matches this glob (e.g. '*.part') as still being written; repeatable, implies --growing provisional")
+	flag.DurationVar(&opts.growingSettle, "growing-settle", 2*time.Second, "how long a file must go unmodified before --growing considers it finished")
+	flag.BoolVar(&opts.version, "version", false, "print the version, VCS revision and Go build of this binary and exit")
+	flag.StringVar(&opts.scanManifest, "scan-manifest", "", "write a JSON record of the tool version, flags, extensions and every file's size, checksum and duration, for reproducing this scan")
+	flag.StringVar(&opts.manifest, "manifest", "", "manifest file written by --checksums (default manifest.<algo>.tsv)")
+	flag.StringVar(&opts.verifyManifest, "verify-manifest", "", "verify the tree against a manifest written by --checksums")
+	flag.StringVar(&opts.fallback, "fallback", "", "backend for files the native probers cannot handle (ffprobe)")
+	flag.StringVar(&opts.backend, "backend", "native", "primary prober: native, or libav when built with -tags libav")
+	flag.BoolVar(&opts.noPlugins, "no-plugins", false, "ignore "+pluginPrefix+"<ext> plugins on PATH")
+	flag.StringVar(&opts.parquet, "parquet", "", "write per-file records (path, duration, size, codec, sample rate, mtime) to this Parquet file")
+	flag.StringVar(&opts.sqlite, "sqlite", "", "append this scan, its files and its errors to a SQLite database (scans, files, errors tables)")
+	flag.StringVar(&opts.shard, "shard", "", "process only shard K of N (e.g. 3/8), assigned by path hash; merge the JSON outputs with the merge command")
+	flag.StringVar(&opts.serveWork, "serve-work", "", "coordinate a distributed scan: hand files out to --join workers on this address (e.g. :7070)")
+	flag.StringVar(&opts.authTokenFile, "auth-token-file", "", "file holding a shared secret that --join workers must present to the --serve-work coordinator")
+	flag.StringVar(&opts.tlsCert, "tls-cert", "", "PEM certificate for --serve-work (served over TLS) or --join (presented as a client certificate)")
+	flag.StringVar(&opts.tlsKey, "tls-key", "", "PEM private key for --tls-cert")
+	flag.StringVar(&opts.tlsCA, "tls-ca", "", "PEM CA bundle: the coordinator requires worker certificates it signed; workers check the coordinator against it")
+	flag.StringVar(&opts.join, "join", "", "probe files for the coordinator at host:port; <folder_path> is this machine's mount of the scanned tree")
+	flag.StringVar(&opts.enqueue, "enqueue", "", "push the walked audio files as jobs to this redis://host/<key> list or nats://host/<subject> instead of probing them")
+	flag.StringVar(&opts.queueJobs, "queue-jobs", "", "probe file paths taken from this redis:// list or nats:// subject instead of walking <folder_path>; relative paths resolve against it")
+	flag.StringVar(&opts.queueResults, "queue-results", "", "publish each file's result as a JSON message to this redis:// list or nats:// subject")
+	flag.DurationVar(&opts.queueIdle, "queue-idle", 10*time.Second, "with --queue-jobs, exit after this long without a job")
+	flag.StringVar(&opts.daemon, "daemon", "", "keep running: rescan the tree every --daemon-rescan and serve per-directory totals as JSON-RPC on this unix socket")
+	flag.DurationVar(&opts.daemonRescan, "daemon-rescan", 15*time.Minute, "with --daemon, how often to rescan; unchanged files are not probed again")
+	flag.StringVar(&opts.allowedRootsFile, "allowed-roots", "", "file listing the absolute directories, one per line, that may be scanned; other roots and files resolving outside them are refused")
+	flag.BoolVar(&opts.readOnly, "read-only", false, "refuse to start if any output (--output, --cache, --audit-log, ...) would be written inside the scanned tree, and reject --emit-dir-files")
+	flag.StringVar(&opts.priorityDirs, "priority-dirs", "", "file listing directories, one per line, to probe first; each one's subtotal is printed as soon as its files are done")
+	flag.StringVar(&opts.order, "order", "interleave", "probe order: walk, small-first, or interleave (smallest and largest files alternately)")
+	flag.IntVar(&numWorkers, "workers", numWorkers, "number of files probed in parallel")
+	flag.IntVar(&opts.ioWorkers, "io-workers", 4, "number of files read ahead in parallel for the probing workers; 0 reads inside the probing workers")
+	flag.IntVar(&opts.readAhead, "read-ahead", 16, "number of read-ahead files buffered between the I/O and probing workers")
+	flag.Float64Var(&opts.maxReadMbps, "max-read-mbps", 0, "cap file reads at this many megabits per second across all workers")
+	flag.Float64Var(&opts.maxIOPS, "max-iops", 0, "cap file reads at this many read operations per second across all workers")
+	flag.IntVar(&readBufferSize, "read-buffer", readBufferSize, "smallest read issued to storage in bytes; smaller reads are served from a buffer of this size (0 = unbuffered)")
+	flag.IntVar(&opts.maxOpenFiles, "max-open-files", 0, "audio files held open at once across all workers; 0 stays 64 below the RLIMIT_NOFILE soft limit")
+	flag.BoolVar(&useMmap, "mmap", false, "memory-map audio files instead of reading them, saving syscalls on many small local files")
+	flag.StringVar(&fileAdvice, "fadvise", "", "readahead hint for opened files on Linux: sequential, random or willneed")
+	flag.Int64Var(&maxScanBytes, "max-bytes", 0, "stop reading from storage once the scan has read this many bytes; later files fail (0 = no limit)")
+	flag.Float64Var(&opts.egressCost, "egress-cost", 0, "storage egress price per GB, for the --read-cost report")
+	flag.Float64Var(&opts.requestCost, "request-cost", 0, "storage price per 1000 read requests, for the --read-cost report")
+	flag.BoolVar(&opts.readCost, "read-cost", false, "report the bytes and read requests the scan issued and what they cost at --egress-cost and --request-cost")
+	flag.Int64Var(&maxProbeBytes, "max-probe-bytes", 0, "give up on a file after reading this many bytes while probing its duration (0 = no limit)")
+	flag.BoolVar(&opts.idle, "idle", false, "run at lowest CPU priority and pause while the system is busy")
+	flag.Float64Var(&opts.idleLoad, "idle-load", 0.7, "load average per CPU above which --idle pauses")
+	flag.DurationVar(&opts.idleLatency, "idle-latency", 50*time.Millisecond, "average read latency above which --idle pauses")
+	flag.BoolVar(&opts.timings, "timings", false, "break the scan time down into walking, I/O and decoding, with a worker-count hint")
+	flag.BoolVar(&opts.interactive, "interactive", false, "ask before following symlinked directories, extracting archives and counting files whose content does not match their extension")
+	flag.StringVar(&opts.auditLog, "audit-log", "", "write every file's counting decision (counted, failed, or skipped and why) to this JSONL file")
+	flag.StringVar(&opts.otlp, "otlp", "", "send a trace of the walk, probe and aggregate stages and scan metrics to this OTLP/HTTP collector (e.g. http://localhost:4318; default $OTEL_EXPORTER_OTLP_ENDPOINT)")
+	flag.StringVar(&opts.emailTo, "email-to", "", "mail the summary to these comma-separated addresses when the scan finishes, through the server in --smtp-config")
+	flag.StringVar(&opts.smtpConfig, "smtp-config", "", "YAML file of SMTP settings (host, port, username, password or password_file, from, tls) for --email-to")
+	flag.BoolVar(&opts.emailHTML, "email-html", false, "attach an HTML report of hours per folder and failed files to the --email-to message")
+	flag.StringVar(&opts.mqtt, "mqtt", "", "publish each scan's totals as a retained JSON message to this mqtt://[user:password@]host/<topic> (or mqtts://) for home-automation dashboards")
+	flag.StringVar(&opts.pprof, "pprof", "", "serve net/http/pprof on this address during the scan (e.g. :6060, on 127.0.0.1 unless a host is given)")
+	flag.StringVar(&opts.trace, "trace", "", "write a runtime execution trace to this file (view with go tool trace)")
+	flag.StringVar(&progressStyle, "progress", progressBar, "how probing progress is shown: bar (redrawn in place), plain (a timestamped line every --progress-every, for CI logs and nohup) or none")
+	flag.StringVar(&progressEvery, "progress-every", progressEvery, "with --progress plain, print a line every this many files (500) or this share of them (5%)")
+	flag.StringVar(&opts.lang, "lang", "", "language for messages: en, fr or es (default from LC_ALL, LC_MESSAGES or LANG)")
+	flag.StringVar(&opts.locale, "locale", "", "format totals with this locale's separators (e.g. de, fr-FR)")
+	flag.StringVar(&opts.units, "units", "hours", "unit for totals: minutes, hours or days")
+	flag.IntVar(&opts.precision, "precision", -1, "decimals for every reported duration and total, in the text report and in --format json (default: each value's own, e.g. 2 for totals)")
+	flag.StringVar(&opts.rounding, "rounding", roundNearest, "how --precision and --integer-seconds round: nearest, down or up")
+	flag.BoolVar(&opts.integerSeconds, "integer-seconds", false, "also report the total as a whole number of seconds (total_seconds_int), rounded once from the exact sum, for systems that reject fractional hours")
+	flag.StringVar(&opts.format, "format", "text", "summary format: text, or json for one JSON object per scan")
+	flag.StringVar(&opts.output, "output", "", "write the summary (in --format, or --template) to this file instead of stdout")
+	flag.BoolVar(&opts.appendOutput, "append", false, "append to --output instead of overwriting it, keeping a rolling log")
+	flag.BoolVar(&opts.resolvePointers, "resolve-placeholders", false, "probe Git LFS and DVC placeholders from the local .git/lfs or .dvc cache, and estimate the hours of the rest from their recorded sizes")
+	flag.BoolVar(&opts.jsonFiles, "json-files", false, "include the per-file results, with codec, sample rate and prober metadata, in the summary")
+	flag.StringVar(&opts.template, "template", "", "print only this Go text/template rendered over the scan summary (e.g. '{{printf \"%.1f\" .TotalHours}}h')")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "list the files that would be scanned without probing them")
+	flag.BoolVar(&opts.estimate, "estimate", false, "probe a stratified random sample and extrapolate total hours with a confidence interval")
+	flag.StringVar(&opts.sample, "sample", "5%", "sample size for --estimate, as a percentage or fraction")
+	flag.Int64Var(&opts.seed, "seed", 1, "random seed for --estimate sampling")
+	flag.BoolVar(&opts.speechHours, "speech-hours", false, "decode audio and estimate speech hours with an energy-based VAD (WAV and MP3)")
+	flag.BoolVar(&opts.detectSilence, "detect-silence", false, "decode audio and flag silent or digital-zero files (WAV and MP3)")
+	flag.Float64Var(&opts.silencePercent, "silence-percent", 95, "percentage of silent frames above which a file is flagged")
+	flag.Float64Var(&opts.silenceLevel, "silence-level", -60, "level in dBFS below which a frame counts as silent")
+	flag.Float64Var(&opts.subtitleGap, "subtitle-gap", 30, "seconds without cues that count as a subtitle gap")
+}
+
+func parseOptions() options {
+	var opts options
+	defineFlags(&opts)
+
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage: %s [flags] <folder_path>\n", os.Args[0])
+		fmt.Fprintf(out, "       %s <command> [flags] <args>\n\n", os.Args[0])
+		fmt.Fprintln(out, "Commands:")
+		fmt.Fprintln(out, "  split        write duration-balanced train/dev/test file lists")
+		fmt.Fprintln(out, "  merge        combine --format json scan outputs into one report")
+		fmt.Fprintln(out, "  compare      compare hours, files and errors of two directories")
+		fmt.Fprintln(out, "  self-update  replace this binary with the latest release")
+		fmt.Fprintln(out, "  completion   print a bash, zsh, fish or powershell completion script")
+		fmt.Fprintln(out, "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if opts.version {
+		printVersion()
+		os.Exit(0)
+	}
+
+	opts.requireTranscript = normalizeExt(opts.requireTranscript)
+	if opts.estimate {
+		fraction, err := parseSampleFraction(opts.sample)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		opts.sampleFraction = fraction
+	}
+	switch opts.fallback {
+	case "":
+	case "ffprobe":
+		if _, err := exec.LookPath("ffprobe"); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: ffprobe not found in PATH; --fallback disabled")
+			opts.fallback = ""
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unsupported fallback backend: %s\n", opts.fallback)
+		os.Exit(2)
+	}
+	switch opts.backend {
+	case "native":
+	case "libav":
+		if !libavAvailable {
+			fmt.Fprintln(os.Stderr, "This binary was built without libav support; rebuild with -tags libav")
+			os.Exit(2)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unsupported backend: %s\n", opts.backend)
+		os.Exit(2)
+	}
+	opts.splitDirs = defaultSplitDirs
+	if opts.splits != "" {
+		mapping, err := parseSplitMapping(opts.splits)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		opts.splitDirs = mapping
+		opts.splitReport = true
+	}
+	if opts.takes {
+		re, err := regexp.Compile(opts.takePattern)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --take-regex: %v\n", err)
+			os.Exit(2)
+		}
+		opts.takeRe = re
+	}
+	if opts.speakerRegex != "" {
+		re, err := regexp.Compile(opts.speakerRegex)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --speaker-regex: %v\n", err)
+			os.Exit(2)
+		}
+		opts.speakers.re = re
+	}
+	if opts.splitReport {
+		ratios, err := parseRatios(opts.expectSplit)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		opts.expectRatios = ratios
+	}
+	if opts.interactive {
+		opts.prompt = newPrompter()
+	}
+	if !opts.noPlugins {
+		opts.plugins = discoverPlugins()
+	}
+	if opts.idle {
+		if err := lowerPriority(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not lower priority: %v\n", err)
+		}
+		opts.idleGate = newIdleGate(opts.idleLoad, opts.idleLatency)
+	}
+	throttle = newReadThrottle(opts.maxReadMbps, opts.maxIOPS)
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	readBufferSize = max(readBufferSize, 0)
+	setOpenLimit(opts.maxOpenFiles)
+	if _, ok := adviceFlags[fileAdvice]; fileAdvice != "" && !ok {
+		fmt.Fprintf(os.Stderr, "Unsupported --fadvise value: %s\n", fileAdvice)
+		os.Exit(2)
+	}
+	opts.ioWorkers = max(opts.ioWorkers, 0)
+	if useMmap {
+		// Mapped files are paged in on demand; read-ahead would copy them.
+		opts.ioWorkers = 0
+	}
+	opts.readAhead = max(opts.readAhead, 0)
+	if opts.lang != "" {
+		setLanguage(opts.lang)
+	}
+	numbers, err := newNumberFormat(opts.locale, opts.units, opts.precision, opts.rounding)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	opts.numbers = numbers
+	switch opts.format {
+	case "text", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "Unsupported format: %s\n", opts.format)
+		os.Exit(2)
+	}
+	if err := checkStatsPolicy(opts.statsOver); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if opts.priorityDirs != "" {
+		if opts.serveWork != "" {
+			fmt.Fprintln(os.Stderr, "--priority-dirs is not supported with --serve-work")
+			os.Exit(2)
+		}
+		dirs, err := readDirList(opts.priorityDirs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --priority-dirs: %v\n", err)
+			os.Exit(2)
+		}
+		opts.priorityList = dirs
+	}
+	if opts.allowedRootsFile != "" {
+		roots, err := loadAllowedRoots(opts.allowedRootsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --allowed-roots: %v\n", err)
+			os.Exit(2)
+		}
+		opts.allowedRoots = roots
+	}
+	if err := checkScheduleOrder(opts.order); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := checkProgress(progressStyle, progressEvery); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := checkTrackDuration(trackDuration); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := checkGrowingMode(opts.growing); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if opts.growing == "" && len(opts.growingGlobs) > 0 {
+		opts.growing = growingProvisional
+	}
+	if opts.shard != "" {
+		spec, err := parseShard(opts.shard)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		opts.shardSpec = spec
+	}
+	if opts.outliers != "" {
+		spec, err := parseOutlierSpec(opts.outliers)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		opts.outlierSpec = spec
+	}
+	if opts.authTokenFile != "" || opts.tlsCert != "" || opts.tlsKey != "" || opts.tlsCA != "" {
+		if opts.serveWork == "" && opts.join == "" {
+			fmt.Fprintln(os.Stderr, "--auth-token-file and --tls-* only apply to --serve-work and --join")
+			os.Exit(2)
+		}
+		security, err := loadWorkSecurity(opts.authTokenFile, opts.tlsCert, opts.tlsKey, opts.tlsCA)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid work security settings: %v\n", err)
+			os.Exit(2)
+		}
+		if _, err := security.serverTLS(); err != nil && opts.serveWork != "" {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		opts.security = security
+	}
+	if opts.serveWork != "" && opts.wantsSamples() {
+		fmt.Fprintln(os.Stderr, "--serve-work only distributes duration probes; sample analyses are not supported")
+		os.Exit(2)
+	}
+	if opts.pareto < 0 || opts.pareto > 100 {
+		fmt.Fprintln(os.Stderr, "--pareto must be a percentage between 0 and 100")
+		os.Exit(2)
+	}
+	if opts.diskUsage < 0 {
+		fmt.Fprintln(os.Stderr, "--disk-usage must be a directory depth of 1 or more")
+		os.Exit(2)
+	}
+	if opts.estimateProc != "" {
+		plan, err := parsePlan(opts.estimateProc)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		opts.processingPlan = plan
+	}
+	if opts.simulateBitrate != "" {
+		target, err := parseBitrateTarget(opts.simulateBitrate)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		opts.bitrateTarget = target
+	}
+	if opts.appendOutput && opts.output == "" {
+		fmt.Fprintln(os.Stderr, "--append requires --output")
+		os.Exit(2)
+	}
+	if opts.jsonFiles && opts.format != "json" && opts.template == "" && opts.queueResults == "" && !opts.emailHTML {
+		fmt.Fprintln(os.Stderr, "--json-files requires --format json, --template, --queue-results or --email-html")
+		os.Exit(2)
+	}
+	for _, u := range []string{opts.enqueue, opts.queueJobs, opts.queueResults} {
+		if err := checkQueueURL(u); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if err := checkMQTTURL(opts.mqtt); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if opts.template != "" {
+		tmpl, err := parseTemplate(opts.template)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --template: %v\n", err)
+			os.Exit(2)
+		}
+		opts.tmpl = tmpl
+	}
+	if opts.layout != "" {
+		layout, err := loadLayout(opts.layout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --layout: %v\n", err)
+			os.Exit(2)
+		}
+		opts.pivot = &layout
+	}
+	if opts.daemonRescan <= 0 {
+		fmt.Fprintln(os.Stderr, "--daemon-rescan must be positive")
+		os.Exit(2)
+	}
+	if opts.emailTo != "" {
+		if opts.smtpConfig == "" {
+			fmt.Fprintln(os.Stderr, "--email-to requires --smtp-config")
+			os.Exit(2)
+		}
+		recipients, err := parseRecipients(opts.emailTo)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		smtp, err := loadSMTPConfig(opts.smtpConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --smtp-config: %v\n", err)
+			os.Exit(2)
+		}
+		opts.emailRecipients, opts.smtp = recipients, smtp
+	} else if opts.emailHTML {
+		fmt.Fprintln(os.Stderr, "--email-html requires --email-to")
+		os.Exit(2)
+	}
+	if opts.emailHTML && !opts.jsonFiles {
+		fmt.Fprintln(os.Stderr, "--email-html requires --json-files for the per-file results it reports")
+		os.Exit(2)
+	}
+	if (csvBOM || csvCRLF) && (opts.ageScatter == "" || ageScatterJSON(opts.ageScatter)) {
+		fmt.Fprintln(os.Stderr, "--bom and --crlf require a CSV export: --age-scatter to a file not ending in .json")
+		os.Exit(2)
+	}
+	if opts.emitDirFiles != "" {
+		if err := checkDirFileName(opts.emitDirFiles, opts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	opts.checksums = strings.ToLower(opts.checksums)
+	if _, ok := checksumAlgorithms[opts.checksums]; opts.checksums != "" && !ok {
+		fmt.Fprintf(os.Stderr, "Unsupported checksum algorithm: %s\n", opts.checksums)
+		os.Exit(2)
+	}
+	return opts
+}
+
+// machineSummary reports whether the run ends with a summary meant for
+// programs rather than people.
+func (o options) machineSummary() bool {
+	return o.tmpl != nil || o.format == "json"
+}
+
+// hashAlgorithm is the checksum computed per file: --checksums, or sha256
+// when only --scan-manifest needs one.
+func (o options) hashAlgorithm() string {
+	if o.checksums == "" && o.scanManifest != "" {
+		return "sha256"
+	}
+	return o.checksums
+}
+
+// wantsFormat reports whether results need the stream format probed.
+func (o options) wantsFormat() bool {
+	return o.parquet != "" || o.jsonFiles
+}
+
+func (o options) manifestPath() string {
+	if o.manifest != "" {
+		return o.manifest
+	}
+	return "manifest." + o.checksums + ".tsv"
+}
+
+// normalizeExt lowercases an extension and makes sure it starts with a dot,
+// so ".TXT", "txt" and ".txt" all match the same files.
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(nfc(strings.TrimSpace(ext)))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
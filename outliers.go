@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --outliers methods.
+const (
+	outlierZScore = "zscore" // distance from the directory mean in standard deviations
+	outlierMAD    = "mad"    // modified z-score: distance from the median in scaled MADs
+)
+
+// outlierMinFiles is the fewest measured files a directory needs before
+// its distribution is used to judge them.
+const outlierMinFiles = 5
+
+// outlierSpec is --outliers METHOD=THRESHOLD.
+type outlierSpec struct {
+	method    string
+	threshold float64
+}
+
+func (s outlierSpec) String() string {
+	return s.method + "=" + strconv.FormatFloat(s.threshold, 'g', -1, 64)
+}
+
+// parseOutlierSpec reads zscore=3 or mad=3.5; a bare method takes the
+// usual threshold for it.
+func parseOutlierSpec(v string) (outlierSpec, error) {
+	method, value, hasValue := strings.Cut(v, "=")
+	spec := outlierSpec{method: method}
+	switch method {
+	case outlierZScore:
+		spec.threshold = 3
+	case outlierMAD:
+		spec.threshold = 3.5
+	default:
+		return spec, fmt.Errorf("invalid --outliers %q (want zscore=K or mad=K)", v)
+	}
+	if hasValue {
+		k, err := strconv.ParseFloat(value, 64)
+		if err != nil || k <= 0 {
+			return spec, fmt.Errorf("invalid --outliers %q (want zscore=K or mad=K)", v)
+		}
+		spec.threshold = k
+	}
+	return spec, nil
+}
+
+type durationOutlier struct {
+	path    string
+	seconds float64
+	center  float64 // the directory mean or median
+	score   float64 // signed: negative for files shorter than usual
+}
+
+type outlierReport struct {
+	spec        outlierSpec
+	directories int // directories with enough files to judge
+	tooFew      int // directories skipped for having fewer than outlierMinFiles
+	outliers    []durationOutlier
+}
+
+// buildOutlierReport scores each file against the durations of all the
+// files in its directory. Files that failed or have no duration are left
+// out of the distributions.
+func buildOutlierReport(audioFiles []string, durations []float64, spec outlierSpec) outlierReport {
+	report := outlierReport{spec: spec}
+	byDir := make(map[string][]int)
+	var dirs []string
+	for i, p := range audioFiles {
+		if durations[i] <= 0 {
+			continue
+		}
+		dir := filepath.Dir(p)
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], i)
+	}
+	for _, dir := range dirs {
+		files := byDir[dir]
+		if len(files) < outlierMinFiles {
+			report.tooFew++
+			continue
+		}
+		report.directories++
+		values := make([]float64, len(files))
+		for j, i := range files {
+			values[j] = durations[i]
+		}
+		center, spread := distribution(values, spec.method)
+		if spread == 0 {
+			continue
+		}
+		for _, i := range files {
+			score := (durations[i] - center) / spread
+			if math.Abs(score) > spec.threshold {
+				report.outliers = append(report.outliers, durationOutlier{path: audioFiles[i], seconds: durations[i], center: center, score: score})
+			}
+		}
+	}
+	sort.Slice(report.outliers, func(i, j int) bool {
+		return math.Abs(report.outliers[i].score) > math.Abs(report.outliers[j].score)
+	})
+	return report
+}
+
+// distribution is the center and the unit a score is measured in: the mean
+// and standard deviation, or the median and 1.4826 MAD, which matches the
+// standard deviation for normal data but is not pulled by the outliers
+// themselves.
+func distribution(values []float64, method string) (center, spread float64) {
+	if method == outlierMAD {
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		center = quantile(sorted, 0.5)
+		deviations := make([]float64, len(sorted))
+		for i, v := range sorted {
+			deviations[i] = math.Abs(v - center)
+		}
+		sort.Float64s(deviations)
+		return center, 1.4826 * quantile(deviations, 0.5)
+	}
+	for _, v := range values {
+		center += v
+	}
+	center /= float64(len(values))
+	var sum float64
+	for _, v := range values {
+		sum += (v - center) * (v - center)
+	}
+	return center, math.Sqrt(sum / float64(len(values)))
+}
+
+func printOutlierReport(root string, report outlierReport) {
+	fmt.Fprintln(console, "\n=== Duration outliers ===")
+	center := "mean"
+	if report.spec.method == outlierMAD {
+		center = "median"
+	}
+	fmt.Fprintf(console, "Directories checked: %d (%d with fewer than %d files skipped)\n", report.directories, report.tooFew, outlierMinFiles)
+	fmt.Fprintf(console, "Outliers (%s): %d\n", report.spec, len(report.outliers))
+	printList(len(report.outliers), func(i int) string {
+		o := report.outliers[i]
+		return fmt.Sprintf("%s (%.2fs, directory %s %.2fs, score %+.1f)", relPath(root, o.path), o.seconds, center, o.center, o.score)
+	})
+}
@@ -0,0 +1,47 @@
+package main
+
+import "fmt"
+
+// printPackReport treats every top-level folder as a sample pack and splits
+// its files into one-shots and loops at the duration threshold.
+func printPackReport(root string, audioFiles []string, durations []float64, loopThreshold float64) {
+	packOf := func(path string) (string, bool) { return topLevelDir(root, path) }
+	durationOf := make(map[string]float64, len(audioFiles))
+	for i, path := range audioFiles {
+		durationOf[path] = durations[i]
+	}
+	packs := groupDurations(audioFiles, durations, packOf)
+	// Files that failed or are empty are neither one-shots nor loops.
+	byKind := func(loop bool) map[string]groupStat {
+		groups := groupDurations(audioFiles, durations, func(path string) (string, bool) {
+			d := durationOf[path]
+			if d <= 0 || (d >= loopThreshold) != loop {
+				return "", false
+			}
+			return packOf(path)
+		})
+		byPack := make(map[string]groupStat, len(groups))
+		for _, g := range groups {
+			byPack[g.key] = g
+		}
+		return byPack
+	}
+	shots, loops := byKind(false), byKind(true)
+	sortGroupsBySeconds(packs)
+
+	fmt.Fprintln(console, "\n=== Sample packs ===")
+	if len(packs) == 0 {
+		fmt.Fprintln(console, "No subfolders found.")
+		return
+	}
+	fmt.Fprintf(console, "Packs: %d (one-shots are shorter than %gs)\n\n", len(packs), loopThreshold)
+	fmt.Fprintf(console, "%-40s %7s %9s %9s %7s %11s\n", "Pack", "Files", "Minutes", "One-shots", "Loops", "Mean shot")
+	for _, p := range packs {
+		s := shots[p.key]
+		mean := "-"
+		if s.files > 0 {
+			mean = fmt.Sprintf("%.2fs", s.seconds/float64(s.files))
+		}
+		fmt.Fprintf(console, "%-40s %7d %9.1f %9d %7d %11s\n", p.key, p.files, p.seconds/60, s.files, loops[p.key].files, mean)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type dirHours struct {
+	dir     string
+	files   int
+	seconds float64
+}
+
+// paretoReport is the smallest set of directories holding a given share of
+// the total hours, and the deepest directory they all live under.
+type paretoReport struct {
+	share        float64
+	dirs         []dirHours // largest first, just enough to reach share
+	totalDirs    int
+	totalSeconds float64
+	covered      float64
+	common       string
+}
+
+// buildParetoReport sums hours per directory (the folder directly holding
+// each file) and takes the largest until they cover share percent.
+func buildParetoReport(root string, audioFiles []string, durations []float64, share float64) paretoReport {
+	report := paretoReport{share: share}
+	index := make(map[string]int)
+	var dirs []dirHours
+	for i, p := range audioFiles {
+		if durations[i] <= 0 {
+			continue
+		}
+		dir := path.Dir(nfc(filepath.ToSlash(relPath(root, p))))
+		n, ok := index[dir]
+		if !ok {
+			n = len(dirs)
+			index[dir] = n
+			dirs = append(dirs, dirHours{dir: dir})
+		}
+		dirs[n].files++
+		dirs[n].seconds += durations[i]
+		report.totalSeconds += durations[i]
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].seconds != dirs[j].seconds {
+			return dirs[i].seconds > dirs[j].seconds
+		}
+		return dirs[i].dir < dirs[j].dir
+	})
+	report.totalDirs = len(dirs)
+
+	for _, d := range dirs {
+		if report.covered >= report.totalSeconds*share/100 {
+			break
+		}
+		report.dirs = append(report.dirs, d)
+		report.covered += d.seconds
+	}
+	report.common = commonDir(report.dirs)
+	return report
+}
+
+// commonDir is the longest directory prefix shared by every entry.
+func commonDir(dirs []dirHours) string {
+	if len(dirs) == 0 {
+		return ""
+	}
+	common := strings.Split(dirs[0].dir, "/")
+	for _, d := range dirs[1:] {
+		parts := strings.Split(d.dir, "/")
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	if len(common) == 0 {
+		return "."
+	}
+	return strings.Join(common, "/")
+}
+
+func printParetoReport(report paretoReport) {
+	fmt.Fprintf(console, "\n=== Where the hours are (%.0f%%) ===\n", report.share)
+	if report.totalSeconds == 0 {
+		fmt.Fprintln(console, "No audio with a known duration.")
+		return
+	}
+	fmt.Fprintf(console, "%d of %d directories hold %.1f%% of all hours", len(report.dirs), report.totalDirs, percent(report.covered, report.totalSeconds))
+	if report.common != "." {
+		fmt.Fprintf(console, ", all under %s", report.common)
+	}
+	fmt.Fprintln(console)
+
+	fmt.Fprintf(console, "\n%-40s %8s %10s %8s %8s\n", "Directory", "Files", "Hours", "Share", "Cumul.")
+	var cumulative float64
+	for i, d := range report.dirs {
+		if i == maxListed {
+			fmt.Fprintf(console, "... and %d more\n", len(report.dirs)-maxListed)
+			break
+		}
+		cumulative += d.seconds
+		fmt.Fprintf(console, "%-40s %8d %10.2f %7.1f%% %7.1f%%\n", d.dir, d.files, d.seconds/3600.0,
+			percent(d.seconds, report.totalSeconds), percent(cumulative, report.totalSeconds))
+	}
+}
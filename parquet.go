@@ -0,0 +1,39 @@
+package main
+
+import (
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetRecord is one row of the --parquet output.
+type parquetRecord struct {
+	Path       string    `parquet:"path"`
+	Duration   float64   `parquet:"duration"`
+	Size       int64     `parquet:"size"`
+	Codec      string    `parquet:"codec,optional"`
+	SampleRate int32     `parquet:"sample_rate,optional"`
+	ModTime    time.Time `parquet:"mtime,timestamp(millisecond)"`
+	Error      string    `parquet:"error,optional"`
+}
+
+// writeParquet writes one record per scanned file, failures included, with
+// paths relative to root.
+func writeParquet(path, root string, audioFiles []string, results []result) error {
+	records := make([]parquetRecord, len(audioFiles))
+	for i, file := range audioFiles {
+		res := results[i]
+		records[i] = parquetRecord{
+			Path:       relPath(root, file),
+			Duration:   res.duration,
+			Size:       res.size,
+			Codec:      res.format.codec,
+			SampleRate: int32(res.format.sampleRate),
+			ModTime:    res.modTime,
+		}
+		if res.err != nil {
+			records[i].Error = res.err.Error()
+		}
+	}
+	return parquet.WriteFile(path, records)
+}
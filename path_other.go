@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+import "path/filepath"
+
+// resolveRoot resolves symlinks in the scan root.
+func resolveRoot(p string) (string, error) {
+	return filepath.EvalSymlinks(p)
+}
+
+// displayPath returns p unchanged; only Windows rewrites scan roots.
+func displayPath(p string) string { return p }
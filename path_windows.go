@@ -0,0 +1,49 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolveRoot makes the scan root absolute and switches it to the
+// extended-length form, so every path the walker derives from it is also
+// extended and files nested beyond MAX_PATH stay reachable. Resolving
+// symlinks fails on some SMB shares; the absolute path is used as is then.
+func resolveRoot(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		if _, serr := os.Stat(abs); serr != nil {
+			return "", err
+		}
+		resolved = abs
+	}
+	return extendedLengthPath(resolved), nil
+}
+
+// extendedLengthPath turns C:\dir into \\?\C:\dir and \\server\share into
+// \\?\UNC\server\share.
+func extendedLengthPath(p string) string {
+	switch {
+	case strings.HasPrefix(p, `\\?\`):
+		return p
+	case strings.HasPrefix(p, `\\`):
+		return `\\?\UNC\` + p[2:]
+	default:
+		return `\\?\` + p
+	}
+}
+
+// displayPath undoes extendedLengthPath for messages.
+func displayPath(p string) string {
+	if rest, ok := strings.CutPrefix(p, `\\?\UNC\`); ok {
+		return `\\` + rest
+	}
+	return strings.TrimPrefix(p, `\\?\`)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// workerBusyNanos sums the time workers spend on files, I/O included.
+var workerBusyNanos atomic.Int64
+
+// perfReport breaks a scan's wall time into stages.
+type perfReport struct {
+	walk         time.Duration
+	probe        time.Duration
+	workers      int
+	ioWorkers    int
+	files        int
+	busy         time.Duration // summed over workers
+	io           time.Duration // summed over workers
+	ahead        time.Duration // summed over I/O workers
+	reads        int64
+	bytes        int64
+	treeBytes    int64 // size of the files probed
+	audioSeconds float64
+}
+
+func buildPerfReport(walk, probe time.Duration, workers, ioWorkers, files int, audioSeconds float64) perfReport {
+	return perfReport{
+		walk:         walk,
+		probe:        probe,
+		workers:      workers,
+		ioWorkers:    ioWorkers,
+		files:        files,
+		busy:         time.Duration(workerBusyNanos.Load()),
+		io:           time.Duration(ioStats.readNanos.Load()),
+		ahead:        time.Duration(ioStats.aheadNanos.Load()),
+		reads:        ioStats.reads.Load(),
+		bytes:        ioStats.bytes.Load(),
+		audioSeconds: audioSeconds,
+	}
+}
+
+// realtimeFactor is audio seconds per wall second across walk and probe.
+func (r perfReport) realtimeFactor() float64 {
+	wall := (r.walk + r.probe).Seconds()
+	if wall <= 0 {
+		return 0
+	}
+	return r.audioSeconds / wall
+}
+
+func printPerfReport(r perfReport) {
+	fmt.Fprintln(console, "\n=== Performance ===")
+	fmt.Fprintf(console, "Walking the tree: %.2fs\n", r.walk.Seconds())
+	capacity := r.probe.Seconds() * float64(r.workers)
+	fmt.Fprintf(console, "Probing: %.2fs wall with %d workers (%.0f%% busy)\n", r.probe.Seconds(), r.workers, percent(r.busy.Seconds(), capacity))
+	decode := max(r.busy-r.io, 0)
+	fmt.Fprintf(console, "  I/O reads: %.2fs (%.0f%% of worker time), %d reads, %.1f MB\n", r.io.Seconds(), percent(r.io.Seconds(), r.busy.Seconds()), r.reads, float64(r.bytes)/1e6)
+	fmt.Fprintf(console, "  Decoding and parsing: %.2fs (%.0f%% of worker time)\n", decode.Seconds(), percent(decode.Seconds(), r.busy.Seconds()))
+	aheadCapacity := r.probe.Seconds() * float64(r.ioWorkers)
+	if r.ioWorkers > 0 {
+		fmt.Fprintf(console, "Read-ahead: %.2fs with %d I/O workers (%.0f%% busy)\n", r.ahead.Seconds(), r.ioWorkers, percent(r.ahead.Seconds(), aheadCapacity))
+	}
+	if wall := (r.walk + r.probe).Seconds(); wall > 0 {
+		fmt.Fprintf(console, "Throughput: %.1f files/s, %.1f MB/s read\n", float64(r.files)/wall, float64(r.bytes)/1e6/wall)
+	}
+
+	// Workers waiting on storage leave the CPU idle, so more of them help;
+	// parsing-bound workers already keep every core busy. A saturated
+	// read-ahead pool starves the probing workers the same way.
+	if r.ioWorkers > 0 && r.ahead.Seconds() > 0.8*aheadCapacity {
+		fmt.Fprintln(console, "Hint: the read-ahead pool is saturated; on network or spinning storage, more --io-workers may raise throughput")
+	} else if r.busy > 0 && r.io*2 > r.busy {
+		fmt.Fprintln(console, "Hint: reads dominate; on network or spinning storage, more --workers may raise throughput")
+	} else if r.busy > 0 && r.workers > runtime.NumCPU() {
+		fmt.Fprintln(console, "Hint: parsing dominates; more --workers than CPU cores is unlikely to help")
+	}
+}
+
+// printReadCost prices the reads the scan issued. On object storage behind
+// a FUSE mount (s3fs, rclone, gcsfuse) each read is at least one ranged
+// GET, though the mount's own read-ahead can fetch more than is counted.
+func printReadCost(r perfReport, egressPerGB, per1000Requests float64) {
+	gb := float64(r.bytes) / 1e9
+	fmt.Fprintln(console, "\n=== Read cost ===")
+	fmt.Fprintf(console, "Bytes read: %.1f MB (%.1f%% of files' total size)\n", float64(r.bytes)/1e6, percent(float64(r.bytes), float64(r.treeBytes)))
+	fmt.Fprintf(console, "Read requests: %d\n", r.reads)
+	egress := gb * egressPerGB
+	requests := float64(r.reads) / 1000 * per1000Requests
+	fmt.Fprintf(console, "Estimated cost: %.4f egress + %.4f requests = %.4f\n", egress, requests, egress+requests)
+	if maxScanBytes > 0 && r.bytes >= maxScanBytes {
+		fmt.Fprintf(console, "Warning: --max-bytes %d was reached; files probed afterwards failed\n", maxScanBytes)
+	}
+}
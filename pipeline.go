@@ -0,0 +1,162 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// With --io-workers, probing runs as two pools: I/O workers read the part
+// of each file its prober needs into memory, and CPU workers (--workers)
+// parse it. A bounded channel between them (--read-ahead) keeps slow
+// network reads from idling the parsers without buffering the whole tree.
+
+const (
+	// prefetchWhole caps how much of a frame-scanned file (MP3) is read
+	// ahead; the rest is read by the CPU worker on demand.
+	prefetchWhole = 8 << 20
+	// prefetchHead is read ahead for formats probed from their headers.
+	prefetchHead = 64 << 10
+	// prefetchBudget caps the bytes held in prefetches at once; without it
+	// the default --io-workers and --read-ahead queue up 160 MB of MP3s.
+	prefetchBudget = 64 << 20
+)
+
+// prefetch is the read-ahead of one file, published in prefetched for
+// openAudio while its job is on a CPU worker.
+type prefetch struct {
+	head []byte
+	info os.FileInfo
+}
+
+var prefetched sync.Map // path -> prefetch
+
+// byteBudget is a semaphore counted in bytes.
+type byteBudget struct {
+	mu    sync.Mutex
+	freed sync.Cond
+	left  int64
+}
+
+func newByteBudget(n int64) *byteBudget {
+	b := &byteBudget{left: n}
+	b.freed.L = &b.mu
+	return b
+}
+
+// acquire waits until n bytes are free and takes them. n must not exceed
+// the budget.
+func (b *byteBudget) acquire(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.left < n {
+		b.freed.Wait()
+	}
+	b.left -= n
+}
+
+func (b *byteBudget) release(n int64) {
+	b.mu.Lock()
+	b.left += n
+	b.mu.Unlock()
+	b.freed.Broadcast()
+}
+
+// prefetchSize is how much of a file its prober will want.
+func prefetchSize(path string, size int64) int64 {
+	limit := int64(prefetchHead)
+	if strings.ToLower(filepath.Ext(path)) == ".mp3" {
+		limit = prefetchWhole
+	}
+	if maxProbeBytes > 0 {
+		limit = min(limit, maxProbeBytes)
+	}
+	return min(size, limit)
+}
+
+// readAhead loads the prefetch for one file, taking its size from budget
+// until the CPU worker is done with it. Failures are left for the CPU
+// worker to hit and report through the normal probe path.
+func readAhead(path string, budget *byteBudget) (prefetch, bool) {
+	if !scanBudgetLeft() {
+		return prefetch{}, false
+	}
+	f, err := openStorage(contentPath(path))
+	if err != nil {
+		return prefetch{}, false
+	}
+	defer closeStorage(f)
+	info, err := f.Stat()
+	if err != nil {
+		return prefetch{}, false
+	}
+	size := prefetchSize(path, info.Size())
+	budget.acquire(size)
+	head := make([]byte, size)
+	throttle.wait(len(head))
+	start := time.Now()
+	n, err := io.ReadFull(f, head)
+	ioStats.aheadNanos.Add(int64(time.Since(start)))
+	ioStats.reads.Add(1)
+	ioStats.bytes.Add(int64(n))
+	if err != nil {
+		budget.release(size)
+		return prefetch{}, false
+	}
+	return prefetch{head: head, info: info}, true
+}
+
+// runPipeline feeds jobs through the I/O pool into the CPU pool.
+func runPipeline(jobs <-chan fileJob, results []result, progress progressMeter, opts options) {
+	loaded := make(chan fileJob, opts.readAhead)
+	budget := newByteBudget(prefetchBudget)
+	var readers sync.WaitGroup
+	for i := 0; i < opts.ioWorkers; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			slot := skipper.slot()
+			defer skipper.release(slot)
+			for job := range jobs {
+				if opts.idleGate != nil {
+					opts.idleGate.wait()
+				}
+				slot.probing(filepath.Dir(job.path))
+				if p, ok := readAhead(job.path, budget); ok {
+					prefetched.Store(job.path, p)
+				}
+				slot.probing("")
+				loaded <- job
+			}
+		}()
+	}
+	go func() {
+		readers.Wait()
+		close(loaded)
+	}()
+
+	var cpu sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		cpu.Add(1)
+		go func() {
+			defer cpu.Done()
+			t := newTally(progress)
+			defer t.flush()
+			slot := skipper.slot()
+			defer skipper.release(slot)
+			for job := range loaded {
+				job.slot = slot
+				start := time.Now()
+				results[job.index] = processFile(job, opts)
+				if p, ok := prefetched.LoadAndDelete(job.path); ok {
+					budget.release(int64(len(p.(prefetch).head)))
+				}
+				t.record(start)
+			}
+		}()
+	}
+	cpu.Wait()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Placeholder kinds: files that stand in for audio whose content is not in
+// the working tree.
+const (
+	placeholderEmpty = "empty"
+	placeholderLFS   = "git-lfs"
+	placeholderDVC   = "dvc"
+	placeholderAnnex = "git-annex"
+)
+
+// lfsPointerPrefix starts every Git LFS pointer file; pointers are well
+// under lfsPointerMax bytes.
+var lfsPointerPrefix = []byte("version https://git-lfs.github.com/spec/")
+
+const lfsPointerMax = 1024
+
+// placeholderKind tells whether an audio-named walk entry is a placeholder:
+// a zero-byte file, a Git LFS pointer, or a git-annex symlink whose object
+// is not present. info comes from the walk, so symlinks are not followed.
+func placeholderKind(path string, info os.FileInfo) string {
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return ""
+		}
+		if _, err := os.Stat(path); err != nil && strings.Contains(filepath.ToSlash(target), "annex/objects/") {
+			return placeholderAnnex
+		}
+		return ""
+	}
+	if info.Size() == 0 {
+		return placeholderEmpty
+	}
+	if info.Size() > lfsPointerMax {
+		return ""
+	}
+	head := make([]byte, len(lfsPointerPrefix))
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	if n, _ := f.Read(head); n == len(head) && bytes.Equal(head, lfsPointerPrefix) {
+		return placeholderLFS
+	}
+	return ""
+}
+
+// dvcPlaceholder returns the audio file a DVC stub (song.wav.dvc) stands
+// for when that file is not checked out.
+func dvcPlaceholder(stub string, extensions map[string]bool) (string, bool) {
+	audio := strings.TrimSuffix(stub, filepath.Ext(stub))
+	if !extensions[strings.ToLower(nfc(filepath.Ext(audio)))] {
+		return "", false
+	}
+	if _, err := os.Lstat(audio); err == nil {
+		return "", false
+	}
+	return audio, true
+}
+
+// placeholderCounts summarises placeholders by kind, e.g.
+// "git-lfs 3, dvc 1".
+func placeholderCounts(paths []string, kinds map[string]string) string {
+	counts := make(map[string]int)
+	for _, p := range paths {
+		counts[kinds[p]]++
+	}
+	names := make([]string, 0, len(counts))
+	for kind := range counts {
+		names = append(names, kind)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, kind := range names {
+		parts[i] = fmt.Sprintf("%s %d", kind, counts[kind])
+	}
+	return strings.Join(parts, ", ")
+}
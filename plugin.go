@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// pluginPrefix names external probers. A plugin for ".xyz" is an executable
+// called howmanyhours-probe-xyz somewhere on PATH. It is run with the file
+// path as its only argument and must print a JSON object such as
+//
+//	{"duration": 12.5, "metadata": {"codec": "xyz"}}
+//
+// to stdout. A non-zero exit status marks the file as failed; anything on
+// stderr becomes the error message.
+const pluginPrefix = "howmanyhours-probe-"
+
+// pluginOutput is the JSON document a plugin prints.
+type pluginOutput struct {
+	Duration float64        `json:"duration"`
+	Metadata map[string]any `json:"metadata"`
+}
+
+// discoverPlugins maps extensions to plugin executables found on PATH. The
+// first match on PATH wins, like a shell lookup.
+func discoverPlugins() map[string]string {
+	plugins := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if e.IsDir() || !strings.HasPrefix(name, pluginPrefix) {
+				continue
+			}
+			ext := strings.TrimPrefix(name, pluginPrefix)
+			if runtime.GOOS == "windows" {
+				ext = strings.TrimSuffix(strings.ToLower(ext), ".exe")
+			}
+			ext = normalizeExt(ext)
+			if _, seen := plugins[ext]; ext == "." || seen {
+				continue
+			}
+			path := filepath.Join(dir, name)
+			if info, err := e.Info(); err != nil || (runtime.GOOS != "windows" && info.Mode()&0o111 == 0) {
+				continue
+			}
+			plugins[ext] = path
+		}
+	}
+	return plugins
+}
+
+// pluginProbe runs an external prober for one file.
+func pluginProbe(plugin, filePath string) (pluginOutput, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ffprobeTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, plugin, contentPath(filePath))
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	name := filepath.Base(plugin)
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return pluginOutput{}, fmt.Errorf("%s: %s", name, msg)
+		}
+		return pluginOutput{}, fmt.Errorf("%s: %w", name, err)
+	}
+
+	var out pluginOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return pluginOutput{}, fmt.Errorf("%s: invalid output: %w", name, err)
+	}
+	if out.Duration <= 0 {
+		return pluginOutput{}, fmt.Errorf("%s: no duration reported", name)
+	}
+	return out, nil
+}
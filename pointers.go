@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// contentPaths maps a placeholder to the cached copy of its content found
+// by --resolve-placeholders. It is filled before probing starts and only
+// read afterwards.
+var contentPaths = map[string]string{}
+
+// contentPath is the file holding path's bytes: its cached copy for a
+// resolved placeholder, otherwise path itself. Probers still look at path
+// for its extension.
+func contentPath(path string) string {
+	if p, ok := contentPaths[path]; ok {
+		return p
+	}
+	return path
+}
+
+// pointer is what a placeholder records about its content.
+type pointer struct {
+	size    int64  // recorded content size; 0 if unknown
+	content string // cached copy of the content, if present locally
+}
+
+// resolvePlaceholder reads a placeholder's pointer and looks for its
+// content in the local LFS, DVC or annex cache.
+func resolvePlaceholder(path, kind string) pointer {
+	switch kind {
+	case placeholderLFS:
+		return resolveLFS(path)
+	case placeholderDVC:
+		return resolveDVC(path)
+	case placeholderAnnex:
+		return resolveAnnex(path)
+	}
+	return pointer{}
+}
+
+// resolveLFS finds a pointer's object in .git/lfs/objects, where
+// "git lfs fetch" leaves content that is not checked out.
+func resolveLFS(path string) pointer {
+	var p pointer
+	var oid string
+	file, err := os.Open(path)
+	if err != nil {
+		return p
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), " ")
+		switch key {
+		case "oid":
+			oid = strings.TrimPrefix(value, "sha256:")
+		case "size":
+			p.size, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	gitDir, ok := findGitDir(filepath.Dir(path))
+	if !ok || len(oid) < 5 {
+		return p
+	}
+	p.content = existing(filepath.Join(gitDir, "lfs", "objects", oid[:2], oid[2:4], oid))
+	return p
+}
+
+// dvcStub is the part of a .dvc file naming its output.
+type dvcStub struct {
+	Outs []struct {
+		MD5  string `yaml:"md5"`
+		Size int64  `yaml:"size"`
+	} `yaml:"outs"`
+}
+
+// resolveDVC reads path + ".dvc" and finds the output in the project's
+// .dvc/cache, in the DVC 3 (files/md5/) or DVC 2 layout.
+func resolveDVC(path string) pointer {
+	var p pointer
+	data, err := os.ReadFile(path + ".dvc")
+	if err != nil {
+		return p
+	}
+	var stub dvcStub
+	if err := yaml.Unmarshal(data, &stub); err != nil || len(stub.Outs) == 0 {
+		return p
+	}
+	out := stub.Outs[0]
+	p.size = out.Size
+	if len(out.MD5) < 3 || strings.HasSuffix(out.MD5, ".dir") {
+		return p
+	}
+	cache, ok := findUp(filepath.Dir(path), filepath.Join(".dvc", "cache"))
+	if !ok {
+		return p
+	}
+	p.content = existing(filepath.Join(cache, "files", "md5", out.MD5[:2], out.MD5[2:]))
+	if p.content == "" {
+		p.content = existing(filepath.Join(cache, out.MD5[:2], out.MD5[2:]))
+	}
+	return p
+}
+
+// annexSize is the size field of a git-annex key, e.g. SHA256E-s1234--....
+var annexSize = regexp.MustCompile(`-s(\d+)-`)
+
+// resolveAnnex reads the size from an annex key. A placeholder's object is
+// by definition missing, so there is no local content to find.
+func resolveAnnex(path string) pointer {
+	var p pointer
+	target, err := os.Readlink(path)
+	if err != nil {
+		return p
+	}
+	if m := annexSize.FindStringSubmatch(filepath.Base(target)); m != nil {
+		p.size, _ = strconv.ParseInt(m[1], 10, 64)
+	}
+	return p
+}
+
+// findGitDir finds the git directory of the repository containing dir,
+// following the "gitdir:" file used by worktrees and submodules.
+func findGitDir(dir string) (string, bool) {
+	dotGit, ok := findUp(dir, ".git")
+	if !ok {
+		return "", false
+	}
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return "", false
+	}
+	if info.IsDir() {
+		return dotGit, true
+	}
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return "", false
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return "", false
+	}
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(dotGit), gitDir)
+	}
+	return gitDir, true
+}
+
+// findUp returns the first dir/name, walking up from dir, that exists.
+func findUp(dir, name string) (string, bool) {
+	for {
+		candidate := filepath.Join(dir, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+func existing(path string) string {
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// bytesPerSecond is the combined size over duration of the probed files,
+// used to turn unresolved placeholders' recorded sizes into hours.
+func bytesPerSecond(results []result) float64 {
+	var bytes int64
+	var seconds float64
+	for _, res := range results {
+		if res.err == nil && res.duration > 0 {
+			bytes += res.size
+			seconds += res.duration
+		}
+	}
+	if seconds == 0 {
+		return 0
+	}
+	return float64(bytes) / seconds
+}
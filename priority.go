@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readDirList reads a --priority-dirs or --allowed-roots file: one
+// directory per line, in order. Blank lines and lines starting with # are
+// ignored.
+func readDirList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var dirs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		dirs = append(dirs, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("%s lists no directories", path)
+	}
+	return dirs, nil
+}
+
+// underDir reports whether path lies in dir, both relative to root with
+// forward slashes.
+func underDir(root, path, dir string) bool {
+	rel := nfc(filepath.ToSlash(relPath(root, path)))
+	return dir == "." || strings.HasPrefix(rel, dir+"/")
+}
+
+// priorityDir makes a --priority-dirs entry relative to root, as in the
+// --expect-counts contract.
+func priorityDir(root, dir string) string {
+	if filepath.IsAbs(dir) {
+		if rel, err := filepath.Rel(root, dir); err == nil {
+			dir = rel
+		}
+	}
+	return expectedDir(filepath.ToSlash(dir))
+}
+
+// processPrioritized probes the files under each --priority-dirs entry in
+// turn, printing the directory's subtotal as soon as its files are done,
+// then the rest of the tree. Results come back in input order, as from
+// processFiles.
+func processPrioritized(root string, audioFiles []string, sizes map[string]int64, dirs []string, opts options) []result {
+	results := make([]result, len(audioFiles))
+	done := make([]bool, len(audioFiles))
+	probe := func(pick func(i int) bool) {
+		var idx []int
+		var paths []string
+		for i, p := range audioFiles {
+			if !done[i] && pick(i) {
+				idx = append(idx, i)
+				paths = append(paths, p)
+			}
+		}
+		if len(paths) == 0 {
+			return
+		}
+		for j, res := range processFiles(paths, sizes, opts) {
+			res.index = idx[j]
+			results[idx[j]] = res
+			done[idx[j]] = true
+		}
+	}
+	for _, entry := range dirs {
+		dir := priorityDir(root, entry)
+		probe(func(i int) bool { return underDir(root, audioFiles[i], dir) })
+		var files, failed int
+		var seconds float64
+		for i, p := range audioFiles {
+			if !underDir(root, p, dir) {
+				continue
+			}
+			files++
+			if results[i].err != nil {
+				failed++
+			} else if results[i].duration > 0 {
+				seconds += results[i].duration
+			}
+		}
+		if files == 0 {
+			printf("Priority %s: no audio files\n", entry)
+			continue
+		}
+		printf("Priority %s: %d files, %s (%d errors)\n", entry, files, opts.numbers.duration(seconds, 2), failed)
+	}
+	probe(func(int) bool { return true })
+	return results
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// lowerPriority renices the process to the lowest CPU priority. Linux
+// applies PRIO_PROCESS to a single thread, so every thread in
+// /proc/self/task is reniced, repeating until a pass finds none the
+// runtime started meanwhile; threads started later inherit the priority
+// of the thread that creates them.
+func lowerPriority() error {
+	reniced := make(map[int]bool)
+	for {
+		tasks, err := os.ReadDir("/proc/self/task")
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, task := range tasks {
+			tid, err := strconv.Atoi(task.Name())
+			if err != nil || reniced[tid] {
+				continue
+			}
+			// A thread that exits before it is reniced needs nothing.
+			if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, 19); err != nil && err != syscall.ESRCH {
+				return err
+			}
+			reniced[tid], found = true, true
+		}
+		if !found {
+			return nil
+		}
+	}
+}
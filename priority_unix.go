@@ -0,0 +1,11 @@
+//go:build unix && !linux
+
+package main
+
+import "syscall"
+
+// lowerPriority renices the process to the lowest CPU priority. Outside
+// Linux, PRIO_PROCESS covers every thread.
+func lowerPriority() error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, 0, 19)
+}
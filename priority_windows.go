@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+import "golang.org/x/sys/windows"
+
+// lowerPriority moves the process to the idle priority class.
+func lowerPriority() error {
+	return windows.SetPriorityClass(windows.CurrentProcess(), windows.IDLE_PRIORITY_CLASS)
+}
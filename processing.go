@@ -0,0 +1,143 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// processingPlan is --estimate-processing: how fast a transcription (or
+// any per-file) job runs and on how many devices.
+type processingPlan struct {
+	rtf     float64 // processing seconds per audio second on one device
+	gpus    int
+	perFile float64 // fixed seconds per file: loading, decoding, writing
+	cost    float64 // price per device-hour; 0 omits the cost line
+}
+
+// parsePlan reads "rtf=0.15,gpus=4[,per_file=0.5][,cost=2.5]".
+func parsePlan(v string) (processingPlan, error) {
+	plan := processingPlan{rtf: -1, gpus: 1}
+	for _, part := range strings.Split(v, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return plan, fmt.Errorf("invalid --estimate-processing %q (want rtf=R[,gpus=N][,per_file=S][,cost=C])", v)
+		}
+		var err error
+		switch key {
+		case "rtf":
+			plan.rtf, err = strconv.ParseFloat(value, 64)
+			if err == nil && plan.rtf <= 0 {
+				err = fmt.Errorf("rtf must be positive")
+			}
+		case "gpus":
+			plan.gpus, err = strconv.Atoi(value)
+			if err == nil && plan.gpus < 1 {
+				err = fmt.Errorf("gpus must be at least 1")
+			}
+		case "per_file":
+			plan.perFile, err = strconv.ParseFloat(value, 64)
+			if err == nil && plan.perFile < 0 {
+				err = fmt.Errorf("per_file must not be negative")
+			}
+		case "cost":
+			plan.cost, err = strconv.ParseFloat(value, 64)
+			if err == nil && plan.cost < 0 {
+				err = fmt.Errorf("cost must not be negative")
+			}
+		default:
+			err = fmt.Errorf("unknown key %q", key)
+		}
+		if err != nil {
+			return plan, fmt.Errorf("invalid --estimate-processing %q: %v", v, err)
+		}
+	}
+	if plan.rtf < 0 {
+		return plan, fmt.Errorf("invalid --estimate-processing %q: rtf is required", v)
+	}
+	return plan, nil
+}
+
+type processingReport struct {
+	plan        processingPlan
+	files       int
+	audio       float64 // seconds
+	deviceTime  float64 // seconds of processing summed over devices
+	wall        float64 // seconds until the last device finishes
+	ideal       float64 // deviceTime / gpus, with perfect balancing
+	longestFile float64
+}
+
+// deviceLoads is a min-heap of the work queued on each device.
+type deviceLoads []float64
+
+func (d deviceLoads) Len() int           { return len(d) }
+func (d deviceLoads) Less(i, j int) bool { return d[i] < d[j] }
+func (d deviceLoads) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d *deviceLoads) Push(x any)        { *d = append(*d, x.(float64)) }
+func (d *deviceLoads) Pop() any {
+	old := *d
+	x := old[len(old)-1]
+	*d = old[:len(old)-1]
+	return x
+}
+
+// buildProcessingReport schedules the measured files longest first, each
+// on the least loaded device, as a batch job's work queue would. The wall
+// time is when the last device finishes; with few long files it is set by
+// the longest file rather than the total divided by the devices.
+func buildProcessingReport(durations []float64, plan processingPlan) processingReport {
+	report := processingReport{plan: plan}
+	var jobs []float64
+	for _, d := range durations {
+		if d <= 0 {
+			continue
+		}
+		job := d*plan.rtf + plan.perFile
+		jobs = append(jobs, job)
+		report.files++
+		report.audio += d
+		report.deviceTime += job
+		report.longestFile = max(report.longestFile, job)
+	}
+	report.ideal = report.deviceTime / float64(plan.gpus)
+	if len(jobs) == 0 {
+		return report
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(jobs)))
+	loads := make(deviceLoads, min(plan.gpus, len(jobs)))
+	for _, job := range jobs {
+		loads[0] += job
+		heap.Fix(&loads, 0)
+	}
+	for _, l := range loads {
+		report.wall = max(report.wall, l)
+	}
+	return report
+}
+
+func printProcessingReport(report processingReport) {
+	plan := report.plan
+	fmt.Fprintln(console, "\n=== Processing estimate ===")
+	if report.files == 0 {
+		fmt.Fprintln(console, "No audio with a known duration.")
+		return
+	}
+	fmt.Fprintf(console, "Audio: %.2f hours in %d files\n", report.audio/3600, report.files)
+	fmt.Fprintf(console, "Real-time factor %g on %d device(s)", plan.rtf, plan.gpus)
+	if plan.perFile > 0 {
+		fmt.Fprintf(console, ", %gs per file", plan.perFile)
+	}
+	fmt.Fprintln(console)
+	fmt.Fprintf(console, "Device time: %.2f hours\n", report.deviceTime/3600)
+	fmt.Fprintf(console, "Wall time: %s (%.2f hours)\n", formatClock(report.wall), report.wall/3600)
+	if report.longestFile > report.ideal {
+		fmt.Fprintf(console, "The longest file alone takes %s, more than a perfectly balanced %s; more devices will not help\n", formatClock(report.longestFile), formatClock(report.ideal))
+	}
+	if plan.cost > 0 {
+		// Devices are billed until the job ends, idle or not.
+		fmt.Fprintf(console, "Cost: %.2f (%d device(s) × %.2f hours at %g per hour)\n", float64(plan.gpus)*report.wall/3600*plan.cost, plan.gpus, report.wall/3600, plan.cost)
+	}
+}
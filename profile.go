@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"runtime/trace"
+)
+
+// startProfiling serves net/http/pprof on --pprof and records an execution
+// trace to --trace. The returned function flushes the trace.
+func startProfiling(opts options) (stop func(), err error) {
+	if opts.pprof != "" {
+		addr := pprofAddr(opts.pprof)
+		go func() {
+			if err := http.ListenAndServe(addr, nil); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: pprof server stopped: %v\n", err)
+			}
+		}()
+		fmt.Fprintf(os.Stderr, "pprof listening on http://%s/debug/pprof/\n", addr)
+		if host, _, _ := net.SplitHostPort(addr); host != "localhost" {
+			if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+				fmt.Fprintln(os.Stderr, "Warning: any machine that can reach this address can read /debug/pprof/cmdline, credentials in the arguments included")
+			}
+		}
+	}
+	if opts.trace == "" {
+		return func() {}, nil
+	}
+	file, err := os.Create(opts.trace)
+	if err != nil {
+		return nil, err
+	}
+	if err := trace.Start(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		trace.Stop()
+		file.Close()
+	}, nil
+}
+
+// pprofAddr binds a --pprof address without a host, such as :6060, to the
+// loopback interface: /debug/pprof/cmdline serves the arguments, and with
+// them any credentials in --mqtt or queue URLs.
+func pprofAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" {
+		return addr
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
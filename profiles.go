@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/tcolgate/mp3"
+)
+
+// maxSampleDescription bounds the stsd payload read for the codec profile.
+const maxSampleDescription = 4096
+
+// profileFrames is how many MP3 frames are compared to tell CBR from VBR
+// when the file carries no Xing, Info or VBRI header.
+const profileFrames = 100
+
+// mpeg4AudioObjects names the MPEG-4 audio object types seen in practice.
+var mpeg4AudioObjects = map[int]string{
+	1:  "AAC Main",
+	2:  "AAC-LC",
+	3:  "AAC SSR",
+	4:  "AAC LTP",
+	5:  "HE-AAC",
+	23: "AAC-LD",
+	29: "HE-AACv2",
+	39: "AAC-ELD",
+	42: "xHE-AAC",
+}
+
+// mp4SampleEntries names the non-AAC sample entry types of audio tracks.
+var mp4SampleEntries = map[string]string{
+	"alac": "ALAC",
+	"ac-3": "AC-3",
+	"ec-3": "E-AC-3",
+	"Opus": "Opus",
+	"fLaC": "FLAC",
+	".mp3": "MP3",
+	"lpcm": "PCM",
+	"sowt": "PCM",
+	"twos": "PCM",
+}
+
+// parseSampleDescription reads the first sample entry of an stsd payload
+// and, for mp4a entries, the audio object type from its esds box.
+func parseSampleDescription(p []byte) (string, int) {
+	if len(p) < 16 {
+		return "", 0
+	}
+	entry := p[8:]
+	size := int(binary.BigEndian.Uint32(entry[0:4]))
+	if size < 8 || size > len(entry) {
+		size = len(entry)
+	}
+	typ := string(entry[4:8])
+	if typ != "mp4a" {
+		return typ, 0
+	}
+	at := bytes.Index(entry[8:size], []byte("esds"))
+	if at < 0 {
+		return typ, 0
+	}
+	return typ, esdsObjectType(entry[8+at+4 : size])
+}
+
+// esdsObjectType follows an esds payload's ES, DecoderConfig and
+// DecoderSpecificInfo descriptors to the AudioSpecificConfig, whose leading
+// bits hold the object type. HE-AAC signalled only implicitly, as an AAC-LC
+// object type with SBR found by the decoder, reads as AAC-LC.
+func esdsObjectType(p []byte) int {
+	if len(p) < 4 {
+		return 0
+	}
+	p = p[4:] // version and flags
+	for len(p) >= 2 {
+		tag := p[0]
+		n, body, ok := descriptor(p[1:])
+		if !ok {
+			return 0
+		}
+		switch tag {
+		case 0x03: // ES_Descriptor: ES_ID, flags, then optional fields
+			if len(body) < 3 {
+				return 0
+			}
+			flags := body[2]
+			skip := 3
+			if flags&0x80 != 0 {
+				skip += 2
+			}
+			if flags&0x40 != 0 && len(body) > skip {
+				skip += 1 + int(body[skip])
+			}
+			if flags&0x20 != 0 {
+				skip += 2
+			}
+			if skip > len(body) {
+				return 0
+			}
+			p = body[skip:]
+			continue
+		case 0x04: // DecoderConfigDescriptor: 13 fixed bytes, then DSI
+			if len(body) < 13 {
+				return 0
+			}
+			if body[0] == 0x69 || body[0] == 0x6B {
+				return 0 // MPEG-1/2 audio in an mp4a entry
+			}
+			p = body[13:]
+			continue
+		case 0x05: // DecoderSpecificInfo: AudioSpecificConfig
+			if len(body) < 1 {
+				return 0
+			}
+			aot := int(body[0] >> 3)
+			if aot == 31 && len(body) >= 2 {
+				aot = 32 + int(body[0]&0x07)<<3 | int(body[1]>>5)
+			}
+			return aot
+		}
+		p = p[1+n:]
+	}
+	return 0
+}
+
+// descriptor splits an MPEG-4 descriptor after its tag: a length of up to
+// four 7-bit groups, then the body. n is the bytes it takes after the tag.
+func descriptor(p []byte) (n int, body []byte, ok bool) {
+	var length, i int
+	for i = 0; i < 4 && i < len(p); i++ {
+		length = length<<7 | int(p[i]&0x7f)
+		if p[i]&0x80 == 0 {
+			break
+		}
+	}
+	i++
+	if i > len(p) || i+length > len(p) {
+		return 0, nil, false
+	}
+	return i + length, p[i : i+length], true
+}
+
+// readCodecProfile names a file's codec profile at the level a
+// re-encoding plan needs: MP3 CBR with its bitrate or VBR/ABR, the AAC
+// object type, PCM with its bit depth, or the codec.
+func readCodecProfile(filePath string) string {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	file, err := openAudio(filePath)
+	if err != nil {
+		return "unknown"
+	}
+	defer file.Close()
+
+	var profile string
+	switch ext {
+	case ".mp3":
+		profile = mp3Profile(file)
+	case ".wav":
+		profile = wavProfile(file)
+	case ".m4a", ".m4b", ".aax":
+		profile = mp4Profile(file, ext)
+	case ".ogg":
+		if o, err := parseOgg(file); err == nil {
+			profile = oggProfile(o)
+		}
+	case ".mod", ".xm", ".s3m", ".it":
+		profile = "Tracker " + strings.ToUpper(ext[1:])
+	}
+	if profile == "" {
+		return "unknown"
+	}
+	return profile
+}
+
+// mp3Profile reads the encoder's own CBR/VBR/ABR claim from a LAME or
+// Xing/Info header in the first frame, or else compares the bitrates of
+// the first profileFrames frames.
+func mp3Profile(file *audioFile) string {
+	// Start past a leading ID3v2 tag, whose bytes can pass for frames.
+	var hdr [10]byte
+	if _, err := file.ReadAt(hdr[:], 0); err == nil && string(hdr[0:3]) == "ID3" {
+		size := 10 + syncsafe(hdr[6:10])
+		if hdr[5]&0x10 != 0 {
+			size += 10 // footer
+		}
+		if _, err := file.Seek(size, io.SeekStart); err != nil {
+			return ""
+		}
+	}
+	decoder := mp3.NewDecoder(file)
+	var frame mp3.Frame
+	var skipped int
+	var layer mp3.FrameLayer
+	var rates []int
+	for n := 0; n < profileFrames; n++ {
+		if err := decoder.Decode(&frame, &skipped); err != nil {
+			break
+		}
+		if n == 0 {
+			layer = frame.Header().Layer()
+			if method, ok := xingMethod(&frame); ok {
+				// The header frame carries no audio; a CBR file's
+				// bitrate comes from the frames after it.
+				if method == "VBR" || method == "ABR" {
+					return mpegName(layer) + " " + method
+				}
+				continue
+			}
+		}
+		rates = append(rates, int(frame.Header().BitRate())/1000)
+	}
+	if len(rates) == 0 {
+		return ""
+	}
+	for _, r := range rates {
+		if r != rates[0] {
+			return mpegName(layer) + " VBR"
+		}
+	}
+	return fmt.Sprintf("%s CBR %d kbps", mpegName(layer), rates[0])
+}
+
+func mpegName(layer mp3.FrameLayer) string {
+	switch layer {
+	case mp3.Layer1:
+		return "MP1"
+	case mp3.Layer2:
+		return "MP2"
+	}
+	return "MP3"
+}
+
+// xingMethod reads the encoding method of a first frame that is a Xing,
+// Info or VBRI header frame. ok is false for an audio frame. The method is
+// the LAME tag's when present, else VBR for Xing and VBRI headers and CBR
+// for Info.
+func xingMethod(frame *mp3.Frame) (method string, ok bool) {
+	buf, err := io.ReadAll(frame.Reader())
+	if err != nil {
+		return "", false
+	}
+	if bytes.Contains(buf, []byte("VBRI")) {
+		return "VBR", true
+	}
+	for _, tag := range []string{"Xing", "Info"} {
+		i := bytes.Index(buf, []byte(tag))
+		if i < 0 || i+8 > len(buf) {
+			continue
+		}
+		method = "VBR"
+		if tag == "Info" {
+			method = "CBR"
+		}
+		flags := binary.BigEndian.Uint32(buf[i+4:])
+		lame := i + 8
+		for _, f := range []struct {
+			bit  uint32
+			size int
+		}{{0x1, 4}, {0x2, 4}, {0x4, 100}, {0x8, 4}} {
+			if flags&f.bit != 0 {
+				lame += f.size
+			}
+		}
+		if lame+10 <= len(buf) && (bytes.HasPrefix(buf[lame:], []byte("LAME")) || bytes.HasPrefix(buf[lame:], []byte("Lavc")) || bytes.HasPrefix(buf[lame:], []byte("Lavf"))) {
+			switch buf[lame+9] & 0x0f {
+			case 1, 8:
+				method = "CBR"
+			case 2, 9:
+				method = "ABR"
+			case 3, 4, 5, 6:
+				method = "VBR"
+			}
+		}
+		return method, true
+	}
+	return "", false
+}
+
+// WAVE_FORMAT_EXTENSIBLE sub-format GUIDs start with the format tag they
+// stand for.
+const wavExtensibleSubFormat = 24
+
+func wavProfile(file *audioFile) string {
+	chunks, err := scanRIFFChunks(file, true)
+	if err != nil {
+		return ""
+	}
+	f, err := readWAVFormat(file, chunks)
+	if err != nil {
+		return ""
+	}
+	tag := f.tag
+	if tag == wavFormatExtensible {
+		for _, c := range chunks {
+			var sub [2]byte
+			if c.id == "fmt " && c.size >= wavExtensibleSubFormat+2 {
+				if _, err := file.ReadAt(sub[:], c.offset+wavExtensibleSubFormat); err == nil {
+					tag = binary.LittleEndian.Uint16(sub[:])
+				}
+				break
+			}
+		}
+	}
+	switch tag {
+	case wavFormatPCM:
+		return fmt.Sprintf("PCM %d-bit", f.bits)
+	case wavFormatFloat:
+		return fmt.Sprintf("PCM float %d-bit", f.bits)
+	case wavFormatALaw:
+		return "A-law"
+	case wavFormatMuLaw:
+		return "µ-law"
+	case 0x0002, 0x0011:
+		return "ADPCM"
+	case 0x0055:
+		return "MP3 in WAV"
+	}
+	return fmt.Sprintf("WAV format 0x%04X", tag)
+}
+
+func mp4Profile(file *audioFile, ext string) string {
+	info, err := parseMP4(file)
+	if err != nil {
+		return ""
+	}
+	for _, t := range info.tracks {
+		if t.handler != "soun" {
+			continue
+		}
+		name := mp4SampleEntries[t.sampleEntry]
+		switch {
+		case t.sampleEntry == "mp4a" && mpeg4AudioObjects[t.objectType] != "":
+			name = mpeg4AudioObjects[t.objectType]
+		case t.sampleEntry == "mp4a" && t.objectType != 0:
+			name = fmt.Sprintf("MPEG-4 audio object %d", t.objectType)
+		case t.sampleEntry == "mp4a":
+			name = "AAC"
+		case name == "":
+			name = t.sampleEntry
+		}
+		switch {
+		case ext == ".aax" && name != "":
+			return "AAX (" + name + ")"
+		case ext == ".aax":
+			return "AAX"
+		case name == "":
+			return "MP4 audio"
+		}
+		return name
+	}
+	return ""
+}
+
+var oggCodecNames = map[string]string{
+	"vorbis": "Vorbis",
+	"opus":   "Opus",
+	"flac":   "FLAC in Ogg",
+	"speex":  "Speex",
+}
+
+// oggProfile names the codec of the first audio stream.
+func oggProfile(o *oggFile) string {
+	for _, c := range o.chains {
+		for _, serial := range c.order {
+			if s := c.streams[serial]; s.rate > 0 {
+				return oggCodecNames[s.codec]
+			}
+		}
+	}
+	return ""
+}
+
+// printProfileReport breaks the hours down by codec profile.
+func printProfileReport(audioFiles []string, durations []float64, profiles []string) {
+	index := make(map[string]string, len(audioFiles))
+	for i, p := range audioFiles {
+		index[p] = profiles[i]
+	}
+	groups := groupDurations(audioFiles, durations, func(path string) (string, bool) {
+		return index[path], index[path] != ""
+	})
+	sortGroupsBySeconds(groups)
+
+	fmt.Fprintln(console, "\n=== Codec profiles ===")
+	if len(groups) == 0 {
+		fmt.Fprintln(console, "No codec profiles read.")
+		return
+	}
+	var total float64
+	for _, g := range groups {
+		total += g.seconds
+	}
+	fmt.Fprintf(console, "%-30s %8s %10s %8s\n", "Profile", "Files", "Hours", "Share")
+	for _, g := range groups {
+		fmt.Fprintf(console, "%-30s %8d %10.2f %7.1f%%\n", g.key, g.files, g.seconds/3600, percent(g.seconds, total))
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --progress styles.
+const (
+	progressBar   = "bar"   // the redrawn ANSI bar
+	progressPlain = "plain" // one timestamped line every --progress-every
+	progressNone  = "none"  // nothing
+)
+
+// progressStyle and progressEvery are --progress and --progress-every.
+var (
+	progressStyle = progressBar
+	progressEvery = "10%"
+)
+
+// progressMeter is what probing reports finished files to.
+type progressMeter interface {
+	Add(n int) error
+	Finish() error
+}
+
+func checkProgress(style, every string) error {
+	switch style {
+	case progressBar, progressPlain, progressNone:
+	default:
+		return fmt.Errorf("unsupported --progress style: %s (want bar, plain or none)", style)
+	}
+	if _, err := progressStep(every, 1); err != nil {
+		return err
+	}
+	return nil
+}
+
+// progressStep is the number of files between plain progress lines: a
+// count such as 500, or a share of total such as 5%.
+func progressStep(every string, total int) (int, error) {
+	if pct, ok := strings.CutSuffix(every, "%"); ok {
+		p, err := strconv.ParseFloat(pct, 64)
+		if err != nil || p <= 0 || p > 100 {
+			return 0, fmt.Errorf("invalid --progress-every: %s (want a file count or a percentage up to 100%%)", every)
+		}
+		return max(int(float64(total)*p/100), 1), nil
+	}
+	n, err := strconv.Atoi(every)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid --progress-every: %s (want a file count or a percentage up to 100%%)", every)
+	}
+	return n, nil
+}
+
+// plainProgress prints a line each time another step of files finishes,
+// so logs captured from CI or nohup stay readable.
+type plainProgress struct {
+	mu      sync.Mutex
+	w       io.Writer
+	total   int
+	done    int
+	step    int
+	printed int
+	start   time.Time
+}
+
+func newPlainProgress(w io.Writer, total int) *plainProgress {
+	step, _ := progressStep(progressEvery, total)
+	return &plainProgress{w: w, total: total, step: step, start: time.Now()}
+}
+
+func (p *plainProgress) Add(n int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += n
+	if p.done/p.step > p.printed/p.step {
+		p.line()
+	}
+	return nil
+}
+
+func (p *plainProgress) Finish() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.printed != p.done || p.done == 0 {
+		p.line()
+	}
+	return nil
+}
+
+func (p *plainProgress) line() {
+	p.printed = p.done
+	now := time.Now()
+	elapsed := now.Sub(p.start)
+	var pct, rate float64
+	if p.total > 0 {
+		pct = 100 * float64(p.done) / float64(p.total)
+	}
+	if elapsed > 0 {
+		rate = float64(p.done) / elapsed.Seconds()
+	}
+	fmt.Fprint(p.w, tr("%s processed %d/%d files (%.1f%%), %s elapsed, %.1f files/s\n",
+		now.UTC().Format(time.RFC3339), p.done, p.total, pct, elapsed.Round(100*time.Millisecond), rate))
+}
+
+type noProgress struct{}
+
+func (noProgress) Add(int) error { return nil }
+func (noProgress) Finish() error { return nil }
+
+// newProgress makes the --progress meter for probing files. Plain progress
+// lines go to stderr so they never mix into the report or a summary on
+// stdout.
+func newProgress(files int) progressMeter {
+	switch progressStyle {
+	case progressPlain:
+		return newPlainProgress(os.Stderr, files)
+	case progressNone:
+		return noProgress{}
+	}
+	return newProgressBar(files)
+}
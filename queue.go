@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Queue integration: --enqueue pushes the walked file list to a Redis list
+// or NATS subject, --queue-jobs turns the process into a worker that probes
+// paths taken from one, and --queue-results publishes every file's result
+// as a JSON message, so scans slot into ingest pipelines that already hand
+// out work through a queue. Job messages are paths relative to
+// <folder_path> (absolute paths are used as they are), either bare or as
+// {"path": "..."}; result messages are the per-file objects of
+// --format json.
+
+// messageQueue is the part of Redis and NATS the integration needs. A
+// queue is not safe for concurrent use.
+type messageQueue interface {
+	push(msg []byte) error
+	pull(timeout time.Duration) (msg []byte, ok bool, err error)
+	// flush returns once the server has every message pushed so far.
+	flush() error
+	Close() error
+}
+
+// dialQueue connects to a redis:// or nats:// URL whose path names the
+// list or subject.
+func dialQueue(rawURL string) (messageQueue, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "redis":
+		return dialRedis(u)
+	case "nats":
+		return dialNATS(u)
+	}
+	return nil, fmt.Errorf("unsupported queue URL %q: use redis://host/<key> or nats://host/<subject>", rawURL)
+}
+
+// checkQueueURL validates a queue flag without connecting.
+func checkQueueURL(rawURL string) error {
+	if rawURL == "" {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "redis" && u.Scheme != "nats") || strings.Trim(u.Path, "/") == "" {
+		return fmt.Errorf("unsupported queue URL %q: use redis://host/<key> or nats://host/<subject>", rawURL)
+	}
+	return nil
+}
+
+// enqueueFiles pushes one job per audio file, relative to root.
+func enqueueFiles(rawURL, root string, audioFiles []string) error {
+	q, err := dialQueue(rawURL)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+	for _, path := range audioFiles {
+		if err := q.push([]byte(filepath.ToSlash(relPath(root, path)))); err != nil {
+			return err
+		}
+	}
+	return q.flush()
+}
+
+// maxQueueMessage bounds what a reply or message from a queue server may
+// make us allocate. Jobs are paths and results one file's JSON.
+const maxQueueMessage = 1 << 20
+
+// jobPath decodes a job message into a path. Relative paths resolve
+// against root; the result must lie under root, or under --allowed-roots
+// when given, so whoever can push jobs cannot have any file probed.
+func jobPath(root string, msg []byte, allowed []string) (string, error) {
+	path := strings.TrimSpace(string(msg))
+	if strings.HasPrefix(path, "{") {
+		var job struct {
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal(msg, &job); err != nil {
+			return "", err
+		}
+		path = job.Path
+	}
+	if path == "" {
+		return "", fmt.Errorf("empty job")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, filepath.FromSlash(path))
+	}
+	if len(allowed) > 0 {
+		if !pathAllowed(path, allowed) {
+			return "", fmt.Errorf("%s: %w", path, errNotAllowed)
+		}
+	} else if !within(realPath(path), realPath(root)) {
+		return "", fmt.Errorf("%s: outside %s", path, root)
+	}
+	return path, nil
+}
+
+// resultPublisher sends per-file results to --queue-results from any
+// worker goroutine.
+type resultPublisher struct {
+	mu   sync.Mutex
+	q    messageQueue
+	root string
+	err  error
+}
+
+func newResultPublisher(rawURL, root string) (*resultPublisher, error) {
+	q, err := dialQueue(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &resultPublisher{q: q, root: root}, nil
+}
+
+// publish sends one result; the first failure is kept and later results
+// are dropped, so a dead queue does not fail the scan itself.
+func (p *resultPublisher) publish(path string, res result, details bool) {
+	msg, err := json.Marshal(newFileSummary(p.root, path, res, details))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return
+	}
+	if err == nil {
+		err = p.q.push(msg)
+	}
+	p.err = err
+}
+
+// Close flushes and closes the queue, returning the first publish error.
+func (p *resultPublisher) Close() error {
+	if p.err == nil {
+		p.err = p.q.flush()
+	}
+	p.q.Close()
+	return p.err
+}
+
+// publishResults sends the results of a finished scan to --queue-results.
+func publishResults(rawURL, root string, audioFiles []string, results []result, details bool) error {
+	p, err := newResultPublisher(rawURL, root)
+	if err != nil {
+		return err
+	}
+	for i, res := range results {
+		p.publish(audioFiles[i], res, details)
+	}
+	return p.Close()
+}
+
+// runQueueWorker probes jobs from --queue-jobs until none arrives for
+// --queue-idle, publishing each result to --queue-results.
+func runQueueWorker(root string, opts options) error {
+	jobs, err := dialQueue(opts.queueJobs)
+	if err != nil {
+		return err
+	}
+	defer jobs.Close()
+	var results *resultPublisher
+	if opts.queueResults != "" {
+		if results, err = newResultPublisher(opts.queueResults, root); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(console, "Waiting for jobs on %s\n", redactURL(opts.queueJobs))
+
+	work := make(chan fileJob, numWorkers)
+	var wg sync.WaitGroup
+	var failed, probed int
+	var mu sync.Mutex
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range work {
+				res := processFile(job, opts)
+				if results != nil {
+					results.publish(job.path, res, opts.jsonFiles)
+				}
+				mu.Lock()
+				probed++
+				if res.err != nil {
+					failed++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var pullErr error
+	for n := 0; ; n++ {
+		msg, ok, err := jobs.pull(opts.queueIdle)
+		if err != nil {
+			pullErr = err
+			break
+		}
+		if !ok {
+			break
+		}
+		path, err := jobPath(root, msg, opts.allowedRoots)
+		if err != nil {
+			fmt.Fprintf(console, "Warning: skipping job %q: %v\n", msg, err)
+			continue
+		}
+		work <- fileJob{path: path, index: n}
+	}
+	close(work)
+	wg.Wait()
+
+	if pullErr == nil {
+		fmt.Fprintf(console, "Queue idle for %s; %d files probed here (%d errors).\n", opts.queueIdle, probed, failed)
+	}
+	if results != nil {
+		if err := results.Close(); err != nil {
+			return err
+		}
+	}
+	return pullErr
+}
+
+// redactURL hides queue passwords and NATS tokens in messages.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if _, ok := u.User.Password(); u.User != nil && !ok {
+		u.User = url.User("xxxxx")
+	}
+	return u.Redacted()
+}
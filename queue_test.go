@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJobPath(t *testing.T) {
+	root := t.TempDir()
+	other := t.TempDir()
+	for _, dir := range []string{filepath.Join(root, "show"), filepath.Join(other, "show")} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name    string
+		msg     string
+		allowed []string
+		want    string
+		err     bool
+	}{
+		{name: "relative", msg: "show/a.mp3", want: filepath.Join(root, "show", "a.mp3")},
+		{name: "json", msg: `{"path": "show/a.mp3"}`, want: filepath.Join(root, "show", "a.mp3")},
+		{name: "absolute under root", msg: filepath.Join(root, "show", "a.mp3"), want: filepath.Join(root, "show", "a.mp3")},
+		{name: "absolute elsewhere", msg: filepath.Join(other, "show", "a.mp3"), err: true},
+		{name: "parent", msg: "../show/a.mp3", err: true},
+		{name: "allowed elsewhere", msg: filepath.Join(other, "show", "a.mp3"), allowed: []string{realPath(other)}, want: filepath.Join(other, "show", "a.mp3")},
+		{name: "empty", msg: " ", err: true},
+	}
+	for _, tt := range tests {
+		got, err := jobPath(root, []byte(tt.msg), tt.allowed)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: jobPath(%q) = %q; want an error", tt.name, tt.msg, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: jobPath(%q) = %q, %v; want %q", tt.name, tt.msg, got, err, tt.want)
+		}
+	}
+	if _, err := jobPath(root, []byte(filepath.Join(other, "a.mp3")), []string{realPath(root)}); !errors.Is(err, errNotAllowed) {
+		t.Errorf("jobPath outside --allowed-roots: %v; want errNotAllowed", err)
+	}
+}
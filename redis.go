@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisQueue is a Redis list used as a work queue: producers RPUSH and
+// workers BLPOP, so each message goes to exactly one worker and messages
+// wait in Redis until someone takes them. It speaks just enough RESP for
+// that and for AUTH.
+type redisQueue struct {
+	conn net.Conn
+	r    *bufio.Reader
+	key  string
+}
+
+// dialRedis connects to redis://[user:password@]host[:port]/<list key>.
+func dialRedis(u *url.URL) (*redisQueue, error) {
+	key := strings.TrimPrefix(u.Path, "/")
+	if key == "" {
+		return nil, fmt.Errorf("%s: missing list key (redis://host:port/<key>)", u.Redacted())
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	conn, err := net.DialTimeout("tcp", host, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	q := &redisQueue{conn: conn, r: bufio.NewReader(conn), key: key}
+	if pass, ok := u.User.Password(); ok {
+		args := []string{"AUTH", pass}
+		if name := u.User.Username(); name != "" {
+			args = []string{"AUTH", name, pass}
+		}
+		if _, err := q.do(args...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+func (q *redisQueue) push(msg []byte) error {
+	_, err := q.do("RPUSH", q.key, string(msg))
+	return err
+}
+
+// pull waits up to timeout for the next message on the list.
+func (q *redisQueue) pull(timeout time.Duration) ([]byte, bool, error) {
+	// Whole seconds: Redis before 6.0 rejects fractional timeouts, and 0
+	// would block forever.
+	secs := strconv.Itoa(max(int(math.Ceil(timeout.Seconds())), 1))
+	reply, err := q.do("BLPOP", q.key, secs)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	items, ok := reply.([]any)
+	if !ok || len(items) != 2 {
+		return nil, false, errors.New("redis: unexpected BLPOP reply")
+	}
+	msg, _ := items[1].(string)
+	return []byte(msg), true, nil
+}
+
+// flush has nothing to wait for: every RPUSH already waited for its reply.
+func (q *redisQueue) flush() error {
+	return nil
+}
+
+func (q *redisQueue) Close() error {
+	return q.conn.Close()
+}
+
+// do sends one command and reads its reply.
+func (q *redisQueue) do(args ...string) (any, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := q.conn.Write([]byte(b.String())); err != nil {
+		return nil, err
+	}
+	return q.reply()
+}
+
+// reply reads one RESP value: strings, integers, arrays or nil.
+func (q *redisQueue) reply() (any, error) {
+	line, err := q.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch body := line[1:]; line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, errors.New("redis: " + body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		if n > maxQueueMessage {
+			return nil, fmt.Errorf("redis: %d-byte reply exceeds %d bytes", n, maxQueueMessage)
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(q.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		if n > maxQueueMessage {
+			return nil, fmt.Errorf("redis: %d-item reply exceeds %d items", n, maxQueueMessage)
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = q.reply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// bitrateTarget is --simulate-bitrate [codec:]RATE.
+type bitrateTarget struct {
+	codec string // only a label; the estimate is the same for any codec
+	bps   float64
+}
+
+func (t bitrateTarget) String() string {
+	s := strconv.FormatFloat(t.bps/1000, 'f', -1, 64) + " kbps"
+	if t.codec != "" {
+		s = t.codec + " " + s
+	}
+	return s
+}
+
+// parseBitrateTarget reads 96k, 96kbps, 128000 or opus:96k.
+func parseBitrateTarget(v string) (bitrateTarget, error) {
+	var t bitrateTarget
+	rate := v
+	if codec, r, ok := strings.Cut(v, ":"); ok {
+		t.codec, rate = codec, r
+	}
+	rate = strings.TrimSuffix(strings.ToLower(rate), "bps")
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(rate, "k"):
+		scale, rate = 1e3, strings.TrimSuffix(rate, "k")
+	case strings.HasSuffix(rate, "m"):
+		scale, rate = 1e6, strings.TrimSuffix(rate, "m")
+	}
+	n, err := strconv.ParseFloat(rate, 64)
+	if err != nil || n <= 0 || math.IsNaN(n) || math.IsInf(n*scale, 0) {
+		return t, fmt.Errorf("invalid --simulate-bitrate %q (want e.g. 96k or opus:96k)", v)
+	}
+	t.bps = n * scale
+	return t, nil
+}
+
+type reencodeGroup struct {
+	key       string
+	files     int
+	bytes     int64
+	estimated float64 // bytes after re-encoding
+}
+
+type reencodeReport struct {
+	target   bitrateTarget
+	total    reencodeGroup
+	folders  []reencodeGroup
+	kept     int // already at or below the target, left as they are
+	unknown  int // no duration, so no estimate; counted at their size
+	keptSize int64
+}
+
+// buildReencodeReport estimates each file's size at the target bitrate
+// from its measured duration. Files already smaller than that would gain
+// nothing and keep their size, as do files without a duration. Container
+// overhead is not counted, which for Ogg Opus or MP4 adds about 1-2%.
+func buildReencodeReport(root string, audioFiles []string, durations []float64, results []result, target bitrateTarget) reencodeReport {
+	report := reencodeReport{target: target, total: reencodeGroup{key: "Total"}}
+	index := make(map[string]int)
+	for i, p := range audioFiles {
+		size := results[i].size
+		estimated := float64(size)
+		switch {
+		case durations[i] <= 0:
+			report.unknown++
+		case durations[i]*target.bps/8 >= float64(size):
+			report.kept++
+			report.keptSize += size
+		default:
+			estimated = durations[i] * target.bps / 8
+		}
+		key, ok := topLevelDir(root, p)
+		if !ok {
+			key = "."
+		}
+		n, seen := index[key]
+		if !seen {
+			n = len(report.folders)
+			index[key] = n
+			report.folders = append(report.folders, reencodeGroup{key: key})
+		}
+		for _, g := range []*reencodeGroup{&report.folders[n], &report.total} {
+			g.files++
+			g.bytes += size
+			g.estimated += estimated
+		}
+	}
+	sort.Slice(report.folders, func(i, j int) bool {
+		a, b := report.folders[i].savings(), report.folders[j].savings()
+		if a != b {
+			return a > b
+		}
+		return report.folders[i].key < report.folders[j].key
+	})
+	return report
+}
+
+func (g reencodeGroup) savings() float64 { return float64(g.bytes) - g.estimated }
+
+func printReencodeReport(report reencodeReport) {
+	fmt.Fprintf(console, "\n=== Re-encoded at %s ===\n", report.target)
+	if report.total.files == 0 {
+		fmt.Fprintln(console, "No audio files.")
+		return
+	}
+	line := func(g reencodeGroup) {
+		fmt.Fprintf(console, "%-30s %8d %10.2f %10.2f %10.2f %7.1f%%\n", g.key, g.files, float64(g.bytes)/1e9, g.estimated/1e9, g.savings()/1e9, percent(g.savings(), float64(g.bytes)))
+	}
+	fmt.Fprintf(console, "%-30s %8s %10s %10s %10s %8s\n", "Folder", "Files", "GB now", "GB after", "GB saved", "Saved")
+	for i, g := range report.folders {
+		if i == maxListed {
+			fmt.Fprintf(console, "... and %d more\n", len(report.folders)-maxListed)
+			break
+		}
+		line(g)
+	}
+	line(report.total)
+	if report.kept > 0 {
+		fmt.Fprintf(console, "Already at or below the target, left as they are: %d files (%.2f GB)\n", report.kept, float64(report.keptSize)/1e9)
+	}
+	if report.unknown > 0 {
+		fmt.Fprintf(console, "Files without a duration, counted at their current size: %d\n", report.unknown)
+	}
+	fmt.Fprintln(console, "Estimates cover the audio payload only; container overhead adds about 1-2%.")
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestParseBitrateTarget(t *testing.T) {
+	tests := []struct {
+		in    string
+		codec string
+		bps   float64
+		err   bool
+	}{
+		{in: "96k", bps: 96000},
+		{in: "opus:96kbps", codec: "opus", bps: 96000},
+		{in: "1.5M", bps: 1.5e6},
+		{in: "128000", bps: 128000},
+		{in: "0k", err: true},
+		{in: "-96k", err: true},
+		{in: "fast", err: true},
+		{in: "NaN", err: true},
+		{in: "aac:nank", err: true},
+		{in: "Inf", err: true},
+		{in: "1e308m", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseBitrateTarget(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseBitrateTarget(%q) = %+v; want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got.codec != tt.codec || got.bps != tt.bps {
+			t.Errorf("parseBitrateTarget(%q) = %+v, %v; want %q at %g", tt.in, got, err, tt.codec, tt.bps)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// console receives the human-readable report and progress. It is stdout
+// unless a machine-readable summary claims stdout, when it is stderr.
+var console io.Writer = os.Stdout
+
+// maxListed caps how many paths a report prints before summarising the rest.
+const maxListed = 20
+
+// relPath shortens p for display relative to the scanned root.
+func relPath(root, p string) string {
+	if rel, err := filepath.Rel(root, p); err == nil {
+		return rel
+	}
+	return p
+}
+
+// printPathList prints paths relative to root, truncating long lists.
+func printPathList(root string, paths []string) {
+	printList(len(paths), func(i int) string { return relPath(root, paths[i]) })
+}
+
+// printList prints n indented lines produced by line, truncating long lists.
+func printList(n int, line func(i int) string) {
+	for i := 0; i < n; i++ {
+		if i == maxListed {
+			fmt.Fprintf(console, "  ... and %d more\n", n-maxListed)
+			return
+		}
+		fmt.Fprintf(console, "  %s\n", line(i))
+	}
+}
+
+// percent returns part as a percentage of total, or 0 when total is empty.
+func percent(part, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return part / total * 100
+}
+
+// formatClock renders seconds as H:MM:SS.
+func formatClock(seconds float64) string {
+	s := int(seconds + 0.5)
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+}
+
+// quantile returns the q-th quantile of sorted values using linear
+// interpolation between neighbours.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	lo := int(pos)
+	if lo+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lo] + (pos-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
+
+// modTimeRange returns the earliest and latest modification times among
+// the files that could be stat'ed.
+func modTimeRange(results []result) (oldest, newest time.Time, ok bool) {
+	for _, r := range results {
+		if r.modTime.IsZero() {
+			continue
+		}
+		if !ok || r.modTime.Before(oldest) {
+			oldest = r.modTime
+		}
+		if !ok || r.modTime.After(newest) {
+			newest = r.modTime
+		}
+		ok = true
+	}
+	return oldest, newest, ok
+}
+
+// formatSpan renders a long duration in days, or years for multi-year
+// spans.
+func formatSpan(d time.Duration) string {
+	days := d.Hours() / 24
+	if days >= 365 {
+		return tr("%.0f days (%.1f years)", days, days/365.25)
+	}
+	return tr("%.1f days", days)
+}
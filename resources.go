@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resourceUsage is what a scan cost the machine, for admins deciding
+// whether and how hard to let it run on shared storage. Fields the
+// platform cannot report are -1.
+type resourceUsage struct {
+	PeakMemory int64   `json:"peak_memory_bytes"` // peak resident set of this process
+	BytesRead  int64   `json:"bytes_read"`        // read from audio files, as counted for --max-bytes
+	CPUSeconds float64 `json:"cpu_seconds"`       // user and system time, waited-for probe processes included
+}
+
+// since is the usage between an earlier reading and u. The peak stays the
+// process's: it cannot be told apart per scan.
+func (u resourceUsage) since(start resourceUsage) resourceUsage {
+	d := u
+	d.CPUSeconds -= start.CPUSeconds
+	d.BytesRead -= start.BytesRead
+	return d
+}
+
+// printResourceUsage prints the usage line of the results.
+func printResourceUsage(u resourceUsage) {
+	printf("Peak memory: %.1f MB, read: %.1f MB, CPU time: %.2fs\n", float64(u.PeakMemory)/1e6, float64(u.BytesRead)/1e6, u.CPUSeconds)
+}
+
+// String is the usage for the scan's log line.
+func (u resourceUsage) String() string {
+	parts := []string{fmt.Sprintf("%.2fs CPU", u.CPUSeconds), fmt.Sprintf("%.1f MB read", float64(u.BytesRead)/1e6)}
+	if u.PeakMemory >= 0 {
+		parts = append(parts, fmt.Sprintf("peak memory %.1f MB", float64(u.PeakMemory)/1e6))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,30 @@
+//go:build unix
+
+package main
+
+import (
+	"runtime"
+	"syscall"
+)
+
+// readResourceUsage reads getrusage for this process and its finished
+// children.
+func readResourceUsage() resourceUsage {
+	u := resourceUsage{PeakMemory: -1, BytesRead: ioStats.bytes.Load()}
+	var self, children syscall.Rusage
+	if syscall.Getrusage(syscall.RUSAGE_SELF, &self) == nil {
+		u.PeakMemory = int64(self.Maxrss)
+		if runtime.GOOS != "darwin" {
+			u.PeakMemory *= 1024 // kilobytes everywhere but macOS
+		}
+		u.CPUSeconds += rusageSeconds(self)
+	}
+	if syscall.Getrusage(syscall.RUSAGE_CHILDREN, &children) == nil {
+		u.CPUSeconds += rusageSeconds(children)
+	}
+	return u
+}
+
+func rusageSeconds(r syscall.Rusage) float64 {
+	return float64(r.Utime.Nano()+r.Stime.Nano()) / 1e9
+}
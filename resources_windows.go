@@ -0,0 +1,44 @@
+//go:build windows
+
+package main
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procGetProcessMemoryInfo = windows.NewLazySystemDLL("psapi.dll").NewProc("GetProcessMemoryInfo")
+
+// processMemoryCounters is PROCESS_MEMORY_COUNTERS.
+type processMemoryCounters struct {
+	cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+// readResourceUsage reads the process times and peak working set. Probe
+// processes are not included: Windows keeps no totals of finished children.
+func readResourceUsage() resourceUsage {
+	u := resourceUsage{PeakMemory: -1, BytesRead: ioStats.bytes.Load()}
+	process := windows.CurrentProcess()
+	var creation, exit, kernel, user windows.Filetime
+	if windows.GetProcessTimes(process, &creation, &exit, &kernel, &user) == nil {
+		// Filetimes count 100ns intervals.
+		ticks := uint64(kernel.HighDateTime)<<32 | uint64(kernel.LowDateTime)
+		ticks += uint64(user.HighDateTime)<<32 | uint64(user.LowDateTime)
+		u.CPUSeconds = float64(ticks) / 1e7
+	}
+	mem := processMemoryCounters{cb: uint32(unsafe.Sizeof(processMemoryCounters{}))}
+	if ok, _, _ := procGetProcessMemoryInfo.Call(uintptr(process), uintptr(unsafe.Pointer(&mem)), uintptr(mem.cb)); ok != 0 {
+		u.PeakMemory = int64(mem.PeakWorkingSetSize)
+	}
+	return u
+}
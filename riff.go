@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-audio/wav"
+)
+
+// maxHeaderChunk caps the size of any RIFF chunk ahead of the audio data.
+// go-audio/wav allocates LIST, smpl and fmt chunks whole, so one corrupt
+// size field could otherwise ask for gigabytes.
+const maxHeaderChunk = 16 << 20
+
+// riffChunk is a chunk header; offset points at the payload.
+type riffChunk struct {
+	id     string
+	offset int64
+	size   int64
+}
+
+// readRIFFChunks lists the chunks of a RIFF/WAVE file up to and including
+// the data chunk, reading only their 8-byte headers.
+func readRIFFChunks(file *audioFile) ([]riffChunk, error) {
+	return scanRIFFChunks(file, true)
+}
+
+// Streaming recorders write the data chunk before they know its size, as
+// 0 or 0xFFFFFFFF; RF64 files always do and keep the real size in ds64.
+const streamedChunkSize = 0xFFFFFFFF
+
+// resyncWindow is how far past a garbled chunk header scanRIFFChunks looks
+// for the next fmt or data chunk.
+const resyncWindow = 64 << 10
+
+// scanRIFFChunks lists chunk headers, stopping at the data chunk when
+// stopAtData is set. Otherwise it skips over the audio to the metadata
+// chunks (iXML, often) that recorders write after it.
+//
+// It accepts the layouts real files have besides the textbook one: RF64,
+// a data chunk whose size was never filled in (taken to run to the end of
+// the file) or that claims more than the file holds (cut to what is
+// there), odd-sized chunks written without their pad byte, and garbage
+// between chunks ahead of the audio. The RIFF size field is ignored.
+func scanRIFFChunks(file *audioFile, stopAtData bool) ([]riffChunk, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var hdr [12]byte
+	if _, err := file.ReadAt(hdr[:], 0); err != nil {
+		return nil, errors.New("invalid WAV file")
+	}
+	switch {
+	case string(hdr[8:12]) != "WAVE":
+		return nil, errors.New("invalid WAV file")
+	case string(hdr[0:4]) == "RIFF", string(hdr[0:4]) == "RF64", string(hdr[0:4]) == "BW64":
+	default:
+		return nil, errors.New("invalid WAV file")
+	}
+
+	var chunks []riffChunk
+	var ds64Data int64 = -1
+	sawData := false
+	for pos := int64(12); pos+8 <= info.Size(); {
+		if _, err := file.ReadAt(hdr[:8], pos); err != nil {
+			return nil, err
+		}
+		c := riffChunk{id: string(hdr[0:4]), offset: pos + 8, size: int64(binary.LittleEndian.Uint32(hdr[4:8]))}
+		if !sawData && (!validChunkID(hdr[0:4]) || c.size > maxHeaderChunk && c.id != "data") {
+			next, ok := resyncRIFF(file, pos+1, info.Size())
+			if !ok {
+				return nil, fmt.Errorf("WAV chunk %q claims %d bytes", c.id, c.size)
+			}
+			pos = next
+			continue
+		}
+		switch c.id {
+		case "ds64":
+			var p [16]byte
+			if c.size >= 16 {
+				if _, err := file.ReadAt(p[:], c.offset); err == nil {
+					if n := int64(binary.LittleEndian.Uint64(p[8:16])); n >= 0 {
+						ds64Data = n
+					}
+				}
+			}
+		case "data":
+			switch {
+			case c.size == streamedChunkSize && ds64Data >= 0:
+				c.size = ds64Data
+			case c.size == 0 || c.size == streamedChunkSize:
+				c.size = info.Size() - c.offset
+			}
+			// A truncated recording keeps the size it was meant to
+			// have; only the bytes actually there are audio.
+			c.size = max(min(c.size, info.Size()-c.offset), 0)
+		}
+		chunks = append(chunks, c)
+		if c.id == "data" {
+			// A short data chunk is a truncated recording, not a layout
+			// error, and was clamped above; its bytes are never buffered
+			// whole.
+			if stopAtData {
+				return chunks, nil
+			}
+			sawData = true
+			pos = nextChunkPos(file, c, info.Size())
+			continue
+		}
+		if c.size > maxHeaderChunk || c.offset+c.size > info.Size() {
+			if sawData {
+				// Trailing junk after the audio does not spoil the
+				// chunks already found.
+				return chunks[:len(chunks)-1], nil
+			}
+			return nil, fmt.Errorf("WAV chunk %q claims %d bytes", c.id, c.size)
+		}
+		pos = nextChunkPos(file, c, info.Size())
+	}
+	if sawData {
+		return chunks, nil
+	}
+	return nil, errors.New("WAV file has no data chunk")
+}
+
+// validChunkID reports whether id looks like a chunk ID: four printable
+// ASCII characters.
+func validChunkID(id []byte) bool {
+	for _, b := range id {
+		if b < 0x20 || b > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// nextChunkPos is where the chunk after c starts. Chunks are padded to an
+// even size, but some writers leave the pad byte out; when the padded
+// position holds no plausible chunk ID and the unpadded one does, the file
+// is taken at its word.
+func nextChunkPos(file *audioFile, c riffChunk, size int64) int64 {
+	end := c.offset + c.size
+	if c.size%2 == 0 || end+9 > size {
+		return end + c.size%2
+	}
+	var id [5]byte
+	if _, err := file.ReadAt(id[:], end); err != nil {
+		return end + 1
+	}
+	if !validChunkID(id[1:5]) && validChunkID(id[0:4]) {
+		return end
+	}
+	return end + 1
+}
+
+// resyncRIFF looks for the next fmt or data chunk header at or after from.
+func resyncRIFF(file *audioFile, from, size int64) (int64, bool) {
+	n := min(int64(resyncWindow), size-from)
+	if n < 8 {
+		return 0, false
+	}
+	buf := make([]byte, n)
+	if _, err := file.ReadAt(buf, from); err != nil && !errors.Is(err, io.EOF) {
+		return 0, false
+	}
+	fmtAt := bytes.Index(buf, []byte("fmt "))
+	dataAt := bytes.Index(buf, []byte("data"))
+	switch {
+	case fmtAt < 0 && dataAt < 0:
+		return 0, false
+	case fmtAt < 0 || dataAt >= 0 && dataAt < fmtAt:
+		return from + int64(dataAt), true
+	default:
+		return from + int64(fmtAt), true
+	}
+}
+
+// WAVE format tags whose data is a whole number of fixed-size frames.
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatALaw       = 6
+	wavFormatMuLaw      = 7
+	wavFormatExtensible = 0xFFFE
+)
+
+// wavFormat is the part of a fmt chunk durations are computed from.
+type wavFormat struct {
+	tag        uint16
+	channels   uint16
+	sampleRate uint32
+	byteRate   uint32
+	blockAlign uint16
+	bits       uint16
+}
+
+// readWAVFormat reads the fmt chunk among chunks, rejecting values no real
+// recording uses.
+func readWAVFormat(file *audioFile, chunks []riffChunk) (wavFormat, error) {
+	for _, c := range chunks {
+		if c.id != "fmt " {
+			continue
+		}
+		if c.size < 16 {
+			return wavFormat{}, errors.New("WAV fmt chunk too short")
+		}
+		var p [16]byte
+		if _, err := file.ReadAt(p[:], c.offset); err != nil {
+			return wavFormat{}, err
+		}
+		f := wavFormat{
+			tag:        binary.LittleEndian.Uint16(p[0:2]),
+			channels:   binary.LittleEndian.Uint16(p[2:4]),
+			sampleRate: binary.LittleEndian.Uint32(p[4:8]),
+			byteRate:   binary.LittleEndian.Uint32(p[8:12]),
+			blockAlign: binary.LittleEndian.Uint16(p[12:14]),
+			bits:       binary.LittleEndian.Uint16(p[14:16]),
+		}
+		switch {
+		case f.channels == 0 || f.channels > 256:
+			return f, fmt.Errorf("implausible WAV channel count %d", f.channels)
+		case f.sampleRate == 0 || f.sampleRate > 1<<20:
+			return f, fmt.Errorf("implausible WAV sample rate %d", f.sampleRate)
+		case f.bits > 64:
+			return f, fmt.Errorf("implausible WAV bit depth %d", f.bits)
+		}
+		return f, nil
+	}
+	return wavFormat{}, errNoWAVFormat
+}
+
+var errNoWAVFormat = errors.New("WAV file has no fmt chunk before its data")
+
+// checkWAVFormat rejects fmt chunks go-audio/wav would divide by zero on,
+// or that no real recording uses.
+func checkWAVFormat(file *audioFile, chunks []riffChunk) error {
+	f, err := readWAVFormat(file, chunks)
+	switch {
+	case err != nil:
+		return err
+	case f.byteRate == 0:
+		return errors.New("WAV fmt chunk has no byte rate")
+	case f.bits == 0:
+		return fmt.Errorf("implausible WAV bit depth %d", f.bits)
+	}
+	return nil
+}
+
+// wavDuration computes a WAV file's duration from its fmt chunk and the
+// size of its data chunk: whole frames for PCM-like encodings, the fact
+// chunk's sample count for compressed ones, and the byte rate otherwise.
+// A fmt chunk written after the audio is found too.
+func wavDuration(file *audioFile) (float64, error) {
+	chunks, err := readRIFFChunks(file)
+	if err != nil {
+		return 0, err
+	}
+	f, err := readWAVFormat(file, chunks)
+	if errors.Is(err, errNoWAVFormat) {
+		if all, scanErr := scanRIFFChunks(file, false); scanErr == nil {
+			f, err = readWAVFormat(file, all)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	data := chunks[len(chunks)-1]
+
+	switch f.tag {
+	case wavFormatPCM, wavFormatFloat, wavFormatALaw, wavFormatMuLaw, wavFormatExtensible:
+		frame := int64(f.blockAlign)
+		if frame == 0 {
+			frame = int64(f.channels) * int64((f.bits+7)/8)
+		}
+		if frame > 0 {
+			return float64(data.size/frame) / float64(f.sampleRate), nil
+		}
+	default:
+		for _, c := range chunks {
+			if c.id != "fact" || c.size < 4 {
+				continue
+			}
+			var p [4]byte
+			if _, err := file.ReadAt(p[:], c.offset); err == nil {
+				if samples := binary.LittleEndian.Uint32(p[:]); samples > 0 {
+					return float64(samples) / float64(f.sampleRate), nil
+				}
+			}
+		}
+	}
+	if f.byteRate == 0 {
+		return 0, errors.New("WAV fmt chunk has no byte rate")
+	}
+	return float64(data.size) / float64(f.byteRate), nil
+}
+
+// newWAVDecoder checks the chunk layout and format with bounded reads
+// before handing the file to go-audio/wav.
+func newWAVDecoder(file *audioFile) (*wav.Decoder, error) {
+	chunks, err := readRIFFChunks(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWAVFormat(file, chunks); err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return wav.NewDecoder(file), nil
+}
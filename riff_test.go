@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func FuzzScanRIFFChunks(f *testing.F) {
+	f.Add(pcmWAV(8000, 16000, 16000))
+	f.Add(pcmWAV(8000, streamedChunkSize, 100))
+	f.Add([]byte("RF64\xff\xff\xff\xffWAVEds64"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		file, err := openAudio(writeTemp(t, "f.wav", data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		readRIFFChunks(file)
+		scanRIFFChunks(file, false)
+		wavDuration(file)
+	})
+}
+
+func TestWAVDurationTruncated(t *testing.T) {
+	// The header claims 10 s at 8 kHz, 16-bit mono; only 1 s was written.
+	path := writeTemp(t, "truncated.wav", pcmWAV(8000, 160000, 16000))
+	got, err := getWAVDuration(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 1 {
+		t.Fatalf("duration = %v s, want 1 s from the data actually present", got)
+	}
+}
+
+func TestWAVDurationDS64Negative(t *testing.T) {
+	b := []byte("RF64\xff\xff\xff\xffWAVEds64\x1c\x00\x00\x00")
+	b = append(b, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	b = append(b, make([]byte, 12)...)
+	wav := pcmWAV(8000, streamedChunkSize, 16000)
+	b = append(b, wav[12:]...)
+	got, err := getWAVDuration(writeTemp(t, "rf64.wav", b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 1 {
+		t.Fatalf("duration = %v s, want 1 s", got)
+	}
+}
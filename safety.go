@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// errNotAllowed fails files that resolve outside every --allowed-roots
+// entry, such as symlinks leading out of the tree or paths handed to a
+// --join or --queue-jobs worker.
+var errNotAllowed = errors.New("outside --allowed-roots")
+
+// loadAllowedRoots reads an --allowed-roots file, one absolute directory per
+// line as in --priority-dirs, and resolves each entry as scan roots are.
+func loadAllowedRoots(path string) ([]string, error) {
+	dirs, err := readDirList(path)
+	if err != nil {
+		return nil, err
+	}
+	for i, dir := range dirs {
+		if !filepath.IsAbs(dir) {
+			return nil, fmt.Errorf("%s: %q is not an absolute path", path, dir)
+		}
+		if resolved, err := resolveRoot(dir); err == nil {
+			dir = resolved
+		}
+		dirs[i] = filepath.Clean(dir)
+	}
+	return dirs, nil
+}
+
+// within reports whether path is dir or lies below it, both clean.
+func within(path, dir string) bool {
+	if path == dir {
+		return true
+	}
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, dir)
+}
+
+// realPath is path made absolute with symlinks resolved as far as they
+// exist: a file not written yet is judged by its directory.
+func realPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if resolved, err := resolveRoot(abs); err == nil {
+		return filepath.Clean(resolved)
+	}
+	if dir, err := resolveRoot(filepath.Dir(abs)); err == nil {
+		return filepath.Join(dir, filepath.Base(abs))
+	}
+	return abs
+}
+
+// pathAllowed reports whether path, followed through symlinks, is in one
+// of roots. Every path is allowed when roots is empty.
+func pathAllowed(path string, roots []string) bool {
+	if len(roots) == 0 {
+		return true
+	}
+	real := realPath(path)
+	for _, root := range roots {
+		if within(real, root) {
+			return true
+		}
+	}
+	return false
+}
+
+// outputPaths names the files the options write, by flag.
+func outputPaths(opts options) map[string]string {
+	paths := map[string]string{
+		"--output":        opts.output,
+		"--audit-log":     opts.auditLog,
+		"--cache":         opts.cache,
+		"--history":       opts.history,
+		"--scan-manifest": opts.scanManifest,
+		"--parquet":       opts.parquet,
+		"--sqlite":        opts.sqlite,
+		"--age-scatter":   opts.ageScatter,
+		"--folded":        opts.folded,
+		"--treemap":       opts.treemap,
+		"--trace":         opts.trace,
+		"--daemon":        opts.daemon,
+	}
+	if opts.checksums != "" && opts.verifyManifest == "" {
+		paths["--manifest"] = opts.manifestPath()
+	}
+	return paths
+}
+
+// checkScanSafety refuses a root outside --allowed-roots and, with
+// --read-only, any option that would write inside the scanned tree.
+func checkScanSafety(root string, opts options) error {
+	if len(opts.allowedRoots) > 0 {
+		if isStreamURL(root) {
+			return fmt.Errorf("%s is not in --allowed-roots", redactURL(root))
+		}
+		if !pathAllowed(root, opts.allowedRoots) {
+			return fmt.Errorf("%s is not in --allowed-roots", root)
+		}
+	}
+	if !opts.readOnly || isStreamURL(root) {
+		return nil
+	}
+	if opts.emitDirFiles != "" {
+		return fmt.Errorf("--emit-dir-files writes into the scanned tree and cannot be used with --read-only")
+	}
+	tree := realPath(root)
+	paths := outputPaths(opts)
+	flags := make([]string, 0, len(paths))
+	for flag := range paths {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	for _, flag := range flags {
+		if p := paths[flag]; p != "" && within(realPath(p), tree) {
+			return fmt.Errorf("%s %s is inside the scanned tree, which --read-only forbids", flag, p)
+		}
+	}
+	return nil
+}
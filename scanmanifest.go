@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// scanManifest is the --scan-manifest file: everything needed to rerun a
+// scan and check that it reproduces the reported hours. Flags holds only
+// the flags set on the command line; the rest were at their defaults for
+// the recorded version. Credentials in URL-valued flags are redacted in
+// both Args and Flags.
+type scanManifest struct {
+	Tool         string             `json:"tool"`
+	Version      string             `json:"version"`
+	Revision     string             `json:"revision,omitempty"`
+	GoVersion    string             `json:"go_version"`
+	Created      time.Time          `json:"created"`
+	Args         []string           `json:"args"`
+	Flags        map[string]string  `json:"flags"`
+	Root         string             `json:"root"`
+	Extensions   []string           `json:"extensions"`
+	Plugins      map[string]string  `json:"plugins,omitempty"`
+	Shard        string             `json:"shard,omitempty"`
+	StatsOver    string             `json:"stats_over"`
+	Algorithm    string             `json:"hash_algorithm"`
+	Files        int                `json:"files"`
+	Errors       int                `json:"errors"`
+	TotalSeconds float64            `json:"total_seconds"`
+	TotalHours   float64            `json:"total_hours"`
+	FileList     []scanManifestFile `json:"file_list"`
+}
+
+// scanManifestFile is one scanned file. Paths are relative to the root,
+// with forward slashes and NFC, as in --checksums manifests.
+type scanManifestFile struct {
+	Path     string  `json:"path"`
+	Size     int64   `json:"size"`
+	Hash     string  `json:"hash,omitempty"`
+	Duration float64 `json:"duration"`
+	Error    string  `json:"error,omitempty"`
+}
+
+func buildScanManifest(summary scanSummary, opts options, algo string, audioFiles []string, results []result) scanManifest {
+	v, revision := buildVersion()
+	m := scanManifest{
+		Tool:         "howManyHours",
+		Version:      v,
+		Revision:     revision,
+		GoVersion:    runtime.Version(),
+		Created:      time.Now().UTC(),
+		Args:         redactArgs(os.Args[1:]),
+		Flags:        make(map[string]string),
+		Root:         summary.Root,
+		Plugins:      opts.plugins,
+		Shard:        summary.Shard,
+		StatsOver:    opts.statsOver,
+		Algorithm:    algo,
+		Files:        summary.Files,
+		Errors:       summary.Errors,
+		TotalSeconds: summary.TotalSeconds,
+		TotalHours:   summary.TotalHours,
+	}
+	flag.Visit(func(f *flag.Flag) {
+		m.Flags[f.Name] = f.Value.String()
+		if urlFlags[f.Name] {
+			m.Flags[f.Name] = redactURL(f.Value.String())
+		}
+	})
+	for ext := range audioExtensions(opts) {
+		m.Extensions = append(m.Extensions, ext)
+	}
+	sort.Strings(m.Extensions)
+
+	entries := buildManifest(summary.Root, algo, audioFiles, durationsOf(results), results).entries
+	errs := make(map[string]string)
+	for i, res := range results {
+		if res.err != nil {
+			errs[manifestPath(summary.Root, audioFiles[i])] = res.err.Error()
+		}
+	}
+	for _, e := range entries {
+		m.FileList = append(m.FileList, scanManifestFile{Path: e.path, Size: e.size, Hash: e.hash, Duration: e.duration, Error: errs[e.path]})
+	}
+	return m
+}
+
+// urlFlags take URLs that may carry a user and password.
+var urlFlags = map[string]bool{"enqueue": true, "queue-jobs": true, "queue-results": true, "mqtt": true, "otlp": true}
+
+// redactArgs returns a copy of args with the values of urlFlags redacted,
+// whether given as -flag=value or -flag value.
+func redactArgs(args []string) []string {
+	out := append([]string(nil), args...)
+	for i := 0; i < len(out); i++ {
+		arg := out[i]
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		switch {
+		case !urlFlags[name]:
+		case ok:
+			out[i] = arg[:len(arg)-len(value)] + redactURL(value)
+		case i+1 < len(out):
+			i++
+			out[i] = redactURL(out[i])
+		}
+	}
+	return out
+}
+
+func durationsOf(results []result) []float64 {
+	durations := make([]float64, len(results))
+	for i, res := range results {
+		if res.err == nil {
+			durations[i] = res.duration
+		}
+	}
+	return durations
+}
+
+func writeScanManifest(path string, m scanManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// scanManifestEntries turns a scan manifest back into a checksum manifest,
+// so --verify-manifest can check a later scan against it.
+func scanManifestEntries(data []byte) (manifest, error) {
+	var sm scanManifest
+	if err := json.Unmarshal(data, &sm); err != nil {
+		return manifest{}, err
+	}
+	m := manifest{algo: sm.Algorithm}
+	for _, f := range sm.FileList {
+		m.entries = append(m.entries, manifestEntry{path: nfc(f.Path), size: f.Size, hash: f.Hash, duration: f.Duration})
+	}
+	return m, nil
+}
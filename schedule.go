@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// scheduleOrders are the --order values.
+var scheduleOrders = map[string]bool{"walk": true, "small-first": true, "interleave": true}
+
+// scheduleFiles returns the order in which files are handed to workers, as
+// indices into files. Results stay aligned with files whatever the order.
+//
+// small-first makes the progress bar and per-file counts meaningful early;
+// interleave alternates the smallest and largest remaining files so early
+// progress is steady and no giant file is left to run alone at the end.
+func scheduleFiles(files []string, sizes map[string]int64, order string) []int {
+	idx := make([]int, len(files))
+	for i := range idx {
+		idx[i] = i
+	}
+	if order == "walk" || sizes == nil {
+		return idx
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return sizes[files[idx[a]]] < sizes[files[idx[b]]] })
+	if order == "small-first" {
+		return idx
+	}
+	interleaved := make([]int, 0, len(idx))
+	for lo, hi := 0, len(idx)-1; lo <= hi; lo, hi = lo+1, hi-1 {
+		interleaved = append(interleaved, idx[lo])
+		if lo != hi {
+			interleaved = append(interleaved, idx[hi])
+		}
+	}
+	return interleaved
+}
+
+func checkScheduleOrder(order string) error {
+	if !scheduleOrders[order] {
+		return fmt.Errorf("unsupported order: %s (want walk, small-first or interleave)", order)
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// segmentSet holds the annotated spans for one recording. dir is set for
+// per-file formats (TextGrid, Audacity labels) so a sibling audio file wins
+// over another file that merely shares the base name.
+type segmentSet struct {
+	id    string
+	dir   string
+	spans []interval
+}
+
+type segmentFileReport struct {
+	path             string
+	rawSeconds       float64
+	annotatedSeconds float64
+}
+
+type segmentReport struct {
+	files            []segmentFileReport
+	rawSeconds       float64
+	annotatedSeconds float64
+	unmatched        []string // recording ids with no audio file
+}
+
+// loadSegments reads every segmentation file under the given paths.
+// Directories are walked for files in a recognised format.
+func loadSegments(paths []string) ([]segmentSet, error) {
+	var sets []segmentSet
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			s, err := parseSegmentFile(p)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", p, err)
+			}
+			sets = append(sets, s...)
+			continue
+		}
+		err = filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || segmentFormat(path) == "" {
+				return nil
+			}
+			s, err := parseSegmentFile(path)
+			if err != nil {
+				// Plain .txt files are usually transcripts, not label tracks.
+				if segmentFormat(path) != "audacity" {
+					fmt.Fprintf(console, "Warning: skipping %s: %v\n", path, err)
+				}
+				return nil
+			}
+			sets = append(sets, s...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sets, nil
+}
+
+// segmentFormat guesses the segmentation format from the file name.
+func segmentFormat(path string) string {
+	base := strings.ToLower(filepath.Base(path))
+	switch {
+	case base == "segments":
+		return "kaldi"
+	case strings.HasSuffix(base, ".rttm"):
+		return "rttm"
+	case strings.HasSuffix(base, ".ctm"):
+		return "ctm"
+	case strings.HasSuffix(base, ".textgrid"):
+		return "textgrid"
+	case strings.HasSuffix(base, ".txt"), strings.HasSuffix(base, ".labels"):
+		return "audacity"
+	}
+	return ""
+}
+
+func parseSegmentFile(path string) ([]segmentSet, error) {
+	format := segmentFormat(path)
+	if format == "" {
+		return nil, fmt.Errorf("unknown segmentation format")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "kaldi":
+		// <utterance-id> <recording-id> <start> <end>
+		return parseColumnSegments(lines, 1, 2, 3, false)
+	case "rttm":
+		// SPEAKER <file-id> <channel> <start> <duration> ...
+		return parseColumnSegments(lines, 1, 3, 4, true)
+	case "ctm":
+		// <file-id> <channel> <start> <duration> <word> [confidence]
+		return parseColumnSegments(lines, 0, 2, 3, true)
+	case "textgrid":
+		return parseTextGrid(path, lines)
+	default:
+		return parseAudacityLabels(path, lines)
+	}
+}
+
+// parseColumnSegments handles the whitespace-separated, multi-recording
+// formats. When relative is true the end column holds a duration.
+func parseColumnSegments(lines []string, idCol, startCol, endCol int, relative bool) ([]segmentSet, error) {
+	byID := make(map[string]*segmentSet)
+	var order []string
+
+	for n, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], ";") || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) <= endCol || len(fields) <= idCol {
+			return nil, fmt.Errorf("line %d: expected at least %d fields", n+1, endCol+1)
+		}
+		start, err1 := strconv.ParseFloat(fields[startCol], 64)
+		end, err2 := strconv.ParseFloat(fields[endCol], 64)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("line %d: invalid time value", n+1)
+		}
+		if relative {
+			end += start
+		}
+
+		id := nfc(fields[idCol])
+		set, ok := byID[id]
+		if !ok {
+			set = &segmentSet{id: id}
+			byID[id] = set
+			order = append(order, id)
+		}
+		set.spans = append(set.spans, interval{start, end})
+	}
+
+	sets := make([]segmentSet, 0, len(order))
+	for _, id := range order {
+		sets = append(sets, *byID[id])
+	}
+	return sets, nil
+}
+
+var textGridField = regexp.MustCompile(`^\s*(xmin|xmax|text)\s*=\s*(.*?)\s*$`)
+
+// parseTextGrid reads Praat's long text format. Only intervals with a
+// non-empty label count as annotated; point tiers are ignored.
+func parseTextGrid(path string, lines []string) ([]segmentSet, error) {
+	if !strings.Contains(strings.Join(lines[:min(len(lines), 3)], "\n"), "TextGrid") {
+		return nil, fmt.Errorf("not a Praat TextGrid file")
+	}
+	set := segmentSet{id: filepath.Base(stem(path)), dir: filepath.Dir(path)}
+
+	inInterval := false
+	var cur interval
+	for _, line := range lines {
+		if strings.Contains(line, "intervals [") {
+			inInterval = true
+			continue
+		}
+		if strings.Contains(line, "points [") || strings.Contains(line, "item [") {
+			inInterval = false
+			continue
+		}
+		if !inInterval {
+			continue
+		}
+		m := textGridField.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		switch m[1] {
+		case "xmin":
+			cur.start, _ = strconv.ParseFloat(m[2], 64)
+		case "xmax":
+			cur.end, _ = strconv.ParseFloat(m[2], 64)
+		case "text":
+			if label := strings.Trim(m[2], `"`); strings.TrimSpace(label) != "" {
+				set.spans = append(set.spans, cur)
+			}
+			inInterval = false
+		}
+	}
+	return []segmentSet{set}, nil
+}
+
+// parseAudacityLabels reads "start<TAB>end<TAB>label" label tracks. Lines
+// starting with a backslash carry spectral selections and are skipped.
+func parseAudacityLabels(path string, lines []string) ([]segmentSet, error) {
+	set := segmentSet{id: filepath.Base(stem(path)), dir: filepath.Dir(path)}
+
+	for n, line := range lines {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "\\") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: not an Audacity label", n+1)
+		}
+		start, err1 := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+		end, err2 := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("line %d: not an Audacity label", n+1)
+		}
+		set.spans = append(set.spans, interval{start, end})
+	}
+	return []segmentSet{set}, nil
+}
+
+// buildSegmentReport matches segment sets to audio files by base name and
+// compares annotated time with the probed duration.
+func buildSegmentReport(audioFiles []string, durations []float64, sets []segmentSet) segmentReport {
+	var report segmentReport
+
+	byStem := make(map[string]int, len(audioFiles))
+	byBase := make(map[string][]int, len(audioFiles))
+	for i, path := range audioFiles {
+		byStem[stem(path)] = i
+		base := filepath.Base(stem(path))
+		byBase[base] = append(byBase[base], i)
+	}
+
+	spans := make(map[int][]interval)
+	for _, set := range sets {
+		idx := -1
+		if i, ok := byStem[filepath.Join(set.dir, set.id)]; ok && set.dir != "" {
+			idx = i
+		} else if candidates := byBase[set.id]; len(candidates) == 1 {
+			idx = candidates[0]
+		}
+		if idx < 0 {
+			report.unmatched = append(report.unmatched, set.id)
+			continue
+		}
+		spans[idx] = append(spans[idx], set.spans...)
+	}
+
+	for i, path := range audioFiles {
+		var annotated float64
+		if s, ok := spans[i]; ok {
+			annotated = coveredSeconds(s)
+		}
+		report.files = append(report.files, segmentFileReport{
+			path:             path,
+			rawSeconds:       durations[i],
+			annotatedSeconds: annotated,
+		})
+		report.rawSeconds += durations[i]
+		report.annotatedSeconds += annotated
+	}
+	sort.Strings(report.unmatched)
+
+	return report
+}
+
+func printSegmentReport(root string, report segmentReport) {
+	fmt.Fprintln(console, "\n=== Annotated Segments ===")
+	fmt.Fprintf(console, "Raw audio: %.2f hours\n", report.rawSeconds/3600.0)
+	fmt.Fprintf(console, "Annotated: %.2f hours (%.1f%%)\n", report.annotatedSeconds/3600.0, percent(report.annotatedSeconds, report.rawSeconds))
+
+	fmt.Fprintf(console, "\n%-50s %12s %12s %8s\n", "File", "Raw (min)", "Annot (min)", "Cover")
+	for i, f := range report.files {
+		if i == maxListed {
+			fmt.Fprintf(console, "... and %d more\n", len(report.files)-maxListed)
+			break
+		}
+		fmt.Fprintf(console, "%-50s %12.2f %12.2f %7.1f%%\n", relPath(root, f.path), f.rawSeconds/60, f.annotatedSeconds/60, percent(f.annotatedSeconds, f.rawSeconds))
+	}
+
+	if len(report.unmatched) > 0 {
+		fmt.Fprintf(console, "\nSegment recordings without audio: %d\n", len(report.unmatched))
+		printList(len(report.unmatched), func(i int) string { return report.unmatched[i] })
+	}
+}
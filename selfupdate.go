@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// defaultReleaseRepo is the GitHub repository self-update looks for
+// releases in. Release assets are named howManyHours_<GOOS>_<GOARCH>
+// (.exe on Windows), next to an optional checksums.txt in sha256sum format.
+const defaultReleaseRepo = "lodjim/howManyHours"
+
+// printVersion implements --version.
+func printVersion() {
+	v, revision := buildVersion()
+	fmt.Printf("howManyHours %s\n", v)
+	if revision != "" {
+		fmt.Printf("revision %s\n", revision)
+	}
+	fmt.Printf("%s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+func releaseAssetName() string {
+	name := fmt.Sprintf("howManyHours_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// selfUpdateFlags are the flags of the self-update subcommand.
+type selfUpdateFlags struct {
+	repo, tag, from, sha256 string
+	insecure, check         bool
+}
+
+func (f *selfUpdateFlags) define(fs *flag.FlagSet) {
+	fs.StringVar(&f.repo, "repo", defaultReleaseRepo, "GitHub repository whose releases to install")
+	fs.StringVar(&f.tag, "tag", "latest", "release tag to install")
+	fs.StringVar(&f.from, "from", "", "install this binary (URL or file path) instead of a GitHub release")
+	fs.StringVar(&f.sha256, "sha256", "", "expected SHA-256 of the --from binary")
+	fs.BoolVar(&f.insecure, "insecure", false, "install even when no checksum is available to verify the binary against")
+	fs.BoolVar(&f.check, "check", false, "only report whether a newer release exists")
+}
+
+// runSelfUpdate implements "howManyHours self-update": it replaces the
+// running binary with the latest release, or with a binary from --from
+// (a URL or a local path, for machines that only see an internal mirror
+// or a USB share).
+func runSelfUpdate(args []string) {
+	var flags selfUpdateFlags
+	fs := flag.NewFlagSet("self-update", flag.ExitOnError)
+	flags.define(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s self-update [flags]\n\nFlags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	current, _ := buildVersion()
+	source, want := flags.from, strings.ToLower(flags.sha256)
+	if source == "" {
+		release, err := fetchRelease(flags.repo, flags.tag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error finding release: %v\n", err)
+			os.Exit(1)
+		}
+		if release.TagName == current {
+			fmt.Printf("Already at %s\n", current)
+			return
+		}
+		if flags.check {
+			fmt.Printf("Release %s is available (running %s)\n", release.TagName, current)
+			return
+		}
+		var checksums string
+		for _, a := range release.Assets {
+			switch a.Name {
+			case releaseAssetName():
+				source = a.URL
+			case "checksums.txt":
+				checksums = a.URL
+			}
+		}
+		if source == "" {
+			fmt.Fprintf(os.Stderr, "Release %s has no %s binary\n", release.TagName, releaseAssetName())
+			os.Exit(1)
+		}
+		if checksums != "" && want == "" {
+			if want, err = releaseChecksum(checksums, releaseAssetName()); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading checksums: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("Updating %s to %s\n", current, release.TagName)
+	} else if flags.check {
+		fmt.Fprintln(os.Stderr, "--check needs a GitHub release, not --from")
+		os.Exit(2)
+	}
+	if want == "" {
+		if !flags.insecure {
+			fmt.Fprintf(os.Stderr, "No checksum to verify %s against: pass --sha256, or --insecure to install it unverified\n", source)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: installing %s without checksum verification\n", source)
+	}
+
+	installed, err := installBinary(source, want)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error updating: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Installed %s\n", installed)
+}
+
+func fetchRelease(repo, tag string) (githubRelease, error) {
+	var release githubRelease
+	url := "https://api.github.com/repos/" + repo + "/releases/latest"
+	if tag != "latest" {
+		url = "https://api.github.com/repos/" + repo + "/releases/tags/" + tag
+	}
+	body, err := download(url)
+	if err != nil {
+		return release, err
+	}
+	defer body.Close()
+	err = json.NewDecoder(body).Decode(&release)
+	return release, err
+}
+
+// releaseChecksum finds name in a sha256sum-style checksums file.
+func releaseChecksum(url, name string) (string, error) {
+	body, err := download(url)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no checksum listed for %s", name)
+}
+
+// download opens a URL or, for anything that is not http(s), a local file.
+func download(source string) (io.ReadCloser, error) {
+	if !isHTTP(source) {
+		return os.Open(source)
+	}
+	client := http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", source, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// installBinary writes the new binary next to the running one, checks its
+// SHA-256 when one is expected, and renames it into place. Windows cannot
+// replace a running executable, so the old one is moved aside to .old.
+func installBinary(source, wantSHA256 string) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return "", err
+	}
+	body, err := download(source)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".howManyHours-update-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), body); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); wantSHA256 != "" && got != wantSHA256 {
+		return "", fmt.Errorf("checksum mismatch: got %s, want %s", got, wantSHA256)
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return "", err
+	}
+	old := ""
+	if runtime.GOOS == "windows" {
+		old = exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return "", err
+		}
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		err = errors.Join(err, fmt.Errorf("the new binary could not replace %s", exe))
+		if old != "" {
+			// Put the running binary back so that exe is not left missing.
+			if restoreErr := os.Rename(old, exe); restoreErr != nil {
+				err = errors.Join(err, fmt.Errorf("restoring %s: %w", old, restoreErr))
+			}
+		}
+		return "", err
+	}
+	return exe, nil
+}
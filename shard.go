@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// shardSpec is --shard K/N: this run takes the K-th of N disjoint shards.
+type shardSpec struct {
+	index, count int // index is 1-based
+}
+
+func (s shardSpec) String() string { return fmt.Sprintf("%d/%d", s.index, s.count) }
+
+func parseShard(v string) (shardSpec, error) {
+	k, n, ok := strings.Cut(v, "/")
+	index, err1 := strconv.Atoi(strings.TrimSpace(k))
+	count, err2 := strconv.Atoi(strings.TrimSpace(n))
+	if !ok || err1 != nil || err2 != nil || count < 1 || index < 1 || index > count {
+		return shardSpec{}, fmt.Errorf("invalid shard %q (want K/N with 1 <= K <= N)", v)
+	}
+	return shardSpec{index: index, count: count}, nil
+}
+
+// shardFiles keeps the files whose hashed relative path lands in the shard.
+// Hashing the normalized, slash-separated path makes the assignment the
+// same on every machine and operating system.
+func shardFiles(root string, files []string, shard shardSpec) []string {
+	var kept []string
+	for _, path := range files {
+		h := fnv.New64a()
+		h.Write([]byte(nfc(filepath.ToSlash(relPath(root, path)))))
+		if int(h.Sum64()%uint64(shard.count)) == shard.index-1 {
+			kept = append(kept, path)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// silenceFrameSeconds is the window over which loudness is judged.
+const silenceFrameSeconds = 0.050
+
+// silenceDetector measures how much of a stream sits below a level, and
+// whether it is digital zero throughout.
+type silenceDetector struct {
+	level        float64 // mean-square threshold
+	channels     int
+	frameLen     int
+	acc          float64
+	accSamples   int
+	frames       int
+	silentFrames int
+	peak         float64
+}
+
+func newSilenceDetector(levelDBFS float64) *silenceDetector {
+	return &silenceDetector{level: math.Pow(10, levelDBFS/10)}
+}
+
+func (s *silenceDetector) start(format pcmFormat) {
+	s.channels = format.channels
+	s.frameLen = int(float64(format.sampleRate)*silenceFrameSeconds) * format.channels
+	if s.frameLen < format.channels {
+		s.frameLen = format.channels
+	}
+}
+
+func (s *silenceDetector) process(samples []float64) {
+	for _, v := range samples {
+		if a := math.Abs(v); a > s.peak {
+			s.peak = a
+		}
+		s.acc += v * v
+		s.accSamples++
+		if s.accSamples == s.frameLen {
+			s.frame()
+		}
+	}
+}
+
+func (s *silenceDetector) frame() {
+	s.frames++
+	if s.acc/float64(s.accSamples) < s.level {
+		s.silentFrames++
+	}
+	s.acc, s.accSamples = 0, 0
+}
+
+// silentFraction returns the share of frames below the level, counting a
+// trailing partial frame.
+func (s *silenceDetector) silentFraction() float64 {
+	if s.accSamples > 0 {
+		s.frame()
+	}
+	if s.frames == 0 {
+		return 1
+	}
+	return float64(s.silentFrames) / float64(s.frames)
+}
+
+type silentFile struct {
+	path     string
+	duration float64
+	fraction float64
+	zero     bool
+}
+
+type silenceReport struct {
+	threshold      float64 // percent
+	files          []silentFile
+	digitalZero    int
+	silentSeconds  float64
+	analyzed       int
+	analyzedFailed int
+}
+
+func buildSilenceReport(audioFiles []string, durations []float64, stats []sampleStats, thresholdPercent float64) silenceReport {
+	report := silenceReport{threshold: thresholdPercent}
+	for i, s := range stats {
+		if durations[i] <= 0 {
+			continue
+		}
+		if !s.analyzed {
+			report.analyzedFailed++
+			continue
+		}
+		report.analyzed++
+		if !s.digitalZero && s.silentFraction*100 < thresholdPercent {
+			continue
+		}
+		report.files = append(report.files, silentFile{
+			path:     audioFiles[i],
+			duration: durations[i],
+			fraction: s.silentFraction,
+			zero:     s.digitalZero,
+		})
+		report.silentSeconds += durations[i]
+		if s.digitalZero {
+			report.digitalZero++
+		}
+	}
+	return report
+}
+
+func printSilenceReport(root string, report silenceReport) {
+	fmt.Fprintln(console, "\n=== Silence ===")
+	fmt.Fprintf(console, "Analyzed files: %d\n", report.analyzed)
+	fmt.Fprintf(console, "Silent files (>= %.0f%% silent): %d (%.2f hours)\n", report.threshold, len(report.files), report.silentSeconds/3600.0)
+	fmt.Fprintf(console, "Digital zero files: %d\n", report.digitalZero)
+	if report.analyzedFailed > 0 {
+		fmt.Fprintf(console, "Not analyzed (no sample decoder or decode error): %d\n", report.analyzedFailed)
+	}
+
+	if len(report.files) > 0 {
+		fmt.Fprintln(console)
+		printList(len(report.files), func(i int) string {
+			f := report.files[i]
+			label := fmt.Sprintf("%.1f%% silent", f.fraction*100)
+			if f.zero {
+				label = "digital zero"
+			}
+			return fmt.Sprintf("%s (%s, %.1fs)", relPath(root, f.path), label, f.duration)
+		})
+	}
+}
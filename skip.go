@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const auditUserSkip = "user-skipped" // the user skipped its directory mid-scan
+
+// skipWiden is how soon a second skip request must follow the first to
+// widen it to the parent directory.
+const skipWiden = 2 * time.Second
+
+// errUserSkipped marks files left unprobed because their directory was
+// skipped while the scan ran.
+var errUserSkipped = errors.New("skipped at the user's request")
+
+// subtreeSkipper lets the user drop the directory the scan is working in
+// without stopping the scan: Ctrl-\ on a terminal or SIGUSR1 (see
+// watchSkipRequests) skips it, and a second request soon after skips its
+// parent as well. The walk stops descending into skipped directories and
+// files already found in them are neither probed nor counted.
+//
+// While the walk runs, the directory it is in is skipped. While files are
+// probed, the workers may each be in a different directory, so the skip
+// takes the deepest directory holding every file in flight. Workers record
+// their file in their own skipSlot and check skipped through the atomic
+// any flag, so probing takes no lock until something has been skipped.
+type subtreeSkipper struct {
+	mu      sync.Mutex
+	root    string
+	walking string      // directory being walked, empty once the walk ends
+	slots   []*skipSlot // one per probing worker, see slot
+	skipped []string    // skipped directories, clean paths under root
+	any     atomic.Bool // whether skipped is non-empty
+	last    time.Time
+}
+
+// skipSlot is where one worker records the directory of the file it is
+// probing, for request to read.
+type skipSlot struct {
+	dir atomic.Pointer[string]
+}
+
+// skipper serves the scan of the process; it is nil until startSkipper.
+var skipper *subtreeSkipper
+
+// startSkipper begins listening for skip requests while root is scanned.
+func startSkipper(root string) {
+	skipper = &subtreeSkipper{root: filepath.Clean(root)}
+	watchSkipRequests(skipper.request)
+}
+
+// walk records dir as where the walk is now; "" marks the walk finished.
+func (s *subtreeSkipper) walk(dir string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.walking = dir
+	if dir != "" {
+		s.walking = filepath.Clean(dir)
+	}
+	s.mu.Unlock()
+}
+
+// slot registers a probing worker until release. It is nil when s is.
+func (s *subtreeSkipper) slot() *skipSlot {
+	if s == nil {
+		return nil
+	}
+	w := &skipSlot{}
+	s.mu.Lock()
+	s.slots = append(s.slots, w)
+	s.mu.Unlock()
+	return w
+}
+
+// release unregisters a worker that has stopped.
+func (s *subtreeSkipper) release(w *skipSlot) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, slot := range s.slots {
+		if slot == w {
+			s.slots = append(s.slots[:i], s.slots[i+1:]...)
+			return
+		}
+	}
+}
+
+// probing records dir as holding the file the worker probes now; ""
+// means none.
+func (w *skipSlot) probing(dir string) {
+	if w == nil {
+		return
+	}
+	if dir == "" {
+		w.dir.Store(nil)
+		return
+	}
+	dir = filepath.Clean(dir)
+	w.dir.Store(&dir)
+}
+
+// current is the directory a skip request applies to, "" when the scan
+// is in none, and how many workers are probing. s.mu must be held.
+func (s *subtreeSkipper) current() (dir string, probing int) {
+	if s.walking != "" {
+		return s.walking, 0
+	}
+	for _, w := range s.slots {
+		d := w.dir.Load()
+		if d == nil {
+			continue
+		}
+		probing++
+		if dir == "" {
+			dir = *d
+			continue
+		}
+		for !within(*d, dir) {
+			dir = filepath.Dir(dir)
+		}
+	}
+	return dir, probing
+}
+
+// request skips the current directory, or widens the last skip to its
+// parent when it follows within skipWiden. The root is never skipped.
+func (s *subtreeSkipper) request() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	dir, probing := s.current()
+	widen := len(s.skipped) > 0 && now.Sub(s.last) < skipWiden
+	if widen {
+		dir = filepath.Dir(s.skipped[len(s.skipped)-1])
+	}
+	s.last = now
+	switch {
+	case dir == "":
+		fmt.Fprintln(os.Stderr, "\nNot skipping: no directory is being scanned right now")
+		return
+	case dir == s.root && probing > 1 && !widen:
+		fmt.Fprintln(os.Stderr, "\nNot skipping: the workers are probing files all over the scanned root (--order walk keeps them together)")
+		return
+	case dir == s.root || !within(dir, s.root):
+		fmt.Fprintf(os.Stderr, "\nNot skipping %s: it is the scanned root\n", dir)
+		return
+	}
+	if widen {
+		s.skipped[len(s.skipped)-1] = dir
+	} else {
+		s.skipped = append(s.skipped, dir)
+		s.any.Store(true)
+	}
+	fmt.Fprintf(os.Stderr, "\nSkipping %s (again within %s to skip its parent)\n", relPath(s.root, dir), skipWiden)
+}
+
+// covers reports whether path is in a skipped directory.
+func (s *subtreeSkipper) covers(path string) bool {
+	if s == nil || !s.any.Load() {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path = filepath.Clean(path)
+	for _, dir := range s.skipped {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// dirs returns the skipped directories so far.
+func (s *subtreeSkipper) dirs() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.skipped...)
+}
+
+// list is the skipped directories relative to the root, for the summary.
+func (s *subtreeSkipper) list() string {
+	dirs := s.dirs()
+	for i, dir := range dirs {
+		dirs[i] = relPath(s.root, dir)
+	}
+	return strings.Join(dirs, ", ")
+}
+
+// dropUserSkipped removes the files in skipped directories, found before
+// the skip, from the scan. dropped marks them by their index in audioFiles.
+func dropUserSkipped(audioFiles []string, results []result, audit *auditLog) (keptFiles []string, keptResults []result, dropped []bool) {
+	dropped = make([]bool, len(audioFiles))
+	for i, p := range audioFiles {
+		if errors.Is(results[i].err, errUserSkipped) || skipper.covers(p) {
+			audit.skipped(p, auditUserSkip, "")
+			dropped[i] = true
+			continue
+		}
+		keptFiles = append(keptFiles, p)
+		keptResults = append(keptResults, results[i])
+	}
+	return keptFiles, keptResults, dropped
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSkipperCurrent(t *testing.T) {
+	p := filepath.FromSlash
+	tests := []struct {
+		name        string
+		walking     string
+		probing     []string // one per worker; "" is idle
+		want        string
+		wantProbing int
+	}{
+		{name: "idle", probing: []string{"", ""}, want: ""},
+		{name: "walk wins over probes", walking: p("/r/a"), probing: []string{p("/r/b")}, want: p("/r/a")},
+		{name: "one worker", probing: []string{p("/r/a/b"), ""}, want: p("/r/a/b"), wantProbing: 1},
+		{name: "same directory", probing: []string{p("/r/a"), p("/r/a")}, want: p("/r/a"), wantProbing: 2},
+		{name: "nested", probing: []string{p("/r/a/b/c"), p("/r/a")}, want: p("/r/a"), wantProbing: 2},
+		{name: "siblings", probing: []string{p("/r/a/x"), p("/r/a/y"), p("/r/a/y/z")}, want: p("/r/a"), wantProbing: 3},
+		{name: "prefix is not a parent", probing: []string{p("/r/ab"), p("/r/a")}, want: p("/r"), wantProbing: 2},
+		{name: "across the root", probing: []string{p("/r/a"), p("/r/b")}, want: p("/r"), wantProbing: 2},
+	}
+	for _, tt := range tests {
+		s := &subtreeSkipper{root: p("/r"), walking: tt.walking}
+		for _, dir := range tt.probing {
+			w := s.slot()
+			w.probing(dir)
+		}
+		dir, probing := s.current()
+		if dir != tt.want || probing != tt.wantProbing {
+			t.Errorf("%s: current() = %q, %d; want %q, %d", tt.name, dir, probing, tt.want, tt.wantProbing)
+		}
+	}
+}
+
+func TestSkipperCovers(t *testing.T) {
+	s := &subtreeSkipper{root: filepath.FromSlash("/r")}
+	w := s.slot()
+	w.probing(filepath.FromSlash("/r/a"))
+	if s.covers(filepath.FromSlash("/r/a/x.wav")) {
+		t.Fatal("covers before any skip")
+	}
+	s.request()
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/r/a/x.wav", true},
+		{"/r/a/b/y.wav", true},
+		{"/r/ab/z.wav", false},
+		{"/r/b.wav", false},
+	}
+	for _, tt := range tests {
+		if got := s.covers(filepath.FromSlash(tt.path)); got != tt.want {
+			t.Errorf("covers(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
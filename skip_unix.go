@@ -0,0 +1,25 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchSkipRequests calls skip on SIGUSR1, and on SIGQUIT (Ctrl-\) when
+// stdin is a terminal, where Ctrl-C stays the way to stop.
+func watchSkipRequests(skip func()) {
+	sigs := []os.Signal{syscall.SIGUSR1}
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		sigs = append(sigs, syscall.SIGQUIT)
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	go func() {
+		for range ch {
+			skip()
+		}
+	}()
+}
@@ -0,0 +1,7 @@
+//go:build windows
+
+package main
+
+// watchSkipRequests does nothing: Windows consoles deliver no signal that
+// could ask for a skip without also stopping the scan.
+func watchSkipRequests(skip func()) {}
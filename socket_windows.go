@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import "net"
+
+// listenPrivate listens on a unix socket. Windows has no umask; the socket
+// file takes the ACL of its directory.
+func listenPrivate(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
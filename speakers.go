@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// speakerRule extracts a speaker ID from a file path: either the folder at
+// a fixed depth below the root, or the first capture group of a regexp
+// matched against the slash-separated relative path.
+type speakerRule struct {
+	level int
+	re    *regexp.Regexp
+}
+
+func (r speakerRule) enabled() bool { return r.level > 0 || r.re != nil }
+
+func (r speakerRule) speakerOf(root, path string) (string, bool) {
+	if r.re != nil {
+		m := r.re.FindStringSubmatch(nfc(filepath.ToSlash(relPath(root, path))))
+		switch {
+		case m == nil:
+			return "", false
+		case len(m) > 1:
+			return m[1], m[1] != ""
+		default:
+			return m[0], true
+		}
+	}
+	parts := pathComponents(root, path)
+	if len(parts) < r.level {
+		return "", false
+	}
+	return parts[r.level-1], true
+}
+
+type speakerReport struct {
+	speakers     []groupStat
+	totalSeconds float64
+	unmatched    int
+	maxShare     float64
+	dominant     []groupStat
+	leaks        []string // speakers found in more than one split
+}
+
+func buildSpeakerReport(root string, audioFiles []string, durations []float64, rule speakerRule, maxShare float64, splitDirs map[string]string) speakerReport {
+	report := speakerReport{maxShare: maxShare}
+	report.speakers = groupDurations(audioFiles, durations, func(path string) (string, bool) {
+		return rule.speakerOf(root, path)
+	})
+	sortGroupsBySeconds(report.speakers)
+
+	for _, s := range report.speakers {
+		report.totalSeconds += s.seconds
+	}
+	for _, s := range report.speakers {
+		if percent(s.seconds, report.totalSeconds) > maxShare {
+			report.dominant = append(report.dominant, s)
+		}
+	}
+
+	// A speaker heard in more than one split leaks between train and test.
+	splitsBySpeaker := make(map[string]map[string]bool)
+	for _, path := range audioFiles {
+		speaker, ok := rule.speakerOf(root, path)
+		if !ok {
+			report.unmatched++
+			continue
+		}
+		split, ok := splitOf(root, path, splitDirs)
+		if !ok {
+			continue
+		}
+		if splitsBySpeaker[speaker] == nil {
+			splitsBySpeaker[speaker] = make(map[string]bool)
+		}
+		splitsBySpeaker[speaker][split] = true
+	}
+	for speaker, splits := range splitsBySpeaker {
+		if len(splits) < 2 {
+			continue
+		}
+		names := make([]string, 0, len(splits))
+		for s := range splits {
+			names = append(names, s)
+		}
+		sort.Strings(names)
+		report.leaks = append(report.leaks, fmt.Sprintf("%s (%s)", speaker, strings.Join(names, ", ")))
+	}
+	sort.Strings(report.leaks)
+	return report
+}
+
+func printSpeakerReport(report speakerReport) {
+	fmt.Fprintln(console, "\n=== Speakers ===")
+	fmt.Fprintf(console, "Speakers: %d\n", len(report.speakers))
+	if report.unmatched > 0 {
+		fmt.Fprintf(console, "Files without a speaker ID: %d\n", report.unmatched)
+	}
+	if len(report.speakers) == 0 {
+		return
+	}
+
+	hours := make([]float64, len(report.speakers))
+	for i, s := range report.speakers {
+		hours[i] = s.seconds / 3600.0
+	}
+	sort.Float64s(hours)
+	fmt.Fprintf(console, "Hours per speaker: min %.2f / median %.2f / mean %.2f / max %.2f\n",
+		hours[0], quantile(hours, 0.5), report.totalSeconds/3600.0/float64(len(hours)), hours[len(hours)-1])
+
+	fmt.Fprintf(console, "\n%-30s %8s %10s %8s\n", "Speaker", "Files", "Hours", "Share")
+	for i, s := range report.speakers {
+		if i == maxListed {
+			fmt.Fprintf(console, "... and %d more\n", len(report.speakers)-maxListed)
+			break
+		}
+		fmt.Fprintf(console, "%-30s %8d %10.2f %7.1f%%\n", s.key, s.files, s.seconds/3600.0, percent(s.seconds, report.totalSeconds))
+	}
+
+	for _, s := range report.dominant {
+		fmt.Fprintf(console, "Warning: speaker %s holds %.1f%% of all hours (threshold %.0f%%)\n", s.key, percent(s.seconds, report.totalSeconds), report.maxShare)
+	}
+	if len(report.leaks) > 0 {
+		fmt.Fprintf(console, "Warning: %d speakers appear in more than one split:\n", len(report.leaks))
+		printList(len(report.leaks), func(i int) string { return report.leaks[i] })
+	}
+}
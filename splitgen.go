@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// splitItem is a unit of assignment: a single file, or every file of one
+// speaker when grouping is enabled so no speaker leaks across splits.
+type splitItem struct {
+	key    string
+	files  []string
+	weight float64
+}
+
+// assignSplits distributes items greedily, largest first, to whichever
+// split is furthest below its target share.
+func assignSplits(items []splitItem, ratios []float64) [][]splitItem {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].weight != items[j].weight {
+			return items[i].weight > items[j].weight
+		}
+		return items[i].key < items[j].key
+	})
+
+	var total float64
+	for _, it := range items {
+		total += it.weight
+	}
+
+	assigned := make([][]splitItem, len(ratios))
+	filled := make([]float64, len(ratios))
+	for _, it := range items {
+		best := 0
+		for i := range ratios {
+			if ratios[i]*total-filled[i] > ratios[best]*total-filled[best] {
+				best = i
+			}
+		}
+		assigned[best] = append(assigned[best], it)
+		filled[best] += it.weight
+	}
+	return assigned
+}
+
+// splitFlags are the flags of the split subcommand.
+type splitFlags struct {
+	ratios, names, by, outDir string
+	groupLevel                int
+}
+
+func (f *splitFlags) define(fs *flag.FlagSet) {
+	fs.StringVar(&f.ratios, "ratios", "80/10/10", "split ratios")
+	fs.StringVar(&f.names, "names", "", "comma-separated split names (default train,dev,test or train,test)")
+	fs.StringVar(&f.by, "by", "duration", "balance splits by duration or count")
+	fs.IntVar(&f.groupLevel, "group-level", 0, "keep files sharing the folder at this depth below the root (e.g. speaker) in one split; 0 disables")
+	fs.StringVar(&f.outDir, "out", ".", "directory for the <split>.list files")
+}
+
+// runSplit implements "howManyHours split": it scans a tree and writes one
+// file list per split, balanced by duration or by file count.
+func runSplit(args []string) {
+	var flags splitFlags
+	fs := flag.NewFlagSet("split", flag.ExitOnError)
+	flags.define(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s split [flags] <folder_path>\n\nFlags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ratios, err := parseRatios(flags.ratios)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	splitLabels := []string{"train", "dev", "test"}
+	if len(ratios) == 2 {
+		splitLabels = []string{"train", "test"}
+	}
+	if flags.names != "" {
+		splitLabels = strings.Split(flags.names, ",")
+	}
+	if len(splitLabels) != len(ratios) {
+		fmt.Fprintf(os.Stderr, "Got %d ratios but %d split names\n", len(ratios), len(splitLabels))
+		os.Exit(2)
+	}
+	if flags.by != "duration" && flags.by != "count" {
+		fmt.Fprintf(os.Stderr, "Unsupported --by value: %s\n", flags.by)
+		os.Exit(2)
+	}
+
+	root, err := resolveRoot(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Error resolving path: %v\n", err)
+		os.Exit(1)
+	}
+	opts := options{order: "interleave"}
+	tree, err := walkTree(root, audioExtensions(opts), opts)
+	if err != nil {
+		fmt.Printf("Error reading directory: %v\n", err)
+		os.Exit(1)
+	}
+	if len(tree.audioFiles) == 0 {
+		fmt.Println("No audio files found in the folder.")
+		return
+	}
+
+	fmt.Printf("Found %d audio files. Processing with %d workers...\n\n", len(tree.audioFiles), numWorkers)
+	results := processFiles(tree.audioFiles, tree.sizes, opts)
+
+	index := make(map[string]int)
+	var items []splitItem
+	var skipped int
+	for i, path := range tree.audioFiles {
+		if results[i].err != nil {
+			skipped++
+			continue
+		}
+		key := path
+		if flags.groupLevel > 0 {
+			parts := pathComponents(root, path)
+			if len(parts) >= flags.groupLevel {
+				key = strings.Join(parts[:flags.groupLevel], "/")
+			}
+		}
+		n, ok := index[key]
+		if !ok {
+			n = len(items)
+			index[key] = n
+			items = append(items, splitItem{key: key})
+		}
+		items[n].files = append(items[n].files, path)
+		if flags.by == "duration" {
+			items[n].weight += results[i].duration
+		} else {
+			items[n].weight++
+		}
+	}
+
+	assigned := assignSplits(items, ratios)
+
+	var total float64
+	for _, it := range items {
+		total += it.weight
+	}
+	unit := "Hours"
+	if flags.by == "count" {
+		unit = "Files"
+	}
+
+	fmt.Println("\n=== Split ===")
+	fmt.Printf("%-10s %8s %8s %12s %8s %8s\n", "Split", "Groups", "Files", unit, "Target", "Actual")
+	for i, label := range splitLabels {
+		var weight float64
+		var files []string
+		for _, it := range assigned[i] {
+			weight += it.weight
+			files = append(files, it.files...)
+		}
+		sort.Strings(files)
+
+		listPath := filepath.Join(flags.outDir, label+".list")
+		if err := writeFileList(listPath, files); err != nil {
+			fmt.Printf("Error writing %s: %v\n", listPath, err)
+			os.Exit(1)
+		}
+
+		shown := weight
+		if flags.by == "duration" {
+			shown /= 3600.0
+		}
+		fmt.Printf("%-10s %8d %8d %12.2f %7.1f%% %7.1f%%\n", label, len(assigned[i]), len(files), shown, ratios[i]*100, percent(weight, total))
+	}
+	if skipped > 0 {
+		fmt.Printf("Skipped (could not be probed): %d\n", skipped)
+	}
+	fmt.Printf("\nFile lists written to %s\n", flags.outDir)
+}
+
+func writeFileList(path string, files []string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	for _, f := range files {
+		fmt.Fprintln(w, f)
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
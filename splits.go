@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// splitNames are the canonical splits, in report order.
+var splitNames = []string{"train", "dev", "test"}
+
+// defaultSplitDirs are the conventional directory names for each split.
+var defaultSplitDirs = map[string]string{
+	"train":      "train",
+	"training":   "train",
+	"dev":        "dev",
+	"valid":      "dev",
+	"validation": "dev",
+	"val":        "dev",
+	"test":       "test",
+	"eval":       "test",
+}
+
+// parseSplitMapping reads "train=tr,dev=cv|valid,test=tt" into a directory
+// name -> split table.
+func parseSplitMapping(s string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		split, dirs, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || split == "" || dirs == "" {
+			return nil, fmt.Errorf("invalid split mapping %q (want split=dir[|dir...])", part)
+		}
+		for _, d := range strings.Split(dirs, "|") {
+			mapping[strings.ToLower(nfc(d))] = split
+		}
+	}
+	return mapping, nil
+}
+
+// parseRatios reads "80/10/10" into fractions that sum to one.
+func parseRatios(s string) ([]float64, error) {
+	parts := strings.Split(s, "/")
+	ratios := make([]float64, len(parts))
+	var sum float64
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid ratios %q", s)
+		}
+		ratios[i] = v
+		sum += v
+	}
+	if sum == 0 {
+		return nil, fmt.Errorf("invalid ratios %q", s)
+	}
+	for i := range ratios {
+		ratios[i] /= sum
+	}
+	return ratios, nil
+}
+
+// splitOf returns the split of the first path component matching the
+// mapping, so "corpus/train/spk1/a.wav" lands in train.
+func splitOf(root, path string, mapping map[string]string) (string, bool) {
+	for _, part := range pathComponents(root, path) {
+		if split, ok := mapping[strings.ToLower(part)]; ok {
+			return split, true
+		}
+	}
+	return "", false
+}
+
+type splitReport struct {
+	splits          []groupStat
+	totalSeconds    float64
+	unassigned      int
+	unassignedHours float64
+	expected        []float64
+	tolerance       float64 // percentage points
+	warnings        []string
+}
+
+func buildSplitReport(root string, audioFiles []string, durations []float64, mapping map[string]string, expected []float64, tolerance float64) splitReport {
+	report := splitReport{expected: expected, tolerance: tolerance}
+	groups := groupDurations(audioFiles, durations, func(path string) (string, bool) {
+		return splitOf(root, path, mapping)
+	})
+
+	// Canonical splits first, then any custom names from --splits.
+	byName := make(map[string]groupStat, len(groups))
+	for _, g := range groups {
+		byName[g.key] = g
+		report.totalSeconds += g.seconds
+	}
+	for _, name := range splitNames {
+		report.splits = append(report.splits, groupStat{key: name, files: byName[name].files, seconds: byName[name].seconds})
+		delete(byName, name)
+	}
+	for _, g := range groups {
+		if _, custom := byName[g.key]; custom {
+			report.splits = append(report.splits, g)
+		}
+	}
+
+	for i, path := range audioFiles {
+		if _, ok := splitOf(root, path, mapping); !ok {
+			report.unassigned++
+			report.unassignedHours += durations[i] / 3600.0
+		}
+	}
+
+	for i, want := range expected {
+		if i >= len(report.splits) || report.totalSeconds == 0 {
+			break
+		}
+		got := report.splits[i].seconds / report.totalSeconds
+		if math.Abs(got-want)*100 > tolerance {
+			report.warnings = append(report.warnings, fmt.Sprintf("%s is %.1f%% of split hours, expected %.1f%%", report.splits[i].key, got*100, want*100))
+		}
+	}
+	return report
+}
+
+func printSplitReport(report splitReport) {
+	fmt.Fprintln(console, "\n=== Splits ===")
+	fmt.Fprintf(console, "%-12s %8s %10s %8s\n", "Split", "Files", "Hours", "Ratio")
+	for _, s := range report.splits {
+		fmt.Fprintf(console, "%-12s %8d %10.2f %7.1f%%\n", s.key, s.files, s.seconds/3600.0, percent(s.seconds, report.totalSeconds))
+	}
+	if report.unassigned > 0 {
+		fmt.Fprintf(console, "Files outside any split: %d (%.2f hours)\n", report.unassigned, report.unassignedHours)
+	}
+	for _, w := range report.warnings {
+		fmt.Fprintf(console, "Warning: %s (tolerance %.0f points)\n", w, report.tolerance)
+	}
+}
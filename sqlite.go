@@ -0,0 +1,103 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema is created on first use; later runs append to the same
+// tables, so one database holds the history of every scan written to it.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS scans (
+	id            INTEGER PRIMARY KEY,
+	time          TEXT NOT NULL,
+	root          TEXT NOT NULL,
+	files         INTEGER NOT NULL,
+	errors        INTEGER NOT NULL,
+	total_seconds REAL NOT NULL
+);
+CREATE TABLE IF NOT EXISTS files (
+	scan_id  INTEGER NOT NULL REFERENCES scans(id),
+	path     TEXT NOT NULL,
+	duration REAL NOT NULL,
+	size     INTEGER NOT NULL,
+	mtime    TEXT,
+	prober   TEXT
+);
+CREATE TABLE IF NOT EXISTS errors (
+	scan_id INTEGER NOT NULL REFERENCES scans(id),
+	path    TEXT NOT NULL,
+	message TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS files_scan ON files(scan_id);
+CREATE INDEX IF NOT EXISTS errors_scan ON errors(scan_id);
+`
+
+// writeSQLite records one scan, its files and its errors in a single
+// transaction. Paths are stored relative to root.
+func writeSQLite(path, root string, scanned time.Time, audioFiles []string, results []result) (int64, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var errorCount int
+	var totalSeconds float64
+	for _, res := range results {
+		if res.err != nil {
+			errorCount++
+		}
+		totalSeconds += res.duration
+	}
+	scan, err := tx.Exec(`INSERT INTO scans (time, root, files, errors, total_seconds) VALUES (?, ?, ?, ?, ?)`,
+		scanned.UTC().Format(time.RFC3339), root, len(audioFiles), errorCount, totalSeconds)
+	if err != nil {
+		return 0, err
+	}
+	scanID, err := scan.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	insertFile, err := tx.Prepare(`INSERT INTO files (scan_id, path, duration, size, mtime, prober) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer insertFile.Close()
+	insertError, err := tx.Prepare(`INSERT INTO errors (scan_id, path, message) VALUES (?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer insertError.Close()
+
+	for i, file := range audioFiles {
+		res := results[i]
+		rel := relPath(root, file)
+		if res.err != nil {
+			if _, err := insertError.Exec(scanID, rel, res.err.Error()); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		var mtime any
+		if !res.modTime.IsZero() {
+			mtime = res.modTime.UTC().Format(time.RFC3339)
+		}
+		if _, err := insertFile.Exec(scanID, rel, res.duration, res.size, mtime, res.prober); err != nil {
+			return 0, err
+		}
+	}
+	return scanID, tx.Commit()
+}
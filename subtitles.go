@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// subtitleExtensions are the caption formats cross-checked with --subtitles.
+var subtitleExtensions = map[string]bool{
+	".srt": true,
+	".vtt": true,
+}
+
+type subtitleFileReport struct {
+	audio        string
+	subtitle     string
+	duration     float64
+	covered      float64
+	largestGap   interval
+	gaps         int     // gaps longer than the configured threshold
+	overrunSecs  float64 // how far cues run past the end of the audio
+	cueCount     int
+	parseFailure error
+}
+
+type subtitleReport struct {
+	files     []subtitleFileReport
+	unpaired  []string // subtitle files with no matching audio
+	minGap    float64
+	overruns  int
+	failures  int
+	covered   float64
+	durations float64
+}
+
+var cueTiming = regexp.MustCompile(`((?:\d+:)?\d{1,2}:\d{2}[,.]\d{1,3})\s*-->\s*((?:\d+:)?\d{1,2}:\d{2}[,.]\d{1,3})`)
+
+// parseSubtitleCues extracts cue spans from SRT or WebVTT files. Both share
+// the "start --> end" timing line; they differ only in the decimal mark and
+// VTT's optional hours field.
+func parseSubtitleCues(path string) ([]interval, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var cues []interval
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		m := cueTiming.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		start, err1 := parseCueTime(m[1])
+		end, err2 := parseCueTime(m[2])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid cue timing %q", m[0])
+		}
+		cues = append(cues, interval{start, end})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("no cues found")
+	}
+	return cues, nil
+}
+
+// parseCueTime converts "HH:MM:SS,mmm" or "MM:SS.mmm" to seconds.
+func parseCueTime(s string) (float64, error) {
+	parts := strings.Split(strings.Replace(s, ",", ".", 1), ":")
+	var seconds float64
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return 0, err
+		}
+		seconds = seconds*60 + v
+	}
+	return seconds, nil
+}
+
+func buildSubtitleReport(audioFiles []string, durations []float64, subtitles []string, minGap float64) subtitleReport {
+	report := subtitleReport{minGap: minGap}
+
+	// Index by exact stem first, then also by the stem with a language tag
+	// removed so "talk.wav" pairs with "talk.en.vtt".
+	byStem := make(map[string]string, len(subtitles))
+	for _, s := range subtitles {
+		byStem[stem(s)] = s
+	}
+	for _, s := range subtitles {
+		key := stem(s)
+		if lang := filepath.Ext(key); len(lang) < 3 || len(lang) > 6 {
+			continue
+		}
+		if _, taken := byStem[stem(key)]; !taken {
+			byStem[stem(key)] = s
+		}
+	}
+
+	paired := make(map[string]bool)
+	for i, audio := range audioFiles {
+		sub, ok := byStem[stem(audio)]
+		if !ok {
+			continue
+		}
+		paired[sub] = true
+
+		f := subtitleFileReport{audio: audio, subtitle: sub, duration: durations[i]}
+		cues, err := parseSubtitleCues(sub)
+		if err != nil {
+			f.parseFailure = err
+			report.failures++
+			report.files = append(report.files, f)
+			continue
+		}
+		f.cueCount = len(cues)
+
+		merged := mergeIntervals(cues)
+		f.covered = coveredSeconds(merged)
+		if last := merged[len(merged)-1].end; last > f.duration && f.duration > 0 {
+			f.overrunSecs = last - f.duration
+			report.overruns++
+		}
+
+		// Gaps include the lead-in before the first cue and the tail after
+		// the last one, since a shifted caption file shows up there first.
+		prev := 0.0
+		bounds := append(merged, interval{f.duration, f.duration})
+		for _, span := range bounds {
+			if gap := span.start - prev; gap > 0 {
+				if gap > f.largestGap.end-f.largestGap.start {
+					f.largestGap = interval{prev, span.start}
+				}
+				if gap >= minGap {
+					f.gaps++
+				}
+			}
+			if span.end > prev {
+				prev = span.end
+			}
+		}
+
+		report.covered += min(f.covered, f.duration)
+		report.durations += f.duration
+		report.files = append(report.files, f)
+	}
+
+	for _, s := range subtitles {
+		if !paired[s] {
+			report.unpaired = append(report.unpaired, s)
+		}
+	}
+	sort.Strings(report.unpaired)
+
+	return report
+}
+
+func printSubtitleReport(root string, report subtitleReport) {
+	fmt.Fprintln(console, "\n=== Subtitles ===")
+	fmt.Fprintf(console, "Audio files with subtitles: %d\n", len(report.files))
+	fmt.Fprintf(console, "Overall coverage: %.1f%%\n", percent(report.covered, report.durations))
+	fmt.Fprintf(console, "Files with cues past the end of audio: %d\n", report.overruns)
+	if report.failures > 0 {
+		fmt.Fprintf(console, "Unreadable subtitle files: %d\n", report.failures)
+	}
+
+	if len(report.files) > 0 {
+		fmt.Fprintf(console, "\n%-40s %10s %9s %6s %22s %10s\n", "File", "Dur (min)", "Coverage", "Gaps", "Largest gap", "Overrun")
+		for i, f := range report.files {
+			if i == maxListed {
+				fmt.Fprintf(console, "... and %d more\n", len(report.files)-maxListed)
+				break
+			}
+			if f.parseFailure != nil {
+				fmt.Fprintf(console, "%-40s error: %v\n", relPath(root, f.audio), f.parseFailure)
+				continue
+			}
+			gap := fmt.Sprintf("%s-%s", formatClock(f.largestGap.start), formatClock(f.largestGap.end))
+			fmt.Fprintf(console, "%-40s %10.2f %8.1f%% %6d %22s %9.1fs\n",
+				relPath(root, f.audio), f.duration/60, percent(min(f.covered, f.duration), f.duration), f.gaps, gap, f.overrunSecs)
+		}
+		fmt.Fprintf(console, "(gaps counted when at least %.0fs long)\n", report.minGap)
+	}
+
+	if len(report.unpaired) > 0 {
+		fmt.Fprintln(console, "\nSubtitles without audio:")
+		printPathList(root, report.unpaired)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// scanSummary is the outcome of one scan as exposed to --format json,
+// --template and --output.
+type scanSummary struct {
+	Time         time.Time      `json:"time"`
+	Root         string         `json:"root"`
+	Shard        string         `json:"shard,omitempty"`
+	Files        int            `json:"files"`
+	Processed    int            `json:"processed"`
+	Zero         int            `json:"zero"`
+	Errors       int            `json:"errors"`
+	Placeholders int            `json:"placeholders,omitempty"`
+	Short        int            `json:"short,omitempty"`       // probed fine but under --short-duration
+	Provisional  int            `json:"provisional,omitempty"` // still being written (--growing)
+	TotalSeconds float64        `json:"total_seconds"`
+	TotalSecsInt *int64         `json:"total_seconds_int,omitempty"` // --integer-seconds
+	TotalHours   float64        `json:"total_hours"`
+	MeanSeconds  float64        `json:"mean_seconds"`
+	MedianSecs   float64        `json:"median_seconds"`
+	StatsOver    string         `json:"stats_over,omitempty"`
+	Oldest       time.Time      `json:"oldest"`
+	Newest       time.Time      `json:"newest"`
+	Resources    *resourceUsage `json:"resources,omitempty"`
+	Results      []fileSummary  `json:"results,omitempty"` // --json-files
+}
+
+// fileSummary is one scanned file; Error is empty on success. The per-file
+// results are only included with --json-files, which also fills in Codec,
+// SampleRate, Metadata and Chapters.
+type fileSummary struct {
+	Path       string         `json:"path"`
+	Duration   float64        `json:"duration"`
+	Size       int64          `json:"size"`
+	ModTime    time.Time      `json:"mtime"`
+	Prober     string         `json:"prober,omitempty"`
+	Codec      string         `json:"codec,omitempty"`
+	SampleRate int            `json:"sample_rate,omitempty"`
+	Metadata   map[string]any `json:"metadata,omitempty"`
+	Chapters   []chapterJSON  `json:"chapters,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// chapterJSON is one chapter of a file in --json-files output.
+type chapterJSON struct {
+	Title    string  `json:"title,omitempty"`
+	Start    float64 `json:"start"`
+	Duration float64 `json:"duration"`
+}
+
+func newScanSummary(root string, scanned time.Time, audioFiles []string, results []result, details bool, policy string) scanSummary {
+	agg := aggregateResults(results, policy)
+	s := scanSummary{
+		Time: scanned, Root: root, Files: len(audioFiles),
+		Processed: agg.valid, Zero: agg.zero, Errors: agg.failed,
+		TotalSeconds: agg.totalSeconds, TotalHours: agg.totalSeconds / 3600.0,
+		MeanSeconds: agg.meanSeconds, MedianSecs: agg.medianSecs, StatsOver: policy,
+	}
+	if details {
+		s.Results = make([]fileSummary, len(audioFiles))
+		for i, res := range results {
+			s.Results[i] = newFileSummary(root, audioFiles[i], res, details)
+		}
+	}
+	s.Oldest, s.Newest, _ = modTimeRange(results)
+	return s
+}
+
+// newFileSummary is the JSON form of one file's result.
+func newFileSummary(root, path string, res result, details bool) fileSummary {
+	f := fileSummary{Path: relPath(root, path), Duration: res.duration, Size: res.size, ModTime: res.modTime, Prober: res.prober}
+	if details {
+		f.Codec, f.SampleRate, f.Metadata = res.format.codec, res.format.sampleRate, res.metadata
+		for _, c := range res.chapters {
+			f.Chapters = append(f.Chapters, chapterJSON{Title: c.title, Start: c.start, Duration: c.duration})
+		}
+	}
+	if res.err != nil {
+		f.Error = res.err.Error()
+	}
+	return f
+}
+
+// rounded is s with its durations rounded to --precision, each in its own
+// unit, as the JSON summary reports them.
+func (s scanSummary) rounded(f numberFormat) scanSummary {
+	if !f.fixed {
+		return s
+	}
+	d := f.precision
+	s.TotalSeconds, s.TotalHours = f.round(s.TotalSeconds, d), f.round(s.TotalHours, d)
+	s.MeanSeconds, s.MedianSecs = f.round(s.MeanSeconds, d), f.round(s.MedianSecs, d)
+	s.Results = append([]fileSummary(nil), s.Results...)
+	for i := range s.Results {
+		s.Results[i].Duration = f.round(s.Results[i].Duration, d)
+	}
+	return s
+}
+
+// templateFuncs are available to --template alongside the builtins.
+var templateFuncs = template.FuncMap{
+	"clock": formatClock,
+}
+
+func parseTemplate(text string) (*template.Template, error) {
+	return template.New("template").Funcs(templateFuncs).Parse(text)
+}
+
+// writeSummary renders the summary in the selected format: a template, one
+// JSON object per line, or a one-line text record. Every format ends with a
+// newline so appended logs stay line-oriented.
+func writeSummary(w io.Writer, s scanSummary, opts options) error {
+	var out strings.Builder
+	s = s.rounded(opts.numbers)
+	switch {
+	case opts.tmpl != nil:
+		if err := opts.tmpl.Execute(&out, s); err != nil {
+			return err
+		}
+	case opts.format == "json":
+		line, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		out.Write(line)
+	default:
+		decimals := opts.numbers.places(2)
+		fmt.Fprintf(&out, "%s\t%s\t%d files\t%d errors\t%s hours",
+			s.Time.Format(time.RFC3339), s.Root, s.Files, s.Errors,
+			strconv.FormatFloat(opts.numbers.round(s.TotalHours, decimals), 'f', decimals, 64))
+		if s.TotalSecsInt != nil {
+			fmt.Fprintf(&out, "\t%d seconds", *s.TotalSecsInt)
+		}
+	}
+	if !strings.HasSuffix(out.String(), "\n") {
+		out.WriteString("\n")
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// writeExitLine writes the key=value line every scan ends with on stderr,
+// whatever --format says, so wrapper scripts can take the totals from the
+// last line of stderr without parsing the report. err, when the scan
+// failed, is given as error=.
+func writeExitLine(w io.Writer, s scanSummary, elapsed time.Duration, err error) error {
+	var out strings.Builder
+	out.WriteString("howmanyhours:")
+	field := func(key string, value any) {
+		text := fmt.Sprint(value)
+		if text == "" || strings.ContainsAny(text, " \t\"=\\") {
+			text = strconv.Quote(text)
+		}
+		fmt.Fprintf(&out, " %s=%s", key, text)
+	}
+	field("files", s.Files)
+	field("processed", s.Processed)
+	field("zero", s.Zero)
+	field("errors", s.Errors)
+	field("placeholders", s.Placeholders)
+	field("short", s.Short)
+	field("provisional", s.Provisional)
+	field("total_seconds", strconv.FormatFloat(s.TotalSeconds, 'f', 3, 64))
+	if s.TotalSecsInt != nil {
+		field("total_seconds_int", *s.TotalSecsInt)
+	}
+	field("total_hours", strconv.FormatFloat(s.TotalHours, 'f', 4, 64))
+	field("elapsed_seconds", strconv.FormatFloat(elapsed.Seconds(), 'f', 3, 64))
+	if u := s.Resources; u != nil {
+		field("cpu_seconds", strconv.FormatFloat(u.CPUSeconds, 'f', 3, 64))
+		field("peak_memory_bytes", u.PeakMemory)
+		field("bytes_read", u.BytesRead)
+	}
+	if s.Shard != "" {
+		field("shard", s.Shard)
+	}
+	field("root", s.Root)
+	if err != nil {
+		field("error", err)
+	}
+	out.WriteString("\n")
+	_, err = io.WriteString(w, out.String())
+	return err
+}
+
+// writeSummaryFile writes the summary to path, appending when asked so
+// repeated runs build a rolling log.
+func writeSummaryFile(path string, appendTo bool, s scanSummary, opts options) error {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendTo {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := writeSummary(file, s, opts); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
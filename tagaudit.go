@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tagDuration returns the duration a file's tags declare, in seconds, and
+// which tag declared it: ID3 TLEN for MP3, the sample count of iTunes'
+// iTunSMPB for MP4 audio. Zero means no such tag.
+func tagDuration(filePath string) (float64, string) {
+	file, err := openAudio(filePath)
+	if err != nil {
+		return 0, ""
+	}
+	defer file.Close()
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".mp3":
+		var hdr [10]byte
+		if _, err := file.ReadAt(hdr[:], 0); err != nil || string(hdr[0:3]) != "ID3" {
+			return 0, ""
+		}
+		size := syncsafe(hdr[6:10])
+		if size > maxID3Tag || (hdr[3] != 3 && hdr[3] != 4) {
+			return 0, ""
+		}
+		tag := make([]byte, size)
+		if _, err := file.ReadAt(tag, 10); err != nil {
+			return 0, ""
+		}
+		for _, f := range id3Frames(tag, hdr[3]) {
+			if f.id == "TLEN" {
+				if ms, err := strconv.ParseFloat(strings.TrimSpace(id3Text(f.body)), 64); err == nil && ms > 0 {
+					return ms / 1000, "TLEN"
+				}
+			}
+		}
+	case ".m4a", ".m4b", ".aax":
+		mp4, err := parseMP4(file)
+		if err != nil || mp4.itunSMPB == "" {
+			return 0, ""
+		}
+		// " 00000000 <delay> <padding> <samples> ...", all hexadecimal.
+		fields := strings.Fields(mp4.itunSMPB)
+		if len(fields) < 4 {
+			return 0, ""
+		}
+		samples, err := strconv.ParseUint(fields[3], 16, 64)
+		if err != nil || samples == 0 {
+			return 0, ""
+		}
+		for _, t := range mp4.audioTracks() {
+			if t.timescale > 0 {
+				return float64(samples) / float64(t.timescale), "iTunSMPB"
+			}
+		}
+	}
+	return 0, ""
+}
+
+type tagMismatch struct {
+	path     string
+	tag      string
+	declared float64
+	measured float64
+}
+
+// tagAuditReport compares tag-declared durations with probed ones; large
+// gaps usually mean a corrupt or mis-tagged file.
+type tagAuditReport struct {
+	checked    int
+	mismatches []tagMismatch
+	tolerance  float64
+}
+
+func buildTagAuditReport(audioFiles []string, results []result, tolerance float64) tagAuditReport {
+	report := tagAuditReport{tolerance: tolerance}
+	for i, res := range results {
+		if res.err != nil || res.tagDuration <= 0 {
+			continue
+		}
+		report.checked++
+		if math.Abs(res.tagDuration-res.duration) > tolerance {
+			report.mismatches = append(report.mismatches, tagMismatch{path: audioFiles[i], tag: res.tagSource, declared: res.tagDuration, measured: res.duration})
+		}
+	}
+	sort.Slice(report.mismatches, func(i, j int) bool {
+		a, b := report.mismatches[i], report.mismatches[j]
+		return math.Abs(a.declared-a.measured) > math.Abs(b.declared-b.measured)
+	})
+	return report
+}
+
+func printTagAuditReport(root string, report tagAuditReport) {
+	fmt.Fprintln(console, "\n=== Tag durations ===")
+	fmt.Fprintf(console, "Files with a duration tag (TLEN, iTunSMPB): %d\n", report.checked)
+	fmt.Fprintf(console, "Tag differs from measured by more than %.1fs: %d\n", report.tolerance, len(report.mismatches))
+	printList(len(report.mismatches), func(i int) string {
+		m := report.mismatches[i]
+		return fmt.Sprintf("%s (%s says %s, measured %s)", relPath(root, m.path), m.tag, formatClock(m.declared), formatClock(m.measured))
+	})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultTakePattern matches the per-track suffix field recorders add to
+// each file of a polyphonic take: TAKE01_T1, SC12-1_Tr2, 240501_003_4,
+// TAKE01_TrLR. The first group is the take.
+const defaultTakePattern = `(?i)^(.+?)[_-](?:t|tr|trk|ch)?(?:\d{1,2}|lr|ms)$`
+
+// takeTolerance is how far apart, in seconds, the tracks of one take may
+// be. Files sharing a prefix but not a length are separate recordings.
+const takeTolerance = 1.0
+
+type take struct {
+	key     string
+	tracks  int
+	seconds float64 // the take's length, not the sum over its tracks
+}
+
+// takeReport compares hours summed over files with hours of unique takes,
+// so an eight-track recorder does not count every minute eight times.
+type takeReport struct {
+	rawSeconds  float64
+	takeSeconds float64
+	files       int
+	takes       []take
+	multiTrack  int
+}
+
+func buildTakeReport(audioFiles []string, durations []float64, re *regexp.Regexp) takeReport {
+	var report takeReport
+	groups := make(map[string][]int)
+	var keys []string
+	for i, p := range audioFiles {
+		if durations[i] <= 0 {
+			continue
+		}
+		report.files++
+		report.rawSeconds += durations[i]
+		stem := nfc(strings.TrimSuffix(filepath.Base(p), filepath.Ext(p)))
+		key := p
+		if m := re.FindStringSubmatch(stem); len(m) > 1 && m[1] != "" {
+			key = filepath.Join(filepath.Dir(p), m[1])
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	for _, key := range keys {
+		members := groups[key]
+		// Take the longest track as the take; members that are not the
+		// same length are separate recordings that happen to share a
+		// prefix, and count on their own.
+		sort.Slice(members, func(a, b int) bool { return durations[members[a]] > durations[members[b]] })
+		t := take{key: key, seconds: durations[members[0]]}
+		for _, i := range members {
+			if math.Abs(durations[i]-t.seconds) <= takeTolerance {
+				t.tracks++
+				continue
+			}
+			report.takes = append(report.takes, take{key: audioFiles[i], tracks: 1, seconds: durations[i]})
+			report.takeSeconds += durations[i]
+		}
+		report.takes = append(report.takes, t)
+		report.takeSeconds += t.seconds
+		if t.tracks > 1 {
+			report.multiTrack++
+		}
+	}
+	return report
+}
+
+func printTakeReport(root string, report takeReport) {
+	fmt.Fprintln(console, "\n=== Polyphonic takes ===")
+	fmt.Fprintf(console, "Files: %d (%.2f hours)\n", report.files, report.rawSeconds/3600.0)
+	fmt.Fprintf(console, "Unique takes: %d (%.2f hours)\n", len(report.takes), report.takeSeconds/3600.0)
+	fmt.Fprintf(console, "Multi-track takes: %d\n", report.multiTrack)
+	if report.takeSeconds > 0 {
+		fmt.Fprintf(console, "File hours are %.1f× take hours\n", report.rawSeconds/report.takeSeconds)
+	}
+
+	var multi []take
+	for _, t := range report.takes {
+		if t.tracks > 1 {
+			multi = append(multi, t)
+		}
+	}
+	sort.Slice(multi, func(i, j int) bool { return multi[i].key < multi[j].key })
+	if len(multi) == 0 {
+		return
+	}
+	fmt.Fprintln(console)
+	printList(len(multi), func(i int) string {
+		return fmt.Sprintf("%s (%d tracks, %s)", relPath(root, multi[i].key), multi[i].tracks, formatClock(multi[i].seconds))
+	})
+}
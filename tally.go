@@ -0,0 +1,44 @@
+package main
+
+import (
+	"time"
+)
+
+// progressInterval bounds how often a worker touches the shared progress
+// bar. The bar takes a mutex on every Add, which on many-core machines
+// becomes the point every worker queues on.
+const progressInterval = 100 * time.Millisecond
+
+// tally is one worker's local accumulation. Workers write results straight
+// into their own slots of the shared slice and only publish to the shared
+// counters (progress, busy time) every progressInterval and when they exit,
+// so the hot path never synchronises with other workers.
+type tally struct {
+	progress progressMeter
+	pending  int
+	busy     time.Duration
+	flushed  time.Time
+}
+
+func newTally(progress progressMeter) *tally {
+	return &tally{progress: progress, flushed: time.Now()}
+}
+
+// record counts one finished file that took the time since start.
+func (t *tally) record(start time.Time) {
+	now := time.Now()
+	t.busy += now.Sub(start)
+	t.pending++
+	if now.Sub(t.flushed) >= progressInterval {
+		t.flush()
+	}
+}
+
+// flush publishes what the worker has accumulated since the last flush.
+func (t *tally) flush() {
+	if t.pending > 0 {
+		t.progress.Add(t.pending)
+	}
+	workerBusyNanos.Add(int64(t.busy))
+	t.pending, t.busy, t.flushed = 0, 0, time.Now()
+}
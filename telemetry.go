@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenTelemetry export: with --otlp (or OTEL_EXPORTER_OTLP_ENDPOINT) a scan
+// sends one trace, a "scan" span with "walk", "probe" and "aggregate"
+// children, and a gauge per stage timing and total to an OTLP/HTTP
+// collector, in the protocol's JSON encoding. A TRACEPARENT from the
+// environment makes the scan a child of the platform job that started it.
+// Everything is sent once the scan ends, so a killed scan exports nothing.
+
+type otlpSpan struct {
+	name       string
+	id         [8]byte
+	start, end time.Time
+	attrs      map[string]any
+}
+
+type telemetry struct {
+	endpoint string
+	headers  map[string]string
+	service  string
+	traceID  [16]byte
+	parentID [8]byte // from TRACEPARENT; zero for a root trace
+	rootID   [8]byte
+	start    time.Time
+	spans    []otlpSpan
+}
+
+// newTelemetry returns nil when no endpoint is configured; the methods of
+// a nil *telemetry do nothing.
+func newTelemetry(endpoint string) *telemetry {
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if endpoint == "" {
+		return nil
+	}
+	t := &telemetry{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		headers:  parseOTLPHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+		service:  os.Getenv("OTEL_SERVICE_NAME"),
+		start:    time.Now(),
+	}
+	if t.service == "" {
+		t.service = "howManyHours"
+	}
+	rand.Read(t.traceID[:])
+	rand.Read(t.rootID[:])
+	// traceparent: version-traceid-parentid-flags
+	if parts := strings.Split(os.Getenv("TRACEPARENT"), "-"); len(parts) == 4 && len(parts[1]) == 32 && len(parts[2]) == 16 {
+		trace, err1 := hex.DecodeString(parts[1])
+		parent, err2 := hex.DecodeString(parts[2])
+		if err1 == nil && err2 == nil {
+			copy(t.traceID[:], trace)
+			copy(t.parentID[:], parent)
+		}
+	}
+	return t
+}
+
+// parseOTLPHeaders reads the "key=value,key2=value2" form of
+// OTEL_EXPORTER_OTLP_HEADERS.
+func parseOTLPHeaders(s string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if k, v, ok := strings.Cut(pair, "="); ok {
+			headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return headers
+}
+
+// stage records one stage of the scan as a child span.
+func (t *telemetry) stage(name string, start, end time.Time, attrs map[string]any) {
+	if t == nil {
+		return
+	}
+	s := otlpSpan{name: name, start: start, end: end, attrs: attrs}
+	rand.Read(s.id[:])
+	t.spans = append(t.spans, s)
+}
+
+// finish closes the scan span and exports the trace and the metrics.
+func (t *telemetry) finish(summary scanSummary, perf perfReport) error {
+	if t == nil {
+		return nil
+	}
+	end := time.Now()
+	root := otlpSpan{name: "scan", id: t.rootID, start: t.start, end: end, attrs: map[string]any{
+		"howmanyhours.root":          summary.Root,
+		"howmanyhours.files":         summary.Files,
+		"howmanyhours.errors":        summary.Errors,
+		"howmanyhours.audio_seconds": summary.TotalSeconds,
+		"howmanyhours.bytes_read":    perf.bytes,
+		"howmanyhours.workers":       perf.workers,
+	}}
+
+	spans := []any{t.spanJSON(root, t.parentID)}
+	for _, s := range t.spans {
+		spans = append(spans, t.spanJSON(s, t.rootID))
+	}
+	traces := map[string]any{"resourceSpans": []any{map[string]any{
+		"resource":   t.resourceJSON(),
+		"scopeSpans": []any{map[string]any{"scope": otlpScope(), "spans": spans}},
+	}}}
+	if err := t.post("/v1/traces", traces); err != nil {
+		return err
+	}
+
+	now := strconv.FormatInt(end.UnixNano(), 10)
+	gauge := func(name, unit string, value float64, attrs map[string]any) any {
+		return map[string]any{"name": name, "unit": unit, "gauge": map[string]any{"dataPoints": []any{map[string]any{
+			"asDouble": value, "timeUnixNano": now, "attributes": otlpAttributes(attrs),
+		}}}}
+	}
+	metrics := []any{
+		gauge("howmanyhours.files", "{file}", float64(summary.Files), nil),
+		gauge("howmanyhours.errors", "{file}", float64(summary.Errors), nil),
+		gauge("howmanyhours.audio", "s", summary.TotalSeconds, nil),
+		gauge("howmanyhours.read", "By", float64(perf.bytes), nil),
+		gauge("howmanyhours.duration", "s", end.Sub(t.start).Seconds(), nil),
+	}
+	for _, s := range t.spans {
+		metrics = append(metrics, gauge("howmanyhours.stage.duration", "s", s.end.Sub(s.start).Seconds(), map[string]any{"stage": s.name}))
+	}
+	return t.post("/v1/metrics", map[string]any{"resourceMetrics": []any{map[string]any{
+		"resource":     t.resourceJSON(),
+		"scopeMetrics": []any{map[string]any{"scope": otlpScope(), "metrics": metrics}},
+	}}})
+}
+
+func (t *telemetry) spanJSON(s otlpSpan, parent [8]byte) map[string]any {
+	span := map[string]any{
+		"traceId":           hex.EncodeToString(t.traceID[:]),
+		"spanId":            hex.EncodeToString(s.id[:]),
+		"name":              s.name,
+		"kind":              1, // SPAN_KIND_INTERNAL
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		"attributes":        otlpAttributes(s.attrs),
+	}
+	if parent != [8]byte{} {
+		span["parentSpanId"] = hex.EncodeToString(parent[:])
+	}
+	return span
+}
+
+func (t *telemetry) resourceJSON() map[string]any {
+	host, _ := os.Hostname()
+	return map[string]any{"attributes": otlpAttributes(map[string]any{
+		"service.name": t.service,
+		"host.name":    host,
+		"process.pid":  os.Getpid(),
+	})}
+}
+
+func otlpScope() map[string]any {
+	return map[string]any{"name": "howManyHours"}
+}
+
+// otlpAttributes encodes attributes as OTLP AnyValues; 64-bit integers are
+// strings in the JSON encoding.
+func otlpAttributes(attrs map[string]any) []any {
+	out := []any{}
+	for k, v := range attrs {
+		var value map[string]any
+		switch v := v.(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, map[string]any{"key": k, "value": value})
+	}
+	return out
+}
+
+func (t *telemetry) post(path string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, t.endpoint+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s%s: %s", t.endpoint, path, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// readThrottle caps the bandwidth and operation rate of audioFile reads
+// across all workers, so a scan can share a NAS with its other users. A nil
+// limiter leaves that dimension unlimited.
+type readThrottle struct {
+	bytes *rate.Limiter
+	ops   *rate.Limiter
+}
+
+// throttle is configured from --max-read-mbps and --max-iops.
+var throttle readThrottle
+
+// newReadThrottle builds the limiters; mbps is in megabits per second, the
+// unit network links are sold in.
+func newReadThrottle(mbps, iops float64) readThrottle {
+	var t readThrottle
+	if mbps > 0 {
+		bytesPerSecond := mbps * 1e6 / 8
+		// A tenth of a second of burst keeps reads smooth without letting
+		// a large buffer blow through the cap.
+		t.bytes = rate.NewLimiter(rate.Limit(bytesPerSecond), max(int(bytesPerSecond/10), 4096))
+	}
+	if iops > 0 {
+		t.ops = rate.NewLimiter(rate.Limit(iops), max(int(iops/10), 1))
+	}
+	return t
+}
+
+// wait blocks until a read of n bytes is allowed.
+func (t readThrottle) wait(n int) {
+	if t.ops != nil {
+		t.ops.Wait(context.Background())
+	}
+	if t.bytes == nil {
+		return
+	}
+	for burst := t.bytes.Burst(); n > 0; n -= burst {
+		t.bytes.WaitN(context.Background(), min(n, burst))
+	}
+}
@@ -0,0 +1,587 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Tracker modules (MOD, S3M, XM, IT) store note patterns rather than audio,
+// so their length is found by playing the order list through, honouring
+// the effects that move time: speed and tempo changes, position jumps,
+// pattern breaks, pattern loops and row delays.
+
+// trackerEffect is a timing effect, whatever its letter in the format.
+type trackerEffect byte
+
+const (
+	fxNone      trackerEffect = iota
+	fxSpeed                   // ticks per row
+	fxTempo                   // BPM: a tick lasts 2.5/BPM seconds
+	fxJump                    // continue at an order
+	fxBreak                   // continue at a row of the next order
+	fxLoop                    // pattern loop: 0 marks the start, n repeats n times
+	fxRowDelay                // repeat the row's ticks n more times
+	fxTickDelay               // add n ticks to the row
+	fxStop                    // end of song (ProTracker F00)
+)
+
+// Order list entries that are not patterns.
+const (
+	orderSkip = -1 // S3M/IT "+++" marker
+	orderEnd  = -2 // S3M/IT "---" end of song
+)
+
+// maxTrackerRows bounds the rows played, for songs whose jumps never
+// revisit a row in the same state.
+const maxTrackerRows = 1 << 20
+
+// trackerRows is the default pattern length of MOD, S3M and empty
+// patterns.
+const trackerRows = 64
+
+// Bounds on what a module may make the parser keep. Order list entries are
+// bytes, so no pattern past the 256th can be played, and no format allows
+// more than 256 rows in a pattern; together they cap the rows held at
+// 65536 however many patterns the header claims.
+const (
+	maxTrackerPatterns    = 256
+	maxTrackerPatternRows = 256
+)
+
+type trackerCell struct {
+	channel int
+	effect  trackerEffect
+	param   int
+}
+
+// trackerPattern holds the timing effects of each row.
+type trackerPattern [][]trackerCell
+
+type trackerSong struct {
+	orders   []int
+	patterns []trackerPattern
+	speed    int
+	tempo    int
+}
+
+func (s *trackerSong) pattern(order int) trackerPattern {
+	if p := s.orders[order]; p < len(s.patterns) && s.patterns[p] != nil {
+		return s.patterns[p]
+	}
+	return make(trackerPattern, trackerRows)
+}
+
+// seconds plays the song from the first order until it ends or comes back
+// to a row it has already played outside a pattern loop, which is where a
+// player would start repeating.
+func (s *trackerSong) seconds() float64 {
+	speed, tempo := s.speed, s.tempo
+	if speed <= 0 {
+		speed = 6
+	}
+	if tempo <= 0 {
+		tempo = 125
+	}
+	type position struct{ order, row int }
+	visited := make(map[position]bool)
+	loopRow := make(map[int]int)
+	loopCount := make(map[int]int)
+	var seconds float64
+	order, row := 0, 0
+	for played := 0; played < maxTrackerRows; played++ {
+		for order < len(s.orders) && s.orders[order] == orderSkip {
+			order++
+		}
+		if order >= len(s.orders) || s.orders[order] == orderEnd {
+			break
+		}
+		pattern := s.pattern(order)
+		if row >= len(pattern) {
+			row = 0
+		}
+		looping := false
+		for _, n := range loopCount {
+			looping = looping || n > 0
+		}
+		if at := (position{order, row}); !looping {
+			if visited[at] {
+				break
+			}
+			visited[at] = true
+		}
+
+		jump, breakRow, loopTo := -1, -1, -1
+		rowDelay, tickDelay := 0, 0
+		stop := false
+		for _, c := range pattern[row] {
+			switch c.effect {
+			case fxSpeed:
+				if c.param > 0 {
+					speed = c.param
+				}
+			case fxTempo:
+				if c.param >= 32 {
+					tempo = c.param
+				}
+			case fxJump:
+				jump = c.param
+			case fxBreak:
+				breakRow = c.param
+			case fxLoop:
+				switch {
+				case c.param == 0:
+					loopRow[c.channel] = row
+				case loopCount[c.channel] == 0:
+					loopCount[c.channel] = c.param
+					loopTo = loopRow[c.channel]
+				default:
+					loopCount[c.channel]--
+					if loopCount[c.channel] > 0 {
+						loopTo = loopRow[c.channel]
+					}
+				}
+			case fxRowDelay:
+				rowDelay = max(rowDelay, c.param)
+			case fxTickDelay:
+				tickDelay += c.param
+			case fxStop:
+				stop = true
+			}
+		}
+		seconds += float64(speed*(1+rowDelay)+tickDelay) * 2.5 / float64(tempo)
+		if stop {
+			break
+		}
+
+		switch {
+		case loopTo >= 0:
+			row = loopTo
+		case jump >= 0 || breakRow >= 0:
+			if jump >= 0 {
+				order = jump
+			} else {
+				order++
+			}
+			row = max(breakRow, 0)
+			clear(loopRow)
+			clear(loopCount)
+		default:
+			row++
+			if row >= len(pattern) {
+				order++
+				row = 0
+				clear(loopRow)
+				clear(loopCount)
+			}
+		}
+	}
+	return seconds
+}
+
+var errTruncatedModule = errors.New("module truncated")
+
+func readBlock(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := r.ReadAt(b, off); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errTruncatedModule
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// bcd decodes the decimal-in-hex row numbers of MOD, S3M and XM pattern
+// breaks.
+func bcd(param int) int {
+	return param>>4*10 + param&0x0f
+}
+
+// modChannels is the channel count a MOD signature announces, or 0.
+func modChannels(sig string) int {
+	switch sig {
+	case "M.K.", "M!K!", "M&K!", "FLT4", "4CHN":
+		return 4
+	case "FLT8", "OKTA", "CD81":
+		return 8
+	}
+	if sig[1:] == "CHN" && sig[0] >= '1' && sig[0] <= '9' {
+		return int(sig[0] - '0')
+	}
+	if (sig[2:] == "CH" || sig[2:] == "CN") && sig[0] >= '0' && sig[0] <= '9' && sig[1] >= '0' && sig[1] <= '9' {
+		return int(sig[0]-'0')*10 + int(sig[1]-'0')
+	}
+	return 0
+}
+
+// parseMOD reads a 31-sample ProTracker module and its multichannel
+// variants.
+func parseMOD(r io.ReaderAt) (*trackerSong, error) {
+	hdr, err := readBlock(r, 0, 1084)
+	if err != nil {
+		return nil, err
+	}
+	channels := modChannels(string(hdr[1080:1084]))
+	if channels == 0 {
+		return nil, errors.New("unrecognised MOD signature")
+	}
+	length := int(hdr[950])
+	if length == 0 || length > 128 {
+		return nil, fmt.Errorf("invalid MOD song length %d", length)
+	}
+	song := &trackerSong{speed: 6, tempo: 125}
+	var patterns int
+	for i, p := range hdr[952:1080] {
+		if i < length {
+			song.orders = append(song.orders, int(p))
+		}
+		patterns = max(patterns, int(p)+1)
+	}
+	size := trackerRows * channels * 4
+	for p := 0; p < patterns; p++ {
+		data, err := readBlock(r, 1084+int64(p*size), size)
+		if err != nil {
+			break
+		}
+		pattern := make(trackerPattern, trackerRows)
+		for row := range pattern {
+			for ch := 0; ch < channels; ch++ {
+				cell := data[(row*channels+ch)*4:]
+				if c, ok := modEffect(ch, int(cell[2]&0x0f), int(cell[3])); ok {
+					pattern[row] = append(pattern[row], c)
+				}
+			}
+		}
+		song.patterns = append(song.patterns, pattern)
+	}
+	return song, nil
+}
+
+// modEffect maps a MOD or XM effect to its timing effect.
+func modEffect(channel, effect, param int) (trackerCell, bool) {
+	c := trackerCell{channel: channel, param: param}
+	switch {
+	case effect == 0xB:
+		c.effect = fxJump
+	case effect == 0xD:
+		c.effect, c.param = fxBreak, bcd(param)
+	case effect == 0xF && param == 0:
+		c.effect = fxStop
+	case effect == 0xF && param < 0x20:
+		c.effect = fxSpeed
+	case effect == 0xF:
+		c.effect = fxTempo
+	case effect == 0xE && param>>4 == 0x6:
+		c.effect, c.param = fxLoop, param&0x0f
+	case effect == 0xE && param>>4 == 0xE:
+		c.effect, c.param = fxRowDelay, param&0x0f
+	default:
+		return c, false
+	}
+	return c, true
+}
+
+// parseXM reads a FastTracker 2 module.
+func parseXM(r io.ReaderAt) (*trackerSong, error) {
+	hdr, err := readBlock(r, 0, 80)
+	if err != nil {
+		return nil, err
+	}
+	if string(hdr[0:17]) != "Extended Module: " {
+		return nil, errors.New("invalid XM file")
+	}
+	headerSize := int64(binary.LittleEndian.Uint32(hdr[60:64]))
+	length := int(binary.LittleEndian.Uint16(hdr[64:66]))
+	patterns := int(binary.LittleEndian.Uint16(hdr[70:72]))
+	if headerSize < 20+4 || length > 256 {
+		return nil, errors.New("invalid XM header")
+	}
+	orders, err := readBlock(r, 80, min(length, 256))
+	if err != nil {
+		return nil, err
+	}
+	song := &trackerSong{
+		speed: int(binary.LittleEndian.Uint16(hdr[76:78])),
+		tempo: int(binary.LittleEndian.Uint16(hdr[78:80])),
+	}
+	for _, p := range orders {
+		song.orders = append(song.orders, int(p))
+	}
+	pos := 60 + headerSize
+	for p := 0; p < min(patterns, maxTrackerPatterns); p++ {
+		ph, err := readBlock(r, pos, 9)
+		if err != nil {
+			break
+		}
+		phLen := int64(binary.LittleEndian.Uint32(ph[0:4]))
+		rows := int(binary.LittleEndian.Uint16(ph[5:7]))
+		packed := int(binary.LittleEndian.Uint16(ph[7:9]))
+		if phLen+int64(packed) <= 0 {
+			// The next header would be this one again.
+			return nil, errors.New("invalid XM pattern header")
+		}
+		data, err := readBlock(r, pos+phLen, packed)
+		if err != nil {
+			break
+		}
+		song.patterns = append(song.patterns, unpackXM(data, rows, int(binary.LittleEndian.Uint16(hdr[68:70]))))
+		pos += phLen + int64(packed)
+	}
+	return song, nil
+}
+
+// unpackXM decodes packed XM pattern data. An empty pattern (no packed
+// data) is rows of nothing.
+func unpackXM(data []byte, rows, channels int) trackerPattern {
+	pattern := make(trackerPattern, min(max(rows, 1), maxTrackerPatternRows))
+	channels = max(channels, 1)
+	i := 0
+	for cell := 0; cell < len(pattern)*channels && i < len(data); cell++ {
+		var fields [5]int
+		mask := 0x1f
+		if data[i]&0x80 != 0 {
+			mask = int(data[i] & 0x1f)
+			i++
+		}
+		for f := 0; f < 5; f++ {
+			if mask&(1<<f) != 0 && i < len(data) {
+				fields[f] = int(data[i])
+				i++
+			}
+		}
+		row, ch := cell/channels, cell%channels
+		if c, ok := modEffect(ch, fields[3], fields[4]); ok {
+			if c.effect == fxStop {
+				// FastTracker 2 ignores F00.
+				continue
+			}
+			pattern[row] = append(pattern[row], c)
+		}
+	}
+	return pattern
+}
+
+// parseS3M reads a Scream Tracker 3 module.
+func parseS3M(r io.ReaderAt) (*trackerSong, error) {
+	hdr, err := readBlock(r, 0, 0x60)
+	if err != nil {
+		return nil, err
+	}
+	if string(hdr[44:48]) != "SCRM" {
+		return nil, errors.New("invalid S3M file")
+	}
+	ordNum := int(binary.LittleEndian.Uint16(hdr[32:34]))
+	insNum := int(binary.LittleEndian.Uint16(hdr[34:36]))
+	patNum := int(binary.LittleEndian.Uint16(hdr[36:38]))
+	tables, err := readBlock(r, 0x60, ordNum+insNum*2+patNum*2)
+	if err != nil {
+		return nil, err
+	}
+	song := &trackerSong{speed: int(hdr[49]), tempo: int(hdr[50]), orders: screamOrders(tables[:ordNum])}
+	pointers := tables[ordNum+insNum*2:]
+	for p := 0; p < min(patNum, maxTrackerPatterns); p++ {
+		off := int64(binary.LittleEndian.Uint16(pointers[p*2:])) * 16
+		if off == 0 {
+			song.patterns = append(song.patterns, nil)
+			continue
+		}
+		lenField, err := readBlock(r, off, 2)
+		if err != nil {
+			song.patterns = append(song.patterns, nil)
+			continue
+		}
+		data, err := readBlock(r, off+2, max(int(binary.LittleEndian.Uint16(lenField))-2, 0))
+		if err != nil {
+			song.patterns = append(song.patterns, nil)
+			continue
+		}
+		song.patterns = append(song.patterns, unpackS3M(data))
+	}
+	return song, nil
+}
+
+// screamOrders converts S3M/IT order bytes, where 254 is a skip marker and
+// 255 ends the song.
+func screamOrders(b []byte) []int {
+	orders := make([]int, len(b))
+	for i, p := range b {
+		switch p {
+		case 254:
+			orders[i] = orderSkip
+		case 255:
+			orders[i] = orderEnd
+		default:
+			orders[i] = int(p)
+		}
+	}
+	return orders
+}
+
+func unpackS3M(data []byte) trackerPattern {
+	pattern := make(trackerPattern, trackerRows)
+	row := 0
+	for i := 0; i < len(data) && row < trackerRows; {
+		what := data[i]
+		i++
+		if what == 0 {
+			row++
+			continue
+		}
+		if what&0x20 != 0 {
+			i += 2
+		}
+		if what&0x40 != 0 {
+			i++
+		}
+		if what&0x80 != 0 && i+1 < len(data) {
+			if c, ok := screamEffect(int(what&0x1f), int(data[i]), int(data[i+1]), true); ok {
+				pattern[row] = append(pattern[row], c)
+			}
+			i += 2
+		}
+	}
+	return pattern
+}
+
+// screamEffect maps an S3M or IT command (1 for A) to its timing effect.
+// S3M writes pattern break rows in decimal-in-hex, IT in plain hex.
+func screamEffect(channel, command, param int, s3m bool) (trackerCell, bool) {
+	c := trackerCell{channel: channel, param: param}
+	switch {
+	case command == 1:
+		c.effect = fxSpeed
+	case command == 2:
+		c.effect = fxJump
+	case command == 3 && s3m:
+		c.effect, c.param = fxBreak, bcd(param)
+	case command == 3:
+		c.effect = fxBreak
+	case command == 20:
+		c.effect = fxTempo
+	case command == 19 && param>>4 == 0xB:
+		c.effect, c.param = fxLoop, param&0x0f
+	case command == 19 && param>>4 == 0xE:
+		c.effect, c.param = fxRowDelay, param&0x0f
+	case command == 19 && param>>4 == 0x6 && !s3m:
+		c.effect, c.param = fxTickDelay, param&0x0f
+	default:
+		return c, false
+	}
+	return c, true
+}
+
+// parseIT reads an Impulse Tracker module.
+func parseIT(r io.ReaderAt) (*trackerSong, error) {
+	hdr, err := readBlock(r, 0, 0xC0)
+	if err != nil {
+		return nil, err
+	}
+	if string(hdr[0:4]) != "IMPM" {
+		return nil, errors.New("invalid IT file")
+	}
+	ordNum := int(binary.LittleEndian.Uint16(hdr[0x20:]))
+	insNum := int(binary.LittleEndian.Uint16(hdr[0x22:]))
+	smpNum := int(binary.LittleEndian.Uint16(hdr[0x24:]))
+	patNum := int(binary.LittleEndian.Uint16(hdr[0x26:]))
+	tables, err := readBlock(r, 0xC0, ordNum+insNum*4+smpNum*4+patNum*4)
+	if err != nil {
+		return nil, err
+	}
+	song := &trackerSong{speed: int(hdr[0x32]), tempo: int(hdr[0x33]), orders: screamOrders(tables[:ordNum])}
+	pointers := tables[ordNum+insNum*4+smpNum*4:]
+	for p := 0; p < min(patNum, maxTrackerPatterns); p++ {
+		off := int64(binary.LittleEndian.Uint32(pointers[p*4:]))
+		if off == 0 {
+			song.patterns = append(song.patterns, nil)
+			continue
+		}
+		ph, err := readBlock(r, off, 8)
+		if err != nil {
+			song.patterns = append(song.patterns, nil)
+			continue
+		}
+		data, err := readBlock(r, off+8, int(binary.LittleEndian.Uint16(ph[0:2])))
+		if err != nil {
+			song.patterns = append(song.patterns, nil)
+			continue
+		}
+		song.patterns = append(song.patterns, unpackIT(data, int(binary.LittleEndian.Uint16(ph[2:4]))))
+	}
+	return song, nil
+}
+
+// unpackIT decodes packed IT pattern data, where each channel remembers
+// its last mask and its last command for cells that reuse them.
+func unpackIT(data []byte, rows int) trackerPattern {
+	pattern := make(trackerPattern, min(max(rows, 1), maxTrackerPatternRows))
+	var lastMask, lastCommand, lastParam [64]int
+	row := 0
+	for i := 0; i < len(data) && row < len(pattern); {
+		what := int(data[i])
+		i++
+		if what == 0 {
+			row++
+			continue
+		}
+		ch := (what - 1) & 63
+		if what&0x80 != 0 && i < len(data) {
+			lastMask[ch] = int(data[i])
+			i++
+		}
+		mask := lastMask[ch]
+		if mask&1 != 0 {
+			i++
+		}
+		if mask&2 != 0 {
+			i++
+		}
+		if mask&4 != 0 {
+			i++
+		}
+		command, param := 0, 0
+		switch {
+		case mask&8 != 0 && i+1 < len(data):
+			command, param = int(data[i]), int(data[i+1])
+			lastCommand[ch], lastParam[ch] = command, param
+			i += 2
+		case mask&128 != 0:
+			command, param = lastCommand[ch], lastParam[ch]
+		}
+		if c, ok := screamEffect(ch, command, param, false); ok {
+			pattern[row] = append(pattern[row], c)
+		}
+	}
+	return pattern
+}
+
+func getTrackerDuration(filePath string) (float64, error) {
+	file, err := openAudio(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var song *trackerSong
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".mod":
+		song, err = parseMOD(file)
+	case ".xm":
+		song, err = parseXM(file)
+	case ".s3m":
+		song, err = parseS3M(file)
+	case ".it":
+		song, err = parseIT(file)
+	}
+	if err != nil {
+		return 0, err
+	}
+	duration := song.seconds()
+	if duration == 0 {
+		return 0, fmt.Errorf("module has no playable orders")
+	}
+	return duration, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+var trackerExtensions = []string{".mod", ".xm", ".s3m", ".it"}
+
+func FuzzTrackerDuration(f *testing.F) {
+	f.Add(uint8(0), make([]byte, 1084))
+	f.Add(uint8(1), []byte("Extended Module: "))
+	f.Add(uint8(2), append(make([]byte, 44), "SCRM"...))
+	f.Add(uint8(3), []byte("IMPM"))
+	f.Fuzz(func(t *testing.T, kind uint8, data []byte) {
+		ext := trackerExtensions[int(kind)%len(trackerExtensions)]
+		getTrackerDuration(writeTemp(t, "f"+ext, data))
+	})
+}
+
+// xmModule is an XM header with one order and count pattern headers, each
+// declaring rows rows, headerLen header bytes and no packed data.
+func xmModule(count, rows int, headerLen uint32, written int) []byte {
+	b := make([]byte, 84, 84+9*written)
+	copy(b, "Extended Module: ")
+	binary.LittleEndian.PutUint32(b[60:], 24) // header size
+	binary.LittleEndian.PutUint16(b[64:], 1)  // song length
+	binary.LittleEndian.PutUint16(b[68:], 4)  // channels
+	binary.LittleEndian.PutUint16(b[70:], uint16(count))
+	for i := 0; i < written; i++ {
+		ph := make([]byte, 9)
+		binary.LittleEndian.PutUint32(ph[0:], headerLen)
+		binary.LittleEndian.PutUint16(ph[5:], uint16(rows))
+		b = append(b, ph...)
+	}
+	return b
+}
+
+func checkTrackerBounds(t *testing.T, song *trackerSong) {
+	t.Helper()
+	if len(song.patterns) > maxTrackerPatterns {
+		t.Errorf("kept %d patterns, want at most %d", len(song.patterns), maxTrackerPatterns)
+	}
+	for i, p := range song.patterns {
+		if len(p) > maxTrackerPatternRows {
+			t.Fatalf("pattern %d has %d rows, want at most %d", i, len(p), maxTrackerPatternRows)
+		}
+	}
+}
+
+func TestParseXMPatternBounds(t *testing.T) {
+	// A header that does not move on was read again for every pattern the
+	// module claimed, each allocating 65535 rows: gigabytes from 93 bytes.
+	if _, err := parseXM(bytes.NewReader(xmModule(2000, 65535, 0, 1))); err == nil {
+		t.Error("parseXM accepted a pattern header that does not advance")
+	}
+
+	song, err := parseXM(bytes.NewReader(xmModule(65535, 65535, 9, 2000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTrackerBounds(t, song)
+}
+
+func TestParseITPatternBounds(t *testing.T) {
+	// Every pattern pointer leads to the same 65535-row header.
+	const patterns = 65535
+	b := make([]byte, 0xC0+1+patterns*4)
+	copy(b, "IMPM")
+	binary.LittleEndian.PutUint16(b[0x20:], 1) // orders
+	binary.LittleEndian.PutUint16(b[0x26:], patterns)
+	header := uint32(len(b))
+	for p := 0; p < patterns; p++ {
+		binary.LittleEndian.PutUint32(b[0xC1+p*4:], header)
+	}
+	ph := make([]byte, 8)
+	binary.LittleEndian.PutUint16(ph[2:], 65535)
+	b = append(b, ph...)
+
+	song, err := parseIT(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTrackerBounds(t, song)
+}
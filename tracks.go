@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// --track-duration modes for MP4 files carrying several audio tracks, such
+// as one per language.
+const (
+	trackDurationContainer = "container" // the movie duration, however many tracks play in it
+	trackDurationSum       = "sum"       // every audio track's duration added up
+)
+
+// trackDuration is --track-duration.
+var trackDuration = trackDurationContainer
+
+func checkTrackDuration(mode string) error {
+	switch mode {
+	case trackDurationContainer, trackDurationSum:
+		return nil
+	}
+	return fmt.Errorf("unsupported --track-duration: %s (want container or sum)", mode)
+}
+
+// mediaLanguage decodes the packed ISO 639-2 language of an mdhd payload:
+// three 5-bit letters offset from 0x60.
+func mediaLanguage(p []byte) string {
+	at := 20
+	if len(p) > 0 && p[0] == 1 {
+		at = 32
+	}
+	if len(p) < at+2 {
+		return "und"
+	}
+	packed := binary.BigEndian.Uint16(p[at:])
+	if packed == 0 || packed == 0x7fff {
+		return "und"
+	}
+	code := []byte{byte(packed>>10&0x1f) + 0x60, byte(packed>>5&0x1f) + 0x60, byte(packed&0x1f) + 0x60}
+	for _, c := range code {
+		if c < 'a' || c > 'z' {
+			return "und"
+		}
+	}
+	return string(code)
+}
+
+// trackSeconds is the duration --track-duration sum counts: the audio
+// tracks added up, or the movie duration for files with at most one.
+func (m *mp4Info) trackSeconds() float64 {
+	tracks := m.audioTracks()
+	if len(tracks) < 2 {
+		return m.seconds()
+	}
+	var sum float64
+	for _, t := range tracks {
+		sum += t.seconds()
+	}
+	return sum
+}
+
+// audioTrack is one audio track of a file, for the --tracks report.
+type audioTrack struct {
+	language string
+	seconds  float64
+}
+
+// readTracks lists the audio tracks of an MP4-family file.
+func readTracks(filePath string) []audioTrack {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".m4a", ".m4b", ".aax":
+	default:
+		return nil
+	}
+	file, err := openAudio(filePath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	mp4, err := parseMP4(file)
+	if err != nil {
+		return nil
+	}
+	var tracks []audioTrack
+	for _, t := range mp4.audioTracks() {
+		tracks = append(tracks, audioTrack{language: t.language, seconds: t.seconds()})
+	}
+	return tracks
+}
+
+// printTrackReport lists the files with more than one audio track, with
+// each track's language and duration, and compares the container hours
+// with the sum over tracks.
+func printTrackReport(root string, audioFiles []string, durations []float64, tracks [][]audioTrack) {
+	fmt.Fprintln(console, "\n=== Audio tracks ===")
+	var files int
+	var container, summed float64
+	var lines []string
+	for i, p := range audioFiles {
+		if len(tracks[i]) < 2 {
+			continue
+		}
+		files++
+		var sum float64
+		var parts []string
+		for _, t := range tracks[i] {
+			sum += t.seconds
+			parts = append(parts, fmt.Sprintf("%s %s", t.language, formatClock(t.seconds)))
+		}
+		container += max(durations[i], 0)
+		summed += sum
+		lines = append(lines, fmt.Sprintf("%s: %d tracks (%s)", relPath(root, p), len(tracks[i]), strings.Join(parts, ", ")))
+	}
+	if files == 0 {
+		fmt.Fprintln(console, "No files with more than one audio track.")
+		return
+	}
+	fmt.Fprintf(console, "Files with several audio tracks: %d\n", files)
+	fmt.Fprintf(console, "Hours counted for them (--track-duration %s): %.2f\n", trackDuration, container/3600.0)
+	fmt.Fprintf(console, "Sum over their tracks: %.2f hours\n", summed/3600.0)
+	printList(len(lines), func(i int) string { return lines[i] })
+}
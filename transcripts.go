@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type transcriptReport struct {
+	transcribed        int
+	transcribedSeconds float64
+	missing            []string // audio files without a transcript
+	missingSeconds     float64
+	orphans            []string // transcripts without an audio file
+}
+
+// stem strips the extension so "a/one.wav" and "a/one.txt" share a key.
+func stem(path string) string {
+	return nfc(strings.TrimSuffix(path, filepath.Ext(path)))
+}
+
+// pairTranscripts matches each audio file with a transcript sitting in the
+// same directory under the same base name.
+func pairTranscripts(audioFiles []string, durations []float64, transcripts []string) transcriptReport {
+	var report transcriptReport
+
+	pending := make(map[string]string, len(transcripts))
+	for _, t := range transcripts {
+		pending[stem(t)] = t
+	}
+
+	for i, path := range audioFiles {
+		key := stem(path)
+		if _, ok := pending[key]; ok {
+			report.transcribed++
+			report.transcribedSeconds += durations[i]
+			delete(pending, key)
+			continue
+		}
+		report.missing = append(report.missing, path)
+		report.missingSeconds += durations[i]
+	}
+
+	for _, t := range pending {
+		report.orphans = append(report.orphans, t)
+	}
+	sort.Strings(report.orphans)
+
+	return report
+}
+
+func printTranscriptReport(root string, report transcriptReport) {
+	fmt.Fprintln(console, "\n=== Transcripts ===")
+	fmt.Fprintf(console, "Audio files with transcript: %d (%.2f hours)\n", report.transcribed, report.transcribedSeconds/3600.0)
+	fmt.Fprintf(console, "Audio files without transcript: %d (%.2f hours)\n", len(report.missing), report.missingSeconds/3600.0)
+	fmt.Fprintf(console, "Transcripts without audio: %d\n", len(report.orphans))
+
+	if len(report.missing) > 0 {
+		fmt.Fprintln(console, "\nMissing transcripts:")
+		printPathList(root, report.missing)
+	}
+	if len(report.orphans) > 0 {
+		fmt.Fprintln(console, "\nOrphan transcripts:")
+		printPathList(root, report.orphans)
+	}
+}
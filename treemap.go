@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// writeFolded writes hours by directory in the folded-stacks format read by
+// flamegraph.pl and speedscope: one "root;dir;sub N" line per directory
+// holding audio. The counts are whole seconds, since those tools expect
+// integers.
+func writeFolded(path, root string, audioFiles []string, durations []float64) error {
+	seconds := make(map[string]float64)
+	base := foldedFrame(filepath.Base(root))
+	for i, p := range audioFiles {
+		if durations[i] <= 0 {
+			continue
+		}
+		stack := []string{base}
+		for _, part := range pathComponents(root, p) {
+			stack = append(stack, foldedFrame(part))
+		}
+		seconds[strings.Join(stack, ";")] += durations[i]
+	}
+	stacks := make([]string, 0, len(seconds))
+	for stack := range seconds {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	for _, stack := range stacks {
+		if n := int64(math.Round(seconds[stack])); n > 0 {
+			fmt.Fprintf(w, "%s %d\n", stack, n)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// foldedFrame makes a folder name safe as a frame: semicolons separate
+// frames and the last space separates the count.
+func foldedFrame(name string) string {
+	return strings.NewReplacer(";", "_", " ", "_").Replace(name)
+}
+
+// treemapNode is the webtreemap JSON shape: a name, a size (here hours)
+// and children, with each directory sized as the sum of its contents.
+type treemapNode struct {
+	Name     string         `json:"name"`
+	Size     float64        `json:"size"`
+	Children []*treemapNode `json:"children,omitempty"`
+
+	index map[string]*treemapNode
+}
+
+func (n *treemapNode) child(name string) *treemapNode {
+	if c, ok := n.index[name]; ok {
+		return c
+	}
+	if n.index == nil {
+		n.index = make(map[string]*treemapNode)
+	}
+	c := &treemapNode{Name: name}
+	n.index[name] = c
+	n.Children = append(n.Children, c)
+	return c
+}
+
+func (n *treemapNode) sort() {
+	sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].Size > n.Children[j].Size })
+	for _, c := range n.Children {
+		c.sort()
+	}
+}
+
+// writeTreemap writes hours by directory as a webtreemap JSON tree.
+func writeTreemap(path, root string, audioFiles []string, durations []float64) error {
+	tree := &treemapNode{Name: filepath.Base(root)}
+	for i, p := range audioFiles {
+		hours := durations[i] / 3600.0
+		if hours <= 0 {
+			continue
+		}
+		node := tree
+		node.Size += hours
+		for _, part := range pathComponents(root, p) {
+			node = node.child(part)
+			node.Size += hours
+		}
+	}
+	tree.sort()
+
+	data, err := json.MarshalIndent(tree, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
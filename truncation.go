@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tcolgate/mp3"
+)
+
+// truncationCheck compares the duration a file declares with the duration
+// its data can actually deliver.
+type truncationCheck struct {
+	checked      bool
+	declared     float64
+	actual       float64
+	partialFrame bool
+	err          error
+}
+
+func checkTruncation(filePath string) truncationCheck {
+	var check truncationCheck
+	var err error
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".mp3":
+		check, err = checkMP3Truncation(filePath)
+	case ".m4a", ".m4b", ".aax":
+		check, err = checkM4ATruncation(filePath)
+	default:
+		return check
+	}
+	if err != nil {
+		check.err = err
+	}
+	return check
+}
+
+// checkMP3Truncation counts the frames present and compares them with the
+// frame count in a Xing/Info or VBRI header, when the encoder wrote one. A
+// final frame cut short by the end of file is flagged either way.
+func checkMP3Truncation(filePath string) (truncationCheck, error) {
+	file, err := openAudio(filePath)
+	if err != nil {
+		return truncationCheck{}, err
+	}
+	defer file.Close()
+
+	check := truncationCheck{checked: true}
+	decoder := mp3.NewDecoder(file)
+	var frame mp3.Frame
+	var skipped int
+	var declaredFrames uint32
+	var samplesPerFrame, sampleRate int
+
+	for n := 0; ; n++ {
+		err := decoder.Decode(&frame, &skipped)
+		if errors.Is(err, errProbeLimit) || errors.Is(err, errScanLimit) {
+			return truncationCheck{}, err
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			check.partialFrame = true
+			break
+		}
+		if err != nil {
+			break
+		}
+		if n == 0 {
+			samplesPerFrame = frame.Samples()
+			sampleRate = int(frame.Header().SampleRate())
+			if frames, ok := vbrFrameCount(&frame); ok {
+				// The info frame itself carries no audio.
+				declaredFrames = frames
+				continue
+			}
+		}
+		check.actual += frame.Duration().Seconds()
+	}
+
+	check.declared = check.actual
+	if declaredFrames > 0 && sampleRate > 0 {
+		check.declared = float64(declaredFrames) * float64(samplesPerFrame) / float64(sampleRate)
+	}
+	return check, nil
+}
+
+// vbrFrameCount reads the total frame count from a Xing/Info or VBRI header
+// embedded in the first frame.
+func vbrFrameCount(frame *mp3.Frame) (uint32, bool) {
+	buf, err := io.ReadAll(frame.Reader())
+	if err != nil {
+		return 0, false
+	}
+	for _, tag := range []string{"Xing", "Info"} {
+		if i := bytes.Index(buf, []byte(tag)); i >= 0 && i+12 <= len(buf) {
+			flags := binary.BigEndian.Uint32(buf[i+4:])
+			if flags&0x1 == 0 {
+				return 0, false
+			}
+			return binary.BigEndian.Uint32(buf[i+8:]), true
+		}
+	}
+	// VBRI sits at a fixed offset of 32 bytes after the side information.
+	if i := bytes.Index(buf, []byte("VBRI")); i >= 0 && i+18 <= len(buf) {
+		return binary.BigEndian.Uint32(buf[i+14:]), true
+	}
+	return 0, false
+}
+
+// checkM4ATruncation follows each audio track's chunk table and sums the
+// samples whose bytes are actually present in the file.
+func checkM4ATruncation(filePath string) (truncationCheck, error) {
+	file, err := openAudio(filePath)
+	if err != nil {
+		return truncationCheck{}, err
+	}
+	defer file.Close()
+
+	info, err := parseMP4(file)
+	if err != nil {
+		return truncationCheck{}, err
+	}
+
+	check := truncationCheck{checked: true}
+	for _, t := range info.audioTracks() {
+		declared := t.seconds()
+		actual := t.playableSeconds(info.fileSize)
+		// Report the track missing the most audio.
+		if declared-actual >= check.declared-check.actual {
+			check.declared, check.actual = declared, actual
+		}
+	}
+	if len(info.audioTracks()) == 0 {
+		check.declared = info.seconds()
+		check.actual = check.declared
+	}
+	return check, nil
+}
+
+type truncatedFile struct {
+	path  string
+	check truncationCheck
+}
+
+type truncationReport struct {
+	checked        int
+	failed         int
+	tolerance      float64
+	files          []truncatedFile
+	missingSeconds float64
+}
+
+func buildTruncationReport(audioFiles []string, checks []truncationCheck, tolerance float64) truncationReport {
+	report := truncationReport{tolerance: tolerance}
+	for i, c := range checks {
+		if !c.checked {
+			if c.err != nil {
+				report.failed++
+			}
+			continue
+		}
+		report.checked++
+		missing := c.declared - c.actual
+		if missing <= tolerance && !c.partialFrame {
+			continue
+		}
+		report.files = append(report.files, truncatedFile{path: audioFiles[i], check: c})
+		report.missingSeconds += max(missing, 0)
+	}
+	sort.Slice(report.files, func(i, j int) bool {
+		a, b := report.files[i].check, report.files[j].check
+		return a.declared-a.actual > b.declared-b.actual
+	})
+	return report
+}
+
+func printTruncationReport(root string, report truncationReport) {
+	fmt.Fprintln(console, "\n=== Truncation ===")
+	fmt.Fprintf(console, "Checked files (MP3/M4A): %d\n", report.checked)
+	if report.failed > 0 {
+		fmt.Fprintf(console, "Could not check: %d\n", report.failed)
+	}
+	fmt.Fprintf(console, "Truncated files: %d\n", len(report.files))
+	fmt.Fprintf(console, "Declared but missing audio: %.2f hours\n", report.missingSeconds/3600.0)
+	printList(len(report.files), func(i int) string {
+		f := report.files[i]
+		line := fmt.Sprintf("%s (declares %.1fs, contains %.1fs", relPath(root, f.path), f.check.declared, f.check.actual)
+		if f.check.partialFrame {
+			line += ", ends mid-frame"
+		}
+		return line + ")"
+	})
+}
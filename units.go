@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// durationUnits maps --units names to their length in seconds.
+var durationUnits = map[string]float64{
+	"minutes": 60,
+	"hours":   3600,
+	"days":    86400,
+}
+
+// --rounding modes for reported values.
+const (
+	roundNearest = "nearest"
+	roundDown    = "down"
+	roundUp      = "up"
+)
+
+// numberFormat renders totals in the selected unit, with the decimal and
+// grouping separators of --locale when one is given.
+type numberFormat struct {
+	printer     *message.Printer // nil keeps plain fmt output
+	unit        string
+	unitSeconds float64
+	// fixed is set by --precision, which then gives the decimals of every
+	// reported value in place of each one's default.
+	fixed     bool
+	precision int
+	rounding  string
+}
+
+func newNumberFormat(locale, unit string, precision int, rounding string) (numberFormat, error) {
+	f := numberFormat{unit: unit, unitSeconds: durationUnits[unit], fixed: precision >= 0, precision: precision, rounding: rounding}
+	if f.unitSeconds == 0 {
+		return f, fmt.Errorf("unsupported unit: %s (want minutes, hours or days)", unit)
+	}
+	if precision < -1 || precision > 15 {
+		return f, fmt.Errorf("invalid --precision: %d (want 0 to 15)", precision)
+	}
+	switch rounding {
+	case roundNearest, roundDown, roundUp:
+	default:
+		return f, fmt.Errorf("unsupported --rounding: %s (want nearest, down or up)", rounding)
+	}
+	if locale != "" {
+		tag, err := language.Parse(locale)
+		if err != nil {
+			return f, fmt.Errorf("invalid locale %q: %w", locale, err)
+		}
+		f.printer = message.NewPrinter(tag)
+	}
+	return f, nil
+}
+
+// places is the number of decimals for a value that defaults to decimals.
+func (f numberFormat) places(decimals int) int {
+	if f.fixed {
+		return f.precision
+	}
+	return decimals
+}
+
+// round rounds v to decimals places the --rounding way. The scaled value is
+// first rounded to 1e-6 so that binary fractions such as 0.29*100 =
+// 28.999999999999996 do not round down a whole unit.
+func (f numberFormat) round(v float64, decimals int) float64 {
+	scale := math.Pow10(decimals)
+	x := math.Round(v*scale*1e6) / 1e6
+	switch f.rounding {
+	case roundDown:
+		x = math.Floor(x)
+	case roundUp:
+		x = math.Ceil(x)
+	default:
+		x = math.Round(x)
+	}
+	return x / scale
+}
+
+// wholeSeconds is seconds as an integer, rounded the --rounding way.
+func (f numberFormat) wholeSeconds(seconds float64) int64 {
+	return int64(f.round(seconds, 0))
+}
+
+// number formats v with the given number of decimals, or --precision's.
+func (f numberFormat) number(v float64, decimals int) string {
+	decimals = f.places(decimals)
+	v = f.round(v, decimals)
+	verb := fmt.Sprintf("%%.%df", decimals)
+	if f.printer == nil {
+		return fmt.Sprintf(verb, v)
+	}
+	return f.printer.Sprintf(verb, v)
+}
+
+// duration formats seconds in the selected unit, e.g. "1.234,56 hours",
+// naming the unit in the message language.
+func (f numberFormat) duration(seconds float64, decimals int) string {
+	return f.number(seconds/f.unitSeconds, decimals) + " " + tr(f.unit)
+}
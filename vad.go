@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Energy VAD tuning. Frames are 30ms like WebRTC's VAD; the hangover keeps
+// short pauses between words inside a speech region.
+const (
+	vadFrameSeconds = 0.030
+	vadFloorDBFS    = -50.0 // never call anything quieter than this speech
+	vadMarginDB     = 10.0  // required rise above the running noise floor
+	vadHangover     = 10    // frames (300ms) kept after energy drops
+)
+
+// speechDetector estimates how much of a stream is speech using frame
+// energy against an adaptive noise floor.
+type speechDetector struct {
+	channels    int
+	frameLen    int
+	frameRate   float64
+	acc         float64
+	accFrames   int
+	noiseFloor  float64
+	hang        int
+	speechCount int
+}
+
+func (v *speechDetector) start(format pcmFormat) {
+	v.channels = format.channels
+	v.frameLen = int(float64(format.sampleRate) * vadFrameSeconds)
+	if v.frameLen < 1 {
+		v.frameLen = 1
+	}
+	v.frameRate = float64(format.sampleRate) / float64(v.frameLen)
+	v.noiseFloor = math.Inf(1)
+}
+
+func (v *speechDetector) process(samples []float64) {
+	for i := 0; i+v.channels <= len(samples); i += v.channels {
+		// Downmix to mono before measuring energy.
+		var mono float64
+		for c := 0; c < v.channels; c++ {
+			mono += samples[i+c]
+		}
+		mono /= float64(v.channels)
+		v.acc += mono * mono
+		v.accFrames++
+		if v.accFrames == v.frameLen {
+			v.frame(v.acc / float64(v.frameLen))
+			v.acc, v.accFrames = 0, 0
+		}
+	}
+}
+
+func (v *speechDetector) frame(meanSquare float64) {
+	db := 10 * math.Log10(meanSquare+1e-12)
+
+	// The floor drops immediately to quieter frames and creeps up slowly,
+	// so it tracks background noise rather than speech.
+	if db < v.noiseFloor {
+		v.noiseFloor = db
+	} else {
+		v.noiseFloor += 0.01 * (db - v.noiseFloor)
+	}
+
+	if db > vadFloorDBFS && db > v.noiseFloor+vadMarginDB {
+		v.hang = vadHangover
+		v.speechCount++
+		return
+	}
+	if v.hang > 0 {
+		v.hang--
+		v.speechCount++
+	}
+}
+
+// speechSeconds returns the detected speech duration.
+func (v *speechDetector) speechSeconds() float64 {
+	if v.frameRate == 0 {
+		return 0
+	}
+	return float64(v.speechCount) / v.frameRate
+}
+
+type speechReport struct {
+	analyzed        int
+	analyzedSeconds float64
+	speechSeconds   float64
+	skipped         int // probed but not decodable, or failed to decode
+}
+
+func buildSpeechReport(durations []float64, stats []sampleStats) speechReport {
+	var report speechReport
+	for i, s := range stats {
+		if durations[i] <= 0 {
+			continue
+		}
+		if !s.analyzed {
+			report.skipped++
+			continue
+		}
+		report.analyzed++
+		report.analyzedSeconds += durations[i]
+		report.speechSeconds += s.speechSeconds
+	}
+	return report
+}
+
+func printSpeechReport(report speechReport) {
+	fmt.Fprintln(console, "\n=== Speech ===")
+	fmt.Fprintf(console, "Analyzed files: %d (%.2f hours)\n", report.analyzed, report.analyzedSeconds/3600.0)
+	fmt.Fprintf(console, "Speech: %.2f hours (%.1f%% of analyzed audio)\n", report.speechSeconds/3600.0, percent(report.speechSeconds, report.analyzedSeconds))
+	fmt.Fprintf(console, "Non-speech: %.2f hours\n", (report.analyzedSeconds-report.speechSeconds)/3600.0)
+	if report.skipped > 0 {
+		fmt.Fprintf(console, "Not analyzed (no sample decoder or decode error): %d\n", report.skipped)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// frameCounter measures how much audio a full decode actually produced.
+type frameCounter struct {
+	channels   int
+	sampleRate int
+	frames     int64
+}
+
+func (f *frameCounter) start(format pcmFormat) {
+	f.channels = format.channels
+	f.sampleRate = format.sampleRate
+}
+
+func (f *frameCounter) process(samples []float64) {
+	f.frames += int64(len(samples) / f.channels)
+}
+
+func (f *frameCounter) seconds() float64 {
+	if f.sampleRate == 0 {
+		return 0
+	}
+	return float64(f.frames) / float64(f.sampleRate)
+}
+
+type verifyProblem struct {
+	path     string
+	claimed  float64
+	verified float64
+	err      error
+}
+
+type verifyReport struct {
+	claimedSeconds    float64
+	verifiedSeconds   float64
+	verified          int
+	unverifiable      int
+	unverifiableHours float64
+	failures          []verifyProblem
+	short             []verifyProblem
+	tolerance         float64
+}
+
+// buildVerifyReport compares claimed (header) durations with what the deep
+// decode produced. A file only contributes verified hours when it decoded
+// cleanly; a short decode counts the decoded part.
+func buildVerifyReport(audioFiles []string, durations []float64, stats []sampleStats, tolerance float64) verifyReport {
+	report := verifyReport{tolerance: tolerance}
+	for i, s := range stats {
+		if durations[i] <= 0 {
+			continue
+		}
+		report.claimedSeconds += durations[i]
+
+		switch {
+		case s.err != nil:
+			report.failures = append(report.failures, verifyProblem{path: audioFiles[i], claimed: durations[i], err: s.err})
+		case !s.analyzed:
+			report.unverifiable++
+			report.unverifiableHours += durations[i] / 3600.0
+		default:
+			report.verified++
+			report.verifiedSeconds += min(s.decodedSeconds, durations[i])
+			if durations[i]-s.decodedSeconds > tolerance {
+				report.short = append(report.short, verifyProblem{path: audioFiles[i], claimed: durations[i], verified: s.decodedSeconds})
+			}
+		}
+	}
+	sort.Slice(report.short, func(i, j int) bool {
+		return report.short[i].claimed-report.short[i].verified > report.short[j].claimed-report.short[j].verified
+	})
+	return report
+}
+
+func printVerifyReport(root string, report verifyReport) {
+	fmt.Fprintln(console, "\n=== Verification ===")
+	fmt.Fprintf(console, "Claimed audio duration: %.2f hours\n", report.claimedSeconds/3600.0)
+	fmt.Fprintf(console, "Verified audio duration: %.2f hours (%d files fully decoded)\n", report.verifiedSeconds/3600.0, report.verified)
+	fmt.Fprintf(console, "Decode failures: %d\n", len(report.failures))
+	fmt.Fprintf(console, "Decoded shorter than claimed (> %.1fs): %d\n", report.tolerance, len(report.short))
+	if report.unverifiable > 0 {
+		fmt.Fprintf(console, "Not verifiable (no sample decoder): %d (%.2f hours)\n", report.unverifiable, report.unverifiableHours)
+	}
+
+	if len(report.failures) > 0 {
+		fmt.Fprintln(console, "\nDecode failures:")
+		printList(len(report.failures), func(i int) string {
+			f := report.failures[i]
+			return fmt.Sprintf("%s: %v", relPath(root, f.path), f.err)
+		})
+	}
+	if len(report.short) > 0 {
+		fmt.Fprintln(console, "\nShorter than claimed:")
+		printList(len(report.short), func(i int) string {
+			f := report.short[i]
+			return fmt.Sprintf("%s (claimed %.1fs, decoded %.1fs)", relPath(root, f.path), f.claimed, f.verified)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import "runtime/debug"
+
+// version is the release this binary was built as, set with
+// -ldflags "-X main.version=v1.2.3". Builds without it report the module
+// version and VCS revision Go recorded.
+var version string
+
+// buildVersion returns the tool version and, when known, the commit it was
+// built from ("+dirty" if the tree had local changes).
+func buildVersion() (string, string) {
+	v, revision := version, ""
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		if v == "" {
+			v = "unknown"
+		}
+		return v, revision
+	}
+	if v == "" {
+		v = info.Main.Version
+	}
+	var dirty bool
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if revision != "" && dirty {
+		revision += "+dirty"
+	}
+	return v, revision
+}
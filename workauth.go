@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/rpc"
+	"os"
+	"strings"
+)
+
+// connectedStatus is what a net/rpc server answers a successful CONNECT
+// with.
+const connectedStatus = "200 Connected to Go RPC"
+
+// workSecurity protects a --serve-work coordinator and its --join workers
+// on a shared network: a bearer token every worker must present, and TLS,
+// mutual when both sides hold certificates signed by --tls-ca.
+type workSecurity struct {
+	token string
+	cert  *tls.Certificate
+	ca    *x509.CertPool
+}
+
+// loadWorkSecurity reads --auth-token-file and the --tls-* files.
+func loadWorkSecurity(tokenFile, certFile, keyFile, caFile string) (workSecurity, error) {
+	var s workSecurity
+	if tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return s, err
+		}
+		s.token = strings.TrimSpace(string(data))
+		if s.token == "" {
+			return s, fmt.Errorf("%s is empty", tokenFile)
+		}
+	}
+	if (certFile == "") != (keyFile == "") {
+		return s, errors.New("--tls-cert and --tls-key go together")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return s, err
+		}
+		s.cert = &cert
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return s, err
+		}
+		s.ca = x509.NewCertPool()
+		if !s.ca.AppendCertsFromPEM(pem) {
+			return s, fmt.Errorf("%s holds no PEM certificates", caFile)
+		}
+	}
+	return s, nil
+}
+
+// serverTLS is the coordinator's TLS configuration, nil without a
+// certificate. With --tls-ca, workers must present a certificate it signed.
+func (s workSecurity) serverTLS() (*tls.Config, error) {
+	if s.cert == nil {
+		if s.ca != nil {
+			return nil, errors.New("--tls-ca on the coordinator needs --tls-cert and --tls-key")
+		}
+		return nil, nil
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{*s.cert}, MinVersion: tls.VersionTLS12}
+	if s.ca != nil {
+		cfg.ClientCAs = s.ca
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// clientTLS is a worker's TLS configuration, nil when it connects in the
+// clear. --tls-ca replaces the system roots for checking the coordinator.
+func (s workSecurity) clientTLS() *tls.Config {
+	if s.cert == nil && s.ca == nil {
+		return nil
+	}
+	cfg := &tls.Config{RootCAs: s.ca, MinVersion: tls.VersionTLS12}
+	if s.cert != nil {
+		cfg.Certificates = []tls.Certificate{*s.cert}
+	}
+	return cfg
+}
+
+// authenticated is true when anything but a plain connection is required
+// of workers.
+func (s workSecurity) authenticated() bool {
+	return s.token != "" || (s.cert != nil && s.ca != nil)
+}
+
+// requireToken refuses requests without the bearer token.
+func (s workSecurity) requireToken(next http.Handler) http.Handler {
+	if s.token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="howmanyhours"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// listen opens the coordinator's listener, under TLS when configured.
+func (s workSecurity) listen(addr string) (net.Listener, error) {
+	cfg, err := s.serverTLS()
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if cfg != nil {
+		listener = tls.NewListener(listener, cfg)
+	}
+	return listener, nil
+}
+
+// dial connects a worker to the coordinator as rpc.DialHTTP does, with the
+// token in the CONNECT request and TLS when configured.
+func (s workSecurity) dial(addr string) (*rpc.Client, error) {
+	var conn net.Conn
+	var err error
+	if cfg := s.clientTLS(); cfg != nil {
+		conn, err = tls.Dial("tcp", addr, cfg)
+	} else {
+		conn, err = net.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	req := "CONNECT " + rpc.DefaultRPCPath + " HTTP/1.0\n"
+	if s.token != "" {
+		req += "Authorization: Bearer " + s.token + "\n"
+	}
+	if _, err := io.WriteString(conn, req+"\n"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
+	if err != nil {
+		conn.Close()
+		if errors.Is(err, io.ErrUnexpectedEOF) && s.clientTLS() == nil {
+			err = fmt.Errorf("%w (if the coordinator uses TLS, set --tls-ca)", err)
+		}
+		return nil, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.Status == connectedStatus:
+		return rpc.NewClient(conn), nil
+	case resp.StatusCode == http.StatusUnauthorized && s.token == "":
+		err = errors.New("the coordinator requires --auth-token-file")
+	case resp.StatusCode == http.StatusUnauthorized:
+		err = errors.New("the coordinator refused the token")
+	default:
+		err = fmt.Errorf("unexpected answer from the coordinator: %s", resp.Status)
+	}
+	conn.Close()
+	return nil, err
+}